@@ -0,0 +1,246 @@
+package plyfile
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"unsafe"
+)
+
+/* Benchmarks of reading and writing, by file format, element shape and size. Every benchmark reports allocations and sets the number of bytes of the file, so its MB/s can be compared with BenchmarkCopy over the same bytes. Sizes above 100k elements are skipped with -short, e.g. go test -run NONE -bench 'Copy|DecodeMesh|PlyGetElement' -short */
+
+var benchSizes = []int{1000, 10000, 100000, 1000000, 10000000}
+
+func sizeName(n int) string {
+	switch {
+	case n >= 1000000:
+		return fmt.Sprintf("%dM", n/1000000)
+	case n >= 1000:
+		return fmt.Sprintf("%dk", n/1000)
+	}
+	return fmt.Sprint(n)
+}
+
+/* benchMesh returns a mesh of n vertices (x, y, z as float) and, if faces is set, n triangles (uchar intensity, vertex_indices as a uchar/int list), the layout of Vertex and Face in ply_test.go. */
+func benchMesh(format int, n int, faces bool) *Mesh {
+	m := NewMesh(format)
+	vertex := m.AddElement("vertex", n)
+	x := vertex.AddProperty("x", PLY_FLOAT)
+	y := vertex.AddProperty("y", PLY_FLOAT)
+	z := vertex.AddProperty("z", PLY_FLOAT)
+	for i := 0; i < n; i++ {
+		x.Data[i] = float64(float32(i) * 0.5)
+		y.Data[i] = float64(float32(i%1000) * 0.25)
+		z.Data[i] = float64(float32(i) * -0.125)
+	}
+	if faces {
+		face := m.AddElement("face", n)
+		intensity := face.AddProperty("intensity", PLY_UCHAR)
+		lists := face.AddListProperty("vertex_indices", PLY_UCHAR, PLY_INT)
+		for i := 0; i < n; i++ {
+			intensity.Data[i] = float64(i % 256)
+			lists.Lists[i] = []float64{float64(i), float64((i + 1) % n), float64((i + 2) % n)}
+		}
+	}
+	return m
+}
+
+/* runBench runs fn as a sub-benchmark for every format, shape and size. */
+func runBench(b *testing.B, fn func(b *testing.B, format int, n int, faces bool)) {
+	for _, format := range allFormats {
+		for _, faces := range []bool{false, true} {
+			shape := "vertex"
+			if faces {
+				shape = "vertex+face"
+			}
+			for _, n := range benchSizes {
+				format, faces, n := format, faces, n
+				b.Run(formatNames[format]+"/"+shape+"/"+sizeName(n), func(b *testing.B) {
+					if testing.Short() && n > 100000 {
+						b.Skip("skipped with -short")
+					}
+					fn(b, format, n, faces)
+				})
+			}
+		}
+	}
+}
+
+/* benchData returns the encoded bench mesh. */
+func benchData(b *testing.B, format int, n int, faces bool) []byte {
+	var buf bytes.Buffer
+	if err := EncodeMesh(&buf, benchMesh(format, n, faces)); err != nil {
+		b.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+/* benchFile writes the encoded bench mesh to a temporary file and returns its path and size. */
+func benchFile(b *testing.B, format int, n int, faces bool) (string, int64) {
+	data := benchData(b, format, n, faces)
+	path := filepath.Join(b.TempDir(), "bench.ply")
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		b.Fatal(err)
+	}
+	return path, int64(len(data))
+}
+
+/* BenchmarkCopy is the baseline: copying the bytes of each file, from memory and from disk. */
+func BenchmarkCopy(b *testing.B) {
+	runBench(b, func(b *testing.B, format int, n int, faces bool) {
+		path, size := benchFile(b, format, n, faces)
+		data, err := os.ReadFile(path)
+		if err != nil {
+			b.Fatal(err)
+		}
+		b.Run("memory", func(b *testing.B) {
+			b.SetBytes(size)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				io.Copy(io.Discard, bytes.NewReader(data))
+			}
+		})
+		b.Run("file", func(b *testing.B) {
+			b.SetBytes(size)
+			b.ReportAllocs()
+			for i := 0; i < b.N; i++ {
+				f, err := os.Open(path)
+				if err != nil {
+					b.Fatal(err)
+				}
+				io.Copy(io.Discard, f)
+				f.Close()
+			}
+		})
+	})
+}
+
+func BenchmarkDecodeMesh(b *testing.B) {
+	runBench(b, func(b *testing.B, format int, n int, faces bool) {
+		data := benchData(b, format, n, faces)
+		b.SetBytes(int64(len(data)))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if _, err := DecodeMesh(bytes.NewReader(data)); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+func BenchmarkEncodeMesh(b *testing.B) {
+	runBench(b, func(b *testing.B, format int, n int, faces bool) {
+		m := benchMesh(format, n, faces)
+		var buf bytes.Buffer
+		if err := EncodeMesh(&buf, m); err != nil {
+			b.Fatal(err)
+		}
+		b.SetBytes(int64(buf.Len()))
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			if err := EncodeMesh(io.Discard, m); err != nil {
+				b.Fatal(err)
+			}
+		}
+	})
+}
+
+/* BenchmarkPlyGetElement reads each file with the C library, one PlyGetElement call per element. The C library reads binary data in the byte order of the machine, so values of the other byte order are read swapped; the work done is the same. */
+func BenchmarkPlyGetElement(b *testing.B) {
+	vert_props, face_props := SetPlyProperties()
+	runBench(b, func(b *testing.B, format int, n int, faces bool) {
+		path, size := benchFile(b, format, n, faces)
+		b.SetBytes(size)
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			cplyfile, elem_names := PlyOpenForReading(path)
+			if cplyfile == nil {
+				b.Fatal("can't read header")
+			}
+			for _, name := range elem_names {
+				_, num_elems, _ := PlyGetElementDescription(cplyfile, name)
+				if name == "vertex" {
+					for _, prop := range vert_props {
+						PlyGetProperty(cplyfile, name, prop)
+					}
+					var vertex Vertex
+					for k := 0; k < num_elems; k++ {
+						PlyGetElement(cplyfile, &vertex, unsafe.Sizeof(vertex))
+					}
+				} else {
+					for _, prop := range face_props {
+						PlyGetProperty(cplyfile, name, prop)
+					}
+					var face Face
+					for k := 0; k < num_elems; k++ {
+						PlyGetElement(cplyfile, &face, unsafe.Sizeof(face))
+					}
+				}
+			}
+			PlyClose(cplyfile)
+		}
+	})
+}
+
+/* BenchmarkPlyPutElement writes each file with the C library, one PlyPutElement call per element. */
+func BenchmarkPlyPutElement(b *testing.B) {
+	vert_props, face_props := SetPlyProperties()
+	runBench(b, func(b *testing.B, format int, n int, faces bool) {
+		elem_names := []string{"vertex"}
+		if faces {
+			elem_names = append(elem_names, "face")
+		}
+		path := filepath.Join(b.TempDir(), "bench.ply")
+
+		/* every face points to the same indices, which must stay in Go memory while writing */
+		indices := [3]int32{0, 1, 2}
+		face := Face{Nverts: 3}
+		copy(face.Verts[:], PointerToByteSlice(uintptr(unsafe.Pointer(&indices))))
+
+		m := benchMesh(format, n, false)
+		x, y, z := m.Elements[0].Properties[0].Data, m.Elements[0].Properties[1].Data, m.Elements[0].Properties[2].Data
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			var version float32
+			cplyfile := PlyOpenForWriting(path, len(elem_names), elem_names, format, &version)
+			PlyElementCount(cplyfile, "vertex", n)
+			for _, prop := range vert_props {
+				PlyDescribeProperty(cplyfile, "vertex", prop)
+			}
+			if faces {
+				PlyElementCount(cplyfile, "face", n)
+				for _, prop := range face_props {
+					PlyDescribeProperty(cplyfile, "face", prop)
+				}
+			}
+			PlyHeaderComplete(cplyfile)
+
+			PlyPutElementSetup(cplyfile, "vertex")
+			for k := 0; k < n; k++ {
+				PlyPutElement(cplyfile, Vertex{float32(x[k]), float32(y[k]), float32(z[k])})
+			}
+			if faces {
+				PlyPutElementSetup(cplyfile, "face")
+				for k := 0; k < n; k++ {
+					face.Intensity = byte(k)
+					PlyPutElement(cplyfile, face)
+				}
+			}
+			PlyClose(cplyfile)
+		}
+		b.StopTimer()
+		runtime.KeepAlive(&indices)
+		if info, err := os.Stat(path); err == nil {
+			b.SetBytes(info.Size())
+		}
+	})
+}
@@ -0,0 +1,232 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plyfile
+
+import (
+	"fmt"
+	"os"
+	"runtime"
+)
+
+/* PlyFile owns a PLY file opened with the C library. It is the low-level escape hatch for code ported from C and for reading into C structs; File reads and writes PLY files in Go, and is the handle to use otherwise. Its methods are those of the Ply* functions; Close frees all the C memory of the file, including the lists read by GetElement. A PlyFile that is dropped without Close is closed by a finalizer, but the finalizer may run late or not at all, so always call Close. */
+type PlyFile struct {
+	cfile CPlyFile
+}
+
+func newPlyFile(cfile CPlyFile) *PlyFile {
+	f := &PlyFile{cfile: cfile}
+	runtime.SetFinalizer(f, (*PlyFile).Close)
+	return f
+}
+
+/* OpenPlyFile opens a PLY file with the C library and reads its header, returning the file and the names of its elements. A malformed header is reported as a *ParseError. */
+func OpenPlyFile(filename string) (*PlyFile, []string, error) {
+	return OpenPlyFileOptions(filename, ReadOptions{})
+}
+
+/* OpenPlyFileOptions opens a PLY file with the C library as OpenPlyFile does, and applies the limits of opts: a file larger than MaxTotalBytes, a header longer than MaxHeaderLines or with a comment longer than MaxCommentLength, or an element whose count exceeds MaxElementCount is rejected with a *LimitError, and MaxListLength is enforced by GetElement, see PlySetLimits. The header limits are checked as the header is read, before it is parsed. The other fields of opts only apply to the Go reader. */
+func OpenPlyFileOptions(filename string, opts ReadOptions) (*PlyFile, []string, error) {
+	if opts.MaxTotalBytes > 0 {
+		if info, err := os.Stat(filename); err == nil && info.Size() > opts.MaxTotalBytes {
+			return nil, nil, &LimitError{Limit: "MaxTotalBytes", Max: opts.MaxTotalBytes, Value: info.Size()}
+		}
+	}
+	cfile, elem_names, err := plyOpenForReading(filename, opts)
+	if err != nil {
+		return nil, nil, err
+	}
+	f := newPlyFile(cfile)
+	if opts.MaxElementCount > 0 {
+		for _, elem := range f.Header().Elements {
+			if elem.Count > opts.MaxElementCount {
+				f.Close()
+				return nil, nil, &LimitError{Limit: "MaxElementCount", Max: int64(opts.MaxElementCount), Value: int64(elem.Count),
+					Where: fmt.Sprintf("element '%s'", elem.Name)}
+			}
+		}
+	}
+	return f, elem_names, nil
+}
+
+/* CreatePlyFile creates a PLY file with the C library, with the given elements, to be described with ElementCount and DescribeProperty. */
+func CreatePlyFile(filename string, elem_names []string, file_type int) (*PlyFile, error) {
+	if len(elem_names) == 0 {
+		return nil, fmt.Errorf("plyfile: can't create '%s' without elements", filename)
+	}
+	var version float32
+	cfile := PlyOpenForWriting(filename, len(elem_names), elem_names, file_type, &version)
+	if cfile == nil {
+		return nil, fmt.Errorf("plyfile: can't create '%s'", filename)
+	}
+	return newPlyFile(cfile), nil
+}
+
+/* Close closes the file and frees its C memory. Closing a file twice returns os.ErrClosed; the other methods panic once the file is closed. */
+func (f *PlyFile) Close() error {
+	if f.cfile == nil {
+		return os.ErrClosed
+	}
+	PlyClose(f.cfile)
+	f.cfile = nil
+	runtime.SetFinalizer(f, nil)
+	return nil
+}
+
+/* open returns the C file of f, and panics if f has been closed, as the C library would dereference a nil file. */
+func (f *PlyFile) open() CPlyFile {
+	if f.cfile == nil {
+		panic("plyfile: PlyFile used after Close")
+	}
+	return f.cfile
+}
+
+/* CFile returns the underlying C file, for use with the Ply* functions. It is valid until Close, and f must be kept alive (e.g. with runtime.KeepAlive) while it is in use. */
+func (f *PlyFile) CFile() CPlyFile {
+	return f.cfile
+}
+
+/* Header returns the format, comments, elements and properties of a file opened for reading, without data. PlyGetProperty ignores properties that aren't in the file, so check the header with ExpectSchema before reading. */
+func (f *PlyFile) Header() *Mesh {
+	defer runtime.KeepAlive(f)
+	return plyHeader(f.open())
+}
+
+/* ElementCount calls PlyElementCount on f. */
+func (f *PlyFile) ElementCount(element_name string, nelems int) {
+	PlyElementCount(f.open(), element_name, nelems)
+	runtime.KeepAlive(f)
+}
+
+/* DescribeProperty calls PlyDescribeProperty on f. */
+func (f *PlyFile) DescribeProperty(element_name string, prop PlyProperty) {
+	PlyDescribeProperty(f.open(), element_name, prop)
+	runtime.KeepAlive(f)
+}
+
+/* PutComment calls PlyPutComment on f. */
+func (f *PlyFile) PutComment(comment string) {
+	PlyPutComment(f.open(), comment)
+	runtime.KeepAlive(f)
+}
+
+/* PutObjInfo calls PlyPutObjInfo on f. */
+func (f *PlyFile) PutObjInfo(obj_info string) {
+	PlyPutObjInfo(f.open(), obj_info)
+	runtime.KeepAlive(f)
+}
+
+/* PutGeoref calls PlyPutGeoref on f. */
+func (f *PlyFile) PutGeoref(g *Georef) {
+	PlyPutGeoref(f.open(), g)
+	runtime.KeepAlive(f)
+}
+
+/* PutMetadata calls PlyPutMetadata on f. */
+func (f *PlyFile) PutMetadata(md *Metadata) {
+	PlyPutMetadata(f.open(), md)
+	runtime.KeepAlive(f)
+}
+
+/* HeaderComplete calls PlyHeaderComplete on f. */
+func (f *PlyFile) HeaderComplete() {
+	PlyHeaderComplete(f.open())
+	runtime.KeepAlive(f)
+}
+
+/* PutElementSetup calls PlyPutElementSetup on f. */
+func (f *PlyFile) PutElementSetup(element_name string) {
+	PlyPutElementSetup(f.open(), element_name)
+	runtime.KeepAlive(f)
+}
+
+/* PutElement calls PlyPutElement on f. */
+func (f *PlyFile) PutElement(element interface{}) {
+	PlyPutElement(f.open(), element)
+	runtime.KeepAlive(f)
+}
+
+/* GetElementDescription calls PlyGetElementDescription on f. */
+func (f *PlyFile) GetElementDescription(element_name string) ([]PlyProperty, int, int) {
+	defer runtime.KeepAlive(f)
+	return PlyGetElementDescription(f.open(), element_name)
+}
+
+/* GetProperty calls PlyGetProperty on f. */
+func (f *PlyFile) GetProperty(elem_name string, prop PlyProperty) {
+	PlyGetProperty(f.open(), elem_name, prop)
+	runtime.KeepAlive(f)
+}
+
+/* GetElement reads the next element, as PlyGetElement. Lists are allocated in C memory owned by f, and stay valid until FreeLists or Close. */
+func (f *PlyFile) GetElement(element interface{}, size uintptr) {
+	PlyGetElement(f.open(), element, size)
+	runtime.KeepAlive(f)
+}
+
+/* FreeLists frees the lists read by GetElement so far, e.g. after copying each batch of a large file into Go memory. */
+func (f *PlyFile) FreeLists() {
+	PlyFreeLists(f.open())
+	runtime.KeepAlive(f)
+}
+
+/* SetConvertPolicy calls PlySetConvertPolicy on f. */
+func (f *PlyFile) SetConvertPolicy(policy ConvertPolicy) {
+	PlySetConvertPolicy(f.open(), policy)
+	runtime.KeepAlive(f)
+}
+
+/* ConvertError calls PlyConvertError on f. */
+func (f *PlyFile) ConvertError() error {
+	defer runtime.KeepAlive(f)
+	return PlyConvertError(f.open())
+}
+
+/* SetLimits calls PlySetLimits on f. */
+func (f *PlyFile) SetLimits(opts ReadOptions) {
+	PlySetLimits(f.open(), opts)
+	runtime.KeepAlive(f)
+}
+
+/* BodyError calls PlyBodyError on f. */
+func (f *PlyFile) BodyError() error {
+	defer runtime.KeepAlive(f)
+	return PlyBodyError(f.open())
+}
+
+/* Comments calls PlyGetComments on f. */
+func (f *PlyFile) Comments() []string {
+	defer runtime.KeepAlive(f)
+	return PlyGetComments(f.open())
+}
+
+/* Georef calls PlyGetGeoref on f. */
+func (f *PlyFile) Georef() *Georef {
+	defer runtime.KeepAlive(f)
+	return PlyGetGeoref(f.open())
+}
+
+/* Metadata calls PlyGetMetadata on f. */
+func (f *PlyFile) Metadata() *Metadata {
+	defer runtime.KeepAlive(f)
+	return PlyGetMetadata(f.open())
+}
+
+/* ObjInfo calls PlyGetObjInfo on f. */
+func (f *PlyFile) ObjInfo() []string {
+	defer runtime.KeepAlive(f)
+	return PlyGetObjInfo(f.open())
+}
@@ -0,0 +1,155 @@
+package plyfile
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"runtime/debug"
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+/* rss returns the resident set size of the process, or 0 if it isn't known. */
+func rss() int64 {
+	data, err := os.ReadFile("/proc/self/statm")
+	if err != nil {
+		return 0
+	}
+	fields := strings.Fields(string(data))
+	if len(fields) < 2 {
+		return 0
+	}
+	pages, _ := strconv.ParseInt(fields[1], 10, 64)
+	return pages * int64(os.Getpagesize())
+}
+
+/* plyFileCycle writes a file of n vertices and n faces with a PlyFile, and reads it back. */
+func plyFileCycle(t *testing.T, path string, format int, n int) {
+	vert_props, face_props := SetPlyProperties()
+	f, err := CreatePlyFile(path, []string{"vertex", "face"}, format)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.PutComment("written by plyFileCycle")
+	f.PutObjInfo("cycle")
+	f.ElementCount("vertex", n)
+	for _, prop := range vert_props {
+		f.DescribeProperty("vertex", prop)
+	}
+	f.ElementCount("face", n)
+	for _, prop := range face_props {
+		f.DescribeProperty("face", prop)
+	}
+	f.HeaderComplete()
+	f.PutElementSetup("vertex")
+	for i := 0; i < n; i++ {
+		f.PutElement(Vertex{float32(i), 0, 0})
+	}
+	indices := [3]int32{0, 1, 2}
+	face := Face{Nverts: 3}
+	copy(face.Verts[:], PointerToByteSlice(uintptr(unsafe.Pointer(&indices))))
+	f.PutElementSetup("face")
+	for i := 0; i < n; i++ {
+		f.PutElement(face)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	runtime.KeepAlive(&indices)
+
+	f, elem_names, err := OpenPlyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if len(f.Comments()) != 1 || len(f.ObjInfo()) != 1 {
+		t.Fatalf("comments %q, obj_info %q", f.Comments(), f.ObjInfo())
+	}
+	for _, name := range elem_names {
+		_, num_elems, _ := f.GetElementDescription(name)
+		if name == "vertex" {
+			for _, prop := range vert_props {
+				f.GetProperty(name, prop)
+			}
+			var vertex Vertex
+			for i := 0; i < num_elems; i++ {
+				f.GetElement(&vertex, unsafe.Sizeof(vertex))
+			}
+		} else {
+			for _, prop := range face_props {
+				f.GetProperty(name, prop)
+			}
+			var face Face
+			for i := 0; i < num_elems; i++ {
+				f.GetElement(&face, unsafe.Sizeof(face))
+				if list := ReadPLYListInt32(ByteSliceToPointer(face.Verts[:]), int(face.Nverts)); list[2] != 2 {
+					t.Fatalf("face %d: %v", i, list)
+				}
+			}
+		}
+	}
+}
+
+/* TestPlyFileMemory checks that the resident memory stays flat over thousands of cycles of writing and reading a file with the C library, i.e. that the C strings, headers and lists are all freed. */
+func TestPlyFileMemory(t *testing.T) {
+	if rss() == 0 {
+		t.Skip("no /proc/self/statm")
+	}
+	cycles := 3000
+	if testing.Short() {
+		cycles = 500
+	}
+	path := filepath.Join(t.TempDir(), "cycle.ply")
+	measure := func() int64 {
+		runtime.GC()
+		debug.FreeOSMemory()
+		return rss()
+	}
+
+	for i := 0; i < 100; i++ {
+		plyFileCycle(t, path, PLY_BINARY_LE, 500)
+	}
+	before := measure()
+	for i := 0; i < cycles; i++ {
+		plyFileCycle(t, path, []int{PLY_ASCII, PLY_BINARY_LE}[i%2], 500)
+	}
+	after := measure()
+	t.Logf("%d cycles: rss %d kB -> %d kB", cycles, before/1024, after/1024)
+	if growth := after - before; growth > 4<<20 {
+		t.Errorf("rss grew by %d kB over %d cycles", growth/1024, cycles)
+	}
+}
+
+/* TestPlyFileClose checks that closing twice fails, that a closed file panics instead of reaching the C library, and that OpenPlyFile and CreatePlyFile report files they can't open. */
+func TestPlyFileClose(t *testing.T) {
+	f, _, err := OpenPlyFile("testdata/golden/blender_ascii.ply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != os.ErrClosed {
+		t.Errorf("second Close = %v, want os.ErrClosed", err)
+	}
+	func() {
+		defer func() {
+			if r := recover(); r != "plyfile: PlyFile used after Close" {
+				t.Errorf("Header after Close panicked with %v", r)
+			}
+		}()
+		f.Header()
+	}()
+
+	if _, _, err := OpenPlyFile("testdata/malformed/header/duplicate_element.ply"); err == nil {
+		t.Errorf("OpenPlyFile succeeded on a malformed file")
+	}
+	if _, _, err := OpenPlyFile(filepath.Join(t.TempDir(), "missing.ply")); err == nil {
+		t.Errorf("OpenPlyFile succeeded on a missing file")
+	}
+	if _, err := CreatePlyFile(filepath.Join(t.TempDir(), "empty.ply"), nil, PLY_ASCII); err == nil {
+		t.Errorf("CreatePlyFile succeeded without elements")
+	}
+}
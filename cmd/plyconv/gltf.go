@@ -0,0 +1,62 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+	"io"
+	"net/url"
+	"path/filepath"
+	"strings"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+	"github.com/ecopia-map/go-plyfile/gltf"
+)
+
+func runGLTF(args []string) error {
+	flags, _ := newFlagSet("gltf")
+	embed := flags.Bool("embed", false, "embed the buffer of .gltf output as base64 instead of writing a .bin file next to it")
+	input, output := parseArgs(flags, args)
+
+	if !isPLY(input) {
+		return errors.New("reading glTF files is not supported")
+	}
+
+	m, err := plyfile.ReadMesh(input)
+	if err != nil {
+		return err
+	}
+	moveTextures(m, input, output)
+	ext := filepath.Ext(output)
+	if !strings.EqualFold(ext, ".gltf") {
+		return writeFile(output, func(w io.Writer) error {
+			return gltf.Encode(w, m)
+		})
+	}
+	if *embed {
+		return writeFile(output, func(w io.Writer) error {
+			return gltf.EncodeJSON(w, m)
+		})
+	}
+	/* .gltf gets JSON whose buffer is the .bin file of the same name */
+	bin := strings.TrimSuffix(output, ext) + ".bin"
+	return writeFile(bin, func(bw io.Writer) error {
+		return writeFile(output, func(w io.Writer) error {
+			return gltf.EncodeJSONBin(w, bw, m, url.PathEscape(filepath.Base(bin)))
+		})
+	})
+}
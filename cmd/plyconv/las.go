@@ -0,0 +1,38 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"errors"
+
+	"github.com/ecopia-map/go-plyfile/las"
+)
+
+func runLAS(args []string) error {
+	flags, format := newFlagSet("las")
+	input, output := parseArgs(flags, args)
+
+	if isPLY(input) {
+		return errors.New("writing LAS files is not supported")
+	}
+
+	m, err := readFile(input, las.Decode)
+	if err != nil {
+		return err
+	}
+	return writePLY(output, m, *format)
+}
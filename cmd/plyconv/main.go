@@ -0,0 +1,160 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Command plyconv converts between PLY files and other geometry formats.
+
+Usage:
+
+	plyconv <command> [flags] <input> <output>
+
+Each command handles one format. The direction of the conversion is chosen from the file extensions: if the input file ends in .ply it is converted to the other format, otherwise the input is converted to PLY. Run "plyconv <command> -h" for the flags of a command.
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+/* command is a plyconv subcommand */
+type command struct {
+	name  string
+	usage string
+	run   func(args []string) error
+}
+
+var commands = []command{
+	{"obj", "convert between Wavefront OBJ and PLY", runOBJ},
+	{"stl", "convert between STL (ASCII or binary) and PLY", runSTL},
+	{"pcd", "convert between Point Cloud Library PCD and PLY", runPCD},
+	{"off", "convert between OFF (including COFF and NOFF) and PLY", runOFF},
+	{"xyz", "convert between XYZ point files and PLY", runXYZ},
+	{"pts", "convert between Leica PTS point files and PLY", runPTS},
+	{"las", "convert uncompressed LAS point clouds to PLY", runLAS},
+	{"gltf", "convert PLY to glTF 2.0 (.glb or .gltf)", runGLTF},
+}
+
+func usage() {
+	fmt.Fprintf(os.Stderr, "usage: plyconv <command> [flags] <input> <output>\n\ncommands:\n")
+	for _, cmd := range commands {
+		fmt.Fprintf(os.Stderr, "  %-8s %s\n", cmd.name, cmd.usage)
+	}
+	os.Exit(2)
+}
+
+func main() {
+	if len(os.Args) < 2 {
+		usage()
+	}
+	for _, cmd := range commands {
+		if cmd.name == os.Args[1] {
+			if err := cmd.run(os.Args[2:]); err != nil {
+				fmt.Fprintf(os.Stderr, "plyconv %s: %v\n", cmd.name, err)
+				os.Exit(1)
+			}
+			return
+		}
+	}
+	usage()
+}
+
+/* newFlagSet returns the flag set for a command, with the -format flag used when writing PLY files. */
+func newFlagSet(name string) (*flag.FlagSet, *string) {
+	flags := flag.NewFlagSet("plyconv "+name, flag.ExitOnError)
+	flags.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: plyconv %s [flags] <input> <output>\n", name)
+		flags.PrintDefaults()
+	}
+	format := flags.String("format", "binary_little_endian", "PLY output format: ascii, binary_little_endian or binary_big_endian")
+	return flags, format
+}
+
+/* parseArgs parses the flags and returns the input and output file names. */
+func parseArgs(flags *flag.FlagSet, args []string) (string, string) {
+	flags.Parse(args)
+	if flags.NArg() != 2 {
+		flags.Usage()
+		os.Exit(2)
+	}
+	return flags.Arg(0), flags.Arg(1)
+}
+
+func isPLY(filename string) bool {
+	return strings.EqualFold(filepath.Ext(filename), ".ply")
+}
+
+func parseFormat(format string) (int, error) {
+	switch format {
+	case "ascii":
+		return plyfile.PLY_ASCII, nil
+	case "binary_little_endian":
+		return plyfile.PLY_BINARY_LE, nil
+	case "binary_big_endian":
+		return plyfile.PLY_BINARY_BE, nil
+	}
+	return 0, fmt.Errorf("unknown PLY format '%s'", format)
+}
+
+/* writePLY writes the mesh to filename using the format named by the -format flag. */
+func writePLY(filename string, m *plyfile.Mesh, format string) error {
+	var err error
+	if m.Format, err = parseFormat(format); err != nil {
+		return err
+	}
+	return plyfile.WriteMesh(filename, m)
+}
+
+/* moveTextures rewrites the TextureFile comments of a mesh read from input so that they are relative to the directory of output. */
+func moveTextures(m *plyfile.Mesh, input, output string) {
+	paths := m.TexturePaths(input)
+	for i, path := range paths {
+		if rel, err := filepath.Rel(filepath.Dir(output), path); err == nil {
+			paths[i] = rel
+		}
+	}
+	m.SetTextureFiles(paths)
+}
+
+/* readFile decodes the mesh stored in filename using decode. */
+func readFile(filename string, decode func(r io.Reader) (*plyfile.Mesh, error)) (*plyfile.Mesh, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return decode(f)
+}
+
+/* writeFile creates filename and calls encode on it. The file is removed if encode fails. */
+func writeFile(filename string, encode func(w io.Writer) error) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := encode(f); err != nil {
+		f.Close()
+		os.Remove(filename)
+		return err
+	}
+	return f.Close()
+}
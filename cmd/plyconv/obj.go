@@ -0,0 +1,60 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+	"github.com/ecopia-map/go-plyfile/obj"
+)
+
+func runOBJ(args []string) error {
+	flags, format := newFlagSet("obj")
+	input, output := parseArgs(flags, args)
+
+	if isPLY(input) {
+		m, err := plyfile.ReadMesh(input)
+		if err != nil {
+			return err
+		}
+		if len(m.TextureFiles()) == 0 {
+			return writeFile(output, func(w io.Writer) error {
+				return obj.Encode(w, m)
+			})
+		}
+		/* the textures become the materials of an MTL file next to the OBJ file */
+		moveTextures(m, input, output)
+		mtl := strings.TrimSuffix(output, filepath.Ext(output)) + ".mtl"
+		if err := writeFile(mtl, func(w io.Writer) error {
+			return obj.EncodeMTL(w, m)
+		}); err != nil {
+			return err
+		}
+		return writeFile(output, func(w io.Writer) error {
+			return obj.EncodeWithMTL(w, m, filepath.Base(mtl))
+		})
+	}
+
+	m, err := readFile(input, obj.Decode)
+	if err != nil {
+		return err
+	}
+	return writePLY(output, m, *format)
+}
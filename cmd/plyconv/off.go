@@ -0,0 +1,45 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+	"github.com/ecopia-map/go-plyfile/off"
+)
+
+func runOFF(args []string) error {
+	flags, format := newFlagSet("off")
+	input, output := parseArgs(flags, args)
+
+	if isPLY(input) {
+		m, err := plyfile.ReadMesh(input)
+		if err != nil {
+			return err
+		}
+		return writeFile(output, func(w io.Writer) error {
+			return off.Encode(w, m)
+		})
+	}
+
+	m, err := readFile(input, off.Decode)
+	if err != nil {
+		return err
+	}
+	return writePLY(output, m, *format)
+}
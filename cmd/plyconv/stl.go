@@ -0,0 +1,55 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import (
+	"io"
+	"path/filepath"
+	"strings"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+	"github.com/ecopia-map/go-plyfile/stl"
+)
+
+func runSTL(args []string) error {
+	flags, format := newFlagSet("stl")
+	ascii := flags.Bool("ascii", false, "write an ASCII instead of a binary STL file")
+	tolerance := flags.Float64("tolerance", 0, "weld STL corners that are at most this far apart")
+	input, output := parseArgs(flags, args)
+
+	if isPLY(input) {
+		m, err := plyfile.ReadMesh(input)
+		if err != nil {
+			return err
+		}
+		return writeFile(output, func(w io.Writer) error {
+			if *ascii {
+				name := strings.TrimSuffix(filepath.Base(output), filepath.Ext(output))
+				return stl.EncodeASCII(w, m, name)
+			}
+			return stl.Encode(w, m)
+		})
+	}
+
+	m, err := readFile(input, func(r io.Reader) (*plyfile.Mesh, error) {
+		return stl.DecodeWeld(r, *tolerance)
+	})
+	if err != nil {
+		return err
+	}
+	return writePLY(output, m, *format)
+}
@@ -0,0 +1,143 @@
+package example
+
+import (
+	"bytes"
+	"math"
+	"os"
+	"reflect"
+	"strings"
+	"testing"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+/* TestReadModel reads sample.ply, and checks every value against the plyfile package. */
+func TestReadModel(t *testing.T) {
+	data, err := os.ReadFile("sample.ply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ply, err := ReadModel(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m, err := plyfile.DecodeMesh(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if ply.Format != "ascii" || !reflect.DeepEqual(ply.Comments, m.Comments) || !reflect.DeepEqual(ply.ObjInfo, m.ObjInfo) {
+		t.Errorf("header: %q %q %q", ply.Format, ply.Comments, ply.ObjInfo)
+	}
+	if len(ply.Vertex) != 3 || len(ply.Face) != 2 {
+		t.Fatalf("%d vertices, %d faces", len(ply.Vertex), len(ply.Face))
+	}
+	want := Vertex{X: 0, Y: 0, Z: 0, Confidence: 1, Red: 255, Flags: -1, Level: math.MinInt16, Label: math.MaxUint16, Id: math.MaxUint32, Segment: math.MinInt32}
+	if ply.Vertex[0] != want {
+		t.Errorf("vertex 0 = %+v, want %+v", ply.Vertex[0], want)
+	}
+	vertex := m.Element("vertex")
+	for i, v := range ply.Vertex {
+		got := []float64{float64(v.X), float64(v.Y), float64(v.Z), v.Confidence, float64(v.Red), float64(v.Green), float64(v.Blue),
+			float64(v.Flags), float64(v.Level), float64(v.Label), float64(v.Id), float64(v.Segment)}
+		for k, prop := range vertex.Properties {
+			if got[k] != prop.Data[i] {
+				t.Errorf("vertex %d %s = %v, plyfile %v", i, prop.Name, got[k], prop.Data[i])
+			}
+		}
+	}
+	if f := ply.Face[0]; !reflect.DeepEqual(f.VertexIndices, []int32{0, 1, 2}) || !reflect.DeepEqual(f.Texcoord, []float32{0, 0, 1, 0, 0, 1}) {
+		t.Errorf("face 0 = %+v", f)
+	}
+	if f := ply.Face[1]; len(f.VertexIndices) != 0 || len(f.Texcoord) != 0 {
+		t.Errorf("face 1 = %+v", f)
+	}
+}
+
+/* TestWriteModel writes the sample in every format, checks that the plyfile package reads the same values, and reads it back. */
+func TestWriteModel(t *testing.T) {
+	data, err := os.ReadFile("sample.ply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	ply, err := ReadModel(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := plyfile.DecodeMesh(bytes.NewReader(data))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, format := range []int{plyfile.PLY_ASCII, plyfile.PLY_BINARY_LE, plyfile.PLY_BINARY_BE} {
+		ply.Format = []string{"", "ascii", "binary_big_endian", "binary_little_endian"}[format]
+		var buf bytes.Buffer
+		if err := WriteModel(&buf, ply); err != nil {
+			t.Fatalf("%s: %v", ply.Format, err)
+		}
+		m, err := plyfile.DecodeMesh(bytes.NewReader(buf.Bytes()))
+		if err != nil {
+			t.Fatalf("%s: %v", ply.Format, err)
+		}
+		want.Format = format
+		if !reflect.DeepEqual(m, want) {
+			t.Errorf("%s: plyfile read %+v, want %+v", ply.Format, m, want)
+		}
+		var enc bytes.Buffer
+		if err := plyfile.EncodeMesh(&enc, want); err != nil {
+			t.Fatal(err)
+		}
+		back, err := ReadModel(&enc)
+		if err != nil {
+			t.Fatalf("%s: %v", ply.Format, err)
+		}
+		if !reflect.DeepEqual(back, ply) {
+			t.Errorf("%s: read back %+v, want %+v", ply.Format, back, ply)
+		}
+	}
+}
+
+/* TestModelErrors checks that files with another header, or bad data, are rejected. */
+func TestModelErrors(t *testing.T) {
+	data, err := os.ReadFile("sample.ply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	sample := string(data)
+	for _, test := range []struct{ old, new, err string }{
+		{"property float y\n", "", "line 7: unexpected property 'float z' in element 'vertex'"},
+		{"property float y\n", "property float32 y\n", ""},
+		{"property list uchar int vertex_indices", "property list uint8 int32 vertex_indices", ""},
+		{"property list uchar int vertex_indices", "property list uchar uint vertex_indices", "unexpected property 'list uchar uint vertex_indices'"},
+		{"element face 2", "element faces 2", "line 18: expected element 'face'"},
+		{"property list ushort float texcoord\n", "", "line 20: missing elements or properties"},
+		{"format ascii", "format binary 1.0", "bad format line"},
+		{"end_header\n", "end_header\r\n", ""},
+		{"-2.75", "x", "element 'vertex' 2: bad float value 'x'"},
+		{"255 0 0 -1", "256 0 0 -1", "element 'vertex' 0: bad uchar value '256'"},
+		{"3 0 1 2 6", "3 0 1 2 -6", "element 'face' 0: bad ushort value '-6'"},
+		{"0 0\n", "0 0 0\n", "element 'face' 1: 1 extra values"},
+		{"0 0\n", "0\n", "element 'face' 1: too few values"},
+		{"0 0\n", "", "element 'face' 1: unexpected end of file"},
+	} {
+		if !strings.Contains(sample, test.old) {
+			t.Fatalf("sample has no %q", test.old)
+		}
+		_, err := ReadModel(strings.NewReader(strings.Replace(sample, test.old, test.new, 1)))
+		if test.err == "" && err != nil {
+			t.Errorf("%q -> %q: %v", test.old, test.new, err)
+		}
+		if test.err != "" && (err == nil || !strings.Contains(err.Error(), test.err)) {
+			t.Errorf("%q -> %q: error %v, want %q", test.old, test.new, err, test.err)
+		}
+	}
+
+	long := &Model{Format: "ascii", Face: []Face{{Texcoord: make([]float32, math.MaxUint16+1)}}}
+	if err := WriteModel(&bytes.Buffer{}, long); err == nil || !strings.Contains(err.Error(), "too long for count type ushort") {
+		t.Errorf("long list: %v", err)
+	}
+	if err := WriteModel(&bytes.Buffer{}, &Model{Format: "binary"}); err == nil {
+		t.Errorf("unknown format succeeded")
+	}
+	if err := WriteModel(&bytes.Buffer{}, &Model{Format: "ascii", Comments: []string{"a\nb"}}); err == nil {
+		t.Errorf("comment with a line break succeeded")
+	}
+}
@@ -0,0 +1,164 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Command plygen generates Go code to read and write PLY files with the header of a sample file.
+
+Usage:
+
+	plygen [flags] <sample.ply>
+
+For each element of the sample, plygen writes a struct with one field per property, of the Go type matching the PLY type (e.g. float32 for float, []int32 for a list of int), and a struct holding a slice of each element, the comments and the format. Read<Type> and Write<Type> read and write files with the same elements and properties as the sample, with any element counts, in any PLY format. The generated code uses only the standard library, and no reflection. It is meant to be run by go generate:
+
+	//go:generate go run github.com/ecopia-map/go-plyfile/cmd/plygen -type Scan scan.ply
+*/
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"go/format"
+	"os"
+	"path/filepath"
+	"strings"
+	"unicode"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+func main() {
+	pkg := flag.String("package", os.Getenv("GOPACKAGE"), "package of the generated code (default $GOPACKAGE, set by go generate)")
+	typ := flag.String("type", "PLY", "name of the struct holding every element; the functions are Read<type> and Write<type>")
+	prefix := flag.String("prefix", "", "prefix of the element struct names")
+	output := flag.String("o", "", "output file (default <sample>_ply.go)")
+	flag.Usage = func() {
+		fmt.Fprintf(os.Stderr, "usage: plygen [flags] <sample.ply>\n")
+		flag.PrintDefaults()
+	}
+	flag.Parse()
+	if flag.NArg() != 1 {
+		flag.Usage()
+		os.Exit(2)
+	}
+	sample := flag.Arg(0)
+	if *pkg == "" {
+		*pkg = "main"
+	}
+	if *output == "" {
+		*output = strings.TrimSuffix(filepath.Base(sample), filepath.Ext(sample)) + "_ply.go"
+	}
+
+	if err := run(sample, *output, *pkg, *typ, *prefix); err != nil {
+		fmt.Fprintf(os.Stderr, "plygen: %v\n", err)
+		os.Exit(1)
+	}
+}
+
+func run(sample, output, pkg, typ, prefix string) error {
+	f, err := os.Open(sample)
+	if err != nil {
+		return err
+	}
+	header, err := plyfile.DecodeHeader(f)
+	f.Close()
+	if err != nil {
+		return err
+	}
+	src, err := generate(header, filepath.Base(sample), pkg, typ, prefix)
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(output, src, 0644)
+}
+
+/* generate returns the formatted source code for the header of a sample file. */
+func generate(header *plyfile.Mesh, sample, pkg, typ, prefix string) ([]byte, error) {
+	if !isExported(typ) {
+		return nil, fmt.Errorf("type name '%s' is not an exported Go identifier", typ)
+	}
+	data := fileData{Package: pkg, Type: typ, Sample: sample}
+	types := map[string]string{typ: "-type"}
+	for _, elem := range header.Elements {
+		e := elemData{Name: elem.Name, Field: goName(elem.Name), Type: prefix + goName(elem.Name)}
+		if e.Field == "Format" || e.Field == "Comments" || e.Field == "ObjInfo" {
+			e.Field += "Elements"
+		}
+		if other, ok := types[e.Type]; ok {
+			return nil, fmt.Errorf("element '%s' and %s are both named %s; use -prefix", elem.Name, other, e.Type)
+		}
+		types[e.Type] = fmt.Sprintf("element '%s'", elem.Name)
+
+		fields := map[string]string{}
+		for _, prop := range elem.Properties {
+			p := propData{Name: prop.Name, Field: goName(prop.Name), Code: prop.Type, GoType: goTypes[prop.Type],
+				IsList: prop.IsList, CountCode: prop.CountType}
+			if other, ok := fields[p.Field]; ok {
+				return nil, fmt.Errorf("properties '%s' and '%s' of element '%s' are both named %s", other, prop.Name, elem.Name, p.Field)
+			}
+			fields[p.Field] = prop.Name
+			p.IsFloat = prop.Type == plyfile.PLY_FLOAT || prop.Type == plyfile.PLY_DOUBLE
+			if prop.IsList {
+				p.Header = fmt.Sprintf("list %s %s %s", plyfile.TypeName(prop.CountType), plyfile.TypeName(prop.Type), prop.Name)
+			} else {
+				p.Header = fmt.Sprintf("%s %s", plyfile.TypeName(prop.Type), prop.Name)
+			}
+			e.Props = append(e.Props, p)
+		}
+		data.Elements = append(data.Elements, e)
+	}
+
+	var buf bytes.Buffer
+	if err := fileTemplate.Execute(&buf, data); err != nil {
+		return nil, err
+	}
+	src, err := format.Source(buf.Bytes())
+	if err != nil {
+		return nil, fmt.Errorf("generated code doesn't compile: %v\n%s", err, buf.Bytes())
+	}
+	return src, nil
+}
+
+/* goTypes are the Go types of the PLY_* types. */
+var goTypes = [...]string{"", "int8", "int16", "int32", "uint8", "uint16", "uint32", "float32", "float64"}
+
+/* goName converts a PLY name to an exported Go identifier, e.g. vertex_indices to VertexIndices. */
+func goName(name string) string {
+	var b strings.Builder
+	upper := true
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) {
+			upper = true
+			continue
+		}
+		if b.Len() == 0 && unicode.IsDigit(r) {
+			b.WriteString("P")
+		}
+		if upper {
+			r = unicode.ToUpper(r)
+			upper = false
+		}
+		b.WriteRune(r)
+	}
+	if b.Len() == 0 {
+		return "P"
+	}
+	return b.String()
+}
+
+func isExported(name string) bool {
+	return name != "" && goName(name) == name && unicode.IsUpper([]rune(name)[0])
+}
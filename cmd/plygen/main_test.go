@@ -0,0 +1,73 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+/* TestGenerate checks that the committed example/sample_ply.go is what plygen generates from example/sample.ply. */
+func TestGenerate(t *testing.T) {
+	f, err := os.Open("example/sample.ply")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	header, err := plyfile.DecodeHeader(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	src, err := generate(header, "sample.ply", "example", "Model", "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	want, err := os.ReadFile("example/sample_ply.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(src, want) {
+		t.Errorf("example/sample_ply.go is out of date; run go generate ./cmd/plygen/example")
+	}
+}
+
+func TestGoName(t *testing.T) {
+	for name, want := range map[string]string{
+		"vertex":           "Vertex",
+		"vertex_indices":   "VertexIndices",
+		"nx":               "Nx",
+		"scalar_Intensity": "ScalarIntensity",
+		"2d-point":         "P2dPoint",
+		"_":                "P",
+	} {
+		if got := goName(name); got != want {
+			t.Errorf("goName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
+
+func TestGenerateErrors(t *testing.T) {
+	for _, test := range []struct {
+		header *plyfile.Mesh
+		typ    string
+		err    string
+	}{
+		{&plyfile.Mesh{}, "model", "not an exported Go identifier"},
+		{&plyfile.Mesh{Elements: []*plyfile.Element{{Name: "model"}}}, "Model", "element 'model' and -type are both named Model"},
+		{&plyfile.Mesh{Elements: []*plyfile.Element{{Name: "a_b"}, {Name: "aB"}}}, "PLY", "element 'aB' and element 'a_b' are both named AB"},
+		{&plyfile.Mesh{Elements: []*plyfile.Element{{Name: "vertex", Properties: []*plyfile.Property{
+			{Name: "red", Type: plyfile.PLY_UCHAR}, {Name: "Red", Type: plyfile.PLY_UCHAR}}}}}, "PLY",
+			"properties 'red' and 'Red' of element 'vertex' are both named Red"},
+	} {
+		_, err := generate(test.header, "sample.ply", "example", test.typ, "")
+		if err == nil || !strings.Contains(err.Error(), test.err) {
+			t.Errorf("error %v, want %q", err, test.err)
+		}
+	}
+	src, err := generate(&plyfile.Mesh{Elements: []*plyfile.Element{{Name: "format"}}}, "sample.ply", "example", "PLY", "")
+	if err != nil || !bytes.Contains(src, []byte("FormatElements []Format")) {
+		t.Errorf("element named format: %v", err)
+	}
+}
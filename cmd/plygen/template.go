@@ -0,0 +1,535 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package main
+
+import "text/template"
+
+/* fileData is the input of fileTemplate. */
+type fileData struct {
+	Package  string
+	Type     string /* name of the struct holding every element */
+	Sample   string /* base name of the sample file */
+	Elements []elemData
+}
+
+/* elemData describes the struct generated for an element. */
+type elemData struct {
+	Name  string /* PLY name */
+	Field string /* field of the file struct */
+	Type  string /* Go type */
+	Props []propData
+}
+
+/* propData describes the field generated for a property. */
+type propData struct {
+	Name      string /* PLY name */
+	Field     string
+	Code      int    /* PLY_* type of the values */
+	GoType    string /* Go type of the values */
+	IsList    bool
+	CountCode int /* PLY_* type of the list count */
+	IsFloat   bool
+	Header    string /* property line of the header, without "property" */
+}
+
+var fileTemplate = template.Must(template.New("file").Parse(`{{$T := .Type}}{{$P := .Package}}// Code generated by plygen from {{.Sample}}; DO NOT EDIT.
+
+package {{.Package}}
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+)
+{{range .Elements}}
+/* {{.Type}} is one {{.Name}} element. */
+type {{.Type}} struct {
+{{- range .Props}}
+	{{.Field}} {{if .IsList}}[]{{end}}{{.GoType}} /* {{.Header}} */
+{{- end}}
+}
+{{end}}
+/* {{$T}} holds the elements of a PLY file with the header of {{.Sample}}. */
+type {{$T}} struct {
+	Format   string /* "ascii", "binary_little_endian" or "binary_big_endian" */
+	Comments []string
+	ObjInfo  []string
+{{- range .Elements}}
+	{{.Field}} []{{.Type}}
+{{- end}}
+}
+
+/* Read{{$T}} reads a PLY file with the elements and properties of {{.Sample}}, in any format and with any element counts. */
+func Read{{$T}}(r io.Reader) (*{{$T}}, error) {
+	d := &decoder{{$T}}{br: bufio.NewReader(r)}
+	ply := &{{$T}}{}
+	counts, err := d.header(ply)
+	if err != nil {
+		return nil, err
+	}
+{{- range $i, $e := .Elements}}
+	ply.{{.Field}} = make([]{{.Type}}, 0, prealloc{{$T}}(counts[{{$i}}]))
+	for i := 0; i < counts[{{$i}}]; i++ {
+		var v {{.Type}}
+		d.begin({{printf "%q" .Name}}, i)
+		v.decode(d)
+		d.end()
+		if d.err != nil {
+			return nil, d.err
+		}
+		ply.{{.Field}} = append(ply.{{.Field}}, v)
+	}
+{{- end}}
+	return ply, nil
+}
+
+/* Write{{$T}} writes ply in ply.Format, with the header of {{.Sample}}; the element counts are the lengths of the slices. */
+func Write{{$T}}(w io.Writer, ply *{{$T}}) error {
+	e := &encoder{{$T}}{bw: bufio.NewWriter(w)}
+	counts := []int{ {{- range .Elements}}len(ply.{{.Field}}), {{end -}} }
+	if err := e.header(ply.Format, ply.Comments, ply.ObjInfo, counts); err != nil {
+		return err
+	}
+{{- range .Elements}}
+	for i := range ply.{{.Field}} {
+		e.begin({{printf "%q" .Name}}, i)
+		ply.{{.Field}}[i].encode(e)
+		e.end()
+	}
+	if e.err != nil {
+		return e.err
+	}
+{{- end}}
+	return e.bw.Flush()
+}
+{{range .Elements}}
+func (v *{{.Type}}) decode(d *decoder{{$T}}) {
+{{- range .Props}}
+{{- if .IsList}}
+	n{{.Field}} := d.count({{.CountCode}})
+	v.{{.Field}} = make([]{{.GoType}}, 0, prealloc{{$T}}(n{{.Field}}))
+	for k := 0; k < n{{.Field}} && d.err == nil; k++ {
+		v.{{.Field}} = append(v.{{.Field}}, {{.GoType}}(d.{{if .IsFloat}}float{{else}}integer{{end}}({{.Code}})))
+	}
+{{- else}}
+	v.{{.Field}} = {{.GoType}}(d.{{if .IsFloat}}float{{else}}integer{{end}}({{.Code}}))
+{{- end}}
+{{- end}}
+}
+
+func (v *{{.Type}}) encode(e *encoder{{$T}}) {
+{{- range .Props}}
+{{- if .IsList}}
+	e.count(len(v.{{.Field}}), {{.CountCode}})
+	for _, x := range v.{{.Field}} {
+		e.{{if .IsFloat}}float(float64(x){{else}}integer(int64(x){{end}}, {{.Code}})
+	}
+{{- else}}
+	e.{{if .IsFloat}}float(float64(v.{{.Field}}){{else}}integer(int64(v.{{.Field}}){{end}}, {{.Code}})
+{{- end}}
+{{- end}}
+}
+{{end}}
+/* header{{$T}} lists the elements of {{.Sample}} and their property lines. */
+var header{{$T}} = []struct {
+	name  string
+	props []string
+}{
+{{- range .Elements}}
+	{ {{- printf "%q" .Name}}, []string{ {{- range $i, $p := .Props}}{{if $i}}, {{end}}{{printf "%q" $p.Header}}{{end -}} } },
+{{- end}}
+}
+
+/* The names, sizes and integer ranges of the PLY types, indexed by the type codes of the plyfile package. */
+var (
+	typeNames{{$T}} = [...]string{"", "char", "short", "int", "uchar", "ushort", "uint", "float", "double"}
+	typeAliases{{$T}} = map[string]string{"int8": "char", "int16": "short", "int32": "int", "uint8": "uchar", "uint16": "ushort", "uint32": "uint",
+		"float32": "float", "float64": "double"}
+	typeSizes{{$T}} = [...]int{0, 1, 2, 4, 1, 2, 4, 4, 8}
+	typeRanges{{$T}} = [...][2]int64{{"{{"}}0, 0}, {math.MinInt8, math.MaxInt8}, {math.MinInt16, math.MaxInt16}, {math.MinInt32, math.MaxInt32},
+		{0, math.MaxUint8}, {0, math.MaxUint16}, {0, math.MaxUint32}}
+)
+
+/* prealloc{{$T}} caps the capacity allocated for a count read from a file, so a bad count can't exhaust memory before the data runs out. */
+func prealloc{{$T}}(n int) int {
+	if n > 4096 {
+		return 4096
+	}
+	return n
+}
+
+/* decoder{{$T}} reads the values of a PLY file, keeping the first error. */
+type decoder{{$T}} struct {
+	br    *bufio.Reader
+	ascii bool
+	order binary.ByteOrder
+	line  int      /* last line read */
+	words []string /* values left on the line, in ASCII files */
+	elem  string   /* element being read */
+	index int
+	buf   [8]byte
+	err   error
+}
+
+func (d *decoder{{$T}}) readLine() (string, bool) {
+	line, err := d.br.ReadString('\n')
+	if err != nil && (err != io.EOF || line == "") {
+		return "", false
+	}
+	d.line++
+	return strings.TrimRight(line, "\r\n"), true
+}
+
+/* header reads the header into ply, checks that it declares the elements and properties of {{.Sample}}, and returns the element counts. */
+func (d *decoder{{$T}}) header(ply *{{$T}}) ([]int, error) {
+	fail := func(format string, args ...interface{}) ([]int, error) {
+		return nil, fmt.Errorf("{{$P}}: line %d: %s", d.line, fmt.Sprintf(format, args...))
+	}
+	if line, ok := d.readLine(); !ok || line != "ply" {
+		return fail("not a PLY file")
+	}
+	var counts []int
+	num_props := 0
+	for {
+		line, ok := d.readLine()
+		if !ok {
+			return fail("unexpected end of file in header")
+		}
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			return fail("blank line in header")
+		}
+		switch words[0] {
+		case "format":
+			if len(words) != 3 {
+				return fail("bad format line")
+			}
+			switch words[1] {
+			case "ascii":
+				d.ascii = true
+			case "binary_little_endian":
+				d.order = binary.LittleEndian
+			case "binary_big_endian":
+				d.order = binary.BigEndian
+			default:
+				return fail("unknown format '%s'", words[1])
+			}
+			ply.Format = words[1]
+		case "comment", "obj_info":
+			text := strings.TrimLeft(strings.TrimLeft(line, " \t")[len(words[0]):], " \t")
+			if words[0] == "comment" {
+				ply.Comments = append(ply.Comments, text)
+			} else {
+				ply.ObjInfo = append(ply.ObjInfo, text)
+			}
+		case "element":
+			if len(counts) > 0 && num_props != len(header{{$T}}[len(counts)-1].props) {
+				return fail("element '%s' has too few properties", header{{$T}}[len(counts)-1].name)
+			}
+			if len(counts) == len(header{{$T}}) {
+				return fail("unexpected element line")
+			}
+			if len(words) != 3 || words[1] != header{{$T}}[len(counts)].name {
+				return fail("expected element '%s'", header{{$T}}[len(counts)].name)
+			}
+			count, err := strconv.Atoi(words[2])
+			if err != nil || count < 0 {
+				return fail("bad count for element '%s'", words[1])
+			}
+			counts = append(counts, count)
+			num_props = 0
+		case "property":
+			if len(counts) == 0 {
+				return fail("property before first element")
+			}
+			elem := header{{$T}}[len(counts)-1]
+			types := words[1:2]
+			if len(words) > 1 && words[1] == "list" {
+				types = words[2:minInt{{$T}}(4, len(words))]
+			}
+			for k, name := range types {
+				if alias, ok := typeAliases{{$T}}[name]; ok {
+					types[k] = alias
+				}
+			}
+			prop := strings.Join(words[1:], " ")
+			if num_props == len(elem.props) || prop != elem.props[num_props] {
+				return fail("unexpected property '%s' in element '%s'", prop, elem.name)
+			}
+			num_props++
+		case "end_header":
+			if ply.Format == "" {
+				return fail("missing format line")
+			}
+			if len(counts) != len(header{{$T}}) || (len(counts) > 0 && num_props != len(header{{$T}}[len(counts)-1].props)) {
+				return fail("missing elements or properties")
+			}
+			return counts, nil
+		default:
+			return fail("unknown keyword '%s'", words[0])
+		}
+	}
+}
+
+func minInt{{$T}}(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func (d *decoder{{$T}}) fail(format string, args ...interface{}) {
+	if d.err == nil {
+		d.err = fmt.Errorf("{{$P}}: element '%s' %d: %s", d.elem, d.index, fmt.Sprintf(format, args...))
+	}
+}
+
+/* begin starts an element, reading its line in ASCII files. */
+func (d *decoder{{$T}}) begin(elem string, index int) {
+	d.elem, d.index = elem, index
+	if d.ascii && d.err == nil {
+		line, ok := d.readLine()
+		if !ok {
+			d.fail("unexpected end of file")
+			return
+		}
+		d.words = strings.Fields(line)
+	}
+}
+
+/* end finishes an element, failing if values are left on its line. */
+func (d *decoder{{$T}}) end() {
+	if d.ascii && len(d.words) > 0 {
+		d.fail("%d extra values", len(d.words))
+	}
+}
+
+func (d *decoder{{$T}}) word() (string, bool) {
+	if d.err != nil {
+		return "", false
+	}
+	if len(d.words) == 0 {
+		d.fail("too few values")
+		return "", false
+	}
+	word := d.words[0]
+	d.words = d.words[1:]
+	return word, true
+}
+
+func (d *decoder{{$T}}) bytes(typ int) []byte {
+	if d.err != nil {
+		return nil
+	}
+	buf := d.buf[:typeSizes{{$T}}[typ]]
+	if _, err := io.ReadFull(d.br, buf); err != nil {
+		d.fail("unexpected end of file")
+		return nil
+	}
+	return buf
+}
+
+/* integer reads a value of an integer type. */
+func (d *decoder{{$T}}) integer(typ int) int64 {
+	if d.ascii {
+		word, ok := d.word()
+		if !ok {
+			return 0
+		}
+		v, err := strconv.ParseInt(word, 10, 64)
+		if err != nil || v < typeRanges{{$T}}[typ][0] || v > typeRanges{{$T}}[typ][1] {
+			d.fail("bad %s value '%s'", typeNames{{$T}}[typ], word)
+			return 0
+		}
+		return v
+	}
+	buf := d.bytes(typ)
+	if buf == nil {
+		return 0
+	}
+	switch typ {
+	case 1:
+		return int64(int8(buf[0]))
+	case 2:
+		return int64(int16(d.order.Uint16(buf)))
+	case 3:
+		return int64(int32(d.order.Uint32(buf)))
+	case 4:
+		return int64(buf[0])
+	case 5:
+		return int64(d.order.Uint16(buf))
+	}
+	return int64(d.order.Uint32(buf))
+}
+
+/* float reads a value of type float or double. */
+func (d *decoder{{$T}}) float(typ int) float64 {
+	if d.ascii {
+		word, ok := d.word()
+		if !ok {
+			return 0
+		}
+		v, err := strconv.ParseFloat(word, 64)
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			d.fail("bad %s value '%s'", typeNames{{$T}}[typ], word)
+			return 0
+		}
+		return v
+	}
+	buf := d.bytes(typ)
+	if buf == nil {
+		return 0
+	}
+	if typ == 7 {
+		return float64(math.Float32frombits(d.order.Uint32(buf)))
+	}
+	return math.Float64frombits(d.order.Uint64(buf))
+}
+
+/* count reads the count of a list. */
+func (d *decoder{{$T}}) count(typ int) int {
+	n := d.integer(typ)
+	if n < 0 {
+		d.fail("negative list count %d", n)
+		return 0
+	}
+	return int(n)
+}
+
+/* encoder{{$T}} writes the values of a PLY file, keeping the first error. */
+type encoder{{$T}} struct {
+	bw    *bufio.Writer
+	ascii bool
+	order binary.ByteOrder
+	line  []byte /* line of the element being written, in ASCII files */
+	elem  string /* element being written */
+	index int
+	buf   [8]byte
+	err   error
+}
+
+func (e *encoder{{$T}}) header(format string, comments, obj_info []string, counts []int) error {
+	switch format {
+	case "ascii":
+		e.ascii = true
+	case "binary_little_endian":
+		e.order = binary.LittleEndian
+	case "binary_big_endian":
+		e.order = binary.BigEndian
+	default:
+		return fmt.Errorf("{{$P}}: unknown format '%s'", format)
+	}
+	fmt.Fprintf(e.bw, "ply\nformat %s 1.0\n", format)
+	for _, comment := range comments {
+		if strings.ContainsAny(comment, "\r\n") {
+			return fmt.Errorf("{{$P}}: comment contains a line break: %q", comment)
+		}
+		fmt.Fprintf(e.bw, "comment %s\n", comment)
+	}
+	for _, info := range obj_info {
+		if strings.ContainsAny(info, "\r\n") {
+			return fmt.Errorf("{{$P}}: obj_info contains a line break: %q", info)
+		}
+		fmt.Fprintf(e.bw, "obj_info %s\n", info)
+	}
+	for i, elem := range header{{$T}} {
+		fmt.Fprintf(e.bw, "element %s %d\n", elem.name, counts[i])
+		for _, prop := range elem.props {
+			fmt.Fprintf(e.bw, "property %s\n", prop)
+		}
+	}
+	_, err := e.bw.WriteString("end_header\n")
+	return err
+}
+
+func (e *encoder{{$T}}) begin(elem string, index int) {
+	e.elem, e.index = elem, index
+	e.line = e.line[:0]
+}
+
+/* end finishes an element, writing its line in ASCII files. */
+func (e *encoder{{$T}}) end() {
+	if e.ascii && e.err == nil {
+		if n := len(e.line); n > 0 {
+			e.line = e.line[:n-1]
+		}
+		e.line = append(e.line, '\n')
+		e.write(e.line)
+	}
+}
+
+func (e *encoder{{$T}}) write(buf []byte) {
+	if e.err == nil {
+		if _, err := e.bw.Write(buf); err != nil {
+			e.err = err
+		}
+	}
+}
+
+/* integer writes a value of an integer type. */
+func (e *encoder{{$T}}) integer(v int64, typ int) {
+	if e.ascii {
+		e.line = append(strconv.AppendInt(e.line, v, 10), ' ')
+		return
+	}
+	buf := e.buf[:typeSizes{{$T}}[typ]]
+	switch len(buf) {
+	case 1:
+		buf[0] = byte(v)
+	case 2:
+		e.order.PutUint16(buf, uint16(v))
+	default:
+		e.order.PutUint32(buf, uint32(v))
+	}
+	e.write(buf)
+}
+
+/* float writes a value of type float or double. */
+func (e *encoder{{$T}}) float(v float64, typ int) {
+	if e.ascii {
+		bits := 64
+		if typ == 7 {
+			bits = 32
+		}
+		e.line = append(strconv.AppendFloat(e.line, v, 'g', -1, bits), ' ')
+		return
+	}
+	buf := e.buf[:typeSizes{{$T}}[typ]]
+	if typ == 7 {
+		e.order.PutUint32(buf, math.Float32bits(float32(v)))
+	} else {
+		e.order.PutUint64(buf, math.Float64bits(v))
+	}
+	e.write(buf)
+}
+
+/* count writes the count of a list, failing if it doesn't fit the count type. */
+func (e *encoder{{$T}}) count(n int, typ int) {
+	if int64(n) > typeRanges{{$T}}[typ][1] {
+		if e.err == nil {
+			e.err = fmt.Errorf("{{$P}}: element '%s' %d: list of %d values too long for count type %s", e.elem, e.index, n, typeNames{{$T}}[typ])
+		}
+		return
+	}
+	e.integer(int64(n), typ)
+}
+`))
@@ -0,0 +1,327 @@
+package plyfile
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+var allTypes = []int{PLY_CHAR, PLY_SHORT, PLY_INT, PLY_UCHAR, PLY_USHORT, PLY_UINT, PLY_FLOAT, PLY_DOUBLE}
+var countTypes = []int{PLY_CHAR, PLY_SHORT, PLY_INT, PLY_UCHAR, PLY_USHORT, PLY_UINT}
+var allFormats = []int{PLY_ASCII, PLY_BINARY_BE, PLY_BINARY_LE}
+var formatNames = map[int]string{PLY_ASCII: "ascii", PLY_BINARY_BE: "binary_big_endian", PLY_BINARY_LE: "binary_little_endian"}
+
+/* boundaryValues returns the extreme and special values of a PLY_* type. */
+func boundaryValues(typ int) []float64 {
+	switch typ {
+	case PLY_FLOAT:
+		return []float64{-math.MaxFloat32, -1, -math.SmallestNonzeroFloat32, math.Copysign(0, -1), 0, math.SmallestNonzeroFloat32,
+			float64(float32(0.1)), 1, 16777217 - 1, math.MaxFloat32, math.Inf(1), math.Inf(-1), math.NaN()}
+	case PLY_DOUBLE:
+		return []float64{-math.MaxFloat64, -1, -math.SmallestNonzeroFloat64, math.Copysign(0, -1), 0, math.SmallestNonzeroFloat64,
+			0.1, 1, 1 << 53, math.MaxFloat64, math.Inf(1), math.Inf(-1), math.NaN()}
+	}
+	lo, hi := typeRange(typ)
+	values := []float64{lo, lo + 1, 0, 1, hi - 1, hi}
+	if lo < 0 {
+		values = append(values, -1)
+	}
+	return values
+}
+
+/* representable reports whether v is stored exactly by a PLY_* type. */
+func representable(v float64, typ int) bool {
+	switch typ {
+	case PLY_DOUBLE:
+		return true
+	case PLY_FLOAT:
+		return math.IsNaN(v) || float64(float32(v)) == v
+	}
+	lo, hi := typeRange(typ)
+	return v >= lo && v <= hi && v == math.Trunc(v) && !(v == 0 && math.Signbit(v))
+}
+
+/* sameValue reports whether two values are identical, treating NaNs as equal and telling 0 from -0. */
+func sameValue(a, b float64) bool {
+	if math.IsNaN(a) || math.IsNaN(b) {
+		return math.IsNaN(a) && math.IsNaN(b)
+	}
+	return a == b && math.Signbit(a) == math.Signbit(b)
+}
+
+/* testLists splits values into lists of 0, 1, 2, ... values. */
+func testLists(values []float64) [][]float64 {
+	lists := [][]float64{{}}
+	for n := 1; len(values) > 0; n++ {
+		if n > len(values) {
+			n = len(values)
+		}
+		lists = append(lists, values[:n])
+		values = values[n:]
+	}
+	return lists
+}
+
+func checkValues(t *testing.T, what string, got []float64, want []float64, same func(a, b float64) bool) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: %d values, want %d", what, len(got), len(want))
+		return
+	}
+	for i := range want {
+		if !same(got[i], want[i]) {
+			t.Errorf("%s: value %d = %v, want %v", what, i, got[i], want[i])
+		}
+	}
+}
+
+func checkLists(t *testing.T, what string, got [][]float64, want [][]float64, same func(a, b float64) bool) {
+	t.Helper()
+	if len(got) != len(want) {
+		t.Errorf("%s: %d lists, want %d", what, len(got), len(want))
+		return
+	}
+	for i := range want {
+		checkValues(t, fmt.Sprintf("%s list %d", what, i), got[i], want[i], same)
+	}
+}
+
+/* conformanceMesh returns a mesh with a scalar property of type typ holding values, and a list property of type typ for every count type. */
+func conformanceMesh(format int, typ int, values []float64) *Mesh {
+	m := NewMesh(format)
+	scalar := m.AddElement("scalar", len(values))
+	copy(scalar.AddProperty("value", typ).Data, values)
+
+	lists := testLists(values)
+	list := m.AddElement("list", len(lists))
+	for _, count_type := range countTypes {
+		prop := list.AddListProperty("values_"+TypeName(count_type), count_type, typ)
+		copy(prop.Lists, lists)
+	}
+	return m
+}
+
+/* TestMeshConformance writes and reads the boundary values of every type, as scalars and as lists with every count type, in every file format. */
+func TestMeshConformance(t *testing.T) {
+	for _, format := range allFormats {
+		for _, typ := range allTypes {
+			format, typ := format, typ
+			t.Run(formatNames[format]+"/"+TypeName(typ), func(t *testing.T) {
+				values := boundaryValues(typ)
+				m := conformanceMesh(format, typ, values)
+
+				var buf bytes.Buffer
+				if err := EncodeMesh(&buf, m); err != nil {
+					t.Fatal(err)
+				}
+				got, err := DecodeMesh(&buf)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if got.Format != format {
+					t.Errorf("format %d, want %d", got.Format, format)
+				}
+				checkValues(t, "scalar", got.Element("scalar").Property("value").Data, values, sameValue)
+				for _, want := range m.Element("list").Properties {
+					prop := got.Element("list").Property(want.Name)
+					if prop == nil || !prop.IsList || prop.CountType != want.CountType || prop.Type != typ {
+						t.Errorf("%s: bad property %+v", want.Name, prop)
+						continue
+					}
+					checkLists(t, want.Name, prop.Lists, want.Lists, sameValue)
+				}
+			})
+		}
+	}
+}
+
+/* The C library reads and writes through records of the internal types. The records of these tests hold a scalar at offset 0, and a list count (int) at offset 8 with a pointer to the list values at offset 16. As in the rest of the package, records are assumed to be little endian. */
+
+const recordSize = 24
+
+/* writeCFile writes a file with the C library, storing the values with the internal type and writing them with the external type. */
+func writeCFile(path string, format int, external int, internal int, count_external int, values []float64, lists [][]float64) {
+	var version float32
+	cplyfile := PlyOpenForWriting(path, 2, []string{"scalar", "list"}, format, &version)
+	PlyElementCount(cplyfile, "scalar", len(values))
+	PlyDescribeProperty(cplyfile, "scalar", PlyProperty{"value", external, internal, 0, 0, 0, 0, 0})
+	PlyElementCount(cplyfile, "list", len(lists))
+	PlyDescribeProperty(cplyfile, "list", PlyProperty{"values", external, internal, 16, 1, count_external, PLY_INT, 8})
+	PlyHeaderComplete(cplyfile)
+
+	PlyPutElementSetup(cplyfile, "scalar")
+	for _, v := range values {
+		var record [recordSize]byte
+		putBinaryItem(record[:], v, internal, binary.LittleEndian)
+		PlyPutElement(cplyfile, record)
+	}
+
+	/* the list values must stay in Go memory until the file is closed */
+	var data [][]byte
+	PlyPutElementSetup(cplyfile, "list")
+	for _, list := range lists {
+		var record [recordSize]byte
+		binary.LittleEndian.PutUint32(record[8:], uint32(len(list)))
+		if len(list) > 0 {
+			size := TypeSize(internal)
+			values := make([]byte, len(list)*size)
+			for k, v := range list {
+				putBinaryItem(values[k*size:], v, internal, binary.LittleEndian)
+			}
+			copy(record[16:], PointerToByteSlice(uintptr(unsafe.Pointer(&values[0]))))
+			data = append(data, values)
+		}
+		PlyPutElement(cplyfile, record)
+	}
+	PlyClose(cplyfile)
+	runtime.KeepAlive(data)
+}
+
+/* readCFile reads a file written by writeCFile with the C library, converting the values to the internal type. */
+func readCFile(t *testing.T, path string, internal int) (values []float64, lists [][]float64) {
+	cplyfile, elem_names := PlyOpenForReading(path)
+	if cplyfile == nil {
+		t.Fatalf("%s: can't read header", path)
+	}
+	defer PlyClose(cplyfile)
+
+	for _, name := range elem_names {
+		_, num_elems, _ := PlyGetElementDescription(cplyfile, name)
+		if name == "scalar" {
+			PlyGetProperty(cplyfile, name, PlyProperty{"value", 0, internal, 0, 0, 0, 0, 0})
+		} else {
+			PlyGetProperty(cplyfile, name, PlyProperty{"values", 0, internal, 16, 1, 0, PLY_INT, 8})
+		}
+		for i := 0; i < num_elems; i++ {
+			var record [recordSize]byte
+			PlyGetElement(cplyfile, &record, recordSize)
+			if name == "scalar" {
+				values = append(values, getBinaryItem(record[:], internal, binary.LittleEndian))
+				continue
+			}
+			n := int(int32(binary.LittleEndian.Uint32(record[8:])))
+			list := []float64{}
+			if n > 0 {
+				/* the list is allocated by the C library */
+				size := TypeSize(internal)
+				raw := unsafe.Slice((*byte)(*(*unsafe.Pointer)(unsafe.Pointer(&record[16]))), n*size)
+				for k := 0; k < n; k++ {
+					list = append(list, getBinaryItem(raw[k*size:], internal, binary.LittleEndian))
+				}
+			}
+			lists = append(lists, list)
+		}
+	}
+	return values, lists
+}
+
+/* TestCConformance writes and reads every pair of external and internal types with the C library, in every file format, using the values that both types hold exactly. Files in ASCII and little endian are also read by DecodeMesh, and written by EncodeMesh and read by the C library; the C library writes binary files in the byte order of the machine, whatever the header says. */
+func TestCConformance(t *testing.T) {
+	one := uint16(1)
+	if *(*byte)(unsafe.Pointer(&one)) != 1 {
+		t.Skip("the C record layout of these tests assumes a little endian machine")
+	}
+	dir := t.TempDir()
+	for _, format := range allFormats {
+		for _, external := range allTypes {
+			for _, internal := range allTypes {
+				format, external, internal := format, external, internal
+				name := formatNames[format] + "/" + TypeName(external) + "_to_" + TypeName(internal)
+				t.Run(name, func(t *testing.T) {
+					var values []float64
+					for _, v := range append(boundaryValues(external), boundaryValues(internal)...) {
+						if !representable(v, external) || !representable(v, internal) {
+							continue
+						}
+						values = append(values, v)
+					}
+					lists := testLists(values)
+					count_external := countTypes[(external+internal)%len(countTypes)]
+
+					path := filepath.Join(dir, fmt.Sprintf("%d_%d_%d.ply", format, external, internal))
+					writeCFile(path, format, external, internal, count_external, values, lists)
+					got_values, got_lists := readCFile(t, path, internal)
+					checkValues(t, "C scalar", got_values, values, sameValue)
+					checkLists(t, "C list", got_lists, lists, sameValue)
+
+					m, err := ReadMesh(path)
+					if err != nil {
+						t.Fatal(err)
+					}
+					elem := m.Element("list")
+					if prop := elem.Property("values"); prop.Type != external || prop.CountType != count_external {
+						t.Errorf("DecodeMesh: list property %+v", prop)
+					}
+					checkValues(t, "DecodeMesh scalar", m.Element("scalar").Property("value").Data, values, sameValue)
+					checkLists(t, "DecodeMesh list", elem.Property("values").Lists, lists, sameValue)
+
+					/* EncodeMesh prints the shortest representation, which is read back exactly */
+					m = NewMesh(format)
+					copy(m.AddElement("scalar", len(values)).AddProperty("value", external).Data, values)
+					copy(m.AddElement("list", len(lists)).AddListProperty("values", count_external, external).Lists, lists)
+					if err := WriteMesh(path, m); err != nil {
+						t.Fatal(err)
+					}
+					got_values, got_lists = readCFile(t, path, internal)
+					checkValues(t, "EncodeMesh scalar", got_values, values, sameValue)
+					checkLists(t, "EncodeMesh list", got_lists, lists, sameValue)
+				})
+			}
+		}
+	}
+}
+
+/* TestASCIIFloatText checks that the C library and EncodeMesh print floating point values with the fewest digits that read back exactly, and that EncodeMeshOptions prints fixed decimals. */
+func TestASCIIFloatText(t *testing.T) {
+	type point struct{ X, Y float64 }
+	values := []point{{581234.567891, 0.1}, {4.5e-7, 16777216}, {-1234567.0625, 3.4028234663852886e38}}
+	const body = "581234.567891 0.1\n4.5e-07 1.6777216e+07\n-1.2345670625e+06 3.4028235e+38\n"
+
+	path := filepath.Join(t.TempDir(), "utm.ply")
+	f, err := CreatePlyFile(path, []string{"vertex"}, PLY_ASCII)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.ElementCount("vertex", len(values))
+	f.DescribeProperty("vertex", PlyProperty{"x", PLY_DOUBLE, PLY_DOUBLE, int(unsafe.Offsetof(point{}.X)), 0, 0, 0, 0})
+	f.DescribeProperty("vertex", PlyProperty{"y", PLY_FLOAT, PLY_DOUBLE, int(unsafe.Offsetof(point{}.Y)), 0, 0, 0, 0})
+	f.HeaderComplete()
+	f.PutElementSetup("vertex")
+	for _, p := range values {
+		f.PutElement(p)
+	}
+	f.Close()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if text := string(data); !strings.HasSuffix(strings.ReplaceAll(text, " \n", "\n"), "end_header\n"+body) {
+		t.Errorf("C library wrote\n%s", text)
+	}
+
+	m, err := ReadMesh(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if x := m.Element("vertex").Property("x").Data; x[0] != 581234.567891 || x[2] != -1234567.0625 {
+		t.Errorf("x = %v", x)
+	}
+	var buf bytes.Buffer
+	if err := EncodeMesh(&buf, m); err != nil || !strings.HasSuffix(buf.String(), "end_header\n"+body) {
+		t.Errorf("EncodeMesh wrote\n%s (%v)", buf.String(), err)
+	}
+
+	buf.Reset()
+	opts := WriteOptions{Decimals: map[string]int{"vertex.x": 3, "vertex.y": 0}}
+	if err := EncodeMeshOptions(&buf, m, opts); err != nil || !strings.HasSuffix(buf.String(),
+		"end_header\n581234.568 0\n0.000 16777216\n-1234567.062 340282346638528859811704183484516925440\n") {
+		t.Errorf("EncodeMeshOptions wrote\n%s (%v)", buf.String(), err)
+	}
+}
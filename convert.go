@@ -0,0 +1,123 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plyfile
+
+import (
+	"bufio"
+	"io"
+	"math"
+	"os"
+	"strconv"
+)
+
+/* ConvertPolicy selects what happens to a value that doesn't fit the type it is stored as: a Mesh value written as its property type, an ASCII word read as its property type, or, in the C library, a value copied between the file type and the type of the user's struct. The values match PLY_CONVERT_* in lib/ply.h. */
+type ConvertPolicy int
+
+const (
+	ConvertDefault              ConvertPolicy = iota /* ConvertWrap when writing and in the C library; ASCII words outside the range of their type are errors when reading */
+	ConvertWrap                                      /* fractions are truncated and integers wrap around, as C casts do; floats overflow to infinity */
+	ConvertSaturate                                  /* fractions are truncated and values are clamped to the range of the type */
+	ConvertErrorOnOverflow                           /* values outside the range of the type are a *ConvertError; fractions are truncated */
+	ConvertErrorOnPrecisionLoss                      /* as ConvertErrorOnOverflow, and values that change when converted are a *ConvertError too, e.g. 300.7 as uchar or 16777217 as float */
+)
+
+/* reasons of a ConvertError */
+const (
+	reasonOverflow  = "out of range"
+	reasonPrecision = "loses precision"
+)
+
+/* WriteOptions controls how a Mesh is written by WriteMeshOptions, EncodeMeshOptions and CreateOptions. The zero value writes as WriteMesh and EncodeMesh do. */
+type WriteOptions struct {
+	Convert  ConvertPolicy  /* what happens to values that don't fit their property type */
+	Decimals map[string]int /* fixed number of decimals of float and double properties in ASCII files, by "element.property", e.g. "vertex.x"; other properties have the fewest digits that read back exactly */
+}
+
+/* WriteMeshOptions writes the mesh to a new PLY file called filename, using the given options. */
+func WriteMeshOptions(filename string, m *Mesh, opts WriteOptions) error {
+	f, err := os.Create(filename)
+	if err != nil {
+		return err
+	}
+	if err := EncodeMeshOptions(f, m, opts); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+/* EncodeMeshOptions writes the mesh, header and data, to w using the given options. */
+func EncodeMeshOptions(w io.Writer, m *Mesh, opts WriteOptions) error {
+	if err := m.Validate(); err != nil {
+		return err
+	}
+	bw := bufio.NewWriter(w)
+	if err := encodeHeader(bw, m); err != nil {
+		return err
+	}
+	if err := encodeBody(bw, m, &opts); err != nil {
+		return err
+	}
+	return bw.Flush()
+}
+
+/* convertValue converts v to a PLY_* type following policy, and returns the value as it is stored, or the reason it can't be. */
+func convertValue(v float64, typ int, policy ConvertPolicy) (float64, string) {
+	switch {
+	case typ == PLY_DOUBLE:
+		return v, ""
+	case typ == PLY_FLOAT:
+		f := float64(float32(v))
+		if math.IsInf(f, 0) && !math.IsInf(v, 0) {
+			switch policy {
+			case ConvertSaturate:
+				return math.Copysign(math.MaxFloat32, v), ""
+			case ConvertErrorOnOverflow, ConvertErrorOnPrecisionLoss:
+				return 0, reasonOverflow
+			}
+		}
+		if policy == ConvertErrorOnPrecisionLoss && f != v && !math.IsNaN(v) && !sameDecimal32(v) {
+			return 0, reasonPrecision
+		}
+		return f, ""
+	}
+
+	if policy == ConvertDefault || policy == ConvertWrap {
+		return float64(storeInt(v, typ)), ""
+	}
+	lo, hi := typeRange(typ)
+	t := math.Trunc(v)
+	if math.IsNaN(v) || t < lo || t > hi {
+		if policy != ConvertSaturate {
+			return 0, reasonOverflow
+		}
+		if math.IsNaN(v) {
+			return 0, ""
+		}
+		return math.Max(lo, math.Min(hi, t)), ""
+	}
+	if policy == ConvertErrorOnPrecisionLoss && t != v {
+		return 0, reasonPrecision
+	}
+	return t, ""
+}
+
+/* sameDecimal32 reports whether the shortest decimal form of v as a float is v, e.g. for 0.1, so that a float written as text isn't taken to lose precision. The C library applies the same rule (same_decimal32 in lib/plyfile.c). */
+func sameDecimal32(v float64) bool {
+	d, err := strconv.ParseFloat(strconv.FormatFloat(v, 'g', -1, 32), 64)
+	return err == nil && d == v
+}
@@ -0,0 +1,271 @@
+package plyfile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"unsafe"
+)
+
+func TestConvertValue(t *testing.T) {
+	const (
+		P = reasonPrecision
+		O = reasonOverflow
+	)
+	policies := []ConvertPolicy{ConvertWrap, ConvertSaturate, ConvertErrorOnOverflow, ConvertErrorOnPrecisionLoss}
+	for _, test := range []struct {
+		v       float64
+		typ     int
+		want    [4]float64 /* per policy, NaN for an error */
+		reasons [4]string
+	}{
+		{300.7, PLY_UCHAR, [4]float64{44, 255, 0, 0}, [4]string{"", "", O, O}},
+		{2.5, PLY_UCHAR, [4]float64{2, 2, 2, 0}, [4]string{"", "", "", P}},
+		{-1, PLY_USHORT, [4]float64{65535, 0, 0, 0}, [4]string{"", "", O, O}},
+		{-129, PLY_CHAR, [4]float64{127, -128, 0, 0}, [4]string{"", "", O, O}},
+		{70000, PLY_SHORT, [4]float64{4464, 32767, 0, 0}, [4]string{"", "", O, O}},
+		{math.NaN(), PLY_INT, [4]float64{0, 0, 0, 0}, [4]string{"skip", "", O, O}},
+		{1e39, PLY_FLOAT, [4]float64{math.Inf(1), math.MaxFloat32, 0, 0}, [4]string{"", "", O, O}},
+		{-1e39, PLY_FLOAT, [4]float64{math.Inf(-1), -math.MaxFloat32, 0, 0}, [4]string{"", "", O, O}},
+		{16777217, PLY_FLOAT, [4]float64{16777216, 16777216, 16777216, 0}, [4]string{"", "", "", P}},
+		{0.1, PLY_FLOAT, [4]float64{float64(float32(0.1)), float64(float32(0.1)), float64(float32(0.1)), float64(float32(0.1))}, [4]string{}},
+		{1e300, PLY_DOUBLE, [4]float64{1e300, 1e300, 1e300, 1e300}, [4]string{}},
+	} {
+		for k, policy := range policies {
+			if test.reasons[k] == "skip" {
+				continue
+			}
+			got, reason := convertValue(test.v, test.typ, policy)
+			if reason != test.reasons[k] || (reason == "" && got != test.want[k]) {
+				t.Errorf("convertValue(%v, %s, %d) = %v, %q; want %v, %q", test.v, TypeName(test.typ), policy, got, reason, test.want[k], test.reasons[k])
+			}
+		}
+	}
+}
+
+/* convertMesh returns a mesh whose vertex 2 has a red value of 300.7. */
+func convertMesh(format int) *Mesh {
+	m := NewMesh(format)
+	vertex := m.AddElement("vertex", 3)
+	copy(vertex.AddProperty("x", PLY_FLOAT).Data, []float64{0.1, 0.2, 0.3})
+	copy(vertex.AddProperty("red", PLY_UCHAR).Data, []float64{1, 255, 300.7})
+	return m
+}
+
+func TestEncodeConvert(t *testing.T) {
+	for _, format := range []int{PLY_ASCII, PLY_BINARY_LE} {
+		for policy, want := range map[ConvertPolicy]float64{ConvertDefault: 44, ConvertWrap: 44, ConvertSaturate: 255} {
+			var buf bytes.Buffer
+			if err := EncodeMeshOptions(&buf, convertMesh(format), WriteOptions{Convert: policy}); err != nil {
+				t.Fatal(err)
+			}
+			m, err := DecodeMesh(&buf)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := m.Element("vertex").Property("red").Data; !reflect.DeepEqual(got, []float64{1, 255, want}) {
+				t.Errorf("format %d, policy %d: red = %v", format, policy, got)
+			}
+		}
+		for _, policy := range []ConvertPolicy{ConvertErrorOnOverflow, ConvertErrorOnPrecisionLoss} {
+			err := EncodeMeshOptions(&bytes.Buffer{}, convertMesh(format), WriteOptions{Convert: policy})
+			want := &ConvertError{Element: "vertex", Index: 2, Property: "red", Value: 300.7, Type: PLY_UCHAR, Reason: "out of range"}
+			if !reflect.DeepEqual(err, want) {
+				t.Errorf("format %d, policy %d: error %v, want %v", format, policy, err, want)
+			}
+		}
+	}
+
+	err := EncodeMeshOptions(&bytes.Buffer{}, convertMesh(PLY_ASCII), WriteOptions{Convert: ConvertErrorOnPrecisionLoss})
+	if err == nil || err.Error() != "plyfile: element 'vertex' 2, property 'red': 300.7 out of range as uchar" {
+		t.Errorf("error %v", err)
+	}
+	m := convertMesh(PLY_BINARY_BE)
+	m.Elements[0].Properties[1].Data[2] = 2
+	m.Elements[0].Properties[0].Data[1] = 0.123456789
+	err = EncodeMeshOptions(&bytes.Buffer{}, m, WriteOptions{Convert: ConvertErrorOnPrecisionLoss})
+	if err == nil || err.Error() != "plyfile: element 'vertex' 1, property 'x': 0.123456789 loses precision as float" {
+		t.Errorf("error %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "convert.ply")
+	f, err := CreateOptions(path, convertMesh(PLY_ASCII), WriteOptions{Convert: ConvertErrorOnOverflow})
+	if err != nil {
+		t.Fatal(err)
+	}
+	var convert_err *ConvertError
+	if err := f.WriteElement(convertMesh(PLY_ASCII).Elements[0]); !errors.As(err, &convert_err) || convert_err.Index != 2 {
+		t.Errorf("File.WriteElement: %v", err)
+	}
+	f.Close()
+}
+
+func TestDecodeConvert(t *testing.T) {
+	const header = "ply\nformat ascii 1.0\nelement vertex 2\nproperty uchar red\nproperty float x\nelement face 1\nproperty list uchar int vertex_indices\nend_header\n"
+	for _, test := range []struct {
+		body   string
+		policy ConvertPolicy
+		red    []float64
+		err    string
+	}{
+		{"1 0\n300 0\n0\n", ConvertDefault, nil, "plyfile: line 10: element 'vertex' 1, property 'red': uchar value '300' out of range"},
+		{"1 0\n300 0\n0\n", ConvertWrap, []float64{1, 44}, ""},
+		{"1 0\n300 0\n0\n", ConvertSaturate, []float64{1, 255}, ""},
+		{"1 0\n300 0\n0\n", ConvertErrorOnOverflow, nil, "plyfile: element 'vertex' 1, property 'red': 300 out of range as uchar"},
+		{"1 0\n2.5 0\n0\n", ConvertDefault, nil, "bad uchar value '2.5'"},
+		{"1 0\n2.5 0\n0\n", ConvertErrorOnOverflow, []float64{1, 2}, ""},
+		{"1 0\n2.5 0\n0\n", ConvertErrorOnPrecisionLoss, nil, "plyfile: element 'vertex' 1, property 'red': 2.5 loses precision as uchar"},
+		{"1 0.1\n2 1e39\n0\n", ConvertDefault, []float64{1, 2}, ""},
+		{"1 0.1\n2 1e39\n0\n", ConvertErrorOnPrecisionLoss, nil, "plyfile: element 'vertex' 1, property 'x': 1e+39 out of range as float"},
+		{"1 0.1\n2 0.123456789\n0\n", ConvertErrorOnPrecisionLoss, nil, "property 'x': 0.123456789 loses precision as float"},
+		{"1 0\n2 0\n1.5 0\n", ConvertErrorOnPrecisionLoss, nil, "plyfile: element 'face' 0, property 'vertex_indices': 1.5 loses precision as uchar"},
+		{"1 0\n2 0\nx\n", ConvertSaturate, nil, "bad uchar value 'x'"},
+	} {
+		m, err := DecodeMeshOptions(strings.NewReader(header+test.body), ReadOptions{Convert: test.policy})
+		if test.err != "" {
+			if err == nil || !strings.Contains(err.Error(), test.err) {
+				t.Errorf("%q, policy %d: error %v, want %q", test.body, test.policy, err, test.err)
+			}
+			continue
+		}
+		if err != nil {
+			t.Errorf("%q, policy %d: %v", test.body, test.policy, err)
+			continue
+		}
+		if got := m.Element("vertex").Property("red").Data; !reflect.DeepEqual(got, test.red) {
+			t.Errorf("%q, policy %d: red = %v, want %v", test.body, test.policy, got, test.red)
+		}
+	}
+}
+
+/* TestPlyConvert writes doubles to a uchar property with the C library, and reads them back into a uchar field, under each policy. */
+func TestPlyConvert(t *testing.T) {
+	type wide struct{ V float64 }
+	type narrow struct{ V uint8 }
+	path := filepath.Join(t.TempDir(), "convert.ply")
+	write := func(policy ConvertPolicy, external int, values ...float64) error {
+		f, err := CreatePlyFile(path, []string{"vertex"}, PLY_BINARY_LE)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		f.SetConvertPolicy(policy)
+		f.ElementCount("vertex", len(values))
+		f.DescribeProperty("vertex", PlyProperty{"v", external, PLY_DOUBLE, 0, 0, 0, 0, 0})
+		f.HeaderComplete()
+		f.PutElementSetup("vertex")
+		for _, v := range values {
+			f.PutElement(wide{v})
+		}
+		return f.ConvertError()
+	}
+	read := func(policy ConvertPolicy) ([]uint8, error) {
+		f, _, err := OpenPlyFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		f.SetConvertPolicy(policy)
+		_, n, _ := f.GetElementDescription("vertex")
+		f.GetProperty("vertex", PlyProperty{"v", 0, PLY_UCHAR, int(unsafe.Offsetof(narrow{}.V)), 0, 0, 0, 0})
+		var values []uint8
+		for i := 0; i < n; i++ {
+			var v narrow
+			f.GetElement(&v, unsafe.Sizeof(v))
+			values = append(values, v.V)
+		}
+		return values, f.ConvertError()
+	}
+
+	for policy, want := range map[ConvertPolicy][]uint8{ConvertWrap: {1, 44, 2}, ConvertSaturate: {1, 255, 2}} {
+		if err := write(policy, PLY_UCHAR, 1, 300.7, 2.2); err != nil {
+			t.Fatal(err)
+		}
+		if got, _ := read(ConvertDefault); !reflect.DeepEqual(got, want) {
+			t.Errorf("write policy %d: %v, want %v", policy, got, want)
+		}
+	}
+	want := &ConvertError{Element: "vertex", Index: 1, Property: "v", Value: 300.7, Type: PLY_UCHAR, Reason: "out of range"}
+	if err := write(ConvertErrorOnOverflow, PLY_UCHAR, 1, 300.7, 2.2, 400); !reflect.DeepEqual(err, want) {
+		t.Errorf("write error %v, want %v", err, want)
+	}
+	want = &ConvertError{Element: "vertex", Index: 2, Property: "v", Value: 2.2, Type: PLY_UCHAR, Reason: "loses precision"}
+	if err := write(ConvertErrorOnPrecisionLoss, PLY_UCHAR, 1, 2, 2.2); !reflect.DeepEqual(err, want) {
+		t.Errorf("write error %v, want %v", err, want)
+	}
+
+	if err := write(ConvertDefault, PLY_DOUBLE, 1, 300.7, -5); err != nil {
+		t.Fatal(err)
+	}
+	if got, err := read(ConvertSaturate); err != nil || !reflect.DeepEqual(got, []uint8{1, 255, 0}) {
+		t.Errorf("read saturated %v, %v", got, err)
+	}
+	want = &ConvertError{Element: "vertex", Index: 1, Property: "v", Value: 300.7, Type: PLY_UCHAR, Reason: "out of range"}
+	if _, err := read(ConvertErrorOnOverflow); !reflect.DeepEqual(err, want) {
+		t.Errorf("read error %v, want %v", err, want)
+	}
+	if _, err := read(ConvertDefault); err != nil {
+		t.Errorf("read with ConvertDefault: %v", err)
+	}
+}
+
+/* TestConvertBothReaders reads the same ASCII float values with DecodeMeshOptions and the C library, and writes the same doubles to a float property with EncodeMeshOptions and the C library, checking that both report the same values as losing precision or out of range. */
+func TestConvertBothReaders(t *testing.T) {
+	type single struct{ X float32 }
+	type double struct{ X float64 }
+	path := filepath.Join(t.TempDir(), "float.ply")
+	for _, policy := range []ConvertPolicy{ConvertErrorOnOverflow, ConvertErrorOnPrecisionLoss} {
+		for _, text := range []string{"0.1", "0.5", "16777216", "3.4028235e+38", "3.4028236e+38", "0.123456789", "16777217", "1e39"} {
+			data := "ply\nformat ascii 1.0\nelement vertex 2\nproperty float x\nend_header\n0\n" + text + "\n"
+			if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, go_err := DecodeMeshOptions(strings.NewReader(data), ReadOptions{Convert: policy})
+
+			f, _, err := OpenPlyFile(path)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.SetConvertPolicy(policy)
+			f.GetProperty("vertex", PlyProperty{"x", 0, PLY_FLOAT, 0, 0, 0, 0, 0})
+			for i := 0; i < 2; i++ {
+				var v single
+				f.GetElement(&v, unsafe.Sizeof(v))
+			}
+			c_err := f.ConvertError()
+			f.Close()
+			if !reflect.DeepEqual(c_err, go_err) {
+				t.Errorf("reading %s, policy %d: C error %v, Go error %v", text, policy, c_err, go_err)
+			}
+
+			v, _ := strconv.ParseFloat(text, 64)
+			m := NewMesh(PLY_BINARY_LE)
+			m.AddElement("vertex", 2).AddProperty("x", PLY_FLOAT).Data[1] = v
+			go_err = EncodeMeshOptions(io.Discard, m, WriteOptions{Convert: policy})
+
+			f, err = CreatePlyFile(path, []string{"vertex"}, PLY_BINARY_LE)
+			if err != nil {
+				t.Fatal(err)
+			}
+			f.SetConvertPolicy(policy)
+			f.ElementCount("vertex", 2)
+			f.DescribeProperty("vertex", PlyProperty{"x", PLY_FLOAT, PLY_DOUBLE, 0, 0, 0, 0, 0})
+			f.HeaderComplete()
+			f.PutElementSetup("vertex")
+			f.PutElement(double{0})
+			f.PutElement(double{v})
+			c_err = f.ConvertError()
+			f.Close()
+			if !reflect.DeepEqual(c_err, go_err) {
+				t.Errorf("writing %s, policy %d: C error %v, Go error %v", text, policy, c_err, go_err)
+			}
+		}
+	}
+}
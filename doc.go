@@ -49,7 +49,7 @@ From there, you should be good to go. But, just to make sure, run go test and ve
 
 Basics
 
-The structure of Turk's original code is preserved. The plyfile package makes heavy use of cgo to interface with Turk's code and use his original functions. Note that this means some memory is not tracked by the Go garbage collector. It is critical to properly close PLY files using the PlyClose function!
+The structure of Turk's original code is preserved. The plyfile package makes heavy use of cgo to interface with Turk's code and use his original functions. Note that this means some memory is not tracked by the Go garbage collector. It is critical to properly close PLY files using the PlyClose function! The lists read by PlyGetElement live in C memory owned by the file, and are freed by PlyClose (or earlier by PlyFreeLists). OpenPlyFile and CreatePlyFile return a *PlyFile, the low-level handle to the C library, which wraps the same functions as methods and has a Close method; a finalizer closes a PlyFile that is dropped without Close, as a safety net. Use PlyFile to port C code or to read into C structs; otherwise use File, described below.
 
 A comparison of ply_test.go and lib/plytest.c shows nearly identical function calls. Thus, porting a C program that uses Turk's plyfile library should be relatively straightforward. The ply_test.go file shows the process of both writing and reading a PLY file, including how to open a file, describe properties, and write and read data. The basics of writing and reading are also described below.
 
@@ -73,6 +73,47 @@ Second, we iterate through the returned element names. For each element name, we
 
 Finally, comments and object info are read from the PLY file, and returned as a slice of strings using the respective reading function.
 
+Meshes and other formats
+
+ReadMesh and WriteMesh read and write a complete PLY file into a Mesh, which stores each property of each element as a column of values. Mesh reading and writing is done in Go rather than through the C library, and returns errors instead of exiting. The Mesh is also the common representation used to convert between PLY and other formats. The obj, stl, pcd, off, xyz and pts packages convert Wavefront OBJ, STL (ASCII and binary), Point Cloud Library PCD, OFF, XYZ and Leica PTS files, the las package reads uncompressed LAS 1.2 to 1.4 point clouds, the gltf package writes glTF 2.0 (GLB or JSON) files, and the plyconv command (cmd/plyconv) exposes each converter as a subcommand:
+  plyconv obj model.obj model.ply
+
+When the format of a file isn't known, Decode detects it from the first bytes of the data and returns the mesh with the name of the format. The three PLY encodings are always available; each converter package registers its own format with RegisterFormat when it is imported, as the image formats of the standard library do:
+
+  import _ "github.com/ecopia-map/go-plyfile/obj"
+
+  m, format, err := plyfile.Decode(r)
+
+Reading and writing one element at a time
+
+A File is an open PLY file, read or written one element at a time in file order. It is the handle to use for files too large for ReadMesh; PlyFile and CPlyFile remain as the low-level interface to the C library. Open reads the header, described by Format, Version, Comments, ObjInfo and Elements, and ReadElement returns the data of each element in turn, then io.EOF. Create writes the header of a Mesh, and WriteElement writes each element, which must match the header. File implements io.Closer and holds no C memory.
+
+NewHeader starts a HeaderBuilder, which describes a header with chained calls, e.g. NewHeader(FormatBinaryLE).Element("vertex", n).Float32("x").Element("face", f).List("vertex_indices", Uint8, Int32).Build(). Names and types are checked as they are added; Build returns the first error, or a Mesh with zeroed properties ready to be filled in and written, and Create starts a File instead.
+
+The plygen command (cmd/plygen) generates Go code from the header of a sample file, for use with go generate: a struct per element with a typed field per property (slices for lists), and Read and Write functions for files with the same header in any format, with no reflection.
+
+ExpectSchema checks that a header has an expected layout, given as a Schema from ParseSchema or SchemaOf: exactly (SchemaExact), among other elements and properties (SchemaSuperset), or with types that convert without loss (SchemaConvertible). A mismatch is a *SchemaError listing the missing, extra and mismatched properties. File.Header and PlyFile.Header return the header of an open file.
+
+A ConvertPolicy, set with WriteOptions, ReadOptions or PlySetConvertPolicy, selects what happens to a value that doesn't fit its type: ConvertWrap casts as C does (the default for writes), ConvertSaturate clamps, and ConvertErrorOnOverflow and ConvertErrorOnPrecisionLoss report a *ConvertError naming the element, index and property. A float keeps a value whose shortest decimal form it holds, such as 0.1, in the Go and C code alike.
+
+ASCII floats and doubles are written with the fewest digits that read back exactly, as strconv.FormatFloat with precision -1 does, or with a fixed number of decimals per property set by WriteOptions.Decimals. Both the Go code and the C library use '.' as the decimal separator whatever the C locale.
+
+Georef describes the coordinate reference system ("crs EPSG:<code>" or "crs_wkt <wkt>" comments) and the origin subtracted from the vertex coordinates ("origin <x> <y> <z>"). Mesh.SetOrigin stores the vertices relative to an origin, and Mesh.ApplyOrigin, or reading with ReadOptions.ApplyOrigin through DecodeMeshOptions, ReadMeshOptions or OpenOptions, makes them absolute doubles again. PlyGetGeoref and PlyPutGeoref read and write the same comments with the C library.
+
+Metadata reads comment and obj_info lines as "key value" or "key=value" pairs, with typed accessors for numbers, TextureFile comments and the num_cols and num_rows of range grids. Mesh.SetMetadata and PlyPutMetadata write the lines back in their original order.
+
+Textured meshes name their images in TextureFile comments, returned by Mesh.TextureFiles and, resolved against the directory of the PLY file, by Mesh.TexturePaths. Texture coordinates are either per vertex (s and t, u and v, or texture_u and texture_v) or MeshLab's texcoord lists on the faces, with an optional texnumber per face; Mesh.ToVertexTexCoords and Mesh.ToFaceTexCoords convert between the two.
+
+Range grids, the Stanford layout of structured scans, give the num_cols and num_rows of the grid as obj_info and hold a range_grid element whose vertex_indices lists are empty or hold one vertex index. Mesh.RangeGrid rebuilds the grid, Mesh.TriangulateRangeGrid triangulates it while skipping edges longer than a maximum, and NewRangeGridMesh builds the layout from a 2D array of points.
+
+Untrusted files
+
+DecodeMeshOptions, DecodeHeaderOptions and ReadMeshOptions take a ReadOptions value that limits the element count, the total size of the file, the list length, the number of header lines and the length of comments. A file that exceeds a limit fails with a *LimitError naming the limit. DecodeOptions applies the limits to data in any registered format, OpenPlyFileOptions applies the same limits to the C library, checking MaxHeaderLines and MaxCommentLength as the header is read, and PlySetLimits applies MaxElementCount and MaxListLength to a file already open.
+
+The Mode field of ReadOptions selects how closely a file must follow the PLY layout: ParseDefault accepts any white space and \r\n line endings, ParseLenient also skips blank lines and keeps unknown header lines in Mesh.Unknown, and ParseStrict reports every deviation as a *ParseError with its line and column.
+
+Header errors are returned as a *ParseError giving the line number, the line and the reason, e.g. an unknown type or an element declared twice; OpenPlyFile returns the same kind of error from the C header parser. Errors in the data are returned as a *BodyError naming the element, its index and the property; when PlyGetElement runs out of values, PlyBodyError describes the error found by the C reader.
+
 A note about elements with list properties
 
 The currently element with list property implementation (see Face in ply_test.go) likely needs to be adjusted. The Verts [16]byte array is used to store a pointer to the vertex_indices, and stores a pointer to the vertex_indices on return. Using a 32-bit or 64-bit integer may be better, and will possibly be changed in a future release. However, the basic idea is as follows:
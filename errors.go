@@ -0,0 +1,78 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plyfile
+
+import (
+	"fmt"
+	"strconv"
+)
+
+/* ParseError describes a line of a file that doesn't follow the PLY layout, e.g. a header line declaring an unknown type. */
+type ParseError struct {
+	Line   int    /* line number, starting at 1 */
+	Column int    /* byte offset in the line, starting at 1, or 0 for the whole line */
+	Text   string /* the line, or "" at the end of the file */
+	Reason string /* e.g. "unknown type 'float3'" */
+}
+
+func (e *ParseError) Error() string {
+	msg := fmt.Sprintf("plyfile: line %d", e.Line)
+	if e.Column > 0 {
+		msg += fmt.Sprintf(", column %d", e.Column)
+	}
+	msg += ": " + e.Reason
+	if e.Text != "" {
+		msg += fmt.Sprintf(": '%s'", e.Text)
+	}
+	return msg
+}
+
+/* BodyError describes a value of the data section that can't be read. */
+type BodyError struct {
+	Element  string /* element name */
+	Index    int    /* index of the element, starting at 0 */
+	Property string /* property name, or "" if the error isn't about a single property */
+	Line     int    /* line number in ASCII files, starting at 1, or 0 in binary files */
+	Reason   string /* e.g. "too few values" */
+}
+
+func (e *BodyError) Error() string {
+	msg := "plyfile: "
+	if e.Line > 0 {
+		msg += fmt.Sprintf("line %d: ", e.Line)
+	}
+	msg += fmt.Sprintf("element '%s' %d", e.Element, e.Index)
+	if e.Property != "" {
+		msg += fmt.Sprintf(", property '%s'", e.Property)
+	}
+	return msg + ": " + e.Reason
+}
+
+/* ConvertError describes a value that doesn't fit the type it is converted to, under ConvertErrorOnOverflow or ConvertErrorOnPrecisionLoss. */
+type ConvertError struct {
+	Element  string  /* element name */
+	Index    int     /* index of the element, starting at 0 */
+	Property string  /* property name */
+	Value    float64 /* value before the conversion */
+	Type     int     /* PLY_* type converted to */
+	Reason   string  /* "out of range" or "loses precision" */
+}
+
+func (e *ConvertError) Error() string {
+	return fmt.Sprintf("plyfile: element '%s' %d, property '%s': %s %s as %s", e.Element, e.Index, e.Property,
+		strconv.FormatFloat(e.Value, 'g', -1, 64), e.Reason, TypeName(e.Type))
+}
@@ -0,0 +1,239 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plyfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+)
+
+/* File is an open PLY file, read or written one element at a time in file order. It is the handle of the package for open files: unlike CPlyFile and PlyFile, the low-level handles to the C library, it holds no C memory and its fields are hidden, so how the data is read and written is not part of its API. */
+type File struct {
+	header   *Mesh /* format, version, comments and elements, without data */
+	f        *os.File
+	br       *bufio.Reader /* set when reading */
+	bw       *bufio.Writer /* set when writing */
+	opts     ReadOptions
+	wopts    WriteOptions
+	next     int     /* index of the next element to read or write */
+	line_num int     /* last line read, in ASCII files */
+	origin   *Georef /* georeferencing whose origin is added to the vertices read, with ReadOptions.ApplyOrigin */
+}
+
+var _ io.Closer = (*File)(nil)
+
+/* Open opens a PLY file for reading and reads its header. */
+func Open(filename string) (*File, error) {
+	return OpenOptions(filename, ReadOptions{})
+}
+
+/* OpenOptions opens a PLY file for reading with the given options, and reads its header. With ReadOptions.ApplyOrigin, ReadElement adds the origin of the file to the vertex x, y and z, which become doubles, and ReadMesh leaves out the origin comment, as DecodeMeshOptions does; the header returned by Header and Elements is still that of the file. */
+func OpenOptions(filename string, opts ReadOptions) (*File, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	br := opts.newReader(f)
+	header, line_num, err := decodeHeader(br, &opts)
+	if err != nil {
+		f.Close()
+		return nil, err
+	}
+	file := &File{header: header, f: f, br: br, opts: opts, line_num: line_num}
+	if g := header.Georef(); opts.ApplyOrigin && g != nil && g.HasOrigin {
+		file.origin = g
+	}
+	return file, nil
+}
+
+/* Create creates a PLY file described by header and writes the header. The data of header is ignored; each element is then written with WriteElement, in the order of header.Elements. */
+func Create(filename string, header *Mesh) (*File, error) {
+	return CreateOptions(filename, header, WriteOptions{})
+}
+
+/* CreateOptions creates a PLY file described by header, as Create, whose elements are written with the given options. */
+func CreateOptions(filename string, header *Mesh, opts WriteOptions) (*File, error) {
+	if err := header.validateHeader(); err != nil {
+		return nil, err
+	}
+	f, err := os.Create(filename)
+	if err != nil {
+		return nil, err
+	}
+	file := &File{header: describeMesh(header), f: f, bw: bufio.NewWriter(f), wopts: opts}
+	if file.header.Version == 0 {
+		file.header.Version = 1.0
+	}
+	if err := encodeHeader(file.bw, file.header); err != nil {
+		f.Close()
+		return nil, err
+	}
+	return file, nil
+}
+
+/* Format returns PLY_ASCII, PLY_BINARY_BE or PLY_BINARY_LE. */
+func (file *File) Format() int {
+	return file.header.Format
+}
+
+/* Version returns the version number of the file. */
+func (file *File) Version() float32 {
+	return file.header.Version
+}
+
+/* Comments returns the comment lines of the header. */
+func (file *File) Comments() []string {
+	return append([]string{}, file.header.Comments...)
+}
+
+/* ObjInfo returns the obj_info lines of the header. */
+func (file *File) ObjInfo() []string {
+	return append([]string{}, file.header.ObjInfo...)
+}
+
+/* Header returns a copy of the header, without data, e.g. to check it with ExpectSchema. */
+func (file *File) Header() *Mesh {
+	return describeMesh(file.header)
+}
+
+/* Elements returns the elements and properties declared by the header, without data. */
+func (file *File) Elements() []*Element {
+	return describeMesh(file.header).Elements
+}
+
+/* ReadElement reads the data of the next element. It returns io.EOF after the last element. */
+func (file *File) ReadElement() (*Element, error) {
+	if file.br == nil {
+		return nil, fmt.Errorf("plyfile: file not open for reading")
+	}
+	if file.next >= len(file.header.Elements) {
+		return nil, io.EOF
+	}
+	elem := describeElement(file.header.Elements[file.next])
+	if err := decodeElement(file.br, file.header.Format, elem, &file.opts, &file.line_num); err != nil {
+		return nil, err
+	}
+	if file.origin != nil && elem.Name == "vertex" {
+		moveElement(elem, file.origin.Origin, PLY_DOUBLE)
+	}
+	file.next++
+	return elem, nil
+}
+
+/* ReadMesh reads the data of the remaining elements, and returns them in a Mesh with the header of the file. */
+func (file *File) ReadMesh() (*Mesh, error) {
+	m := describeMesh(file.header)
+	m.Elements = m.Elements[:0]
+	if file.origin != nil {
+		g := *file.origin
+		g.Origin = [3]float64{}
+		g.HasOrigin = false
+		m.SetGeoref(&g)
+	}
+	for {
+		elem, err := file.ReadElement()
+		if err == io.EOF {
+			return m, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		m.Elements = append(m.Elements, elem)
+	}
+}
+
+/* WriteElement writes the data of the next element, which must match the element declared by the header: same name, count and properties. */
+func (file *File) WriteElement(elem *Element) error {
+	if file.bw == nil {
+		return fmt.Errorf("plyfile: file not open for writing")
+	}
+	if file.next >= len(file.header.Elements) {
+		return fmt.Errorf("plyfile: element '%s' not declared by the header", elem.Name)
+	}
+	want := file.header.Elements[file.next]
+	if elem.Name != want.Name || elem.Count != want.Count || len(elem.Properties) != len(want.Properties) {
+		return fmt.Errorf("plyfile: element '%s' %d doesn't match element '%s' %d of the header", elem.Name, elem.Count, want.Name, want.Count)
+	}
+	for i, prop := range elem.Properties {
+		if w := want.Properties[i]; prop.Name != w.Name || prop.Type != w.Type || prop.IsList != w.IsList || (prop.IsList && prop.CountType != w.CountType) {
+			return fmt.Errorf("plyfile: property '%s' of element '%s' doesn't match the header", prop.Name, elem.Name)
+		}
+	}
+	if err := elem.validateData(); err != nil {
+		return err
+	}
+	if err := encodeElement(file.bw, file.header.Format, elem, &file.wopts); err != nil {
+		return err
+	}
+	file.next++
+	return nil
+}
+
+/* WriteMesh writes the data of the remaining elements from m, by name. */
+func (file *File) WriteMesh(m *Mesh) error {
+	for file.bw != nil && file.next < len(file.header.Elements) {
+		name := file.header.Elements[file.next].Name
+		elem := m.Element(name)
+		if elem == nil {
+			return fmt.Errorf("plyfile: mesh has no element '%s'", name)
+		}
+		if err := file.WriteElement(elem); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* Close closes the file. When writing, it flushes the data, and fails if some elements of the header weren't written. Closing a file twice returns os.ErrClosed. */
+func (file *File) Close() error {
+	if file.f == nil {
+		return os.ErrClosed
+	}
+	var err error
+	if file.bw != nil {
+		err = file.bw.Flush()
+		if err == nil && file.next < len(file.header.Elements) {
+			err = fmt.Errorf("plyfile: element '%s' not written", file.header.Elements[file.next].Name)
+		}
+	}
+	if close_err := file.f.Close(); err == nil {
+		err = close_err
+	}
+	file.f = nil
+	return err
+}
+
+/* describeMesh returns a copy of the header of m, without data. */
+func describeMesh(m *Mesh) *Mesh {
+	d := &Mesh{Format: m.Format, Version: m.Version, Comments: append([]string(nil), m.Comments...), ObjInfo: append([]string(nil), m.ObjInfo...),
+		Unknown: append([]string(nil), m.Unknown...)}
+	for _, elem := range m.Elements {
+		d.Elements = append(d.Elements, describeElement(elem))
+	}
+	return d
+}
+
+/* describeElement returns a copy of elem and its properties, without data. */
+func describeElement(elem *Element) *Element {
+	d := &Element{Name: elem.Name, Count: elem.Count}
+	for _, prop := range elem.Properties {
+		d.Properties = append(d.Properties, &Property{Name: prop.Name, Type: prop.Type, IsList: prop.IsList, CountType: prop.CountType})
+	}
+	return d
+}
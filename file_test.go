@@ -0,0 +1,111 @@
+package plyfile
+
+import (
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+/* TestFile writes the cube one element at a time with Create and reads it back with Open, in every format. */
+func TestFile(t *testing.T) {
+	dir := t.TempDir()
+	for _, format := range allFormats {
+		path := filepath.Join(dir, formatNames[format]+".ply")
+		cube := cubeMesh(format)
+		w, err := Create(path, cube)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, elem := range cube.Elements {
+			if err := w.WriteElement(elem); err != nil {
+				t.Fatal(err)
+			}
+		}
+		if err := w.WriteElement(cube.Elements[0]); err == nil {
+			t.Errorf("%s: WriteElement past the last element succeeded", formatNames[format])
+		}
+		if err := w.Close(); err != nil {
+			t.Fatal(err)
+		}
+
+		r, err := Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if r.Format() != format || r.Version() != 1 || !reflect.DeepEqual(r.Comments(), cube.Comments) || !reflect.DeepEqual(r.ObjInfo(), cube.ObjInfo) {
+			t.Errorf("%s: header %d %v %q %q", formatNames[format], r.Format(), r.Version(), r.Comments(), r.ObjInfo())
+		}
+		elems := r.Elements()
+		if len(elems) != 2 || elems[1].Name != "face" || elems[1].Count != 6 || elems[1].Properties[1].Lists != nil {
+			t.Errorf("%s: elements %+v", formatNames[format], elems)
+		}
+		vertex, err := r.ReadElement()
+		if err != nil || !reflect.DeepEqual(vertex, cube.Elements[0]) {
+			t.Errorf("%s: vertex %+v (%v)", formatNames[format], vertex, err)
+		}
+		m, err := r.ReadMesh()
+		if err != nil || len(m.Elements) != 1 || !reflect.DeepEqual(m.Elements[0], cube.Elements[1]) {
+			t.Errorf("%s: face %+v (%v)", formatNames[format], m, err)
+		}
+		if _, err := r.ReadElement(); err != io.EOF {
+			t.Errorf("%s: ReadElement after the last element = %v, want io.EOF", formatNames[format], err)
+		}
+		if err := r.Close(); err != nil {
+			t.Fatal(err)
+		}
+		if err := r.Close(); err != os.ErrClosed {
+			t.Errorf("%s: second Close = %v, want os.ErrClosed", formatNames[format], err)
+		}
+
+		r, err = Open(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		m, err = r.ReadMesh()
+		r.Close()
+		if err != nil || !reflect.DeepEqual(m, cube) {
+			t.Errorf("%s: ReadMesh differs from the cube (%v)", formatNames[format], err)
+		}
+	}
+}
+
+/* TestFileWriteErrors checks that WriteElement rejects elements that don't match the header, and that Close reports missing elements. */
+func TestFileWriteErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "errors.ply")
+	cube := cubeMesh(PLY_ASCII)
+	w, err := Create(path, cube)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.WriteElement(cube.Elements[1]); err == nil {
+		t.Errorf("WriteElement of the wrong element succeeded")
+	}
+	vertex := cubeMesh(PLY_ASCII).Elements[0]
+	vertex.Properties[0].Type = PLY_DOUBLE
+	if err := w.WriteElement(vertex); err == nil {
+		t.Errorf("WriteElement with the wrong property type succeeded")
+	}
+	vertex = cubeMesh(PLY_ASCII).Elements[0]
+	vertex.Properties[0].Data = vertex.Properties[0].Data[1:]
+	if err := w.WriteElement(vertex); err == nil {
+		t.Errorf("WriteElement with missing values succeeded")
+	}
+	if err := w.WriteElement(cube.Elements[0]); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := w.ReadElement(); err == nil {
+		t.Errorf("ReadElement of a file open for writing succeeded")
+	}
+	if err := w.Close(); err == nil {
+		t.Errorf("Close with an element missing succeeded")
+	}
+
+	if _, err := Create(path, &Mesh{Format: 7}); err == nil {
+		t.Errorf("Create with a bad format succeeded")
+	}
+	if _, err := Open(filepath.Join(t.TempDir(), "missing.ply")); err == nil {
+		t.Errorf("Open of a missing file succeeded")
+	}
+}
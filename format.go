@@ -0,0 +1,147 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plyfile
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"io"
+	"sync"
+)
+
+/* SniffLen is the number of bytes from the start of a file that are passed to the sniff functions of the registered formats. */
+const SniffLen = 4096
+
+/* ErrFormat is returned by Decode when the data does not match any registered format. */
+var ErrFormat = errors.New("plyfile: unknown format")
+
+/* format is a registered geometry format */
+type format struct {
+	name          string
+	sniff         func(prefix []byte) bool
+	decode        func(io.Reader) (*Mesh, error)
+	decodeOptions func(io.Reader, ReadOptions) (*Mesh, error) /* nil if the format takes no options */
+}
+
+var (
+	formatsMu sync.Mutex
+	formats   []format
+)
+
+/* RegisterFormat registers a format for use by Decode and DecodeOptions. Name is the name of the format, like "obj" or "ply_ascii". Sniff reports whether prefix, the first SniffLen bytes of the data (or all of it, if shorter), looks like the format. Decode reads the data into a Mesh. Formats are tried in the order in which they are registered, so sniff functions should be specific. Packages providing a format usually call RegisterFormat in an init function, like the image formats of the standard library. */
+func RegisterFormat(name string, sniff func(prefix []byte) bool, decode func(io.Reader) (*Mesh, error)) {
+	formatsMu.Lock()
+	formats = append(formats, format{name, sniff, decode, nil})
+	formatsMu.Unlock()
+}
+
+/* RegisterFormatOptions registers a format for use by Decode and DecodeOptions, as RegisterFormat does, whose decode function takes the ReadOptions passed to DecodeOptions and enforces its limits while reading. Decode passes the zero ReadOptions. */
+func RegisterFormatOptions(name string, sniff func(prefix []byte) bool, decode func(io.Reader, ReadOptions) (*Mesh, error)) {
+	formatsMu.Lock()
+	formats = append(formats, format{name, sniff, func(r io.Reader) (*Mesh, error) { return decode(r, ReadOptions{}) }, decode})
+	formatsMu.Unlock()
+}
+
+/* Decode reads geometry in any registered format from r. It returns the mesh and the name of the format that was detected. The three PLY encodings are always registered; other formats are registered by importing their packages, e.g. import _ "github.com/ecopia-map/go-plyfile/obj". */
+func Decode(r io.Reader) (*Mesh, string, error) {
+	return DecodeOptions(r, ReadOptions{})
+}
+
+/* DecodeOptions reads geometry in any registered format from r, as Decode does, using the given options. MaxTotalBytes limits the data read in every format. Formats registered with RegisterFormatOptions, such as PLY, apply the other options while reading; for the others, the element counts and list lengths of the decoded mesh are checked against MaxElementCount and MaxListLength. */
+func DecodeOptions(r io.Reader, opts ReadOptions) (*Mesh, string, error) {
+	if opts.MaxTotalBytes > 0 {
+		r = &limitReader{r: r, n: opts.MaxTotalBytes, max: opts.MaxTotalBytes}
+	}
+	br := bufio.NewReaderSize(r, SniffLen)
+	prefix, err := br.Peek(SniffLen)
+	if err != nil && err != io.EOF && err != bufio.ErrBufferFull {
+		return nil, "", err
+	}
+
+	formatsMu.Lock()
+	registered := formats
+	formatsMu.Unlock()
+
+	for _, f := range registered {
+		if !f.sniff(prefix) {
+			continue
+		}
+		if f.decodeOptions != nil {
+			m, err := f.decodeOptions(br, opts)
+			return m, f.name, err
+		}
+		m, err := f.decode(br)
+		if err != nil {
+			return m, f.name, err
+		}
+		if err := opts.checkMesh(m); err != nil {
+			return nil, f.name, err
+		}
+		return m, f.name, nil
+	}
+	return nil, "", ErrFormat
+}
+
+func init() {
+	for _, f := range []struct {
+		name   string
+		format int
+	}{
+		{"ply_ascii", PLY_ASCII},
+		{"ply_binary_little_endian", PLY_BINARY_LE},
+		{"ply_binary_big_endian", PLY_BINARY_BE},
+	} {
+		format := f.format
+		RegisterFormatOptions(f.name, func(prefix []byte) bool {
+			return sniffPLY(prefix) == format
+		}, DecodeMeshOptions)
+	}
+}
+
+/* sniffPLY returns the format of the PLY data starting with prefix, or 0 if prefix doesn't start like a PLY file. Like ply_open_and_read_header, it requires the "ply" magic on the first line and looks for the format line, skipping comments and obj_info lines that precede it. */
+func sniffPLY(prefix []byte) int {
+	lines := bytes.Split(prefix, []byte("\n"))
+	if string(bytes.TrimRight(lines[0], "\r")) != "ply" {
+		return 0
+	}
+	/* the last line may be cut off */
+	for _, line := range lines[1 : len(lines)-1] {
+		words := bytes.Fields(line)
+		if len(words) == 0 {
+			return 0
+		}
+		switch string(words[0]) {
+		case "comment", "obj_info":
+			continue
+		case "format":
+			if len(words) != 3 {
+				return 0
+			}
+			switch string(words[1]) {
+			case "ascii":
+				return PLY_ASCII
+			case "binary_little_endian":
+				return PLY_BINARY_LE
+			case "binary_big_endian":
+				return PLY_BINARY_BE
+			}
+		}
+		return 0
+	}
+	return 0
+}
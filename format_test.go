@@ -0,0 +1,145 @@
+package plyfile_test
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"strings"
+	"testing"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+	_ "github.com/ecopia-map/go-plyfile/las"
+	"github.com/ecopia-map/go-plyfile/obj"
+	"github.com/ecopia-map/go-plyfile/off"
+	"github.com/ecopia-map/go-plyfile/pcd"
+	"github.com/ecopia-map/go-plyfile/pts"
+	"github.com/ecopia-map/go-plyfile/stl"
+	"github.com/ecopia-map/go-plyfile/xyz"
+)
+
+/* triangle returns a mesh with a single triangle. */
+func triangle() *plyfile.Mesh {
+	m := plyfile.NewMesh(plyfile.PLY_ASCII)
+	vertex := m.AddElement("vertex", 3)
+	x := vertex.AddProperty("x", plyfile.PLY_FLOAT)
+	y := vertex.AddProperty("y", plyfile.PLY_FLOAT)
+	vertex.AddProperty("z", plyfile.PLY_FLOAT)
+	x.Data[1], y.Data[2] = 1, 1
+	face := m.AddElement("face", 1)
+	face.AddListProperty("vertex_indices", plyfile.PLY_UCHAR, plyfile.PLY_INT).Lists = [][]float64{{0, 1, 2}}
+	return m
+}
+
+/* lasFile returns a minimal LAS 1.2 file without points. */
+func lasFile() []byte {
+	buf := make([]byte, 227)
+	copy(buf, "LASF")
+	buf[24], buf[25] = 1, 2
+	binary.LittleEndian.PutUint16(buf[94:], 227)
+	binary.LittleEndian.PutUint32(buf[96:], 227)
+	binary.LittleEndian.PutUint16(buf[105:], 20)
+	return buf
+}
+
+func TestDecodeFormats(t *testing.T) {
+	files := map[string]func(*bytes.Buffer) error{
+		"ply_ascii": func(buf *bytes.Buffer) error { return plyfile.EncodeMesh(buf, triangle()) },
+		"ply_binary_little_endian": func(buf *bytes.Buffer) error {
+			m := triangle()
+			m.Format = plyfile.PLY_BINARY_LE
+			m.Comments = []string{"comments before the format line are allowed"}
+			return plyfile.EncodeMesh(buf, m)
+		},
+		"ply_binary_big_endian": func(buf *bytes.Buffer) error {
+			m := triangle()
+			m.Format = plyfile.PLY_BINARY_BE
+			return plyfile.EncodeMesh(buf, m)
+		},
+		"obj":       func(buf *bytes.Buffer) error { return obj.Encode(buf, triangle()) },
+		"stl":       func(buf *bytes.Buffer) error { return stl.Encode(buf, triangle()) },
+		"stl_ascii": func(buf *bytes.Buffer) error { return stl.EncodeASCII(buf, triangle(), "t") },
+		"off":       func(buf *bytes.Buffer) error { return off.Encode(buf, triangle()) },
+		"xyz":       func(buf *bytes.Buffer) error { return xyz.Encode(buf, triangle()) },
+		"pts":       func(buf *bytes.Buffer) error { return pts.Encode(buf, triangle()) },
+		"pcd":       func(buf *bytes.Buffer) error { return pcd.Encode(buf, triangle(), pcd.DataBinary) },
+		"las":       func(buf *bytes.Buffer) error { _, err := buf.Write(lasFile()); return err },
+	}
+
+	for name, encode := range files {
+		var buf bytes.Buffer
+		if err := encode(&buf); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		m, format, err := plyfile.Decode(&buf)
+		if err != nil {
+			t.Errorf("%s: %v", name, err)
+			continue
+		}
+		if want := strings.TrimSuffix(name, "_ascii"); format != want && format != name {
+			t.Errorf("detected %s as %s", name, format)
+		}
+		if m.Element("vertex") == nil {
+			t.Errorf("%s: no vertex element", name)
+		}
+	}
+}
+
+func TestDecodeUnknown(t *testing.T) {
+	inputs := []string{
+		"",
+		"hello world\n",
+		"ply\nformat binary_middle_endian 1.0\nend_header\n",
+		"ply\nelement vertex 0\nformat ascii 1.0\nend_header\n",
+	}
+	for _, input := range inputs {
+		if _, format, err := plyfile.Decode(strings.NewReader(input)); err != plyfile.ErrFormat {
+			t.Errorf("%q: detected %q (%v), want ErrFormat", input, format, err)
+		}
+	}
+}
+
+/* TestDecodeOptions checks that DecodeOptions applies the limits to PLY files while reading them, and to the meshes decoded from other formats. */
+func TestDecodeOptions(t *testing.T) {
+	var ply, obj_file bytes.Buffer
+	if err := plyfile.EncodeMesh(&ply, triangle()); err != nil {
+		t.Fatal(err)
+	}
+	if err := obj.Encode(&obj_file, triangle()); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		data  []byte
+		opts  plyfile.ReadOptions
+		limit string
+	}{
+		{"ply element count", ply.Bytes(), plyfile.ReadOptions{MaxElementCount: 2}, "MaxElementCount"},
+		{"ply list length", ply.Bytes(), plyfile.ReadOptions{MaxListLength: 2}, "MaxListLength"},
+		{"ply comment length", []byte("ply\nformat ascii 1.0\ncomment " + strings.Repeat("x", 100) + "\nend_header\n"),
+			plyfile.ReadOptions{MaxCommentLength: 80}, "MaxCommentLength"},
+		{"obj element count", obj_file.Bytes(), plyfile.ReadOptions{MaxElementCount: 2}, "MaxElementCount"},
+		{"obj list length", obj_file.Bytes(), plyfile.ReadOptions{MaxListLength: 2}, "MaxListLength"},
+		{"obj size", obj_file.Bytes(), plyfile.ReadOptions{MaxTotalBytes: int64(obj_file.Len() - 1)}, "MaxTotalBytes"},
+	}
+	for _, test := range tests {
+		m, _, err := plyfile.DecodeOptions(bytes.NewReader(test.data), test.opts)
+		var limit_err *plyfile.LimitError
+		if !errors.As(err, &limit_err) || m != nil {
+			t.Errorf("%s: error %v, want a *LimitError", test.name, err)
+			continue
+		}
+		if limit_err.Limit != test.limit {
+			t.Errorf("%s: limit %s, want %s (%v)", test.name, limit_err.Limit, test.limit, err)
+		}
+	}
+
+	/* the limits are inclusive */
+	opts := plyfile.ReadOptions{MaxElementCount: 3, MaxListLength: 3}
+	for _, data := range [][]byte{ply.Bytes(), obj_file.Bytes()} {
+		opts.MaxTotalBytes = int64(len(data))
+		if _, format, err := plyfile.DecodeOptions(bytes.NewReader(data), opts); err != nil {
+			t.Errorf("%s: %v", format, err)
+		}
+	}
+}
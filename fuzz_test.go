@@ -0,0 +1,159 @@
+package plyfile
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+/* malformedFiles returns the contents of the malformed files in testdata/malformed/<kind>, by file name. */
+func malformedFiles(t testing.TB, kind string) map[string][]byte {
+	paths, err := filepath.Glob(filepath.Join("testdata", "malformed", kind, "*.ply"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no malformed %s files (%v)", kind, err)
+	}
+	files := make(map[string][]byte)
+	for _, path := range paths {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		files[path] = data
+	}
+	return files
+}
+
+/* TestMalformedHeaders checks that both the Go and the C header parser reject every file of the header corpus, and say on which line. */
+func TestMalformedHeaders(t *testing.T) {
+	for path, data := range malformedFiles(t, "header") {
+		_, err := DecodeMesh(bytes.NewReader(data))
+		var parse_err *ParseError
+		if !errors.As(err, &parse_err) || parse_err.Line < 1 {
+			t.Errorf("%s: DecodeMesh error %v, want a *ParseError", path, err)
+		}
+		if plyfile, _ := PlyOpenForReading(path); plyfile != nil {
+			PlyClose(plyfile)
+			t.Errorf("%s: PlyOpenForReading succeeded", path)
+		}
+		if !errors.As(PlyHeaderError(), &parse_err) || parse_err.Line < 1 || parse_err.Reason == "" {
+			t.Errorf("%s: PlyHeaderError %v, want a *ParseError", path, PlyHeaderError())
+		}
+	}
+}
+
+/* cBodyReasons are the reasons of the body errors the C library reports as DecodeMesh does. */
+var cBodyReasons = map[string]bool{"too few values": true, "negative list count": true, "unexpected end of file": true}
+
+/* TestMalformedBodies checks that files of the body corpus have a valid header, but fail to decode, and that the C library reports the same error when it checks for it. */
+func TestMalformedBodies(t *testing.T) {
+	for path, data := range malformedFiles(t, "body") {
+		if _, err := DecodeHeader(bytes.NewReader(data)); err != nil {
+			t.Errorf("%s: bad header: %v", path, err)
+		}
+		_, err := DecodeMesh(bytes.NewReader(data))
+		if err == nil {
+			t.Errorf("%s: DecodeMesh succeeded", path)
+		}
+		/* the C library only checks that the values it needs are there, not their text nor what follows them */
+		c_err := readCBody(path, ReadOptions{})
+		var body_err *BodyError
+		if !errors.As(err, &body_err) || !cBodyReasons[body_err.Reason] {
+			continue
+		}
+		if !reflect.DeepEqual(c_err, err) {
+			t.Errorf("%s: C body error %v, want %v", path, c_err, err)
+		}
+	}
+}
+
+/* readCBody reads every element of a file with the C library and the limits of opts, as doubles and lists of doubles, and returns why the file couldn't be read. */
+func readCBody(path string, opts ReadOptions) error {
+	f, elem_names, err := OpenPlyFileOptions(path, opts)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	plyfile := f.CFile()
+
+	for _, name := range elem_names {
+		props, num_elems, _ := PlyGetElementDescription(plyfile, name)
+		if len(props) == 0 {
+			/* PlyGetProperty selects the element to read, so one without properties can't be */
+			continue
+		}
+		for i, prop := range props {
+			prop.Internal_type, prop.Offset = PLY_DOUBLE, 16*i
+			if prop.Is_list == PLY_LIST {
+				prop.Count_internal, prop.Count_offset = PLY_INT, 16*i+8
+			}
+			PlyGetProperty(plyfile, name, prop)
+		}
+		record := make([]byte, 16*len(props))
+		for i := 0; i < num_elems && PlyBodyError(plyfile) == nil; i++ {
+			PlyGetElement(plyfile, record, uintptr(len(record)))
+			PlyFreeLists(plyfile)
+		}
+		if err := PlyBodyError(plyfile); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* addSeeds adds the files written by the mesh tests and the malformed corpus to the fuzzing corpus. */
+func addSeeds(f *testing.F) {
+	for _, format := range []int{PLY_ASCII, PLY_BINARY_LE, PLY_BINARY_BE} {
+		var buf bytes.Buffer
+		if err := EncodeMesh(&buf, cubeMesh(format)); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes())
+	}
+	for _, kind := range []string{"header", "body"} {
+		for _, data := range malformedFiles(f, kind) {
+			f.Add(data)
+		}
+	}
+}
+
+func FuzzDecodeHeader(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		DecodeHeader(bytes.NewReader(data))
+	})
+}
+
+/* FuzzDecodeMesh decodes arbitrary data, and checks that a mesh that decodes and encodes without errors decodes again. */
+func FuzzDecodeMesh(f *testing.F) {
+	addSeeds(f)
+	f.Fuzz(func(t *testing.T, data []byte) {
+		m, err := DecodeMesh(bytes.NewReader(data))
+		if err != nil {
+			return
+		}
+		var buf bytes.Buffer
+		if err := EncodeMesh(&buf, m); err != nil {
+			return
+		}
+		if _, err := DecodeMesh(&buf); err != nil {
+			t.Errorf("decoding re-encoded mesh: %v", err)
+		}
+	})
+}
+
+/* FuzzCFile runs the header parser and the body reader of the C library (through a temporary file) on arbitrary data. */
+func FuzzCFile(f *testing.F) {
+	addSeeds(f)
+	f.Add([]byte("ply\nformat ascii 1.0\ncomment " + string(bytes.Repeat([]byte("x"), 10000)) + "\nend_header\n"))
+	f.Add([]byte("ply\nformat ascii 1.0\nend_header"))
+	path := filepath.Join(f.TempDir(), "fuzz.ply")
+	f.Fuzz(func(t *testing.T, data []byte) {
+		if err := os.WriteFile(path, data, 0644); err != nil {
+			t.Fatal(err)
+		}
+		readCBody(path, ReadOptions{MaxElementCount: 1 << 20, MaxListLength: 1 << 10})
+	})
+}
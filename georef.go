@@ -0,0 +1,202 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plyfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/* Georef places the coordinates of a file on the earth. It is stored in header comments, as the LAS reader writes them: "crs EPSG:32633" or "crs_wkt <wkt>" for the coordinate reference system, and "origin <x> <y> <z>" for the offset that was subtracted from the vertex coordinates, so that large projected coordinates fit in floats. */
+type Georef struct {
+	CRS       string     /* identifier of the coordinate reference system, e.g. "EPSG:32633", or "" */
+	WKT       string     /* OGC WKT description of the coordinate reference system, or "" */
+	Origin    [3]float64 /* added to the vertex x, y and z to give absolute coordinates */
+	HasOrigin bool       /* true if the file has an origin comment */
+}
+
+/* georef comment keywords */
+const (
+	georefCRS    = "crs"
+	georefWKT    = "crs_wkt"
+	georefOrigin = "origin"
+)
+
+/* ParseGeoref reads the georeferencing comments among comments, e.g. Mesh.Comments or the result of PlyGetComments. It returns nil if there are none. A comment starting with a keyword whose value can't be read, e.g. "origin unknown", is an ordinary comment. */
+func ParseGeoref(comments []string) *Georef {
+	var g *Georef
+	for _, comment := range comments {
+		key, value := georefComment(comment)
+		if key == "" {
+			continue
+		}
+		if g == nil {
+			g = &Georef{}
+		}
+		switch key {
+		case georefCRS:
+			g.CRS = value
+		case georefWKT:
+			g.WKT = value
+		case georefOrigin:
+			g.Origin, g.HasOrigin = parseOrigin(value)
+		}
+	}
+	return g
+}
+
+/* georefComment returns the keyword and the value of a georeferencing comment, or "" if the comment isn't one: the value of a crs or crs_wkt comment can't be empty, and that of an origin comment must be three numbers. */
+func georefComment(comment string) (string, string) {
+	key, value, _ := strings.Cut(strings.TrimSpace(comment), " ")
+	value = strings.TrimSpace(value)
+	switch key {
+	case georefCRS, georefWKT:
+		if value != "" {
+			return key, value
+		}
+	case georefOrigin:
+		if _, ok := parseOrigin(value); ok {
+			return key, value
+		}
+	}
+	return "", ""
+}
+
+/* parseOrigin reads the x, y and z of an origin comment, reporting whether the value is three numbers. */
+func parseOrigin(value string) ([3]float64, bool) {
+	var origin [3]float64
+	words := strings.Fields(value)
+	if len(words) != 3 {
+		return origin, false
+	}
+	for i, word := range words {
+		v, err := strconv.ParseFloat(word, 64)
+		if err != nil {
+			return origin, false
+		}
+		origin[i] = v
+	}
+	return origin, true
+}
+
+/* EPSG returns the EPSG code of the coordinate reference system, or 0 if it isn't given as one. */
+func (g *Georef) EPSG() int {
+	if !strings.HasPrefix(g.CRS, "EPSG:") {
+		return 0
+	}
+	n, err := strconv.Atoi(strings.TrimPrefix(g.CRS, "EPSG:"))
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+/* Comments returns the comment lines describing g, in the order crs, crs_wkt, origin. */
+func (g *Georef) Comments() []string {
+	var comments []string
+	if g.CRS != "" {
+		comments = append(comments, georefCRS+" "+g.CRS)
+	}
+	if g.WKT != "" {
+		comments = append(comments, georefWKT+" "+g.WKT)
+	}
+	if g.HasOrigin {
+		comments = append(comments, fmt.Sprintf("%s %s %s %s", georefOrigin,
+			strconv.FormatFloat(g.Origin[0], 'f', -1, 64),
+			strconv.FormatFloat(g.Origin[1], 'f', -1, 64),
+			strconv.FormatFloat(g.Origin[2], 'f', -1, 64)))
+	}
+	return comments
+}
+
+/* Georef returns the georeferencing of the mesh, or nil if it has none. */
+func (m *Mesh) Georef() *Georef {
+	return ParseGeoref(m.Comments)
+}
+
+/* SetGeoref replaces the georeferencing comments of the mesh with those of g; a nil g removes them. The vertex coordinates are left as they are. */
+func (m *Mesh) SetGeoref(g *Georef) {
+	var comments []string
+	for _, comment := range m.Comments {
+		if key, _ := georefComment(comment); key == "" {
+			comments = append(comments, comment)
+		}
+	}
+	if g != nil {
+		comments = append(comments, g.Comments()...)
+	}
+	m.Comments = comments
+}
+
+/* ApplyOrigin adds the origin of the mesh, if it has one, to the vertex x, y and z, which become doubles, and removes the origin comment. The coordinates are then absolute. */
+func (m *Mesh) ApplyOrigin() {
+	g := m.Georef()
+	if g == nil || !g.HasOrigin {
+		return
+	}
+	m.moveVertices(g.Origin, PLY_DOUBLE)
+	g.Origin = [3]float64{}
+	g.HasOrigin = false
+	m.SetGeoref(g)
+}
+
+/* SetOrigin makes the vertex x, y and z relative to origin and stores them as typ, e.g. PLY_FLOAT, with an origin comment. Coordinates already relative to another origin are made absolute first. */
+func (m *Mesh) SetOrigin(origin [3]float64, typ int) {
+	m.ApplyOrigin()
+	g := m.Georef()
+	if g == nil {
+		g = &Georef{}
+	}
+	m.moveVertices([3]float64{-origin[0], -origin[1], -origin[2]}, typ)
+	g.Origin = origin
+	g.HasOrigin = true
+	m.SetGeoref(g)
+}
+
+/* moveVertices adds offset to the vertex x, y and z, and sets their type to typ. */
+func (m *Mesh) moveVertices(offset [3]float64, typ int) {
+	if vertex := m.Element("vertex"); vertex != nil {
+		moveElement(vertex, offset, typ)
+	}
+}
+
+/* moveElement adds offset to the x, y and z of vertex, and sets their type to typ. */
+func moveElement(vertex *Element, offset [3]float64, typ int) {
+	for i, name := range []string{"x", "y", "z"} {
+		prop := vertex.Property(name)
+		if prop == nil || prop.IsList {
+			continue
+		}
+		for j := range prop.Data {
+			prop.Data[j] += offset[i]
+		}
+		prop.Type = typ
+	}
+}
+
+/* PlyGetGeoref returns the georeferencing of an open PLY file, or nil if it has none. */
+func PlyGetGeoref(plyfile CPlyFile) *Georef {
+	return ParseGeoref(PlyGetComments(plyfile))
+}
+
+/* PlyPutGeoref writes the georeferencing comments of g into the PLY file header. The coordinates written must be relative to g.Origin. */
+func PlyPutGeoref(plyfile CPlyFile, g *Georef) {
+	for _, comment := range g.Comments() {
+		PlyPutComment(plyfile, comment)
+	}
+}
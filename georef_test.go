@@ -0,0 +1,123 @@
+package plyfile
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestParseGeoref(t *testing.T) {
+	comments := []string{"made by lidar2ply", "crs EPSG:32633", "crs_wkt PROJCS[\"WGS 84 / UTM zone 33N\"]", "origin 581000 5812000 -0.5"}
+	g := ParseGeoref(comments)
+	want := &Georef{CRS: "EPSG:32633", WKT: "PROJCS[\"WGS 84 / UTM zone 33N\"]", Origin: [3]float64{581000, 5812000, -0.5}, HasOrigin: true}
+	if !reflect.DeepEqual(g, want) {
+		t.Errorf("ParseGeoref = %+v, want %+v", g, want)
+	}
+	if g.EPSG() != 32633 {
+		t.Errorf("EPSG = %d, want 32633", g.EPSG())
+	}
+	if got := g.Comments(); !reflect.DeepEqual(got, comments[1:]) {
+		t.Errorf("Comments = %q, want %q", got, comments[1:])
+	}
+
+	if g := ParseGeoref([]string{"bounding_box 0 0 0 1 1 1"}); g != nil {
+		t.Errorf("ParseGeoref without georef comments = %v", g)
+	}
+	/* lines that only start like georef comments are ordinary comments */
+	plain := []string{"origin unknown", "origin 1 2", "origin 1 2 z", "crs", "crs_wkt  "}
+	if g := ParseGeoref(plain); g != nil {
+		t.Errorf("ParseGeoref(%q) = %v", plain, g)
+	}
+	m := &Mesh{Comments: append(append([]string{}, plain...), "origin 1 2 3")}
+	if g := m.Georef(); g == nil || g.Origin != [3]float64{1, 2, 3} {
+		t.Errorf("Georef = %+v", g)
+	}
+	m.SetGeoref(nil)
+	if !reflect.DeepEqual(m.Comments, plain) {
+		t.Errorf("SetGeoref(nil) left %q, want %q", m.Comments, plain)
+	}
+}
+
+/* TestOrigin stores UTM coordinates as floats relative to an origin, and reads them back as absolute doubles. */
+func TestOrigin(t *testing.T) {
+	x := []float64{581234.5, 581999.25}
+	y := []float64{5812345.125, 5812001}
+	m := NewMesh(PLY_BINARY_LE)
+	m.Comments = []string{"crs EPSG:32633"}
+	vertex := m.AddElement("vertex", 2)
+	copy(vertex.AddProperty("x", PLY_DOUBLE).Data, x)
+	copy(vertex.AddProperty("y", PLY_DOUBLE).Data, y)
+	m.SetOrigin([3]float64{581000, 5812000, 0}, PLY_FLOAT)
+	if want := []string{"crs EPSG:32633", "origin 581000 5812000 0"}; !reflect.DeepEqual(m.Comments, want) {
+		t.Errorf("comments %q, want %q", m.Comments, want)
+	}
+	if got := vertex.Property("x").Data; got[0] != 234.5 || vertex.Property("x").Type != PLY_FLOAT {
+		t.Errorf("relative x %v as %s", got, TypeName(vertex.Property("x").Type))
+	}
+
+	var buf bytes.Buffer
+	if err := EncodeMesh(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	relative, err := DecodeMesh(bytes.NewReader(buf.Bytes()))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := relative.Element("vertex").Property("y").Data[0]; got != 345.125 {
+		t.Errorf("y read without ApplyOrigin = %v, want 345.125", got)
+	}
+	absolute, err := DecodeMeshOptions(bytes.NewReader(buf.Bytes()), ReadOptions{ApplyOrigin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	v := absolute.Element("vertex")
+	if !reflect.DeepEqual(v.Property("x").Data, x) || !reflect.DeepEqual(v.Property("y").Data, y) || v.Property("x").Type != PLY_DOUBLE {
+		t.Errorf("absolute x %v y %v as %s, want %v %v", v.Property("x").Data, v.Property("y").Data, TypeName(v.Property("x").Type), x, y)
+	}
+	if want := []string{"crs EPSG:32633"}; !reflect.DeepEqual(absolute.Comments, want) {
+		t.Errorf("comments after ApplyOrigin %q, want %q", absolute.Comments, want)
+	}
+
+	path := filepath.Join(t.TempDir(), "origin.ply")
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	file, err := OpenOptions(path, ReadOptions{ApplyOrigin: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+	read, err := file.ReadMesh()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(read, absolute) {
+		t.Errorf("File.ReadMesh with ApplyOrigin = %+v, want %+v", read, absolute)
+	}
+}
+
+func TestPlyGeoref(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "georef.ply")
+	g := &Georef{CRS: "EPSG:2056", Origin: [3]float64{2600000, 1200000, 400}, HasOrigin: true}
+	f, err := CreatePlyFile(path, []string{"vertex"}, PLY_ASCII)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.PutGeoref(g)
+	f.ElementCount("vertex", 0)
+	f.HeaderComplete()
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, _, err = OpenPlyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if got := f.Georef(); !reflect.DeepEqual(got, g) {
+		t.Errorf("Georef = %+v, want %+v", got, g)
+	}
+}
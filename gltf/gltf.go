@@ -0,0 +1,431 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package gltf writes PLY meshes as glTF 2.0 files, either as a binary GLB container, or as JSON whose buffer is a separate .bin file or is embedded as base64.
+
+The vertex element becomes a single mesh primitive with a POSITION attribute and, when the vertex element has them, NORMAL (nx, ny, nz), COLOR_0 (red, green, blue and optionally alpha) and TEXCOORD_0 (s, t or u, v or texture_u, texture_v) attributes. Texture coordinates may also come from the texcoord lists of the faces, as MeshLab writes them. Faces are triangulated and drawn as TRIANGLES; a mesh without faces is drawn as POINTS. When the mesh has TextureFile comments and texture coordinates, each texture becomes a material whose base color texture refers to the image by its path in the comment, which must be relative to the glTF file; faces are grouped into one primitive per texture by their texnumber property. Coordinates are written unchanged, so PLY files that are not Y-up keep their orientation.
+*/
+package gltf
+
+import (
+	"bufio"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+/* glTF constants */
+const (
+	componentFloat       = 5126
+	componentUnsignedInt = 5125
+	targetArrayBuffer    = 34962
+	targetElementBuffer  = 34963
+	modePoints           = 0
+	modeTriangles        = 4
+	wrapRepeat           = 10497
+)
+
+/* GLB container constants */
+const (
+	glbMagic     = 0x46546c67 /* "glTF" */
+	glbVersion   = 2
+	glbChunkJSON = 0x4e4f534a /* "JSON" */
+	glbChunkBIN  = 0x004e4942 /* "BIN\0" */
+)
+
+type document struct {
+	Asset       asset        `json:"asset"`
+	Scene       int          `json:"scene"`
+	Scenes      []scene      `json:"scenes"`
+	Nodes       []node       `json:"nodes"`
+	Meshes      []mesh       `json:"meshes"`
+	Accessors   []accessor   `json:"accessors"`
+	BufferViews []bufferView `json:"bufferViews"`
+	Buffers     []buffer     `json:"buffers"`
+	Materials   []material   `json:"materials,omitempty"`
+	Textures    []texture    `json:"textures,omitempty"`
+	Images      []image      `json:"images,omitempty"`
+	Samplers    []sampler    `json:"samplers,omitempty"`
+}
+
+type asset struct {
+	Version   string `json:"version"`
+	Generator string `json:"generator,omitempty"`
+}
+
+type scene struct {
+	Nodes []int `json:"nodes"`
+}
+
+type node struct {
+	Mesh int `json:"mesh"`
+}
+
+type mesh struct {
+	Primitives []primitive `json:"primitives"`
+}
+
+type primitive struct {
+	Attributes map[string]int `json:"attributes"`
+	Indices    *int           `json:"indices,omitempty"`
+	Material   *int           `json:"material,omitempty"`
+	Mode       int            `json:"mode"`
+}
+
+type material struct {
+	PBR pbr `json:"pbrMetallicRoughness"`
+}
+
+type pbr struct {
+	BaseColorTexture textureInfo `json:"baseColorTexture"`
+	MetallicFactor   float64     `json:"metallicFactor"`
+}
+
+type textureInfo struct {
+	Index int `json:"index"`
+}
+
+type texture struct {
+	Sampler int `json:"sampler"`
+	Source  int `json:"source"`
+}
+
+type image struct {
+	URI string `json:"uri"`
+}
+
+type sampler struct {
+	WrapS int `json:"wrapS"`
+	WrapT int `json:"wrapT"`
+}
+
+type accessor struct {
+	BufferView    int       `json:"bufferView"`
+	ComponentType int       `json:"componentType"`
+	Count         int       `json:"count"`
+	Type          string    `json:"type"`
+	Min           []float64 `json:"min,omitempty"`
+	Max           []float64 `json:"max,omitempty"`
+}
+
+type bufferView struct {
+	Buffer     int `json:"buffer"`
+	ByteOffset int `json:"byteOffset"`
+	ByteLength int `json:"byteLength"`
+	Target     int `json:"target,omitempty"`
+}
+
+type buffer struct {
+	ByteLength int    `json:"byteLength"`
+	URI        string `json:"uri,omitempty"`
+}
+
+/* accessor types by number of components */
+var accessorTypes = map[int]string{1: "SCALAR", 2: "VEC2", 3: "VEC3", 4: "VEC4"}
+
+/* builder collects the binary buffer and the accessors describing it. */
+type builder struct {
+	doc  document
+	data []byte
+}
+
+/* addFloats appends vertex attribute data with the given number of components per vertex and returns the index of its accessor. */
+func (b *builder) addFloats(values []float32, components int, bounds bool) int {
+	acc := accessor{ComponentType: componentFloat, Count: len(values) / components, Type: accessorTypes[components]}
+	if bounds && len(values) > 0 {
+		acc.Min = make([]float64, components)
+		acc.Max = make([]float64, components)
+		for k := 0; k < components; k++ {
+			acc.Min[k], acc.Max[k] = math.Inf(1), math.Inf(-1)
+		}
+		for i, v := range values {
+			k := i % components
+			if math.IsNaN(float64(v)) || math.IsInf(float64(v), 0) {
+				continue
+			}
+			acc.Min[k] = math.Min(acc.Min[k], float64(v))
+			acc.Max[k] = math.Max(acc.Max[k], float64(v))
+		}
+		for k := 0; k < components; k++ {
+			if acc.Min[k] > acc.Max[k] {
+				acc.Min[k], acc.Max[k] = 0, 0
+			}
+		}
+	}
+	raw := make([]byte, 4*len(values))
+	for i, v := range values {
+		binary.LittleEndian.PutUint32(raw[4*i:], math.Float32bits(v))
+	}
+	return b.add(acc, raw, targetArrayBuffer)
+}
+
+/* addIndices appends triangle indices and returns the index of their accessor. */
+func (b *builder) addIndices(triangles [][3]int) int {
+	raw := make([]byte, 12*len(triangles))
+	for i, tri := range triangles {
+		for j, v := range tri {
+			binary.LittleEndian.PutUint32(raw[12*i+4*j:], uint32(v))
+		}
+	}
+	acc := accessor{ComponentType: componentUnsignedInt, Count: 3 * len(triangles), Type: "SCALAR"}
+	return b.add(acc, raw, targetElementBuffer)
+}
+
+/* add appends raw as a new buffer view (aligned to 4 bytes) and acc as its accessor. */
+func (b *builder) add(acc accessor, raw []byte, target int) int {
+	for len(b.data)%4 != 0 {
+		b.data = append(b.data, 0)
+	}
+	acc.BufferView = len(b.doc.BufferViews)
+	b.doc.BufferViews = append(b.doc.BufferViews, bufferView{ByteOffset: len(b.data), ByteLength: len(raw), Target: target})
+	b.data = append(b.data, raw...)
+	b.doc.Accessors = append(b.doc.Accessors, acc)
+	return len(b.doc.Accessors) - 1
+}
+
+/* build converts the mesh into a glTF document and its binary buffer. */
+func build(m *plyfile.Mesh) (*builder, error) {
+	if m.FaceTexCoords() != nil {
+		m = m.Clone()
+		if err := m.ToVertexTexCoords(); err != nil {
+			return nil, fmt.Errorf("gltf: %v", err)
+		}
+	}
+	positions, err := m.Positions()
+	if err != nil {
+		return nil, fmt.Errorf("gltf: %v", err)
+	}
+	if len(positions) == 0 {
+		return nil, errors.New("gltf: mesh has no vertices")
+	}
+	if uint64(len(positions)) > math.MaxUint32 {
+		return nil, errors.New("gltf: too many vertices")
+	}
+	textures := m.TextureFiles()
+	groups, err := triangleGroups(m, positions, len(textures))
+	if err != nil {
+		return nil, fmt.Errorf("gltf: %v", err)
+	}
+	vertex_elem := m.Element("vertex")
+
+	b := &builder{}
+	prim := primitive{Attributes: map[string]int{}, Mode: modePoints}
+
+	values := make([]float32, 0, 3*len(positions))
+	for _, p := range positions {
+		values = append(values, float32(p[0]), float32(p[1]), float32(p[2]))
+	}
+	prim.Attributes["POSITION"] = b.addFloats(values, 3, true)
+
+	if normals := vertex_elem.Scalars("nx", "ny", "nz"); normals != nil {
+		values = values[:0]
+		for i := 0; i < vertex_elem.Count; i++ {
+			n := [3]float64{normals[0].Data[i], normals[1].Data[i], normals[2].Data[i]}
+			length := math.Sqrt(n[0]*n[0] + n[1]*n[1] + n[2]*n[2])
+			if length == 0 || math.IsNaN(length) || math.IsInf(length, 0) {
+				n, length = [3]float64{0, 0, 1}, 1
+			}
+			values = append(values, float32(n[0]/length), float32(n[1]/length), float32(n[2]/length))
+		}
+		prim.Attributes["NORMAL"] = b.addFloats(values, 3, false)
+	}
+
+	colors := vertex_elem.Scalars("red", "green", "blue", "alpha")
+	if colors == nil {
+		colors = vertex_elem.Scalars("red", "green", "blue")
+	}
+	if colors != nil {
+		values = make([]float32, 0, len(colors)*vertex_elem.Count)
+		for i := 0; i < vertex_elem.Count; i++ {
+			for _, c := range colors {
+				values = append(values, float32(colorValue(c, i)))
+			}
+		}
+		prim.Attributes["COLOR_0"] = b.addFloats(values, len(colors), false)
+	}
+
+	u, v := m.VertexTexCoords()
+	if u != nil {
+		/* glTF puts the origin of texture space at the top left corner, PLY at the bottom left */
+		values = make([]float32, 0, 2*vertex_elem.Count)
+		for i := 0; i < vertex_elem.Count; i++ {
+			values = append(values, float32(u.Data[i]), float32(1-v.Data[i]))
+		}
+		prim.Attributes["TEXCOORD_0"] = b.addFloats(values, 2, false)
+		for i, file := range textures {
+			b.doc.Images = append(b.doc.Images, image{URI: filepath.ToSlash(file)})
+			b.doc.Textures = append(b.doc.Textures, texture{Source: i})
+			b.doc.Materials = append(b.doc.Materials, material{PBR: pbr{BaseColorTexture: textureInfo{Index: i}}})
+		}
+		if len(textures) > 0 {
+			b.doc.Samplers = []sampler{{WrapS: wrapRepeat, WrapT: wrapRepeat}}
+		}
+	}
+
+	prims := []primitive{prim}
+	if len(groups) > 0 {
+		prims = prims[:0]
+		for k, triangles := range groups {
+			if len(triangles) == 0 {
+				continue
+			}
+			p := primitive{Attributes: prim.Attributes, Mode: modeTriangles}
+			indices := b.addIndices(triangles)
+			p.Indices = &indices
+			if k < len(b.doc.Materials) {
+				index := k
+				p.Material = &index
+			}
+			prims = append(prims, p)
+		}
+	}
+
+	b.doc.Asset = asset{Version: "2.0", Generator: "go-plyfile"}
+	b.doc.Scenes = []scene{{Nodes: []int{0}}}
+	b.doc.Nodes = []node{{Mesh: 0}}
+	b.doc.Meshes = []mesh{{Primitives: prims}}
+	return b, nil
+}
+
+/* triangleGroups returns the triangles of the mesh grouped by the texnumber of their face, with ntextures groups, or a single group if the faces have no texnumber or there are fewer than two textures. It returns no group if no face has three vertices. */
+func triangleGroups(m *plyfile.Mesh, positions [][3]float64, ntextures int) ([][][3]int, error) {
+	faces, err := m.Faces()
+	if err != nil {
+		return nil, err
+	}
+	var texnumber *plyfile.Property
+	if face_elem := m.Element("face"); face_elem != nil && ntextures > 1 {
+		if texnumber = face_elem.Property(plyfile.TexNumberProperty); texnumber != nil && texnumber.IsList {
+			texnumber = nil
+		}
+	}
+	groups := make([][][3]int, 1)
+	if texnumber != nil {
+		groups = make([][][3]int, ntextures)
+	}
+	count := 0
+	for i, face := range faces {
+		k := 0
+		if texnumber != nil {
+			k = int(texnumber.Data[i])
+			if k < 0 || k >= ntextures {
+				return nil, fmt.Errorf("face %d: texnumber %d out of range", i, k)
+			}
+		}
+		before := len(groups[k])
+		groups[k] = plyfile.Triangulate(groups[k], face, positions)
+		count += len(groups[k]) - before
+	}
+	if count == 0 {
+		return nil, nil
+	}
+	return groups, nil
+}
+
+/* colorValue returns the i-th value of a color property scaled to the range 0 to 1. Integer colors are divided by the largest value of their type. */
+func colorValue(prop *plyfile.Property, i int) float64 {
+	v := prop.Data[i]
+	switch prop.Type {
+	case plyfile.PLY_CHAR, plyfile.PLY_UCHAR:
+		v /= 255
+	case plyfile.PLY_SHORT, plyfile.PLY_USHORT:
+		v /= 65535
+	case plyfile.PLY_INT, plyfile.PLY_UINT:
+		v /= math.MaxUint32
+	}
+	if !(v > 0) {
+		return 0
+	}
+	return math.Min(v, 1)
+}
+
+/* Encode writes a PLY mesh to w as a binary glTF (GLB) file. */
+func Encode(w io.Writer, m *plyfile.Mesh) error {
+	b, err := build(m)
+	if err != nil {
+		return err
+	}
+	for len(b.data)%4 != 0 {
+		b.data = append(b.data, 0)
+	}
+	b.doc.Buffers = []buffer{{ByteLength: len(b.data)}}
+	js, err := json.Marshal(b.doc)
+	if err != nil {
+		return err
+	}
+	for len(js)%4 != 0 {
+		js = append(js, ' ')
+	}
+
+	total := 12 + 8 + len(js) + 8 + len(b.data)
+	if uint64(total) > math.MaxUint32 {
+		return errors.New("gltf: mesh too large for a GLB file")
+	}
+	bw := bufio.NewWriter(w)
+	var header [12]byte
+	binary.LittleEndian.PutUint32(header[0:], glbMagic)
+	binary.LittleEndian.PutUint32(header[4:], glbVersion)
+	binary.LittleEndian.PutUint32(header[8:], uint32(total))
+	bw.Write(header[:])
+	writeChunk(bw, glbChunkJSON, js)
+	writeChunk(bw, glbChunkBIN, b.data)
+	return bw.Flush()
+}
+
+func writeChunk(w io.Writer, typ uint32, data []byte) {
+	var header [8]byte
+	binary.LittleEndian.PutUint32(header[0:], uint32(len(data)))
+	binary.LittleEndian.PutUint32(header[4:], typ)
+	w.Write(header[:])
+	w.Write(data)
+}
+
+/* EncodeJSON writes a PLY mesh to w as a glTF JSON file, with the binary data embedded as a base64 data URI. */
+func EncodeJSON(w io.Writer, m *plyfile.Mesh) error {
+	b, err := build(m)
+	if err != nil {
+		return err
+	}
+	return b.encodeJSON(w, "data:application/octet-stream;base64,"+base64.StdEncoding.EncodeToString(b.data))
+}
+
+/* EncodeJSONBin writes a PLY mesh as a glTF JSON file to w and its binary data to bin. The JSON refers to the binary data by uri, usually the escaped name of the .bin file relative to the glTF file, e.g. "mesh.bin". */
+func EncodeJSONBin(w, bin io.Writer, m *plyfile.Mesh, uri string) error {
+	b, err := build(m)
+	if err != nil {
+		return err
+	}
+	if _, err := bin.Write(b.data); err != nil {
+		return err
+	}
+	return b.encodeJSON(w, uri)
+}
+
+/* encodeJSON writes the document to w, with a single buffer at uri. */
+func (b *builder) encodeJSON(w io.Writer, uri string) error {
+	b.doc.Buffers = []buffer{{ByteLength: len(b.data), URI: uri}}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(b.doc)
+}
@@ -0,0 +1,224 @@
+package gltf
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+/* cube returns a unit cube with quadrilateral faces, vertex colors and texture coordinates. */
+func cube() *plyfile.Mesh {
+	m := plyfile.NewMesh(plyfile.PLY_BINARY_LE)
+	vertex := m.AddElement("vertex", 8)
+	x := vertex.AddProperty("x", plyfile.PLY_FLOAT)
+	y := vertex.AddProperty("y", plyfile.PLY_FLOAT)
+	z := vertex.AddProperty("z", plyfile.PLY_FLOAT)
+	red := vertex.AddProperty("red", plyfile.PLY_UCHAR)
+	vertex.AddProperty("green", plyfile.PLY_UCHAR)
+	vertex.AddProperty("blue", plyfile.PLY_UCHAR)
+	s := vertex.AddProperty("s", plyfile.PLY_FLOAT)
+	vertex.AddProperty("t", plyfile.PLY_FLOAT)
+	for i := 0; i < 8; i++ {
+		x.Data[i], y.Data[i], z.Data[i] = float64(i&1), 2*float64(i>>1&1), 3*float64(i>>2&1)
+		red.Data[i] = 255
+		s.Data[i] = 0.5
+	}
+	face := m.AddElement("face", 6)
+	face.AddListProperty("vertex_indices", plyfile.PLY_UCHAR, plyfile.PLY_INT).Lists = [][]float64{
+		{0, 2, 3, 1}, {4, 5, 7, 6}, {0, 1, 5, 4}, {2, 6, 7, 3}, {0, 4, 6, 2}, {1, 3, 7, 5},
+	}
+	return m
+}
+
+/* readGLB checks the GLB container and returns its JSON document and binary chunk. */
+func readGLB(t *testing.T, data []byte) (document, []byte) {
+	le := binary.LittleEndian
+	if len(data) < 20 || le.Uint32(data) != glbMagic || le.Uint32(data[4:]) != 2 || int(le.Uint32(data[8:])) != len(data) {
+		t.Fatalf("bad GLB header")
+	}
+	json_len := int(le.Uint32(data[12:]))
+	if le.Uint32(data[16:]) != glbChunkJSON || json_len%4 != 0 {
+		t.Fatalf("bad JSON chunk")
+	}
+	var doc document
+	if err := json.Unmarshal(data[20:20+json_len], &doc); err != nil {
+		t.Fatal(err)
+	}
+	bin := data[20+json_len:]
+	if le.Uint32(bin[4:]) != glbChunkBIN || int(le.Uint32(bin)) != len(bin)-8 {
+		t.Fatalf("bad BIN chunk")
+	}
+	return doc, bin[8:]
+}
+
+func TestEncode(t *testing.T) {
+	var buf bytes.Buffer
+	if err := Encode(&buf, cube()); err != nil {
+		t.Fatal(err)
+	}
+	doc, bin := readGLB(t, buf.Bytes())
+	if doc.Asset.Version != "2.0" || len(doc.Meshes) != 1 || len(doc.Buffers) != 1 || doc.Buffers[0].ByteLength != len(bin) {
+		t.Fatalf("bad document %+v", doc)
+	}
+	prim := doc.Meshes[0].Primitives[0]
+	if prim.Mode != modeTriangles || prim.Indices == nil {
+		t.Fatalf("bad primitive %+v", prim)
+	}
+	if want := []string{"COLOR_0", "POSITION", "TEXCOORD_0"}; !reflect.DeepEqual(keys(prim.Attributes), want) {
+		t.Errorf("attributes = %v, want %v", keys(prim.Attributes), want)
+	}
+
+	position := doc.Accessors[prim.Attributes["POSITION"]]
+	if position.Count != 8 || position.Type != "VEC3" {
+		t.Errorf("bad position accessor %+v", position)
+	}
+	if !reflect.DeepEqual(position.Min, []float64{0, 0, 0}) || !reflect.DeepEqual(position.Max, []float64{1, 2, 3}) {
+		t.Errorf("position bounds = %v %v", position.Min, position.Max)
+	}
+	indices := doc.Accessors[*prim.Indices]
+	if indices.Count != 36 || indices.ComponentType != componentUnsignedInt {
+		t.Errorf("bad index accessor %+v", indices)
+	}
+	for _, view := range doc.BufferViews {
+		if view.ByteOffset%4 != 0 || view.ByteOffset+view.ByteLength > len(bin) {
+			t.Errorf("bad buffer view %+v", view)
+		}
+	}
+
+	/* colors are scaled to 0..1 and t is flipped */
+	if got := floats(doc, bin, prim.Attributes["COLOR_0"])[:3]; !reflect.DeepEqual(got, []float32{1, 0, 0}) {
+		t.Errorf("color = %v", got)
+	}
+	if got := floats(doc, bin, prim.Attributes["TEXCOORD_0"])[:2]; !reflect.DeepEqual(got, []float32{0.5, 1}) {
+		t.Errorf("texcoord = %v", got)
+	}
+}
+
+/* TestEncodeTextures checks that each texture becomes a material, and that faces are grouped by texnumber. */
+func TestEncodeTextures(t *testing.T) {
+	m := cube()
+	m.Comments = []string{"TextureFile tex/side.png", "TextureFile top.png"}
+	copy(m.Element("face").AddProperty("texnumber", plyfile.PLY_UCHAR).Data, []float64{0, 1, 0, 0, 0, 0})
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	doc, _ := readGLB(t, buf.Bytes())
+	if len(doc.Images) != 2 || doc.Images[0].URI != "tex/side.png" || len(doc.Textures) != 2 || doc.Textures[1].Source != 1 || len(doc.Materials) != 2 || len(doc.Samplers) != 1 {
+		t.Fatalf("bad textures %+v %+v %+v", doc.Images, doc.Textures, doc.Materials)
+	}
+	prims := doc.Meshes[0].Primitives
+	if len(prims) != 2 {
+		t.Fatalf("%d primitives, want 2", len(prims))
+	}
+	for k, count := range []int{30, 6} {
+		if prims[k].Material == nil || *prims[k].Material != k || doc.Accessors[*prims[k].Indices].Count != count {
+			t.Errorf("primitive %d: %+v, want material %d with %d indices", k, prims[k], k, count)
+		}
+	}
+}
+
+func TestEncodePoints(t *testing.T) {
+	m := cube()
+	m.Elements = m.Elements[:1]
+	vertex := m.Element("vertex")
+	for _, name := range []string{"nx", "ny", "nz"} {
+		vertex.AddProperty(name, plyfile.PLY_FLOAT)
+	}
+	vertex.Property("nz").Data[0] = 2
+
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	doc, bin := readGLB(t, buf.Bytes())
+	prim := doc.Meshes[0].Primitives[0]
+	if prim.Mode != modePoints || prim.Indices != nil {
+		t.Errorf("bad primitive %+v", prim)
+	}
+	/* normals are normalized, zero normals replaced */
+	normals := floats(doc, bin, prim.Attributes["NORMAL"])
+	if !reflect.DeepEqual(normals[:6], []float32{0, 0, 1, 0, 0, 1}) {
+		t.Errorf("normals = %v", normals[:6])
+	}
+}
+
+func TestEncodeJSON(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeJSON(&buf, cube()); err != nil {
+		t.Fatal(err)
+	}
+	var doc document
+	if err := json.Unmarshal(buf.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	const prefix = "data:application/octet-stream;base64,"
+	if !strings.HasPrefix(doc.Buffers[0].URI, prefix) {
+		t.Fatalf("bad buffer uri %.50s", doc.Buffers[0].URI)
+	}
+	bin, err := base64.StdEncoding.DecodeString(doc.Buffers[0].URI[len(prefix):])
+	if err != nil || len(bin) != doc.Buffers[0].ByteLength {
+		t.Fatalf("bad buffer data (%v)", err)
+	}
+	prim := doc.Meshes[0].Primitives[0]
+	if got := floats(doc, bin, prim.Attributes["POSITION"])[21:]; !reflect.DeepEqual(got, []float32{1, 2, 3}) {
+		t.Errorf("last position = %v", got)
+	}
+}
+
+func TestEncodeJSONBin(t *testing.T) {
+	var js, bin bytes.Buffer
+	if err := EncodeJSONBin(&js, &bin, cube(), "cube.bin"); err != nil {
+		t.Fatal(err)
+	}
+	var doc document
+	if err := json.Unmarshal(js.Bytes(), &doc); err != nil {
+		t.Fatal(err)
+	}
+	if got := doc.Buffers[0]; got.URI != "cube.bin" || got.ByteLength != bin.Len() {
+		t.Fatalf("buffer %+v for %d bytes, want uri cube.bin", got, bin.Len())
+	}
+	prim := doc.Meshes[0].Primitives[0]
+	if got := floats(doc, bin.Bytes(), prim.Attributes["POSITION"])[21:]; !reflect.DeepEqual(got, []float32{1, 2, 3}) {
+		t.Errorf("last position = %v", got)
+	}
+}
+
+func TestEncodeErrors(t *testing.T) {
+	m := plyfile.NewMesh(plyfile.PLY_ASCII)
+	if err := Encode(&bytes.Buffer{}, m); err == nil {
+		t.Error("expected an error for a mesh without vertices")
+	}
+	m = cube()
+	m.Element("face").Properties[0].Lists[0][0] = 8
+	if err := Encode(&bytes.Buffer{}, m); err == nil {
+		t.Error("expected an error for a bad vertex index")
+	}
+}
+
+func keys(m map[string]int) []string {
+	var names []string
+	for _, name := range []string{"COLOR_0", "NORMAL", "POSITION", "TEXCOORD_0"} {
+		if _, ok := m[name]; ok {
+			names = append(names, name)
+		}
+	}
+	return names
+}
+
+/* floats returns the float values of an accessor. */
+func floats(doc document, bin []byte, index int) []float32 {
+	view := doc.BufferViews[doc.Accessors[index].BufferView]
+	values := make([]float32, view.ByteLength/4)
+	for i := range values {
+		values[i] = math.Float32frombits(binary.LittleEndian.Uint32(bin[view.ByteOffset+4*i:]))
+	}
+	return values
+}
@@ -1,3 +1,3 @@
 module github.com/ecopia-map/go-plyfile
 
-go 1.16
+go 1.18
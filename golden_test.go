@@ -0,0 +1,149 @@
+package plyfile
+
+import (
+	"encoding/json"
+	"flag"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the expected results of testdata/golden from the decoded files")
+
+/* goldenMesh is the JSON form of a decoded Mesh stored next to each file of testdata/golden. */
+type goldenMesh struct {
+	Format   string          `json:"format"`
+	Version  float32         `json:"version"`
+	Comments []string        `json:"comments"`
+	ObjInfo  []string        `json:"obj_info"`
+	Elements []goldenElement `json:"elements"`
+}
+
+type goldenElement struct {
+	Name       string           `json:"name"`
+	Count      int              `json:"count"`
+	Properties []goldenProperty `json:"properties"`
+}
+
+type goldenProperty struct {
+	Name      string      `json:"name"`
+	Type      string      `json:"type"`
+	CountType string      `json:"count_type,omitempty"`
+	Data      []float64   `json:"data,omitempty"`
+	Lists     [][]float64 `json:"lists,omitempty"`
+}
+
+func toGolden(m *Mesh) *goldenMesh {
+	g := &goldenMesh{Format: formatNames[m.Format], Version: m.Version, Comments: []string{}, ObjInfo: []string{}}
+	g.Comments = append(g.Comments, m.Comments...)
+	g.ObjInfo = append(g.ObjInfo, m.ObjInfo...)
+	for _, elem := range m.Elements {
+		ge := goldenElement{Name: elem.Name, Count: elem.Count}
+		for _, prop := range elem.Properties {
+			gp := goldenProperty{Name: prop.Name, Type: TypeName(prop.Type)}
+			if prop.IsList {
+				gp.CountType = TypeName(prop.CountType)
+				for _, list := range prop.Lists {
+					gp.Lists = append(gp.Lists, append([]float64{}, list...))
+				}
+			} else {
+				gp.Data = append([]float64{}, prop.Data...)
+			}
+			ge.Properties = append(ge.Properties, gp)
+		}
+		g.Elements = append(g.Elements, ge)
+	}
+	return g
+}
+
+/* TestGoldenFiles decodes the files of testdata/golden, written in the style of other PLY producers, and compares each with its expected result. Run with -update to rewrite the expected results after checking the differences. */
+func TestGoldenFiles(t *testing.T) {
+	paths, err := filepath.Glob(filepath.Join("testdata", "golden", "*.ply"))
+	if err != nil || len(paths) == 0 {
+		t.Fatalf("no golden files (%v)", err)
+	}
+	for _, path := range paths {
+		m, err := ReadMesh(path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		got := toGolden(m)
+
+		json_path := strings.TrimSuffix(path, ".ply") + ".json"
+		if *update {
+			data, err := json.MarshalIndent(got, "", " ")
+			if err != nil {
+				t.Fatal(err)
+			}
+			if err := os.WriteFile(json_path, append(data, '\n'), 0644); err != nil {
+				t.Fatal(err)
+			}
+			continue
+		}
+
+		data, err := os.ReadFile(json_path)
+		if err != nil {
+			t.Errorf("%s: %v", path, err)
+			continue
+		}
+		var want goldenMesh
+		if err := json.Unmarshal(data, &want); err != nil {
+			t.Errorf("%s: %v", json_path, err)
+			continue
+		}
+		if !reflect.DeepEqual(got, &want) {
+			got_json, _ := json.MarshalIndent(got, "", " ")
+			t.Errorf("%s: decoded mesh differs from %s:\n%s", path, json_path, got_json)
+		}
+	}
+}
+
+/* TestGoldenFilesC reads the header of every golden file with the C library, and compares the elements and comments with the expected results. */
+func TestGoldenFilesC(t *testing.T) {
+	paths, _ := filepath.Glob(filepath.Join("testdata", "golden", "*.ply"))
+	for _, path := range paths {
+		data, err := os.ReadFile(strings.TrimSuffix(path, ".ply") + ".json")
+		if err != nil {
+			t.Fatal(err)
+		}
+		var want goldenMesh
+		if err := json.Unmarshal(data, &want); err != nil {
+			t.Fatal(err)
+		}
+
+		cplyfile, elem_names := PlyOpenForReading(path)
+		if cplyfile == nil {
+			t.Errorf("%s: PlyOpenForReading failed", path)
+			continue
+		}
+		got := goldenMesh{Format: formatNames[int(cplyfile.file_type)], Version: float32(cplyfile.version),
+			Comments: PlyGetComments(cplyfile), ObjInfo: PlyGetObjInfo(cplyfile)}
+		for _, name := range elem_names {
+			plist, num_elems, _ := PlyGetElementDescription(cplyfile, name)
+			ge := goldenElement{Name: name, Count: num_elems}
+			for _, prop := range plist {
+				gp := goldenProperty{Name: prop.Name, Type: TypeName(prop.External_type)}
+				if prop.Is_list != 0 {
+					gp.CountType = TypeName(prop.Count_external)
+				}
+				ge.Properties = append(ge.Properties, gp)
+			}
+			got.Elements = append(got.Elements, ge)
+		}
+		PlyClose(cplyfile)
+
+		/* compare everything but the data */
+		for i := range want.Elements {
+			for k := range want.Elements[i].Properties {
+				want.Elements[i].Properties[k].Data = nil
+				want.Elements[i].Properties[k].Lists = nil
+			}
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("%s: C header differs:\n got %+v\nwant %+v", path, got, want)
+		}
+	}
+}
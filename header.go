@@ -0,0 +1,143 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plyfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+/* HeaderBuilder describes the header of a PLY file with chained calls, e.g. NewHeader(FormatBinaryLE).Element("vertex", n).Float32("x").Float32("y").Float32("z").Element("face", m).List("vertex_indices", Uint8, Int32).Comment("made by me"). The first invalid call is remembered and returned by Build; later calls are ignored. */
+type HeaderBuilder struct {
+	m    *Mesh
+	elem *Element /* element receiving properties */
+	err  error
+}
+
+/* NewHeader starts a header of the specified file format. */
+func NewHeader(format int) *HeaderBuilder {
+	b := &HeaderBuilder{m: NewMesh(format)}
+	if format != PLY_ASCII && format != PLY_BINARY_BE && format != PLY_BINARY_LE {
+		b.err = fmt.Errorf("plyfile: bad file type = %d", format)
+	}
+	return b
+}
+
+/* Element starts a new element with count instances; the properties that follow belong to it. */
+func (b *HeaderBuilder) Element(name string, count int) *HeaderBuilder {
+	switch {
+	case b.err != nil:
+	case !validName(name):
+		b.err = fmt.Errorf("plyfile: bad element name '%s'", name)
+	case b.m.Element(name) != nil:
+		b.err = fmt.Errorf("plyfile: element '%s' declared twice", name)
+	case count < 0:
+		b.err = fmt.Errorf("plyfile: element '%s' has negative count %d", name, count)
+	default:
+		b.elem = b.m.AddElement(name, count)
+	}
+	return b
+}
+
+/* Property adds a scalar property of a PLY_* type to the current element. */
+func (b *HeaderBuilder) Property(name string, typ int) *HeaderBuilder {
+	if b.checkProperty(name) && !validType(typ) {
+		b.err = fmt.Errorf("plyfile: property '%s' of element '%s' has bad type = %d", name, b.elem.Name, typ)
+	}
+	if b.err == nil {
+		b.elem.AddProperty(name, typ)
+	}
+	return b
+}
+
+/* List adds a list property to the current element, with an integer count type and a value type. */
+func (b *HeaderBuilder) List(name string, count_type int, typ int) *HeaderBuilder {
+	if b.checkProperty(name) {
+		if !isIntegerType(count_type) {
+			b.err = fmt.Errorf("plyfile: list property '%s' of element '%s' has bad count type = %d", name, b.elem.Name, count_type)
+		} else if !validType(typ) {
+			b.err = fmt.Errorf("plyfile: property '%s' of element '%s' has bad type = %d", name, b.elem.Name, typ)
+		}
+	}
+	if b.err == nil {
+		b.elem.AddListProperty(name, count_type, typ)
+	}
+	return b
+}
+
+/* checkProperty checks that a property named name can be added, and reports whether it can. */
+func (b *HeaderBuilder) checkProperty(name string) bool {
+	switch {
+	case b.err != nil:
+	case b.elem == nil:
+		b.err = fmt.Errorf("plyfile: property '%s' before first element", name)
+	case !validName(name):
+		b.err = fmt.Errorf("plyfile: bad property name '%s'", name)
+	case b.elem.Property(name) != nil:
+		b.err = fmt.Errorf("plyfile: property '%s' declared twice in element '%s'", name, b.elem.Name)
+	}
+	return b.err == nil
+}
+
+/* Int8, Int16, Int32, Uint8, Uint16, Uint32, Float32 and Float64 add a scalar property of that type to the current element. */
+func (b *HeaderBuilder) Int8(name string) *HeaderBuilder    { return b.Property(name, Int8) }
+func (b *HeaderBuilder) Int16(name string) *HeaderBuilder   { return b.Property(name, Int16) }
+func (b *HeaderBuilder) Int32(name string) *HeaderBuilder   { return b.Property(name, Int32) }
+func (b *HeaderBuilder) Uint8(name string) *HeaderBuilder   { return b.Property(name, Uint8) }
+func (b *HeaderBuilder) Uint16(name string) *HeaderBuilder  { return b.Property(name, Uint16) }
+func (b *HeaderBuilder) Uint32(name string) *HeaderBuilder  { return b.Property(name, Uint32) }
+func (b *HeaderBuilder) Float32(name string) *HeaderBuilder { return b.Property(name, Float32) }
+func (b *HeaderBuilder) Float64(name string) *HeaderBuilder { return b.Property(name, Float64) }
+
+/* Comment adds a comment line to the header. */
+func (b *HeaderBuilder) Comment(text string) *HeaderBuilder {
+	if b.err == nil && strings.ContainsAny(text, "\r\n") {
+		b.err = fmt.Errorf("plyfile: comment contains a line break: '%s'", text)
+	}
+	if b.err == nil {
+		b.m.Comments = append(b.m.Comments, text)
+	}
+	return b
+}
+
+/* ObjInfo adds an obj_info line to the header. */
+func (b *HeaderBuilder) ObjInfo(text string) *HeaderBuilder {
+	if b.err == nil && strings.ContainsAny(text, "\r\n") {
+		b.err = fmt.Errorf("plyfile: obj_info contains a line break: '%s'", text)
+	}
+	if b.err == nil {
+		b.m.ObjInfo = append(b.m.ObjInfo, text)
+	}
+	return b
+}
+
+/* Build returns the described header as a Mesh, whose properties hold zero values and empty lists ready to be filled in and written with WriteMesh, or passed to Create. It returns the error of the first invalid call, if any. */
+func (b *HeaderBuilder) Build() (*Mesh, error) {
+	if b.err != nil {
+		return nil, b.err
+	}
+	return b.m, nil
+}
+
+/* Create builds the header and creates a File with it, to be written one element at a time. */
+func (b *HeaderBuilder) Create(filename string) (*File, error) {
+	m, err := b.Build()
+	if err != nil {
+		return nil, err
+	}
+	return Create(filename, m)
+}
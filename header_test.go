@@ -0,0 +1,79 @@
+package plyfile
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+/* TestHeaderBuilder builds the header of the cube, fills it in and compares it with cubeMesh. */
+func TestHeaderBuilder(t *testing.T) {
+	cube := cubeMesh(PLY_BINARY_LE)
+	m, err := NewHeader(FormatBinaryLE).
+		Element("vertex", 8).Float32("x").Float32("y").Float32("z").
+		Element("face", 6).Uint8("intensity").List("vertex_indices", Uint8, Int32).
+		Comment(cube.Comments[0]).ObjInfo(cube.ObjInfo[0]).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, elem := range m.Elements {
+		for k, prop := range elem.Properties {
+			copy(prop.Data, cube.Elements[i].Properties[k].Data)
+			copy(prop.Lists, cube.Elements[i].Properties[k].Lists)
+		}
+	}
+	if !reflect.DeepEqual(m, cube) {
+		t.Errorf("built mesh differs from the cube")
+	}
+
+	var want, got bytes.Buffer
+	EncodeMesh(&want, cube)
+	if err := EncodeMesh(&got, m); err != nil || !bytes.Equal(got.Bytes(), want.Bytes()) {
+		t.Errorf("built mesh encodes differently (%v)", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "built.ply")
+	f, err := NewHeader(FormatASCII).Element("vertex", 1).Float64("x").Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	vertex := f.Elements()[0]
+	vertex.Properties[0].Data = []float64{0.5}
+	if err := f.WriteElement(vertex); err != nil {
+		t.Fatal(err)
+	}
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+	if m, err := ReadMesh(path); err != nil || m.Element("vertex").Property("x").Data[0] != 0.5 {
+		t.Errorf("file written from a built header: %v", err)
+	}
+}
+
+/* TestHeaderBuilderErrors checks that Build returns the first invalid call. */
+func TestHeaderBuilderErrors(t *testing.T) {
+	tests := []struct {
+		b    *HeaderBuilder
+		want string
+	}{
+		{NewHeader(7), "plyfile: bad file type = 7"},
+		{NewHeader(FormatASCII).Float32("x"), "plyfile: property 'x' before first element"},
+		{NewHeader(FormatASCII).Element("my vertex", 1), "plyfile: bad element name 'my vertex'"},
+		{NewHeader(FormatASCII).Element("vertex", -1), "plyfile: element 'vertex' has negative count -1"},
+		{NewHeader(FormatASCII).Element("vertex", 1).Element("vertex", 2), "plyfile: element 'vertex' declared twice"},
+		{NewHeader(FormatASCII).Element("vertex", 1).Float32("x").Float64("x"), "plyfile: property 'x' declared twice in element 'vertex'"},
+		{NewHeader(FormatASCII).Element("vertex", 1).Property("x", 9), "plyfile: property 'x' of element 'vertex' has bad type = 9"},
+		{NewHeader(FormatASCII).Element("face", 1).List("vertex_indices", Float32, Int32),
+			"plyfile: list property 'vertex_indices' of element 'face' has bad count type = 7"},
+		{NewHeader(FormatASCII).Element("face", 1).List("vertex_indices", Uint8, 0), "plyfile: property 'vertex_indices' of element 'face' has bad type = 0"},
+		{NewHeader(FormatASCII).Comment("two\nlines"), "plyfile: comment contains a line break: 'two\nlines'"},
+		{NewHeader(FormatASCII).Element("vertex", 1).Int32("").Element("face", -1), "plyfile: bad property name ''"},
+	}
+	for _, test := range tests {
+		if _, err := test.b.Build(); err == nil || err.Error() != test.want {
+			t.Errorf("error %v, want %s", err, test.want)
+		}
+	}
+}
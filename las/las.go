@@ -0,0 +1,381 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package las reads uncompressed ASPRS LAS 1.2 to 1.4 point clouds into PLY meshes.
+
+The points become a PLY vertex element with the properties
+
+	double x, y, z             (scale and offset applied)
+	ushort intensity
+	uchar  return_number, number_of_returns, classification
+	double gps_time            (point formats with GPS time)
+	ushort red, green, blue    (point formats with colors)
+	ushort nir                 (point formats 8 and 10)
+
+The bounding box of the LAS header is stored as a "bounding_box min_x min_y min_z max_x max_y max_z" comment. The coordinate reference system, when the file describes one, is stored as a "crs EPSG:<code>" comment (from the GeoTIFF keys) or a "crs_wkt <wkt>" comment (from an OGC WKT record). Compressed (LAZ) files are not supported.
+*/
+package las
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+/* Header holds the fields of the LAS public header block used by the reader. */
+type Header struct {
+	VersionMajor     uint8
+	VersionMinor     uint8
+	SystemIdentifier string
+	Software         string
+	HeaderSize       uint16
+	PointDataOffset  uint32
+	NumberOfVLRs     uint32
+	PointFormat      uint8
+	PointRecordSize  uint16
+	NumberOfPoints   uint64
+	Scale            [3]float64
+	Offset           [3]float64
+	Min              [3]float64
+	Max              [3]float64
+	EVLRStart        uint64 /* LAS 1.4 only */
+	NumberOfEVLRs    uint32 /* LAS 1.4 only */
+}
+
+/* size of each point format without extra bytes, indexed by point format */
+var pointSizes = [...]uint16{20, 28, 26, 34, 57, 63, 30, 36, 38, 59, 67}
+
+/* GeoTIFF keys holding an EPSG code */
+const (
+	geographicTypeGeoKey  = 2048
+	projectedCSTypeGeoKey = 3072
+)
+
+/* reader keeps track of the position in the file, so that offsets in the header can be used with a plain io.Reader. */
+type reader struct {
+	br  *bufio.Reader
+	pos uint64
+}
+
+func (r *reader) read(buf []byte) error {
+	n, err := io.ReadFull(r.br, buf)
+	r.pos += uint64(n)
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return errors.New("las: unexpected end of file")
+	}
+	return err
+}
+
+/* skipTo discards everything up to the specified offset. */
+func (r *reader) skipTo(offset uint64) error {
+	if offset < r.pos {
+		return fmt.Errorf("las: offset %d points backwards (at %d)", offset, r.pos)
+	}
+	n, err := io.CopyN(io.Discard, r.br, int64(offset-r.pos))
+	r.pos += uint64(n)
+	if err == io.EOF {
+		return errors.New("las: unexpected end of file")
+	}
+	return err
+}
+
+func init() {
+	plyfile.RegisterFormat("las", sniff, Decode)
+}
+
+func sniff(prefix []byte) bool {
+	return bytes.HasPrefix(prefix, []byte("LASF"))
+}
+
+/* ReadHeader reads the public header block from the start of a LAS file. */
+func ReadHeader(r io.Reader) (*Header, error) {
+	return readHeader(&reader{br: bufio.NewReader(r)})
+}
+
+func readHeader(r *reader) (*Header, error) {
+	buf := make([]byte, 227)
+	if err := r.read(buf); err != nil {
+		return nil, err
+	}
+	if string(buf[0:4]) != "LASF" {
+		return nil, errors.New("las: not a LAS file")
+	}
+	le := binary.LittleEndian
+	h := &Header{
+		VersionMajor:     buf[24],
+		VersionMinor:     buf[25],
+		SystemIdentifier: cString(buf[26:58]),
+		Software:         cString(buf[58:90]),
+		HeaderSize:       le.Uint16(buf[94:]),
+		PointDataOffset:  le.Uint32(buf[96:]),
+		NumberOfVLRs:     le.Uint32(buf[100:]),
+		PointFormat:      buf[104],
+		PointRecordSize:  le.Uint16(buf[105:]),
+		NumberOfPoints:   uint64(le.Uint32(buf[107:])),
+	}
+	for i := 0; i < 3; i++ {
+		h.Scale[i] = math.Float64frombits(le.Uint64(buf[131+8*i:]))
+		h.Offset[i] = math.Float64frombits(le.Uint64(buf[155+8*i:]))
+		h.Max[i] = math.Float64frombits(le.Uint64(buf[179+16*i:]))
+		h.Min[i] = math.Float64frombits(le.Uint64(buf[187+16*i:]))
+	}
+
+	if h.VersionMajor != 1 || h.VersionMinor < 2 || h.VersionMinor > 4 {
+		return nil, fmt.Errorf("las: unsupported version %d.%d", h.VersionMajor, h.VersionMinor)
+	}
+	if h.PointFormat&0xc0 != 0 {
+		return nil, errors.New("las: compressed (LAZ) files are not supported")
+	}
+	if int(h.PointFormat) >= len(pointSizes) {
+		return nil, fmt.Errorf("las: unknown point format %d", h.PointFormat)
+	}
+	if h.PointRecordSize < pointSizes[h.PointFormat] {
+		return nil, fmt.Errorf("las: point records of %d bytes are too short for point format %d", h.PointRecordSize, h.PointFormat)
+	}
+	if h.HeaderSize < 227 || uint32(h.HeaderSize) > h.PointDataOffset {
+		return nil, fmt.Errorf("las: bad header size %d", h.HeaderSize)
+	}
+
+	/* the rest of the header: waveform data (1.3), extended VLRs and 64 bit point counts (1.4) */
+	rest := make([]byte, h.HeaderSize-227)
+	if err := r.read(rest); err != nil {
+		return nil, err
+	}
+	if h.VersionMinor == 4 && len(rest) >= 375-227 {
+		h.EVLRStart = le.Uint64(rest[235-227:])
+		h.NumberOfEVLRs = le.Uint32(rest[243-227:])
+		if h.NumberOfPoints == 0 {
+			h.NumberOfPoints = le.Uint64(rest[247-227:])
+		}
+	}
+	return h, nil
+}
+
+/* vlr is a (extended) variable length record */
+type vlr struct {
+	user string
+	id   uint16
+	data []byte
+}
+
+/* readVLR reads a variable length record, or an extended one (LAS 1.4) with a 64 bit length. */
+func readVLR(r *reader, extended bool) (vlr, error) {
+	size := 54
+	if extended {
+		size = 60
+	}
+	buf := make([]byte, size)
+	if err := r.read(buf); err != nil {
+		return vlr{}, err
+	}
+	v := vlr{user: cString(buf[2:18]), id: binary.LittleEndian.Uint16(buf[18:])}
+	var length uint64
+	if extended {
+		length = binary.LittleEndian.Uint64(buf[20:])
+	} else {
+		length = uint64(binary.LittleEndian.Uint16(buf[20:]))
+	}
+
+	/* only the projection records are kept, everything else is skipped */
+	if v.user != "LASF_Projection" {
+		return v, r.skipTo(r.pos + length)
+	}
+	var data bytes.Buffer
+	n, err := io.CopyN(&data, r.br, int64(length))
+	r.pos += uint64(n)
+	if err != nil {
+		return v, errors.New("las: unexpected end of file")
+	}
+	v.data = data.Bytes()
+	return v, nil
+}
+
+/* crsComment describes the coordinate reference system given by the projection records, or returns an empty string. */
+func crsComment(vlrs []vlr) string {
+	for _, v := range vlrs {
+		if v.id == 2112 && len(v.data) > 0 {
+			wkt := strings.Join(strings.Fields(cString(v.data)), " ")
+			if wkt != "" {
+				return "crs_wkt " + wkt
+			}
+		}
+	}
+	for _, v := range vlrs {
+		if v.id != 34735 || len(v.data) < 8 {
+			continue
+		}
+		/* GeoKeyDirectoryTag: a header followed by (key, location, count, value) entries */
+		keys := make([]uint16, len(v.data)/2)
+		for i := range keys {
+			keys[i] = binary.LittleEndian.Uint16(v.data[2*i:])
+		}
+		nkeys := int(keys[3])
+		var geographic uint16
+		for i := 1; i <= nkeys && 4*i+3 < len(keys); i++ {
+			key, location, value := keys[4*i], keys[4*i+1], keys[4*i+3]
+			if location != 0 || value == 0 || value == 32767 {
+				continue
+			}
+			switch key {
+			case projectedCSTypeGeoKey:
+				return "crs EPSG:" + strconv.Itoa(int(value))
+			case geographicTypeGeoKey:
+				geographic = value
+			}
+		}
+		if geographic != 0 {
+			return "crs EPSG:" + strconv.Itoa(int(geographic))
+		}
+	}
+	return ""
+}
+
+/* Decode reads a LAS file from r and returns its points as the vertex element of a PLY mesh. */
+func Decode(r io.Reader) (*plyfile.Mesh, error) {
+	rd := &reader{br: bufio.NewReader(r)}
+	h, err := readHeader(rd)
+	if err != nil {
+		return nil, err
+	}
+
+	var vlrs []vlr
+	for i := uint32(0); i < h.NumberOfVLRs && rd.pos < uint64(h.PointDataOffset); i++ {
+		v, err := readVLR(rd, false)
+		if err != nil {
+			return nil, err
+		}
+		vlrs = append(vlrs, v)
+	}
+	if err := rd.skipTo(uint64(h.PointDataOffset)); err != nil {
+		return nil, err
+	}
+
+	format := h.PointFormat
+	has_time := format == 1 || format >= 3
+	has_color := format == 2 || format == 3 || format == 5 || format == 7 || format == 8 || format == 10
+	has_nir := format == 8 || format == 10
+
+	/* columns are filled as points are read, so a bad point count can't cause a huge allocation */
+	var x, y, z, intensity, return_number, number_of_returns, classification, gps_time, red, green, blue, nir []float64
+	le := binary.LittleEndian
+	rec := make([]byte, h.PointRecordSize)
+	for i := uint64(0); i < h.NumberOfPoints; i++ {
+		if err := rd.read(rec); err != nil {
+			return nil, fmt.Errorf("las: point %d: %v", i, err)
+		}
+		x = append(x, float64(int32(le.Uint32(rec[0:])))*h.Scale[0]+h.Offset[0])
+		y = append(y, float64(int32(le.Uint32(rec[4:])))*h.Scale[1]+h.Offset[1])
+		z = append(z, float64(int32(le.Uint32(rec[8:])))*h.Scale[2]+h.Offset[2])
+		intensity = append(intensity, float64(le.Uint16(rec[12:])))
+
+		color := 0
+		if format < 6 {
+			return_number = append(return_number, float64(rec[14]&0x07))
+			number_of_returns = append(number_of_returns, float64(rec[14]>>3&0x07))
+			classification = append(classification, float64(rec[15]&0x1f))
+			if has_time {
+				gps_time = append(gps_time, math.Float64frombits(le.Uint64(rec[20:])))
+			}
+			color = 20
+			if format == 3 || format == 5 {
+				color = 28
+			}
+		} else {
+			return_number = append(return_number, float64(rec[14]&0x0f))
+			number_of_returns = append(number_of_returns, float64(rec[14]>>4))
+			classification = append(classification, float64(rec[16]))
+			gps_time = append(gps_time, math.Float64frombits(le.Uint64(rec[22:])))
+			color = 30
+		}
+		if has_color {
+			red = append(red, float64(le.Uint16(rec[color:])))
+			green = append(green, float64(le.Uint16(rec[color+2:])))
+			blue = append(blue, float64(le.Uint16(rec[color+4:])))
+		}
+		if has_nir {
+			nir = append(nir, float64(le.Uint16(rec[36:])))
+		}
+	}
+
+	/* extended VLRs follow the points in LAS 1.4 files */
+	if h.NumberOfEVLRs > 0 && h.EVLRStart >= rd.pos {
+		if err := rd.skipTo(h.EVLRStart); err != nil {
+			return nil, err
+		}
+		for i := uint32(0); i < h.NumberOfEVLRs; i++ {
+			v, err := readVLR(rd, true)
+			if err != nil {
+				return nil, err
+			}
+			vlrs = append(vlrs, v)
+		}
+	}
+
+	m := plyfile.NewMesh(plyfile.PLY_BINARY_LE)
+	m.Comments = append(m.Comments, fmt.Sprintf("source LAS %d.%d point format %d", h.VersionMajor, h.VersionMinor, h.PointFormat))
+	m.Comments = append(m.Comments, fmt.Sprintf("bounding_box %s %s %s %s %s %s",
+		formatFloat(h.Min[0]), formatFloat(h.Min[1]), formatFloat(h.Min[2]),
+		formatFloat(h.Max[0]), formatFloat(h.Max[1]), formatFloat(h.Max[2])))
+	if crs := crsComment(vlrs); crs != "" {
+		m.Comments = append(m.Comments, crs)
+	}
+
+	vertex := m.AddElement("vertex", len(x))
+	add := func(name string, typ int, data []float64) {
+		prop := vertex.AddProperty(name, typ)
+		copy(prop.Data, data)
+	}
+	add("x", plyfile.PLY_DOUBLE, x)
+	add("y", plyfile.PLY_DOUBLE, y)
+	add("z", plyfile.PLY_DOUBLE, z)
+	add("intensity", plyfile.PLY_USHORT, intensity)
+	add("return_number", plyfile.PLY_UCHAR, return_number)
+	add("number_of_returns", plyfile.PLY_UCHAR, number_of_returns)
+	add("classification", plyfile.PLY_UCHAR, classification)
+	if has_time {
+		add("gps_time", plyfile.PLY_DOUBLE, gps_time)
+	}
+	if has_color {
+		add("red", plyfile.PLY_USHORT, red)
+		add("green", plyfile.PLY_USHORT, green)
+		add("blue", plyfile.PLY_USHORT, blue)
+	}
+	if has_nir {
+		add("nir", plyfile.PLY_USHORT, nir)
+	}
+	return m, nil
+}
+
+/* cString returns the text of a NUL padded string field. */
+func cString(b []byte) string {
+	if i := bytes.IndexByte(b, 0); i >= 0 {
+		b = b[:i]
+	}
+	return string(b)
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
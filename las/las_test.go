@@ -0,0 +1,211 @@
+package las
+
+import (
+	"bytes"
+	"encoding/binary"
+	"math"
+	"reflect"
+	"testing"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+/* lasPoint is a point of a test file, in raw (unscaled) coordinates */
+type lasPoint struct {
+	x, y, z        int32
+	intensity      uint16
+	ret, returns   uint8
+	class          uint8
+	time           float64
+	red, grn, blue uint16
+}
+
+/* buildLAS writes a LAS file with the given version, point format and records. A GeoTIFF key record is written for LAS 1.2, an OGC WKT extended record for LAS 1.4. */
+func buildLAS(minor, format uint8, points []lasPoint) []byte {
+	le := binary.LittleEndian
+	header_size := 227
+	if minor == 4 {
+		header_size = 375
+	}
+	record_size := int(pointSizes[format]) + 2 /* two extra bytes per point */
+
+	var vlrs []byte
+	if minor == 2 {
+		/* GeoKeyDirectoryTag with a projected CS type of EPSG:32633 */
+		keys := []uint16{1, 1, 0, 2, 1024, 0, 1, 1, 3072, 0, 1, 32633}
+		data := make([]byte, 2*len(keys))
+		for i, k := range keys {
+			le.PutUint16(data[2*i:], k)
+		}
+		vlrs = append(vlrHeader("LASF_Projection", 34735, len(data), false), data...)
+	}
+	/* a record that is skipped */
+	vlrs = append(vlrs, vlrHeader("LASF_Spec", 3, 4, false)...)
+	vlrs = append(vlrs, 1, 2, 3, 4)
+
+	buf := make([]byte, header_size)
+	copy(buf, "LASF")
+	buf[24], buf[25] = 1, minor
+	copy(buf[58:], "go-plyfile test")
+	le.PutUint16(buf[94:], uint16(header_size))
+	le.PutUint32(buf[96:], uint32(header_size+len(vlrs)))
+	le.PutUint32(buf[100:], 2)
+	buf[104] = format
+	le.PutUint16(buf[105:], uint16(record_size))
+	le.PutUint32(buf[107:], uint32(len(points)))
+	for i, v := range []float64{0.01, 0.01, 0.001, 1000, 2000, 0, 10, 0, 20, 0, 3, -1} {
+		le.PutUint64(buf[131+8*i:], math.Float64bits(v))
+	}
+	buf = append(buf, vlrs...)
+
+	for _, p := range points {
+		rec := make([]byte, record_size)
+		le.PutUint32(rec[0:], uint32(p.x))
+		le.PutUint32(rec[4:], uint32(p.y))
+		le.PutUint32(rec[8:], uint32(p.z))
+		le.PutUint16(rec[12:], p.intensity)
+		color := 20
+		if format < 6 {
+			rec[14] = p.ret | p.returns<<3
+			rec[15] = p.class | 0x80 /* withheld flag */
+			le.PutUint64(rec[20:], math.Float64bits(p.time))
+			color = 28
+		} else {
+			rec[14] = p.ret | p.returns<<4
+			rec[16] = p.class
+			le.PutUint64(rec[22:], math.Float64bits(p.time))
+			color = 30
+		}
+		le.PutUint16(rec[color:], p.red)
+		le.PutUint16(rec[color+2:], p.grn)
+		le.PutUint16(rec[color+4:], p.blue)
+		buf = append(buf, rec...)
+	}
+
+	if minor == 4 {
+		wkt := "GEOGCS[\"WGS 84\",\n  DATUM[\"WGS_1984\"]]\x00"
+		le.PutUint64(buf[235:], uint64(len(buf)))
+		le.PutUint32(buf[243:], 1)
+		le.PutUint32(buf[107:], 0)
+		le.PutUint64(buf[247:], uint64(len(points)))
+		buf = append(buf, vlrHeader("LASF_Projection", 2112, len(wkt), true)...)
+		buf = append(buf, wkt...)
+	}
+	return buf
+}
+
+func vlrHeader(user string, id uint16, length int, extended bool) []byte {
+	h := make([]byte, 54)
+	if extended {
+		h = make([]byte, 60)
+		binary.LittleEndian.PutUint64(h[20:], uint64(length))
+	} else {
+		binary.LittleEndian.PutUint16(h[20:], uint16(length))
+	}
+	copy(h[2:18], user)
+	binary.LittleEndian.PutUint16(h[18:], id)
+	return h
+}
+
+var testPoints = []lasPoint{
+	{100, 200, 300, 10, 1, 2, 2, 1.5, 65535, 0, 0},
+	{-100, 0, 3000, 20, 2, 2, 6, 2.5, 0, 65535, 256},
+}
+
+func checkPoints(t *testing.T, m *plyfile.Mesh, names []string) {
+	vertex := m.Element("vertex")
+	if vertex == nil || vertex.Count != 2 {
+		t.Fatalf("bad vertex element %v", vertex)
+	}
+	var got []string
+	for _, prop := range vertex.Properties {
+		got = append(got, prop.Name+":"+plyfile.TypeName(prop.Type))
+	}
+	if !reflect.DeepEqual(got, names) {
+		t.Errorf("properties = %v, want %v", got, names)
+	}
+
+	want := map[string][]float64{
+		"x":                 {1001, 999},
+		"y":                 {2002, 2000},
+		"z":                 {0.3, 3},
+		"intensity":         {10, 20},
+		"return_number":     {1, 2},
+		"number_of_returns": {2, 2},
+		"classification":    {2, 6},
+		"gps_time":          {1.5, 2.5},
+		"red":               {65535, 0},
+		"green":             {0, 65535},
+		"blue":              {0, 256},
+	}
+	for name, values := range want {
+		prop := vertex.Property(name)
+		for i, v := range values {
+			if math.Abs(prop.Data[i]-v) > 1e-9 {
+				t.Errorf("%s = %v, want %v", name, prop.Data, values)
+				break
+			}
+		}
+	}
+}
+
+func TestDecode12(t *testing.T) {
+	m, err := Decode(bytes.NewReader(buildLAS(2, 3, testPoints)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPoints(t, m, []string{"x:double", "y:double", "z:double", "intensity:ushort", "return_number:uchar",
+		"number_of_returns:uchar", "classification:uchar", "gps_time:double", "red:ushort", "green:ushort", "blue:ushort"})
+	want := []string{"source LAS 1.2 point format 3", "bounding_box 0 0 -1 10 20 3", "crs EPSG:32633"}
+	if !reflect.DeepEqual(m.Comments, want) {
+		t.Errorf("comments = %q, want %q", m.Comments, want)
+	}
+}
+
+func TestDecode14(t *testing.T) {
+	m, err := Decode(bytes.NewReader(buildLAS(4, 7, testPoints)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	checkPoints(t, m, []string{"x:double", "y:double", "z:double", "intensity:ushort", "return_number:uchar",
+		"number_of_returns:uchar", "classification:uchar", "gps_time:double", "red:ushort", "green:ushort", "blue:ushort"})
+	want := []string{"source LAS 1.4 point format 7", "bounding_box 0 0 -1 10 20 3", `crs_wkt GEOGCS["WGS 84", DATUM["WGS_1984"]]`}
+	if !reflect.DeepEqual(m.Comments, want) {
+		t.Errorf("comments = %q, want %q", m.Comments, want)
+	}
+
+	/* the mesh can be written as a PLY file */
+	var buf bytes.Buffer
+	if err := plyfile.EncodeMesh(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	good := buildLAS(2, 3, testPoints)
+	laz := append([]byte(nil), good...)
+	laz[104] |= 0x80
+	version := append([]byte(nil), good...)
+	version[25] = 1
+
+	tests := map[string][]byte{
+		"empty":     nil,
+		"magic":     append([]byte("LASX"), good[4:]...),
+		"laz":       laz,
+		"version":   version,
+		"truncated": good[:len(good)-10],
+	}
+	for name, data := range tests {
+		if _, err := Decode(bytes.NewReader(data)); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add(buildLAS(2, 3, testPoints))
+	f.Add(buildLAS(4, 7, testPoints))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Decode(bytes.NewReader(data))
+	})
+}
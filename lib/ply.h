@@ -63,6 +63,27 @@ extern "C" {
 #define  PLY_SCALAR  0
 #define  PLY_LIST    1
 
+/* policies for values that don't fit the type they are converted to */
+
+#define PLY_CONVERT_DEFAULT          0  /* same as PLY_CONVERT_WRAP */
+#define PLY_CONVERT_WRAP             1  /* cast, as C does */
+#define PLY_CONVERT_SATURATE         2  /* clamp to the range of the type */
+#define PLY_CONVERT_ERROR_OVERFLOW   3  /* report values out of range */
+#define PLY_CONVERT_ERROR_PRECISION  4  /* report values that change */
+
+/* limits set by ply_set_limits that the body of a file can exceed, */
+/* and by ply_open_and_read_header_limits that the header can exceed */
+
+#define PLY_LIMIT_ELEMENT_COUNT   1
+#define PLY_LIMIT_LIST_LENGTH     2
+#define PLY_LIMIT_HEADER_LINES    3
+#define PLY_LIMIT_COMMENT_LENGTH  4
+
+/* reasons a value can't be converted */
+
+#define PLY_OUT_OF_RANGE       1
+#define PLY_LOSES_PRECISION    2
+
 
 typedef struct PlyProperty {    /* description of a property */
 
@@ -112,6 +133,21 @@ typedef struct PlyOtherElems {  /* "other" elements, not interpreted by user */
   OtherElem *other_list;        /* list of data for other elements */
 } PlyOtherElems;
 
+typedef struct PlyHeaderError { /* why a header was rejected */
+  int line;                     /* offending line, counting from 1, or 0 */
+  char text[1024];              /* the offending line */
+  char reason[256];             /* why it was rejected, or why the file couldn't be opened */
+  int limit;                    /* PLY_LIMIT_* exceeded by the header, or 0 */
+  double value;                 /* the value that exceeded it, or 0 if not known */
+} PlyHeaderError;
+
+typedef struct PlyLimits {      /* limits on what is read from an untrusted file, 0 for no limit */
+  int max_header_lines;         /* largest number of header lines, from "ply" to "end_header" */
+  int max_comment_length;       /* largest length of the text of a comment or obj_info line */
+  int max_element_count;        /* largest count of an element read, see ply_set_limits */
+  int max_list_length;          /* largest list count read, see ply_set_limits */
+} PlyLimits;
+
 typedef struct PlyFile {        /* description of PLY file */
   FILE *fp;                     /* file pointer */
   int file_type;                /* ascii or binary */
@@ -124,6 +160,31 @@ typedef struct PlyFile {        /* description of PLY file */
   char **obj_info;              /* list of object info items */
   PlyElement *which_elem;       /* which element we're currently writing */
   PlyOtherElems *other_elems;   /* "other" elements from a PLY file */
+  char **lists;                 /* memory allocated by ply_get_element */
+  int num_lists;                /* number of blocks in lists */
+  int max_lists;                /* room in lists */
+  int elem_index;               /* index of the element being read or written */
+  int convert_policy;           /* PLY_CONVERT_* */
+  int convert_reason;           /* PLY_OUT_OF_RANGE or PLY_LOSES_PRECISION */
+  char *convert_elem;           /* element of the first value not converted */
+  int convert_index;            /* its index */
+  char *convert_prop;           /* its property */
+  double convert_value;         /* the value */
+  int convert_type;             /* the type it was converted to */
+  PlyHeaderError *header_error; /* where read_header reports errors, only while reading the header */
+  int line_num;                 /* number of the last line read, in ascii files */
+  long file_size;               /* size of the file being read, or -1 if unknown */
+  char body_reason[256];        /* why the body couldn't be read, or "" */
+  char *body_elem;              /* element of the first value not read */
+  int body_index;               /* its index */
+  char *body_prop;              /* its property, or NULL */
+  int body_line;                /* its line, in ascii files, or 0 */
+  int max_header_lines;         /* largest number of header lines, or 0 for no limit */
+  int max_comment_length;       /* largest length of a comment or obj_info text, or 0 for no limit */
+  int max_element_count;        /* largest count of an element read, or 0 for no limit */
+  int max_list_length;          /* largest list count read, or 0 for no limit */
+  int body_limit;               /* PLY_LIMIT_* exceeded by the body, or 0 */
+  double body_value;            /* the count that exceeded it */
 } PlyFile;
 
 /* memory allocation */
@@ -145,8 +206,13 @@ extern void ply_put_element(PlyFile *, void *);
 extern void ply_put_comment(PlyFile *, char *);
 extern void ply_put_obj_info(PlyFile *, char *);
 extern PlyFile *ply_read(FILE *, int *, char ***);
+extern PlyFile *ply_read_error(FILE *, int *, char ***, PlyHeaderError *);
 extern PlyFile *ply_open_and_read_header(char *);
+extern PlyFile *ply_open_and_read_header_error(char *, PlyHeaderError *);
+extern PlyFile *ply_open_and_read_header_limits(char *, PlyHeaderError *, PlyLimits *);
+extern int ply_get_header_error(char **, char **);
 extern PlyFile *ply_open_for_reading( char *, int *, char ***, int *, float *);
+extern PlyFile *ply_open_for_reading_error( char *, int *, char ***, int *, float *, PlyHeaderError *);
 extern PlyProperty **ply_get_element_description(PlyFile *, char *, int*, int*);
 extern void ply_get_element_setup( PlyFile *, char *, int, PlyProperty *);
 extern void ply_get_property(PlyFile *, char *, PlyProperty *);
@@ -156,6 +222,11 @@ extern PlyElement *ply_get_element_by_index(PlyFile *, int);
 extern char **ply_get_comments(PlyFile *, int *);
 extern char **ply_get_obj_info(PlyFile *, int *);
 extern void ply_close(PlyFile *);
+extern void ply_free_lists(PlyFile *);
+extern void ply_set_convert_policy(PlyFile *, int);
+extern int ply_get_convert_error(PlyFile *, char **, int *, char **, double *, int *);
+extern char *ply_get_body_error(PlyFile *, char **, int *, char **, int *);
+extern void ply_set_limits(PlyFile *, int, int);
 extern void ply_get_info(PlyFile *, float *, int *);
 extern PlyOtherElems *ply_get_other_element (PlyFile *, char *, int);
 extern void ply_describe_other_elements ( PlyFile *, PlyOtherElems *);
@@ -34,8 +34,14 @@ WARRANTY OF MERCHANTABILITY OR FITNESS FOR A PARTICULAR PURPOSE.
 
 #include <stdio.h>
 #include <stdlib.h>
+#include <stdarg.h>
+#include <limits.h>
+#include <locale.h>
+#include <errno.h>
+#include <float.h>
 #include <math.h>
 #include <string.h>
+#include <sys/stat.h>
 #include "ply.h"
 
 char *type_names[] = {
@@ -49,6 +55,14 @@ int ply_type_size[] = {
   0, 1, 2, 4, 1, 2, 4, 4, 8
 };
 
+/* sized type names written by newer PLY producers, accepted when reading */
+char *type_aliases[] = {
+"invalid",
+"int8", "int16", "int32",
+"uint8", "uint16", "uint32",
+"float32", "float64",
+};
+
 #define NO_OTHER_PROPS  -1
 
 #define DONT_STORE_PROP  0
@@ -75,13 +89,20 @@ char **get_words(FILE *, int *, char **);
 char **old_get_words(FILE *, int *);
 
 /* write an item to a file */
-void write_binary_item(FILE *, int, unsigned int, double, int);
+void write_binary_item(PlyFile *, int, unsigned int, double, int);
 void write_ascii_item(FILE *, int, unsigned int, double, int);
 double old_write_ascii_item(FILE *, char *, int);
 
+/* write and read floating point values in ascii, whatever the C locale */
+static void write_ascii_real(FILE *, double, int);
+static double ascii_to_real(char *, int);
+
+/* whether a float holds the shortest decimal form of a value */
+static int same_decimal32(double);
+
 /* add information to a PLY file descriptor */
-void add_element(PlyFile *, char **, int);
-void add_property(PlyFile *, char **, int);
+int add_element(PlyFile *, char **, int);
+int add_property(PlyFile *, char **, int);
 void add_comment(PlyFile *, char *);
 void add_obj_info(PlyFile *, char *);
 
@@ -99,7 +120,17 @@ double get_item_value(char *, int);
 
 /* get binary or ascii item and store it according to ptr and type */
 void get_ascii_item(char *, int, int *, unsigned int *, double *);
-void get_binary_item(FILE *, int, int *, unsigned int *, double *);
+void get_binary_item(PlyFile *, int, int *, unsigned int *, double *);
+
+/* read and write binary values in the byte order of the file */
+static int native_binary_type(void);
+static void read_binary(PlyFile *, void *, int);
+static void write_binary(PlyFile *, void *, int);
+static void swap_binary(PlyFile *, char *, int);
+
+/* apply the conversion policy to an item */
+static void convert_item(PlyFile *, PlyProperty *, int, int *, unsigned int *, double *);
+static void check_ascii_float(PlyFile *, PlyProperty *, char *);
 
 /* get a bunch of elements from a file */
 void ascii_get_element(PlyFile *, char *);
@@ -108,6 +139,29 @@ void binary_get_element(PlyFile *, char *);
 /* memory allocation */
 char *my_alloc(int, int, char *);
 
+/* read and check the header of a file */
+static int read_header(PlyFile *);
+
+/* free the memory of a file, except for its file pointer */
+static void free_plyfile(PlyFile *);
+
+/* remember memory allocated by ply_get_element, to be freed by ply_close */
+static void keep_list(PlyFile *, char *);
+
+/* the error of the last header read without a PlyHeaderError of its own, see ply_get_header_error */
+static PlyHeaderError last_header_error;
+static void clear_header_error(PlyHeaderError *);
+static void header_error(PlyFile *, char *, ...);
+static void header_limit(PlyFile *, int, double);
+static int check_header_text(PlyFile *, char *);
+
+/* remember the first value of the body that can't be read, see ply_get_body_error */
+static void body_error(PlyFile *, PlyProperty *, char *, ...);
+static int check_list_count(PlyFile *, PlyProperty *, double);
+
+/* size of a regular file, or -1 */
+static long file_size(FILE *);
+
 
 /*************/
 /*  Writing  */
@@ -152,6 +206,20 @@ PlyFile *ply_write(
   plyfile->version = 1.0;
   plyfile->fp = fp;
   plyfile->other_elems = NULL;
+  plyfile->lists = NULL;
+  plyfile->num_lists = 0;
+  plyfile->max_lists = 0;
+  plyfile->elem_index = 0;
+  plyfile->convert_policy = PLY_CONVERT_DEFAULT;
+  plyfile->convert_reason = 0;
+  plyfile->line_num = 0;
+  plyfile->file_size = -1;
+  plyfile->body_reason[0] = '\0';
+  plyfile->max_header_lines = 0;
+  plyfile->max_comment_length = 0;
+  plyfile->max_element_count = 0;
+  plyfile->max_list_length = 0;
+  plyfile->body_limit = 0;
 
   /* tuck aside the names of the elements */
 
@@ -162,6 +230,8 @@ PlyFile *ply_write(
     elem->name = strdup (elem_names[i]);
     elem->num = 0;
     elem->nprops = 0;
+    elem->props = NULL;
+    elem->store_prop = NULL;
   }
 
   /* return pointer to the file descriptor */
@@ -208,6 +278,7 @@ PlyFile *ply_open_for_writing(
   /* open the file for writing */
 
   fp = fopen (name, "w");
+  free (name);
   if (fp == NULL) {
     return (NULL);
   }
@@ -547,6 +618,7 @@ void ply_put_element_setup(PlyFile *plyfile, char *elem_name)
   }
 
   plyfile->which_elem = elem;
+  plyfile->elem_index = 0;
 }
 
 
@@ -596,6 +668,8 @@ void ply_put_element(PlyFile *plyfile, void *elem_ptr)
         item = elem_data + prop->count_offset;
         get_stored_item ((void *) item, prop->count_internal,
                          &int_val, &uint_val, &double_val);
+        convert_item (plyfile, prop, prop->count_external,
+                      &int_val, &uint_val, &double_val);
         write_ascii_item (fp, int_val, uint_val, double_val,
                           prop->count_external);
         list_count = uint_val;
@@ -605,6 +679,8 @@ void ply_put_element(PlyFile *plyfile, void *elem_ptr)
         for (k = 0; k < list_count; k++) {
           get_stored_item ((void *) item, prop->internal_type,
                            &int_val, &uint_val, &double_val);
+          convert_item (plyfile, prop, prop->external_type,
+                        &int_val, &uint_val, &double_val);
           write_ascii_item (fp, int_val, uint_val, double_val,
                             prop->external_type);
           item += item_size;
@@ -614,6 +690,8 @@ void ply_put_element(PlyFile *plyfile, void *elem_ptr)
         item = elem_data + prop->offset;
         get_stored_item ((void *) item, prop->internal_type,
                          &int_val, &uint_val, &double_val);
+        convert_item (plyfile, prop, prop->external_type,
+                      &int_val, &uint_val, &double_val);
         write_ascii_item (fp, int_val, uint_val, double_val,
                           prop->external_type);
       }
@@ -637,7 +715,9 @@ void ply_put_element(PlyFile *plyfile, void *elem_ptr)
         item_size = ply_type_size[prop->count_internal];
         get_stored_item ((void *) item, prop->count_internal,
                          &int_val, &uint_val, &double_val);
-        write_binary_item (fp, int_val, uint_val, double_val,
+        convert_item (plyfile, prop, prop->count_external,
+                      &int_val, &uint_val, &double_val);
+        write_binary_item (plyfile, int_val, uint_val, double_val,
                            prop->count_external);
         list_count = uint_val;
         item_ptr = (char **) (elem_data + prop->offset);
@@ -646,7 +726,9 @@ void ply_put_element(PlyFile *plyfile, void *elem_ptr)
         for (k = 0; k < list_count; k++) {
           get_stored_item ((void *) item, prop->internal_type,
                            &int_val, &uint_val, &double_val);
-          write_binary_item (fp, int_val, uint_val, double_val,
+          convert_item (plyfile, prop, prop->external_type,
+                        &int_val, &uint_val, &double_val);
+          write_binary_item (plyfile, int_val, uint_val, double_val,
                              prop->external_type);
           item += item_size;
         }
@@ -656,12 +738,16 @@ void ply_put_element(PlyFile *plyfile, void *elem_ptr)
         item_size = ply_type_size[prop->internal_type];
         get_stored_item ((void *) item, prop->internal_type,
                          &int_val, &uint_val, &double_val);
-        write_binary_item (fp, int_val, uint_val, double_val,
+        convert_item (plyfile, prop, prop->external_type,
+                      &int_val, &uint_val, &double_val);
+        write_binary_item (plyfile, int_val, uint_val, double_val,
                            prop->external_type);
       }
     }
 
   }
+
+  plyfile->elem_index++;
 }
 
 
@@ -724,7 +810,9 @@ void ply_put_obj_info(PlyFile *plyfile, char *obj_info)
 
 
 /******************************************************************************
-Given a file pointer, get ready to read PLY data from the file.
+Given a file pointer, get ready to read PLY data from the file.  Why the
+header was rejected is kept for ply_get_header_error, which is shared by
+all the files read this way; ply_read_error reports it per file instead.
 
 Entry:
   fp - the given file pointer
@@ -737,16 +825,39 @@ Exit:
 
 PlyFile *ply_read(FILE *fp, int *nelems, char ***elem_names)
 {
-  int i,j;
+  return (ply_read_error (fp, nelems, elem_names, &last_header_error));
+}
+
+
+/******************************************************************************
+Given a file pointer, get ready to read PLY data from the file, reporting
+why the header was rejected.
+
+Entry:
+  fp    - the given file pointer
+  error - where to report the error
+
+Exit:
+  nelems     - number of elements in object
+  elem_names - list of element names
+  error      - the offending line and the reason, or line 0 and no reason
+               on success
+  returns a pointer to a PlyFile, used to refer to this file, or NULL if error
+******************************************************************************/
+
+PlyFile *ply_read_error(
+  FILE *fp,
+  int *nelems,
+  char ***elem_names,
+  PlyHeaderError *error
+)
+{
+  int i;
   PlyFile *plyfile;
-  int nwords;
-  char **words;
-  int found_format = 0;
   char **elist;
-  PlyElement *elem;
-  char *orig_line;
 
   /* check for NULL file pointer */
+  clear_header_error (error);
   if (fp == NULL)
     return (NULL);
 
@@ -760,48 +871,156 @@ PlyFile *ply_read(FILE *fp, int *nelems, char ***elem_names)
   plyfile->num_obj_info = 0;
   plyfile->fp = fp;
   plyfile->other_elems = NULL;
+  plyfile->lists = NULL;
+  plyfile->num_lists = 0;
+  plyfile->max_lists = 0;
+  plyfile->elem_index = 0;
+  plyfile->convert_policy = PLY_CONVERT_DEFAULT;
+  plyfile->convert_reason = 0;
+  plyfile->header_error = error;
+  plyfile->line_num = 0;
+  plyfile->file_size = file_size (fp);
+  plyfile->body_reason[0] = '\0';
+  plyfile->max_header_lines = 0;
+  plyfile->max_comment_length = 0;
+  plyfile->max_element_count = 0;
+  plyfile->max_list_length = 0;
+  plyfile->body_limit = 0;
 
   /* read and parse the file's header */
 
-  words = get_words (plyfile->fp, &nwords, &orig_line);
-  if (!words || !equal_strings (words[0], "ply"))
+  if (read_header (plyfile) < 0) {
+    free_plyfile (plyfile);
     return (NULL);
+  }
+  plyfile->header_error = NULL;
+
+  /* set return values about the elements */
+
+  elist = (char **) myalloc (sizeof (char *) * plyfile->nelems);
+  for (i = 0; i < plyfile->nelems; i++)
+    elist[i] = strdup (plyfile->elems[i]->name);
 
-  while (words) {
+  *elem_names = elist;
+  *nelems = plyfile->nelems;
+
+  /* return a pointer to the file's information */
+
+  return (plyfile);
+}
+
+/******************************************************************************
+Read and check the header of a PLY file, up to and including end_header.
+
+Entry:
+  plyfile - PLY file descriptor, positioned at the start of the file
+
+Exit:
+  returns 0 if the header is complete and valid, -1 otherwise
+******************************************************************************/
+
+static int read_header(PlyFile *plyfile)
+{
+  int i,j;
+  int nwords;
+  char **words;
+  int found_format = 0;
+  int found_end = 0;
+  int error = 0;
+  int line_num = 1;
+  PlyElement *elem;
+  char *orig_line;
+
+  PlyHeaderError *err = plyfile->header_error;
+
+  clear_header_error (err);
+
+  words = get_words (plyfile->fp, &nwords, &orig_line);
+  if (!words || nwords == 0 || !equal_strings (words[0], "ply")) {
+    header_error (plyfile, "not a PLY file");
+    err->line = 1;
+    if (orig_line)
+      strncpy (err->text, orig_line, sizeof (err->text) - 1);
+    free (words);
+    return (-1);
+  }
+  free (words);
+
+  while (!found_end && !error) {
+
+    line_num++;
+    if (plyfile->max_header_lines > 0 && line_num > plyfile->max_header_lines) {
+      header_limit (plyfile, PLY_LIMIT_HEADER_LINES, 0);
+      err->line = line_num;
+      break;
+    }
+    words = get_words (plyfile->fp, &nwords, &orig_line);
+    if (!words) {
+      header_error (plyfile, "unexpected end of file in header");
+      err->line = line_num;
+      break;
+    }
 
     /* parse words */
 
-    if (equal_strings (words[0], "format")) {
-      if (nwords != 3)
-        return (NULL);
-      if (equal_strings (words[1], "ascii"))
+    if (nwords == 0) {
+      header_error (plyfile, "blank line in header");
+      error = 1;
+    }
+    else if (equal_strings (words[0], "format")) {
+      if (nwords != 3) {
+        header_error (plyfile, "bad format line");
+        error = 1;
+      }
+      else if (equal_strings (words[1], "ascii"))
         plyfile->file_type = PLY_ASCII;
       else if (equal_strings (words[1], "binary_big_endian"))
         plyfile->file_type = PLY_BINARY_BE;
       else if (equal_strings (words[1], "binary_little_endian"))
         plyfile->file_type = PLY_BINARY_LE;
-      else
-        return (NULL);
-      plyfile->version = atof (words[2]);
+      else {
+        header_error (plyfile, "unknown format '%s'", words[1]);
+        error = 1;
+      }
+      if (!error)
+        plyfile->version = ascii_to_real (words[2], PLY_FLOAT);
       found_format = 1;
     }
     else if (equal_strings (words[0], "element"))
-      add_element (plyfile, words, nwords);
+      error = add_element (plyfile, words, nwords) < 0;
     else if (equal_strings (words[0], "property"))
-      add_property (plyfile, words, nwords);
-    else if (equal_strings (words[0], "comment"))
-      add_comment (plyfile, orig_line);
-    else if (equal_strings (words[0], "obj_info"))
-      add_obj_info (plyfile, orig_line);
-    else if (equal_strings (words[0], "end_header"))
-      break;
+      error = add_property (plyfile, words, nwords) < 0;
+    else if (equal_strings (words[0], "comment")) {
+      error = !check_header_text (plyfile, orig_line);
+      if (!error)
+        add_comment (plyfile, orig_line);
+    }
+    else if (equal_strings (words[0], "obj_info")) {
+      error = !check_header_text (plyfile, orig_line);
+      if (!error)
+        add_obj_info (plyfile, orig_line);
+    }
+    else if (equal_strings (words[0], "end_header")) {
+      found_end = 1;
+      if (!found_format) {
+        header_error (plyfile, "missing format line");
+        error = 1;
+      }
+    }
+
+    if (error) {
+      err->line = line_num;
+      strncpy (err->text, orig_line, sizeof (err->text) - 1);
+    }
 
     /* free up words space */
     free (words);
-
-    words = get_words (plyfile->fp, &nwords, &orig_line);
   }
 
+  if (error || !found_end || !found_format)
+    return (-1);
+  plyfile->line_num = line_num;
+
   /* create tags for each property of each element, to be used */
   /* later to say whether or not to store each property for the user */
 
@@ -813,20 +1032,124 @@ PlyFile *ply_read(FILE *fp, int *nelems, char ***elem_names)
     elem->other_offset = NO_OTHER_PROPS; /* no "other" props by default */
   }
 
-  /* set return values about the elements */
+  return (0);
+}
 
-  elist = (char **) myalloc (sizeof (char *) * plyfile->nelems);
-  for (i = 0; i < plyfile->nelems; i++)
-    elist[i] = strdup (plyfile->elems[i]->name);
 
-  *elem_names = elist;
-  *nelems = plyfile->nelems;
+/******************************************************************************
+Describe why the last header read by ply_read, ply_open_and_read_header or
+ply_open_for_reading was rejected.  Deprecated: the last header may have been
+read by another thread, so use ply_read_error, ply_open_and_read_header_error
+or ply_open_for_reading_error, which report the error of each file.
 
-  /* return a pointer to the file's information */
+Entry:
+  text   - returns the offending header line
+  reason - returns why it was rejected
 
-  return (plyfile);
+Exit:
+  returns the number of the offending line, counting from 1, or 0 if the
+  last header was read without errors (or the file couldn't be opened)
+******************************************************************************/
+
+int ply_get_header_error(char **text, char **reason)
+{
+  *text = last_header_error.text;
+  *reason = last_header_error.reason;
+  return (last_header_error.line);
 }
 
+
+/******************************************************************************
+Clear a header error before reading a header.
+******************************************************************************/
+
+static void clear_header_error(PlyHeaderError *err)
+{
+  err->line = 0;
+  err->text[0] = '\0';
+  err->reason[0] = '\0';
+  err->limit = 0;
+  err->value = 0;
+}
+
+
+/******************************************************************************
+Record the reason for rejecting the header of a file.  Only the first reason
+is kept.
+******************************************************************************/
+
+static void header_error(PlyFile *plyfile, char *format, ...)
+{
+  va_list args;
+  PlyHeaderError *err = plyfile->header_error;
+
+  if (err->reason[0] != '\0')
+    return;
+  va_start (args, format);
+  vsnprintf (err->reason, sizeof (err->reason), format, args);
+  va_end (args);
+}
+
+
+/******************************************************************************
+Record that the header of a file exceeds one of the limits of
+ply_open_and_read_header_limits.
+
+Entry:
+  plyfile - file whose header is read
+  limit   - the PLY_LIMIT_* exceeded
+  value   - the value that exceeded it, or 0 if not known
+******************************************************************************/
+
+static void header_limit(PlyFile *plyfile, int limit, double value)
+{
+  PlyHeaderError *err = plyfile->header_error;
+
+  if (err->reason[0] != '\0')
+    return;
+  err->limit = limit;
+  err->value = value;
+  if (limit == PLY_LIMIT_HEADER_LINES)
+    header_error (plyfile, "more than %d header lines", plyfile->max_header_lines);
+  else
+    header_error (plyfile, "text of %.0f characters exceeds %d", value,
+                  plyfile->max_comment_length);
+}
+
+
+/******************************************************************************
+Check the length of the text of a comment or obj_info line, which follows
+the keyword and the spaces and tabs after it, against max_comment_length.
+
+Entry:
+  plyfile - file whose header is read
+  line    - the comment or obj_info line
+
+Exit:
+  returns 1 if the text is short enough, 0 if not
+******************************************************************************/
+
+static int check_header_text(PlyFile *plyfile, char *line)
+{
+  size_t len;
+
+  if (plyfile->max_comment_length <= 0)
+    return (1);
+
+  while (*line == ' ' || *line == '\t')
+    line++;
+  while (*line != '\0' && *line != ' ' && *line != '\t')
+    line++;
+  while (*line == ' ' || *line == '\t')
+    line++;
+  len = strlen (line);
+  if (len <= (size_t) plyfile->max_comment_length)
+    return (1);
+  header_limit (plyfile, PLY_LIMIT_COMMENT_LENGTH, (double) len);
+  return (0);
+}
+
+
 /******************************************************************************
 Given a filename, open the PLY file, read the PLY header information and return PlyFile struct.
 
@@ -840,15 +1163,59 @@ PlyFile *ply_open_and_read_header (
   char *filename
 )
 {
-  int i,j;
+  return (ply_open_and_read_header_error (filename, &last_header_error));
+}
+
+
+/******************************************************************************
+Given a filename, open the PLY file, read the PLY header information and return PlyFile struct,
+reporting why the file couldn't be opened or its header was rejected.
+
+Entry:
+  filename - the given filename
+  error    - where to report the error
+
+Exit:
+  error - the offending line and the reason, or line 0 and the reason the
+          file couldn't be opened, or line 0 and no reason on success
+  returns a pointer to a PlyFile, used to refer to this file, or NULL if error
+******************************************************************************/
+PlyFile *ply_open_and_read_header_error (
+  char *filename,
+  PlyHeaderError *error
+)
+{
+  return (ply_open_and_read_header_limits (filename, error, NULL));
+}
+
+
+/******************************************************************************
+Given a filename, open the PLY file, read the PLY header information and return PlyFile struct,
+as ply_open_and_read_header_error does, within the limits given for an untrusted file.
+A header with more lines than max_header_lines, or a comment or obj_info
+line whose text is longer than max_comment_length, is rejected as soon as it
+is read, with the exceeded limit in error->limit.  The element count and list
+length limits then apply to ply_get_element, as set by ply_set_limits.
+
+Entry:
+  filename - the given filename
+  error    - where to report the error
+  limits   - the limits, or NULL for none
+
+Exit:
+  error - the offending line and the reason, or line 0 and the reason the
+          file couldn't be opened, or line 0 and no reason on success
+  returns a pointer to a PlyFile, used to refer to this file, or NULL if error
+******************************************************************************/
+PlyFile *ply_open_and_read_header_limits (
+  char *filename,
+  PlyHeaderError *error,
+  PlyLimits *limits
+)
+{
   FILE *fp;
   PlyFile *plyfile;
   char *name;
-  int nwords;
-  char **words;
-  int found_format = 0;
-  PlyElement *elem;
-  char *orig_line;
 
   /* tack on the extension .ply, if necessary */
 
@@ -860,9 +1227,13 @@ PlyFile *ply_open_and_read_header (
 
   /* open the file for reading */
 
+  clear_header_error (error);
   fp = fopen (name, "r");
-  if (fp == NULL)
+  free (name);
+  if (fp == NULL) {
+    snprintf (error->reason, sizeof (error->reason), "%s", strerror (errno));
     return (NULL);
+  }
 
   /* create temporary record */
   plyfile = (PlyFile *) myalloc (sizeof (PlyFile));
@@ -873,57 +1244,35 @@ PlyFile *ply_open_and_read_header (
   plyfile->num_obj_info = 0;
   plyfile->fp = fp;
   plyfile->other_elems = NULL;
-
-  /* read and parse the file's header */
-  words = get_words (fp, &nwords, &orig_line);
-  if (!words || !equal_strings (words[0], "ply"))
-  return (NULL);
-
-  while (words) {
-
-    /* parse words */
-
-    if (equal_strings (words[0], "format")) {
-      if (nwords != 3)
-        return (NULL);
-      if (equal_strings (words[1], "ascii"))
-        plyfile->file_type = PLY_ASCII;
-      else if (equal_strings (words[1], "binary_big_endian"))
-        plyfile->file_type = PLY_BINARY_BE;
-      else if (equal_strings (words[1], "binary_little_endian"))
-        plyfile->file_type = PLY_BINARY_LE;
-      else
-        return (NULL);
-      plyfile->version = atof (words[2]);
-      found_format = 1;
-    }
-    else if (equal_strings (words[0], "element"))
-      add_element (plyfile, words, nwords);
-    else if (equal_strings (words[0], "property"))
-      add_property (plyfile, words, nwords);
-    else if (equal_strings (words[0], "comment"))
-      add_comment (plyfile, orig_line);
-    else if (equal_strings (words[0], "obj_info"))
-      add_obj_info (plyfile, orig_line);
-    else if (equal_strings (words[0], "end_header"))
-      break;
-
-    /* free up words space */
-    free (words);
-
-    words = get_words (plyfile->fp, &nwords, &orig_line);
+  plyfile->lists = NULL;
+  plyfile->num_lists = 0;
+  plyfile->max_lists = 0;
+  plyfile->elem_index = 0;
+  plyfile->convert_policy = PLY_CONVERT_DEFAULT;
+  plyfile->convert_reason = 0;
+  plyfile->header_error = error;
+  plyfile->line_num = 0;
+  plyfile->file_size = file_size (fp);
+  plyfile->body_reason[0] = '\0';
+  plyfile->max_header_lines = 0;
+  plyfile->max_comment_length = 0;
+  plyfile->max_element_count = 0;
+  plyfile->max_list_length = 0;
+  plyfile->body_limit = 0;
+  if (limits != NULL) {
+    plyfile->max_header_lines = limits->max_header_lines;
+    plyfile->max_comment_length = limits->max_comment_length;
+    plyfile->max_element_count = limits->max_element_count;
+    plyfile->max_list_length = limits->max_list_length;
   }
 
-  /* create tags for each property of each element, to be used */
-  /* later to say whether or not to store each property for the user */
-
-  for (i = 0; i < plyfile->nelems; i++) {
-    elem = plyfile->elems[i];
-    elem->store_prop = (char *) myalloc (sizeof (char) * elem->nprops);
-    for (j = 0; j < elem->nprops; j++)
-      elem->store_prop[j] = DONT_STORE_PROP;
-    elem->other_offset = NO_OTHER_PROPS; /* no "other" props by default */
+  /* read and parse the file's header */
+  if (read_header (plyfile) < 0) {
+    fclose (fp);
+    free_plyfile (plyfile);
+    return (NULL);
   }
+  plyfile->header_error = NULL;
 
   /* return a pointer to the file's information */
 
@@ -932,7 +1281,10 @@ PlyFile *ply_open_and_read_header (
 
 
 /******************************************************************************
-Open a polygon file for reading.
+Open a polygon file for reading.  Why the file couldn't be opened or its
+header was rejected is kept for ply_get_header_error, which is shared by all
+the files opened this way; ply_open_for_reading_error reports it per file
+instead.
 
 Entry:
   filename - name of file to read from
@@ -952,6 +1304,38 @@ PlyFile *ply_open_for_reading(
   int *file_type,
   float *version
 )
+{
+  return (ply_open_for_reading_error (filename, nelems, elem_names,
+                                      file_type, version, &last_header_error));
+}
+
+
+/******************************************************************************
+Open a polygon file for reading, reporting why the file couldn't be opened
+or its header was rejected.
+
+Entry:
+  filename - name of file to read from
+  error    - where to report the error
+
+Exit:
+  nelems     - number of elements in object
+  elem_names - list of element names
+  file_type  - file type, either ascii or binary
+  version    - version number of PLY file
+  error      - the offending line and the reason, or line 0 and the reason
+               the file couldn't be opened, or line 0 and no reason on success
+  returns a file identifier, used to refer to this file, or NULL if error
+******************************************************************************/
+
+PlyFile *ply_open_for_reading_error(
+  char *filename,
+  int *nelems,
+  char ***elem_names,
+  int *file_type,
+  float *version,
+  PlyHeaderError *error
+)
 {
   FILE *fp;
   PlyFile *plyfile;
@@ -968,12 +1352,20 @@ PlyFile *ply_open_for_reading(
   /* open the file for reading */
 
   fp = fopen (name, "r");
-  if (fp == NULL)
+  free (name);
+  if (fp == NULL) {
+    clear_header_error (error);
+    snprintf (error->reason, sizeof (error->reason), "%s", strerror (errno));
     return (NULL);
+  }
 
   /* create the PlyFile data structure */
 
-  plyfile = ply_read (fp, nelems, elem_names);
+  plyfile = ply_read_error (fp, nelems, elem_names, error);
+  if (plyfile == NULL) {
+    fclose (fp);
+    return (NULL);
+  }
 
   /* determine the file type and version */
 
@@ -1059,6 +1451,7 @@ void ply_get_element_setup(
   /* find information about the element */
   elem = find_element (plyfile, elem_name);
   plyfile->which_elem = elem;
+  plyfile->elem_index = 0;
 
   /* deposit the property information into the element's description */
   for (i = 0; i < nprops; i++) {
@@ -1108,6 +1501,7 @@ void ply_get_property(
   /* find information about the element */
   elem = find_element (plyfile, elem_name);
   plyfile->which_elem = elem;
+  plyfile->elem_index = 0;
 
   /* deposit the property information into the element's description */
 
@@ -1139,10 +1533,31 @@ Entry:
 
 void ply_get_element(PlyFile *plyfile, void *elem_ptr)
 {
+  /* the position in the file is unknown after an error */
+  if (plyfile->body_reason[0] != '\0')
+    return;
+
+  /* nothing would be read, so a huge count would never end */
+  if (plyfile->which_elem->nprops == 0) {
+    body_error (plyfile, NULL, "element has no properties");
+    return;
+  }
+
+  if (plyfile->max_element_count > 0 &&
+      plyfile->which_elem->num > plyfile->max_element_count) {
+    plyfile->body_limit = PLY_LIMIT_ELEMENT_COUNT;
+    plyfile->body_value = plyfile->which_elem->num;
+    body_error (plyfile, NULL, "count %d exceeds the limit of %d",
+                plyfile->which_elem->num, plyfile->max_element_count);
+    return;
+  }
+
   if (plyfile->file_type == PLY_ASCII)
     ascii_get_element (plyfile, (char *) elem_ptr);
   else
     binary_get_element (plyfile, (char *) elem_ptr);
+
+  plyfile->elem_index++;
 }
 
 
@@ -1301,6 +1716,7 @@ PlyOtherProp *ply_get_other_properties(
 
   /* remember that this is the "current" element */
   plyfile->which_elem = elem;
+  plyfile->elem_index = 0;
 
   /* save the offset to where to store the other_props */
   elem->other_offset = offset;
@@ -1412,123 +1828,502 @@ PlyOtherElems *ply_get_other_element (
   /* save name of element */
   other->elem_name = strdup (elem_name);
 
-  /* create a list to hold all the current elements */
-  other->other_data = (OtherData **)
-                  malloc (sizeof (OtherData *) * other->elem_count);
+  /* create a list to hold all the current elements */
+  other->other_data = (OtherData **)
+                  malloc (sizeof (OtherData *) * other->elem_count);
+
+  /* set up for getting elements */
+  other->other_props = ply_get_other_properties (plyfile, elem_name,
+                         offsetof(OtherData,other_props));
+
+  /* grab all these elements */
+  for (i = 0; i < other->elem_count; i++) {
+    /* grab and element from the file */
+    other->other_data[i] = (OtherData *) malloc (sizeof (OtherData));
+    ply_get_element (plyfile, (void *) other->other_data[i]);
+  }
+
+  /* return pointer to the other elements data */
+  return (other_elems);
+}
+
+
+/******************************************************************************
+Pass along a pointer to "other" elements that we want to save in a given
+PLY file.  These other elements were presumably read from another PLY file.
+
+Entry:
+  plyfile     - file pointer in which to store this other element info
+  other_elems - info about other elements that we want to store
+******************************************************************************/
+
+void ply_describe_other_elements (
+  PlyFile *plyfile,
+  PlyOtherElems *other_elems
+)
+{
+  int i;
+  OtherElem *other;
+
+  /* ignore this call if there is no other element */
+  if (other_elems == NULL)
+    return;
+
+  /* save pointer to this information */
+  plyfile->other_elems = other_elems;
+
+  /* describe the other properties of this element */
+
+  for (i = 0; i < other_elems->num_elems; i++) {
+    other = &(other_elems->other_list[i]);
+    ply_element_count (plyfile, other->elem_name, other->elem_count);
+    ply_describe_other_properties (plyfile, other->other_props,
+                                   offsetof(OtherData,other_props));
+  }
+}
+
+
+/******************************************************************************
+Write out the "other" elements specified for this PLY file.
+
+Entry:
+  plyfile - pointer to PLY file to write out other elements for
+******************************************************************************/
+
+void ply_put_other_elements (PlyFile *plyfile)
+{
+  int i,j;
+  OtherElem *other;
+
+  /* make sure we have other elements to write */
+  if (plyfile->other_elems == NULL)
+    return;
+
+  /* write out the data for each "other" element */
+
+  for (i = 0; i < plyfile->other_elems->num_elems; i++) {
+
+    other = &(plyfile->other_elems->other_list[i]);
+    ply_put_element_setup (plyfile, other->elem_name);
+
+    /* write out each instance of the current element */
+    for (j = 0; j < other->elem_count; j++)
+      ply_put_element (plyfile, (void *) other->other_data[j]);
+  }
+}
+
+
+/******************************************************************************
+Free up storage used by an "other" elements data structure.
+
+Entry:
+  other_elems - data structure to free up
+******************************************************************************/
+
+void ply_free_other_elements (PlyOtherElems *other_elems)
+{
+
+}
+
+
+
+/*******************/
+/*  Miscellaneous  */
+/*******************/
+
+
+
+/******************************************************************************
+Close a PLY file.
+
+Entry:
+  plyfile - identifier of file to close
+******************************************************************************/
+
+void ply_close(PlyFile *plyfile)
+{
+  fclose (plyfile->fp);
+
+  /* free up memory associated with the PLY file */
+  free_plyfile (plyfile);
+}
+
+
+/******************************************************************************
+Free the lists (and "other" properties) returned by ply_get_element so far.
+The pointers to them stored in the user's structures become invalid.  The
+lists are freed by ply_close anyway; this is for reading large files.
+
+Entry:
+  plyfile - file identifier
+******************************************************************************/
+
+void ply_free_lists(PlyFile *plyfile)
+{
+  int i;
+
+  for (i = 0; i < plyfile->num_lists; i++)
+    free (plyfile->lists[i]);
+  plyfile->num_lists = 0;
+}
+
+
+/******************************************************************************
+Set what happens to values that don't fit the type they are converted to,
+when read into the user's structures or written to the file.
+
+Entry:
+  plyfile - file identifier
+  policy  - PLY_CONVERT_*
+******************************************************************************/
+
+void ply_set_convert_policy(PlyFile *plyfile, int policy)
+{
+  plyfile->convert_policy = policy;
+}
+
+
+/******************************************************************************
+Limit what ply_get_element reads from an untrusted file.  An element whose
+count exceeds max_element_count isn't read at all, and a list count above
+max_list_length stops reading, as if the body were malformed; the limit is
+reported by ply_get_body_error, and in body_limit.
+
+Entry:
+  plyfile           - file identifier
+  max_element_count - largest count of an element to read, or 0 for no limit
+  max_list_length   - largest number of values in a list, or 0 for no limit
+******************************************************************************/
+
+void ply_set_limits(PlyFile *plyfile, int max_element_count, int max_list_length)
+{
+  plyfile->max_element_count = max_element_count;
+  plyfile->max_list_length = max_list_length;
+}
+
+
+/******************************************************************************
+Return the first value that couldn't be converted under the policies
+PLY_CONVERT_ERROR_OVERFLOW and PLY_CONVERT_ERROR_PRECISION.
+
+Entry:
+  plyfile - file identifier
+
+Exit:
+  elem_name - element of the value
+  index     - index of the element
+  prop_name - property of the value
+  value     - the value
+  type      - the type it was converted to
+  returns PLY_OUT_OF_RANGE, PLY_LOSES_PRECISION, or 0 if every value converted
+******************************************************************************/
+
+int ply_get_convert_error(
+  PlyFile *plyfile,
+  char **elem_name,
+  int *index,
+  char **prop_name,
+  double *value,
+  int *type
+)
+{
+  if (plyfile->convert_reason) {
+    *elem_name = plyfile->convert_elem;
+    *index = plyfile->convert_index;
+    *prop_name = plyfile->convert_prop;
+    *value = plyfile->convert_value;
+    *type = plyfile->convert_type;
+  }
+  return (plyfile->convert_reason);
+}
+
+
+/******************************************************************************
+Return why the body of a file couldn't be read by ply_get_element.  After
+such an error, ply_get_element reads nothing more.
+
+Entry:
+  plyfile - file identifier
+
+Exit:
+  elem_name - element being read
+  index     - index of the element
+  prop_name - property being read, or NULL if the error isn't about one
+  line      - line of the element in ascii files, counting from 1, or 0
+  returns the reason, or NULL if the body was read without errors so far
+******************************************************************************/
+
+char *ply_get_body_error(
+  PlyFile *plyfile,
+  char **elem_name,
+  int *index,
+  char **prop_name,
+  int *line
+)
+{
+  if (plyfile->body_reason[0] == '\0')
+    return (NULL);
+  *elem_name = plyfile->body_elem;
+  *index = plyfile->body_index;
+  *prop_name = plyfile->body_prop;
+  *line = plyfile->body_line;
+  return (plyfile->body_reason);
+}
+
+
+/******************************************************************************
+Remember why a value of the element being read can't be read, unless an
+earlier value couldn't be read either.
+
+Entry:
+  plyfile - file identifier
+  prop    - property of the value, or NULL
+  format  - printf format of the reason
+******************************************************************************/
+
+static void body_error(PlyFile *plyfile, PlyProperty *prop, char *format, ...)
+{
+  va_list args;
+
+  if (plyfile->body_reason[0] != '\0')
+    return;
+
+  va_start (args, format);
+  vsnprintf (plyfile->body_reason, sizeof (plyfile->body_reason), format, args);
+  va_end (args);
+
+  plyfile->body_elem = plyfile->which_elem->name;
+  plyfile->body_index = plyfile->elem_index;
+  plyfile->body_prop = prop ? prop->name : NULL;
+  plyfile->body_line = plyfile->file_type == PLY_ASCII ? plyfile->line_num : 0;
+}
+
+
+/******************************************************************************
+Check a list count read from the body against the limits of a file.
+
+Entry:
+  plyfile - file identifier
+  prop    - the list property
+  count   - the count
+
+Exit:
+  returns 1 if the list can be read, or 0 after reporting a body error
+******************************************************************************/
+
+static int check_list_count(PlyFile *plyfile, PlyProperty *prop, double count)
+{
+  if (count < 0) {
+    body_error (plyfile, prop, "negative list count");
+    return (0);
+  }
+  if (plyfile->max_list_length > 0 && count > plyfile->max_list_length) {
+    plyfile->body_limit = PLY_LIMIT_LIST_LENGTH;
+    plyfile->body_value = count;
+    body_error (plyfile, prop, "list count %.0f exceeds the limit of %d",
+                count, plyfile->max_list_length);
+    return (0);
+  }
+  return (1);
+}
+
+
+/******************************************************************************
+Return the size of a file, to bound the list counts read from it.
+
+Entry:
+  fp - file pointer
 
-  /* set up for getting elements */
-  other->other_props = ply_get_other_properties (plyfile, elem_name,
-                         offsetof(OtherData,other_props));
+Exit:
+  returns the size in bytes, or -1 if fp isn't a regular file
+******************************************************************************/
 
-  /* grab all these elements */
-  for (i = 0; i < other->elem_count; i++) {
-    /* grab and element from the file */
-    other->other_data[i] = (OtherData *) malloc (sizeof (OtherData));
-    ply_get_element (plyfile, (void *) other->other_data[i]);
-  }
+static long file_size(FILE *fp)
+{
+  struct stat st;
 
-  /* return pointer to the other elements data */
-  return (other_elems);
+  if (fstat (fileno (fp), &st) != 0 || !S_ISREG (st.st_mode))
+    return (-1);
+  return ((long) st.st_size);
 }
 
 
 /******************************************************************************
-Pass along a pointer to "other" elements that we want to save in a given
-PLY file.  These other elements were presumably read from another PLY file.
+Apply the conversion policy of a PLY file to a value converted to another
+type.  The first value that can't be converted is remembered, and is stored
+as by PLY_CONVERT_WRAP.
 
 Entry:
-  plyfile     - file pointer in which to store this other element info
-  other_elems - info about other elements that we want to store
+  plyfile    - file identifier
+  prop       - property of the value
+  type       - data type the value is converted to
+  int_val    - integer version of value
+  uint_val   - unsigned integer version of value
+  double_val - double version of value
+
+Exit:
+  int_val, uint_val, double_val - the value to store
 ******************************************************************************/
 
-void ply_describe_other_elements (
+static void convert_item(
   PlyFile *plyfile,
-  PlyOtherElems *other_elems
+  PlyProperty *prop,
+  int type,
+  int *int_val,
+  unsigned int *uint_val,
+  double *double_val
 )
 {
-  int i;
-  OtherElem *other;
+  int policy = plyfile->convert_policy;
+  int reason = 0;
+  double v = *double_val;
+  double t, lo, hi;
 
-  /* ignore this call if there is no other element */
-  if (other_elems == NULL)
+  if (policy == PLY_CONVERT_DEFAULT || policy == PLY_CONVERT_WRAP ||
+      type == PLY_DOUBLE)
     return;
 
-  /* save pointer to this information */
-  plyfile->other_elems = other_elems;
+  if (type == PLY_FLOAT) {
+    /* values from halfway between FLT_MAX and the next power of two round to infinity */
+    if (!isinf (v) && fabs (v) >= 0x1.ffffffp+127) {
+      if (policy == PLY_CONVERT_SATURATE)
+        *double_val = v < 0 ? -FLT_MAX : FLT_MAX;
+      else
+        reason = PLY_OUT_OF_RANGE;
+    }
+    else if (policy == PLY_CONVERT_ERROR_PRECISION && !isnan (v) &&
+             (double) (float) v != v && !same_decimal32 (v))
+      reason = PLY_LOSES_PRECISION;
+  }
+  else {
+    switch (type) {
+      case PLY_CHAR:   lo = SCHAR_MIN; hi = SCHAR_MAX; break;
+      case PLY_SHORT:  lo = SHRT_MIN;  hi = SHRT_MAX;  break;
+      case PLY_INT:    lo = INT_MIN;   hi = INT_MAX;   break;
+      case PLY_UCHAR:  lo = 0;         hi = UCHAR_MAX; break;
+      case PLY_USHORT: lo = 0;         hi = USHRT_MAX; break;
+      default:         lo = 0;         hi = UINT_MAX;  break;
+    }
+    t = trunc (v);
+    if (isnan (v) || t < lo || t > hi) {
+      if (policy == PLY_CONVERT_SATURATE)
+        t = isnan (v) ? 0 : (t < lo ? lo : hi);
+      else
+        reason = PLY_OUT_OF_RANGE;
+    }
+    else if (policy == PLY_CONVERT_ERROR_PRECISION && t != v)
+      reason = PLY_LOSES_PRECISION;
 
-  /* describe the other properties of this element */
+    if (!reason) {
+      if (t < 0) {
+        *int_val = (int) t;
+        *uint_val = (unsigned int) *int_val;
+      }
+      else {
+        *uint_val = (unsigned int) t;
+        *int_val = (int) *uint_val;
+      }
+      *double_val = t;
+    }
+  }
 
-  for (i = 0; i < other_elems->num_elems; i++) {
-    other = &(other_elems->other_list[i]);
-    ply_element_count (plyfile, other->elem_name, other->elem_count);
-    ply_describe_other_properties (plyfile, other->other_props,
-                                   offsetof(OtherData,other_props));
+  if (reason && !plyfile->convert_reason) {
+    plyfile->convert_reason = reason;
+    plyfile->convert_elem = plyfile->which_elem->name;
+    plyfile->convert_index = plyfile->elem_index;
+    plyfile->convert_prop = prop->name;
+    plyfile->convert_value = v;
+    plyfile->convert_type = type;
   }
 }
 
 
 /******************************************************************************
-Write out the "other" elements specified for this PLY file.
+Check the text of a float value of an ascii file against the conversion
+policy, before it is rounded to a float: the text may hold a number too
+large for a float, or more digits than a float keeps.
 
 Entry:
-  plyfile - pointer to PLY file to write out other elements for
+  plyfile - file identifier
+  prop    - property of the value
+  word    - text of the value
 ******************************************************************************/
 
-void ply_put_other_elements (PlyFile *plyfile)
+static void check_ascii_float(PlyFile *plyfile, PlyProperty *prop, char *word)
 {
-  int i,j;
-  OtherElem *other;
+  int int_val = 0;
+  unsigned int uint_val = 0;
+  double double_val;
 
-  /* make sure we have other elements to write */
-  if (plyfile->other_elems == NULL)
+  if (prop->external_type != PLY_FLOAT ||
+      (plyfile->convert_policy != PLY_CONVERT_ERROR_OVERFLOW &&
+       plyfile->convert_policy != PLY_CONVERT_ERROR_PRECISION))
     return;
 
-  /* write out the data for each "other" element */
-
-  for (i = 0; i < plyfile->other_elems->num_elems; i++) {
-
-    other = &(plyfile->other_elems->other_list[i]);
-    ply_put_element_setup (plyfile, other->elem_name);
-
-    /* write out each instance of the current element */
-    for (j = 0; j < other->elem_count; j++)
-      ply_put_element (plyfile, (void *) other->other_data[j]);
-  }
+  double_val = ascii_to_real (word, PLY_DOUBLE);
+  convert_item (plyfile, prop, PLY_FLOAT, &int_val, &uint_val, &double_val);
 }
 
 
 /******************************************************************************
-Free up storage used by an "other" elements data structure.
+Remember a block of memory allocated by ply_get_element.
 
 Entry:
-  other_elems - data structure to free up
+  plyfile - file identifier
+  ptr     - the memory
 ******************************************************************************/
 
-void ply_free_other_elements (PlyOtherElems *other_elems)
+static void keep_list(PlyFile *plyfile, char *ptr)
 {
-
+  if (plyfile->num_lists == plyfile->max_lists) {
+    plyfile->max_lists = plyfile->max_lists == 0 ? 64 : plyfile->max_lists * 2;
+    plyfile->lists = (char **) realloc (plyfile->lists,
+                      sizeof (char *) * plyfile->max_lists);
+  }
+  plyfile->lists[plyfile->num_lists++] = ptr;
 }
 
 
-
-/*******************/
-/*  Miscellaneous  */
-/*******************/
-
-
-
 /******************************************************************************
-Close a PLY file.
+Free all the memory of a PLY file: elements, properties, comments, object
+information and the lists read by ply_get_element.  The file pointer is
+left alone.
 
 Entry:
-  plyfile - identifier of file to close
+  plyfile - file identifier
 ******************************************************************************/
 
-void ply_close(PlyFile *plyfile)
+static void free_plyfile(PlyFile *plyfile)
 {
-  fclose (plyfile->fp);
+  int i,j;
+  PlyElement *elem;
 
-  /* free up memory associated with the PLY file */
+  for (i = 0; i < plyfile->nelems; i++) {
+    elem = plyfile->elems[i];
+    for (j = 0; j < elem->nprops; j++) {
+      free (elem->props[j]->name);
+      free (elem->props[j]);
+    }
+    free (elem->props);
+    free (elem->store_prop);
+    free (elem->name);
+    free (elem);
+  }
+  if (plyfile->nelems > 0)
+    free (plyfile->elems);
+
+  for (i = 0; i < plyfile->num_comments; i++)
+    free (plyfile->comments[i]);
+  if (plyfile->num_comments > 0)
+    free (plyfile->comments);
+
+  for (i = 0; i < plyfile->num_obj_info; i++)
+    free (plyfile->obj_info[i]);
+  if (plyfile->num_obj_info > 0)
+    free (plyfile->obj_info);
+
+  ply_free_lists (plyfile);
+  free (plyfile->lists);
   free (plyfile);
 }
 
@@ -1663,6 +2458,7 @@ void ascii_get_element(PlyFile *plyfile, char *elem_ptr)
     other_flag = 1;
     /* make room for other_props */
     other_data = (char *) myalloc (elem->other_size);
+    keep_list (plyfile, other_data);
     /* store pointer in user's structure to the other_props */
     ptr = (char **) (elem_ptr + elem->other_offset);
     *ptr = other_data;
@@ -1672,10 +2468,11 @@ void ascii_get_element(PlyFile *plyfile, char *elem_ptr)
 
   /* read in the element */
 
+  plyfile->line_num++;
   words = get_words (plyfile->fp, &nwords, &orig_line);
   if (words == NULL) {
-    fprintf (stderr, "ply_get_element: unexpected end of file\n");
-    exit (-1);
+    body_error (plyfile, NULL, "unexpected end of file");
+    return;
   }
 
   which_word = 0;
@@ -1694,11 +2491,30 @@ void ascii_get_element(PlyFile *plyfile, char *elem_ptr)
     if (prop->is_list) {       /* a list */
 
       /* get and store the number of items in the list */
+      if (which_word >= nwords) {
+        body_error (plyfile, prop, "too few values");
+        free (words);
+        return;
+      }
       get_ascii_item (words[which_word++], prop->count_external,
                       &int_val, &uint_val, &double_val);
+      if (!check_list_count (plyfile, prop, double_val)) {
+        free (words);
+        return;
+      }
+      if (double_val > nwords - which_word) {
+        body_error (plyfile, prop, "too few values");
+        free (words);
+        return;
+      }
       if (store_it) {
+        /* convert a copy, as the count read from the file is used below */
+        int count_int = int_val;
+        unsigned int count_uint = uint_val;
+        double count_double = double_val;
         item = elem_data + prop->count_offset;
-        store_item(item, prop->count_internal, int_val, uint_val, double_val);
+        convert_item (plyfile, prop, prop->count_internal, &count_int, &count_uint, &count_double);
+        store_item(item, prop->count_internal, count_int, count_uint, count_double);
       }
 
       /* allocate space for an array of items and store a ptr to the array */
@@ -1713,15 +2529,18 @@ void ascii_get_element(PlyFile *plyfile, char *elem_ptr)
       else {
         if (store_it) {
           item_ptr = (char *) myalloc (sizeof (char) * item_size * list_count);
+          keep_list (plyfile, item_ptr);
           item = item_ptr;
           *store_array = item_ptr;
         }
 
         /* read items and store them into the array */
         for (k = 0; k < list_count; k++) {
+          check_ascii_float (plyfile, prop, words[which_word]);
           get_ascii_item (words[which_word++], prop->external_type,
                           &int_val, &uint_val, &double_val);
           if (store_it) {
+            convert_item (plyfile, prop, prop->internal_type, &int_val, &uint_val, &double_val);
             store_item (item, prop->internal_type,
                         int_val, uint_val, double_val);
             item += item_size;
@@ -1731,10 +2550,17 @@ void ascii_get_element(PlyFile *plyfile, char *elem_ptr)
 
     }
     else {                     /* not a list */
+      if (which_word >= nwords) {
+        body_error (plyfile, prop, "too few values");
+        free (words);
+        return;
+      }
+      check_ascii_float (plyfile, prop, words[which_word]);
       get_ascii_item (words[which_word++], prop->external_type,
                       &int_val, &uint_val, &double_val);
       if (store_it) {
         item = elem_data + prop->offset;
+        convert_item (plyfile, prop, prop->internal_type, &int_val, &uint_val, &double_val);
         store_item (item, prop->internal_type, int_val, uint_val, double_val);
       }
     }
@@ -1781,6 +2607,7 @@ void binary_get_element(PlyFile *plyfile, char *elem_ptr)
     other_flag = 1;
     /* make room for other_props */
     other_data = (char *) myalloc (elem->other_size);
+    keep_list (plyfile, other_data);
     /* store pointer in user's structure to the other_props */
     ptr = (char **) (elem_ptr + elem->other_offset);
     *ptr = other_data;
@@ -1804,11 +2631,28 @@ void binary_get_element(PlyFile *plyfile, char *elem_ptr)
     if (prop->is_list) {       /* a list */
 
       /* get and store the number of items in the list */
-      get_binary_item (fp, prop->count_external,
+      get_binary_item (plyfile, prop->count_external,
                       &int_val, &uint_val, &double_val);
+      if (feof (fp) || ferror (fp)) {
+        body_error (plyfile, prop, "unexpected end of file");
+        return;
+      }
+      if (!check_list_count (plyfile, prop, double_val))
+        return;
+      /* a count the rest of the file can't hold would only exhaust memory */
+      if (plyfile->file_size >= 0 &&
+          double_val * ply_type_size[prop->external_type] > plyfile->file_size - ftell (fp)) {
+        body_error (plyfile, prop, "unexpected end of file");
+        return;
+      }
       if (store_it) {
+        /* convert a copy, as the count read from the file is used below */
+        int count_int = int_val;
+        unsigned int count_uint = uint_val;
+        double count_double = double_val;
         item = elem_data + prop->count_offset;
-        store_item(item, prop->count_internal, int_val, uint_val, double_val);
+        convert_item (plyfile, prop, prop->count_internal, &count_int, &count_uint, &count_double);
+        store_item(item, prop->count_internal, count_int, count_uint, count_double);
       }
 
       /* allocate space for an array of items and store a ptr to the array */
@@ -1828,15 +2672,21 @@ void binary_get_element(PlyFile *plyfile, char *elem_ptr)
       else {
         if (store_it) {
           item_ptr = (char *) myalloc (sizeof (char) * item_size * list_count);
+          keep_list (plyfile, item_ptr);
           item = item_ptr;
           *store_array = item_ptr;
         }
 
         /* read items and store them into the array */
         for (k = 0; k < list_count; k++) {
-          get_binary_item (fp, prop->external_type,
+          get_binary_item (plyfile, prop->external_type,
                           &int_val, &uint_val, &double_val);
+          if (feof (fp) || ferror (fp)) {
+            body_error (plyfile, prop, "unexpected end of file");
+            return;
+          }
           if (store_it) {
+            convert_item (plyfile, prop, prop->internal_type, &int_val, &uint_val, &double_val);
             store_item (item, prop->internal_type,
                         int_val, uint_val, double_val);
             item += item_size;
@@ -1846,10 +2696,15 @@ void binary_get_element(PlyFile *plyfile, char *elem_ptr)
 
     }
     else {                     /* not a list */
-      get_binary_item (fp, prop->external_type,
+      get_binary_item (plyfile, prop->external_type,
                       &int_val, &uint_val, &double_val);
+      if (feof (fp) || ferror (fp)) {
+        body_error (plyfile, prop, "unexpected end of file");
+        return;
+      }
       if (store_it) {
         item = elem_data + prop->offset;
+        convert_item (plyfile, prop, prop->internal_type, &int_val, &uint_val, &double_val);
         store_item (item, prop->internal_type, int_val, uint_val, double_val);
       }
     }
@@ -1913,12 +2768,20 @@ char **get_words(FILE *fp, int *nwords, char **orig_line)
   /* read in a line */
   result = fgets (str, BIG_STRING, fp);
   if (result == NULL) {
+    free (words);
     *nwords = 0;
     *orig_line = NULL;
     return (NULL);
   }
 
-  /* convert line-feed and tabs into spaces */
+  /* a line that doesn't fit is cut off, and the rest of it skipped */
+  if (strchr (str, '\n') == NULL && !feof (fp)) {
+    int c;
+    while ((c = getc (fp)) != '\n' && c != EOF)
+      ;
+  }
+
+  /* convert line-feed, carriage returns and tabs into spaces */
   /* (this guarentees that there will be a space before the */
   /*  null character at the end of the string) */
 
@@ -1931,12 +2794,25 @@ char **get_words(FILE *fp, int *nwords, char **orig_line)
       *ptr = ' ';
       *ptr2 = ' ';
     }
+    else if (*ptr == '\r' && (ptr[1] == '\n' || ptr[1] == '\0')) {
+      /* a \r\n line ending, e.g. from a file written on Windows */
+      *ptr = ' ';
+      *ptr2 = '\0';
+      if (ptr[1] == '\n')
+        ptr[1] = ' ';
+      break;
+    }
+    else if (*ptr == '\r') {
+      *ptr = ' ';
+      *ptr2 = ' ';
+    }
     else if (*ptr == '\n') {
       *ptr = ' ';
       *ptr2 = '\0';
       break;
     }
   }
+  *ptr2 = '\0';
 
   /* find the words in the line */
 
@@ -1958,9 +2834,11 @@ char **get_words(FILE *fp, int *nwords, char **orig_line)
     }
     words[num_words++] = ptr;
 
-    /* jump over non-spaces */
-    while (*ptr != ' ')
+    /* jump over non-spaces (a line without a line-feed ends in a null) */
+    while (*ptr != ' ' && *ptr != '\0')
       ptr++;
+    if (*ptr == '\0')
+      break;
 
     /* place a null character here to mark the end of the word */
     *ptr++ = '\0';
@@ -2042,7 +2920,7 @@ double get_item_value(char *item, int type)
 Write out an item to a file as raw binary bytes.
 
 Entry:
-  fp         - file to write to
+  plyfile    - file to write to, in its byte order
   int_val    - integer version of item
   uint_val   - unsigned integer version of item
   double_val - double-precision float version of item
@@ -2050,7 +2928,7 @@ Entry:
 ******************************************************************************/
 
 void write_binary_item(
-  FILE *fp,
+  PlyFile *plyfile,
   int int_val,
   unsigned int uint_val,
   double double_val,
@@ -2066,32 +2944,32 @@ void write_binary_item(
   switch (type) {
     case PLY_CHAR:
       char_val = int_val;
-      fwrite (&char_val, 1, 1, fp);
+      write_binary (plyfile, &char_val, 1);
       break;
     case PLY_SHORT:
       short_val = int_val;
-      fwrite (&short_val, 2, 1, fp);
+      write_binary (plyfile, &short_val, 2);
       break;
     case PLY_INT:
-      fwrite (&int_val, 4, 1, fp);
+      write_binary (plyfile, &int_val, 4);
       break;
     case PLY_UCHAR:
       uchar_val = uint_val;
-      fwrite (&uchar_val, 1, 1, fp);
+      write_binary (plyfile, &uchar_val, 1);
       break;
     case PLY_USHORT:
       ushort_val = uint_val;
-      fwrite (&ushort_val, 2, 1, fp);
+      write_binary (plyfile, &ushort_val, 2);
       break;
     case PLY_UINT:
-      fwrite (&uint_val, 4, 1, fp);
+      write_binary (plyfile, &uint_val, 4);
       break;
     case PLY_FLOAT:
       float_val = double_val;
-      fwrite (&float_val, 4, 1, fp);
+      write_binary (plyfile, &float_val, 4);
       break;
     case PLY_DOUBLE:
-      fwrite (&double_val, 8, 1, fp);
+      write_binary (plyfile, &double_val, 8);
       break;
     default:
       fprintf (stderr, "write_binary_item: bad type = %d\n", type);
@@ -2104,7 +2982,7 @@ void write_binary_item(
 Write out an item to a file as ascii characters.
 
 Entry:
-  fp         - file to write to
+  plyfile    - file to write to, in its byte order
   int_val    - integer version of item
   uint_val   - unsigned integer version of item
   double_val - double-precision float version of item
@@ -2132,7 +3010,7 @@ void write_ascii_item(
       break;
     case PLY_FLOAT:
     case PLY_DOUBLE:
-      fprintf (fp, "%g ", double_val);
+      write_ascii_real (fp, double_val, type);
       break;
     default:
       fprintf (stderr, "write_ascii_item: bad type = %d\n", type);
@@ -2141,6 +3019,132 @@ void write_ascii_item(
 }
 
 
+/******************************************************************************
+Write out a floating point value as ascii characters, with the fewest
+significant digits that read back as the same float or double, and a '.'
+decimal separator whatever the C locale.  The text is the same as Go's
+strconv.FormatFloat(value, 'g', -1, bits).
+
+Entry:
+  fp    - file to write to
+  value - value to write
+  type  - PLY_FLOAT or PLY_DOUBLE
+******************************************************************************/
+
+static void write_ascii_real(FILE *fp, double value, int type)
+{
+  char buf[64];
+  char point = localeconv()->decimal_point[0];
+  char *p;
+  int digits;
+  int exponent;
+
+  if (isnan (value)) {
+    fprintf (fp, "NaN ");
+    return;
+  }
+  if (isinf (value)) {
+    fprintf (fp, value > 0 ? "+Inf " : "-Inf ");
+    return;
+  }
+
+  for (digits = 1; digits < 17; digits++) {
+    snprintf (buf, sizeof (buf), "%.*e", digits - 1, value);
+    if (type == PLY_FLOAT) {
+      if ((float) strtod (buf, NULL) == (float) value)
+        break;
+    }
+    else if (strtod (buf, NULL) == value)
+      break;
+  }
+  snprintf (buf, sizeof (buf), "%.*e", digits - 1, value);
+
+  /* as Go, use an exponent only for very small or large values */
+  exponent = atoi (strchr (buf, 'e') + 1);
+  if (exponent >= -4 && exponent < 6)
+    snprintf (buf, sizeof (buf), "%.*f",
+              digits - 1 - exponent > 0 ? digits - 1 - exponent : 0, value);
+
+  if (point != '.')
+    for (p = buf; *p; p++)
+      if (*p == point)
+        *p = '.';
+
+  fprintf (fp, "%s ", buf);
+}
+
+
+/******************************************************************************
+Tell whether a value reads back from the shortest decimal form of the float
+nearest to it, e.g. 0.1, so that a float written as text isn't taken to lose
+precision.  The same rule as sameDecimal32 in convert.go.
+
+Entry:
+  value - value to check
+
+Exit:
+  returns 1 if it does, 0 otherwise
+******************************************************************************/
+
+static int same_decimal32(double value)
+{
+  char buf[64];
+  float f = (float) value;
+  int digits;
+
+  if (isinf (f) || isnan (f))
+    return (0);
+
+  for (digits = 1; digits < 9; digits++) {
+    snprintf (buf, sizeof (buf), "%.*e", digits - 1, (double) f);
+    if (strtof (buf, NULL) == f)
+      break;
+  }
+  snprintf (buf, sizeof (buf), "%.*e", digits - 1, (double) f);
+  return (strtod (buf, NULL) == value);
+}
+
+
+/******************************************************************************
+Read a floating point value from an ascii word, with a '.' decimal separator
+whatever the C locale.  Like atof, the value ends at the first character
+that isn't part of a number.
+
+Entry:
+  word - word to read
+  type - PLY_FLOAT or PLY_DOUBLE
+
+Exit:
+  returns the value, rounded to a float for PLY_FLOAT
+******************************************************************************/
+
+static double ascii_to_real(char *word, int type)
+{
+  char buf[64];
+  char point = localeconv()->decimal_point[0];
+  char *p;
+
+  if (point != '.') {
+    strncpy (buf, word, sizeof (buf) - 1);
+    buf[sizeof (buf) - 1] = '\0';
+    for (p = buf; *p; p++) {
+      if (*p == point) {
+        /* not a separator in a PLY file, so the number ends here */
+        *p = '\0';
+        break;
+      }
+      if (*p == '.')
+        *p = point;
+    }
+    word = buf;
+  }
+
+  if (type == PLY_FLOAT)
+    return (strtof (word, NULL));
+  return (strtod (word, NULL));
+}
+
+
 /******************************************************************************
 Write out an item to a file as ascii characters.
 
@@ -2201,12 +3205,12 @@ double old_write_ascii_item(FILE *fp, char *item, int type)
     case PLY_FLOAT:
       pfloat = (float *) item;
       double_value = *pfloat;
-      fprintf (fp, "%g ", double_value);
+      write_ascii_real (fp, double_value, PLY_FLOAT);
       return (double_value);
     case PLY_DOUBLE:
       pdouble = (double *) item;
       double_value = *pdouble;
-      fprintf (fp, "%g ", double_value);
+      write_ascii_real (fp, double_value, PLY_DOUBLE);
       return (double_value);
     default:
       fprintf (stderr, "old_write_ascii_item: bad type = %d\n", type);
@@ -2290,8 +3294,8 @@ Get the value of an item from a binary file, and place the result
 into an integer, an unsigned integer and a double.
 
 Entry:
-  fp   - file to get item from
-  type - data type supposedly in the word
+  plyfile - file to get item from, in its byte order
+  type    - data type supposedly in the word
 
 Exit:
   int_val    - integer value
@@ -2300,7 +3304,7 @@ Exit:
 ******************************************************************************/
 
 void get_binary_item(
-  FILE *fp,
+  PlyFile *plyfile,
   int type,
   int *int_val,
   unsigned int *uint_val,
@@ -2314,49 +3318,49 @@ void get_binary_item(
 
   switch (type) {
     case PLY_CHAR:
-      fread (ptr, 1, 1, fp);
+      read_binary (plyfile, ptr, 1);
       *int_val = *((char *) ptr);
       *uint_val = *int_val;
       *double_val = *int_val;
       break;
     case PLY_UCHAR:
-      fread (ptr, 1, 1, fp);
+      read_binary (plyfile, ptr, 1);
       *uint_val = *((unsigned char *) ptr);
       *int_val = *uint_val;
       *double_val = *uint_val;
       break;
     case PLY_SHORT:
-      fread (ptr, 2, 1, fp);
+      read_binary (plyfile, ptr, 2);
       *int_val = *((short int *) ptr);
       *uint_val = *int_val;
       *double_val = *int_val;
       break;
     case PLY_USHORT:
-      fread (ptr, 2, 1, fp);
+      read_binary (plyfile, ptr, 2);
       *uint_val = *((unsigned short int *) ptr);
       *int_val = *uint_val;
       *double_val = *uint_val;
       break;
     case PLY_INT:
-      fread (ptr, 4, 1, fp);
+      read_binary (plyfile, ptr, 4);
       *int_val = *((int *) ptr);
       *uint_val = *int_val;
       *double_val = *int_val;
       break;
     case PLY_UINT:
-      fread (ptr, 4, 1, fp);
+      read_binary (plyfile, ptr, 4);
       *uint_val = *((unsigned int *) ptr);
       *int_val = *uint_val;
       *double_val = *uint_val;
       break;
     case PLY_FLOAT:
-      fread (ptr, 4, 1, fp);
+      read_binary (plyfile, ptr, 4);
       *double_val = *((float *) ptr);
       *int_val = *double_val;
       *uint_val = *double_val;
       break;
     case PLY_DOUBLE:
-      fread (ptr, 8, 1, fp);
+      read_binary (plyfile, ptr, 8);
       *double_val = *((double *) ptr);
       *int_val = *double_val;
       *uint_val = *double_val;
@@ -2368,6 +3372,84 @@ void get_binary_item(
 }
 
 
+/******************************************************************************
+Return the binary file type matching the byte order of this machine.
+******************************************************************************/
+
+static int native_binary_type(void)
+{
+  unsigned int one = 1;
+
+  if (*((unsigned char *) &one) == 1)
+    return (PLY_BINARY_LE);
+  else
+    return (PLY_BINARY_BE);
+}
+
+
+/******************************************************************************
+Reverse the bytes of a binary value whose file byte order isn't that of
+this machine.
+
+Entry:
+  plyfile - file the value is read from or written to
+  ptr     - the value
+  size    - size of the value in bytes
+******************************************************************************/
+
+static void swap_binary(PlyFile *plyfile, char *ptr, int size)
+{
+  int i;
+  char c;
+
+  if (plyfile->file_type == native_binary_type())
+    return;
+
+  for (i = 0; i < size / 2; i++) {
+    c = ptr[i];
+    ptr[i] = ptr[size - 1 - i];
+    ptr[size - 1 - i] = c;
+  }
+}
+
+
+/******************************************************************************
+Read a binary value of the given size in the byte order of the file.
+
+Entry:
+  plyfile - file to read from
+  size    - size of the value in bytes
+
+Exit:
+  ptr - the value, in the byte order of this machine
+******************************************************************************/
+
+static void read_binary(PlyFile *plyfile, void *ptr, int size)
+{
+  fread (ptr, size, 1, plyfile->fp);
+  swap_binary (plyfile, (char *) ptr, size);
+}
+
+
+/******************************************************************************
+Write a binary value of the given size in the byte order of the file.
+
+Entry:
+  plyfile - file to write to
+  ptr     - the value, in the byte order of this machine
+  size    - size of the value in bytes
+******************************************************************************/
+
+static void write_binary(PlyFile *plyfile, void *ptr, int size)
+{
+  char c[8];
+
+  memcpy (c, ptr, size);
+  swap_binary (plyfile, c, size);
+  fwrite (c, size, 1, plyfile->fp);
+}
+
+
 /******************************************************************************
 Extract the value of an item from an ascii word, and place the result
 into an integer, an unsigned integer and a double.
@@ -2408,8 +3490,14 @@ void get_ascii_item(
       break;
 
     case PLY_FLOAT:
+      /* round to float, as a binary file would store it */
+      *double_val = ascii_to_real (word, PLY_FLOAT);
+      *int_val = (int) *double_val;
+      *uint_val = (unsigned int) *double_val;
+      break;
+
     case PLY_DOUBLE:
-      *double_val = atof (word);
+      *double_val = ascii_to_real (word, PLY_DOUBLE);
       *int_val = (int) *double_val;
       *uint_val = (unsigned int) *double_val;
       break;
@@ -2499,15 +3587,34 @@ Entry:
   nwords  - number of words in the list
 ******************************************************************************/
 
-void add_element (PlyFile *plyfile, char **words, int nwords)
+int add_element (PlyFile *plyfile, char **words, int nwords)
 {
   PlyElement *elem;
+  char *end;
+  long num;
+
+  /* an element line is "element <name> <count>" */
+  if (nwords != 3) {
+    header_error (plyfile, "bad element line");
+    return (-1);
+  }
+  num = strtol (words[2], &end, 10);
+  if (*end != '\0' || num < 0 || num > INT_MAX) {
+    header_error (plyfile, "bad count for element '%s'", words[1]);
+    return (-1);
+  }
+  if (find_element (plyfile, words[1]) != NULL) {
+    header_error (plyfile, "element '%s' declared twice", words[1]);
+    return (-1);
+  }
 
   /* create the new element */
   elem = (PlyElement *) myalloc (sizeof (PlyElement));
   elem->name = strdup (words[1]);
-  elem->num = atoi (words[2]);
+  elem->num = (int) num;
   elem->nprops = 0;
+  elem->props = NULL;
+  elem->store_prop = NULL;
 
   /* make room for new element in the object's list of elements */
   if (plyfile->nelems == 0)
@@ -2519,11 +3626,14 @@ void add_element (PlyFile *plyfile, char **words, int nwords)
   /* add the new element to the object's list */
   plyfile->elems[plyfile->nelems] = elem;
   plyfile->nelems++;
+  return (0);
 }
 
 
 /******************************************************************************
-Return the type of a property, given the name of the property.
+Return the type of a property, given the name of the property.  Both the
+original names (uchar, float, ...) and the sized names (uint8, float32, ...)
+are accepted.
 
 Entry:
   name - name of property type
@@ -2537,7 +3647,8 @@ int get_prop_type(char *type_name)
   int i;
 
   for (i = PLY_START_TYPE + 1; i < PLY_END_TYPE; i++)
-    if (equal_strings (type_name, type_names[i]))
+    if (equal_strings (type_name, type_names[i]) ||
+        equal_strings (type_name, type_aliases[i]))
       return (i);
 
   /* if we get here, we didn't find the type */
@@ -2554,12 +3665,47 @@ Entry:
   nwords  - number of words in the list
 ******************************************************************************/
 
-void add_property (PlyFile *plyfile, char **words, int nwords)
+int add_property (PlyFile *plyfile, char **words, int nwords)
 {
-  int prop_type;
-  int count_type;
   PlyProperty *prop;
   PlyElement *elem;
+  int index;
+
+  /* properties belong to the last element, so there must be one */
+  if (plyfile->nelems == 0) {
+    header_error (plyfile, "property before first element");
+    return (-1);
+  }
+  elem = plyfile->elems[plyfile->nelems - 1];
+
+  /* a property line is "property <type> <name>" or */
+  /* "property list <count type> <type> <name>" */
+  if (nwords >= 2 && equal_strings (words[1], "list")) {
+    if (nwords != 5) {
+      header_error (plyfile, "bad list property line");
+      return (-1);
+    }
+    if (get_prop_type (words[2]) == 0) {
+      header_error (plyfile, "unknown type '%s'", words[2]);
+      return (-1);
+    }
+    if (get_prop_type (words[3]) == 0) {
+      header_error (plyfile, "unknown type '%s'", words[3]);
+      return (-1);
+    }
+  }
+  else if (nwords != 3) {
+    header_error (plyfile, "bad property line");
+    return (-1);
+  }
+  else if (get_prop_type (words[1]) == 0) {
+    header_error (plyfile, "unknown type '%s'", words[1]);
+    return (-1);
+  }
+  if (find_property (elem, words[nwords - 1], &index) != NULL) {
+    header_error (plyfile, "property '%s' declared twice in element '%s'", words[nwords - 1], elem->name);
+    return (-1);
+  }
 
   /* create the new property */
 
@@ -2579,8 +3725,6 @@ void add_property (PlyFile *plyfile, char **words, int nwords)
 
   /* add this property to the list of properties of the current element */
 
-  elem = plyfile->elems[plyfile->nelems - 1];
-
   if (elem->nprops == 0)
     elem->props = (PlyProperty **) myalloc (sizeof (PlyProperty *));
   else
@@ -2589,6 +3733,7 @@ void add_property (PlyFile *plyfile, char **words, int nwords)
 
   elem->props[elem->nprops] = prop;
   elem->nprops++;
+  return (0);
 }
 
 
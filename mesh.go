@@ -0,0 +1,228 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plyfile
+
+import (
+	"fmt"
+	"math"
+)
+
+/* Mesh is an in-memory PLY object. Where the CPlyFile functions stream elements through user defined structs, a Mesh holds every element of the file as a set of property columns. This makes it the common representation used when converting between PLY and other geometry formats. */
+type Mesh struct {
+	Format   int        /* PLY_ASCII, PLY_BINARY_BE or PLY_BINARY_LE */
+	Version  float32    /* version number of the file, written as 1.0 if zero */
+	Comments []string   /* comment lines of the header */
+	ObjInfo  []string   /* obj_info lines of the header */
+	Elements []*Element /* elements, in file order */
+	Unknown  []string   /* header lines with unknown keywords, kept when reading with ParseLenient; never written */
+}
+
+/* Element is a named group of properties, e.g. vertex or face. Every property of an element holds exactly Count values. */
+type Element struct {
+	Name       string
+	Count      int
+	Properties []*Property
+}
+
+/* Property is a single column of an element. Scalar properties store their values in Data, list properties store one list per element in Lists. Values are kept as float64, which represents every PLY scalar type exactly. */
+type Property struct {
+	Name      string
+	Type      int  /* PLY_* type of the values */
+	IsList    bool /* true for list properties */
+	CountType int  /* PLY_* type of the list count, lists only */
+
+	Data  []float64
+	Lists [][]float64
+}
+
+/* NewMesh returns an empty Mesh that will be written using the specified file type. */
+func NewMesh(format int) *Mesh {
+	return &Mesh{Format: format, Version: 1.0}
+}
+
+/* Clone returns a deep copy of the mesh. */
+func (m *Mesh) Clone() *Mesh {
+	c := *m
+	c.Comments = append([]string(nil), m.Comments...)
+	c.ObjInfo = append([]string(nil), m.ObjInfo...)
+	c.Unknown = append([]string(nil), m.Unknown...)
+	c.Elements = make([]*Element, len(m.Elements))
+	for i, elem := range m.Elements {
+		e := *elem
+		e.Properties = make([]*Property, len(elem.Properties))
+		for j, prop := range elem.Properties {
+			p := *prop
+			p.Data = append([]float64(nil), prop.Data...)
+			if prop.Lists != nil {
+				p.Lists = make([][]float64, len(prop.Lists))
+				for k, list := range prop.Lists {
+					p.Lists[k] = append([]float64(nil), list...)
+				}
+			}
+			e.Properties[j] = &p
+		}
+		c.Elements[i] = &e
+	}
+	return &c
+}
+
+/* Element returns the element with the specified name, or nil if the mesh doesn't contain it. */
+func (m *Mesh) Element(name string) *Element {
+	for _, elem := range m.Elements {
+		if elem.Name == name {
+			return elem
+		}
+	}
+	return nil
+}
+
+/* AddElement appends a new element with count instances to the mesh and returns it. Any existing element with the same name is replaced. */
+func (m *Mesh) AddElement(name string, count int) *Element {
+	elem := &Element{Name: name, Count: count}
+	for i, e := range m.Elements {
+		if e.Name == name {
+			m.Elements[i] = elem
+			return elem
+		}
+	}
+	m.Elements = append(m.Elements, elem)
+	return elem
+}
+
+/* Property returns the property with the specified name, or nil if the element doesn't contain it. */
+func (e *Element) Property(name string) *Property {
+	for _, prop := range e.Properties {
+		if prop.Name == name {
+			return prop
+		}
+	}
+	return nil
+}
+
+/* FindProperty returns the first property matching one of the specified names. It is useful for properties that are spelled differently by different PLY producers, e.g. vertex_indices and vertex_index. */
+func (e *Element) FindProperty(names ...string) *Property {
+	for _, name := range names {
+		if prop := e.Property(name); prop != nil {
+			return prop
+		}
+	}
+	return nil
+}
+
+/* Scalars returns the named scalar properties of the element, in order, or nil unless all of them exist, e.g. e.Scalars("x", "y", "z"). */
+func (e *Element) Scalars(names ...string) []*Property {
+	props := make([]*Property, len(names))
+	for i, name := range names {
+		props[i] = e.Property(name)
+		if props[i] == nil || props[i].IsList {
+			return nil
+		}
+	}
+	return props
+}
+
+/* AddProperty appends a scalar property of the specified type to the element and returns it. The values are zero initialized. */
+func (e *Element) AddProperty(name string, typ int) *Property {
+	prop := &Property{Name: name, Type: typ, Data: make([]float64, e.Count)}
+	e.Properties = append(e.Properties, prop)
+	return prop
+}
+
+/* AddListProperty appends a list property to the element and returns it. count_type is the type used to store the length of each list, typ the type of the list values. The lists are initially empty. */
+func (e *Element) AddListProperty(name string, count_type int, typ int) *Property {
+	prop := &Property{Name: name, Type: typ, IsList: true, CountType: count_type, Lists: make([][]float64, e.Count)}
+	e.Properties = append(e.Properties, prop)
+	return prop
+}
+
+/* RemoveProperty removes the property with the specified name from the element. */
+func (e *Element) RemoveProperty(name string) {
+	for i, prop := range e.Properties {
+		if prop.Name == name {
+			e.Properties = append(e.Properties[:i], e.Properties[i+1:]...)
+			return
+		}
+	}
+}
+
+/* ColorByte returns the i-th value of a color property as an integer from 0 to 255. Colors stored as floats or doubles are taken to be between 0 and 1, and scaled. */
+func (p *Property) ColorByte(i int) uint8 {
+	v := p.Data[i]
+	if p.Type == PLY_FLOAT || p.Type == PLY_DOUBLE {
+		v *= 255
+	}
+	return uint8(math.Max(0, math.Min(255, math.Round(v))))
+}
+
+/* AppendText appends the i-th value of a scalar property to b, followed by a space, as an ASCII PLY file holds it: floats and doubles with the fewest digits that read back as the same value. */
+func (p *Property) AppendText(b []byte, i int) []byte {
+	return appendASCIIItem(b, p.Data[i], p.Type, -1)
+}
+
+/* Validate checks that the mesh can be written: the file type and property types must be known, and every property must hold one value per element. */
+func (m *Mesh) Validate() error {
+	if err := m.validateHeader(); err != nil {
+		return err
+	}
+	for _, elem := range m.Elements {
+		if err := elem.validateData(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* validateHeader checks the format and the element and property descriptions, but not the data. */
+func (m *Mesh) validateHeader() error {
+	if m.Format != PLY_ASCII && m.Format != PLY_BINARY_BE && m.Format != PLY_BINARY_LE {
+		return fmt.Errorf("plyfile: bad file type = %d", m.Format)
+	}
+	if !(m.Version >= 0) || math.IsInf(float64(m.Version), 1) {
+		return fmt.Errorf("plyfile: bad version = %v", m.Version)
+	}
+	for _, elem := range m.Elements {
+		if elem.Count < 0 {
+			return fmt.Errorf("plyfile: element '%s' has negative count %d", elem.Name, elem.Count)
+		}
+		for _, prop := range elem.Properties {
+			if !validType(prop.Type) {
+				return fmt.Errorf("plyfile: property '%s' of element '%s' has bad type = %d", prop.Name, elem.Name, prop.Type)
+			}
+			if prop.IsList && (!validType(prop.CountType) || prop.CountType == PLY_FLOAT || prop.CountType == PLY_DOUBLE) {
+				return fmt.Errorf("plyfile: list property '%s' of element '%s' has bad count type = %d", prop.Name, elem.Name, prop.CountType)
+			}
+		}
+	}
+	return nil
+}
+
+/* validateData checks that every property holds Count values or lists, and that an element with instances has properties. */
+func (e *Element) validateData() error {
+	if e.Count > 0 && len(e.Properties) == 0 {
+		return fmt.Errorf("plyfile: element '%s' has %d instances but no properties", e.Name, e.Count)
+	}
+	for _, prop := range e.Properties {
+		if prop.IsList {
+			if len(prop.Lists) != e.Count {
+				return fmt.Errorf("plyfile: list property '%s' of element '%s' has %d lists, expected %d", prop.Name, e.Name, len(prop.Lists), e.Count)
+			}
+		} else if len(prop.Data) != e.Count {
+			return fmt.Errorf("plyfile: property '%s' of element '%s' has %d values, expected %d", prop.Name, e.Name, len(prop.Data), e.Count)
+		}
+	}
+	return nil
+}
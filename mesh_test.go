@@ -0,0 +1,341 @@
+package plyfile
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"strings"
+	"sync"
+	"testing"
+)
+
+/* cubeMesh builds the cube of GenerateVertexFaceData as a Mesh. */
+func cubeMesh(format int) *Mesh {
+	verts, _, vertex_indices := GenerateVertexFaceData()
+	intensity := []float64{1, 4, 8, 16, 100, 255}
+
+	m := NewMesh(format)
+	m.Comments = []string{"go author: Alex Baden, c author: Greg Turk"}
+	m.ObjInfo = []string{"random information"}
+
+	vertex := m.AddElement("vertex", len(verts))
+	x := vertex.AddProperty("x", PLY_FLOAT)
+	y := vertex.AddProperty("y", PLY_FLOAT)
+	z := vertex.AddProperty("z", PLY_FLOAT)
+	for i, v := range verts {
+		x.Data[i], y.Data[i], z.Data[i] = float64(v.X), float64(v.Y), float64(v.Z)
+	}
+
+	face := m.AddElement("face", len(vertex_indices))
+	face.AddProperty("intensity", PLY_UCHAR).Data = intensity
+	lists := face.AddListProperty("vertex_indices", PLY_UCHAR, PLY_INT)
+	for i, indices := range vertex_indices {
+		for _, index := range indices {
+			lists.Lists[i] = append(lists.Lists[i], float64(index))
+		}
+	}
+	return m
+}
+
+/* TestMeshRoundTrip writes the cube in every file type and checks that reading it back gives the same mesh. */
+func TestMeshRoundTrip(t *testing.T) {
+	for _, format := range []int{PLY_ASCII, PLY_BINARY_BE, PLY_BINARY_LE} {
+		m := cubeMesh(format)
+
+		var buf bytes.Buffer
+		if err := EncodeMesh(&buf, m); err != nil {
+			t.Fatalf("format %d: %v", format, err)
+		}
+		got, err := DecodeMesh(&buf)
+		if err != nil {
+			t.Fatalf("format %d: %v", format, err)
+		}
+		if !reflect.DeepEqual(got, m) {
+			t.Errorf("format %d: mesh changed in round trip:\n got %+v\nwant %+v", format, got, m)
+		}
+	}
+}
+
+/* TestReadMeshWrittenByC checks that a file written by the C library is read by the Go mesh reader. */
+/* TestVersion checks that EncodeMesh writes the version of the mesh, and 1.0 for a zero version. */
+func TestVersion(t *testing.T) {
+	for _, test := range []struct {
+		version float32
+		line    string
+	}{{1.5, "format ascii 1.5\n"}, {2, "format ascii 2.0\n"}, {0, "format ascii 1.0\n"}} {
+		m := &Mesh{Format: PLY_ASCII, Version: test.version}
+		var buf bytes.Buffer
+		if err := EncodeMesh(&buf, m); err != nil {
+			t.Fatal(err)
+		}
+		if !strings.Contains(buf.String(), test.line) {
+			t.Errorf("version %v written as %q, want %q", test.version, buf.String(), test.line)
+		}
+		if test.version == 0 {
+			continue
+		}
+		got, err := DecodeMesh(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got.Version != test.version {
+			t.Errorf("version %v read back as %v", test.version, got.Version)
+		}
+	}
+	if err := EncodeMesh(&bytes.Buffer{}, &Mesh{Format: PLY_ASCII, Version: -1}); err == nil {
+		t.Errorf("EncodeMesh accepted version -1")
+	}
+}
+
+func TestReadMeshWrittenByC(t *testing.T) {
+	filename := t.TempDir() + "/cube.ply"
+	elem_names := []string{"vertex", "face"}
+	var version float32
+
+	cplyfile := PlyOpenForWriting(filename, len(elem_names), elem_names, PLY_ASCII, &version)
+	verts, faces, vertex_indices := GenerateVertexFaceData()
+	vert_props, face_props := SetPlyProperties()
+	PlyElementCount(cplyfile, "vertex", len(verts))
+	for _, prop := range vert_props {
+		PlyDescribeProperty(cplyfile, "vertex", prop)
+	}
+	PlyElementCount(cplyfile, "face", len(faces))
+	for _, prop := range face_props {
+		PlyDescribeProperty(cplyfile, "face", prop)
+	}
+	PlyPutComment(cplyfile, "go author: Alex Baden, c author: Greg Turk")
+	PlyPutObjInfo(cplyfile, "random information")
+	PlyHeaderComplete(cplyfile)
+	PlyPutElementSetup(cplyfile, "vertex")
+	for _, vertex := range verts {
+		PlyPutElement(cplyfile, vertex)
+	}
+	PlyPutElementSetup(cplyfile, "face")
+	for _, face := range faces {
+		PlyPutElement(cplyfile, face)
+	}
+	PlyClose(cplyfile)
+	runtime.KeepAlive(vertex_indices)
+
+	got, err := ReadMesh(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := cubeMesh(PLY_ASCII); !reflect.DeepEqual(got, want) {
+		t.Errorf("got %+v\nwant %+v", got, want)
+	}
+}
+
+/* TestDecodeMeshErrors checks that malformed files produce errors. */
+func TestDecodeMeshErrors(t *testing.T) {
+	files := []string{
+		"",
+		"plx\n",
+		"ply\nformat ascii 1.0\n",
+		"ply\nformat ascii\nend_header\n",
+		"ply\nformat ascii 1.0\nproperty float x\nend_header\n",
+		"ply\nformat ascii 1.0\nelement vertex -1\nend_header\n",
+		"ply\nformat ascii 1.0\nelement vertex 1\nproperty float3 x\nend_header\n",
+		"ply\nformat ascii 1.0\nelement face 1\nproperty list uchar\nend_header\n",
+		"ply\nformat ascii 1.0\nelement vertex 2\nproperty float x\nend_header\n1\n",
+		"ply\nformat ascii 1.0\nelement vertex 1\nproperty uchar x\nend_header\n256\n",
+		"ply\nformat ascii 1.0\nelement face 1\nproperty list uchar int v\nend_header\n3 1 2\n",
+		"ply\nformat binary_little_endian 1.0\nelement vertex 1\nproperty float x\nend_header\n\x00\x00",
+	}
+	for _, file := range files {
+		if _, err := DecodeMesh(strings.NewReader(file)); err == nil {
+			t.Errorf("expected an error for %q", file)
+		}
+	}
+}
+
+/* TestHeaderDiagnostics checks the line, text and reason of header errors, from the Go and the C header parsers. */
+func TestHeaderDiagnostics(t *testing.T) {
+	tests := []struct {
+		file   string
+		line   int
+		text   string
+		reason string
+	}{
+		{"plx\n", 1, "plx", "not a PLY file"},
+		{"ply\nformat ascii 1.0\nelement vertex 1\nproperty float3 x\nend_header\n", 4, "property float3 x", "unknown type 'float3'"},
+		{"ply\nformat ascii 1.0\nelement face 1\nproperty list uchar int\nend_header\n", 4, "property list uchar int", "bad list property line"},
+		{"ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nelement vertex 2\nend_header\n", 5, "element vertex 2", "element 'vertex' declared twice"},
+		{"ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nproperty int x\nend_header\n", 5, "property int x",
+			"property 'x' declared twice in element 'vertex'"},
+		{"ply\nformat ascii 1.0\nelement vertex x\nend_header\n", 3, "element vertex x", "bad count for element 'vertex'"},
+		{"ply\nformat ascii 1.0\nproperty float x\nend_header\n", 3, "property float x", "property before first element"},
+		{"ply\nformat binary 1.0\nend_header\n", 2, "format binary 1.0", "unknown format 'binary'"},
+		{"ply\ncomment no format\nend_header\n", 3, "end_header", "missing format line"},
+		{"ply\nformat ascii 1.0\nelement vertex 1\n", 4, "", "unexpected end of file in header"},
+	}
+	path := filepath.Join(t.TempDir(), "header.ply")
+	for _, test := range tests {
+		want := &ParseError{Line: test.line, Text: test.text, Reason: test.reason}
+		_, err := DecodeHeader(strings.NewReader(test.file))
+		if !reflect.DeepEqual(err, want) {
+			t.Errorf("DecodeHeader(%q) = %v, want %v", test.file, err, want)
+		}
+
+		if err := os.WriteFile(path, []byte(test.file), 0644); err != nil {
+			t.Fatal(err)
+		}
+		if plyfile, _ := PlyOpenForReading(path); plyfile != nil {
+			PlyClose(plyfile)
+			t.Errorf("PlyOpenForReading(%q) succeeded", test.file)
+			continue
+		}
+		if err := PlyHeaderError(); !reflect.DeepEqual(err, want) {
+			t.Errorf("PlyHeaderError(%q) = %v, want %v", test.file, err, want)
+		}
+		if _, _, err := OpenPlyFile(path); !reflect.DeepEqual(err, want) {
+			t.Errorf("OpenPlyFile(%q) error = %v, want %v", test.file, err, want)
+		}
+	}
+
+	/* a missing file isn't reported as the header error of the file before */
+	missing := filepath.Join(t.TempDir(), "missing.ply")
+	if plyfile, _ := PlyOpenForReading(missing); plyfile != nil {
+		t.Fatalf("PlyOpenForReading succeeded on a missing file")
+	}
+	if err := PlyHeaderError(); err != nil {
+		t.Errorf("PlyHeaderError after a missing file = %v", err)
+	}
+	if _, _, err := OpenPlyFile(missing); err == nil || !strings.Contains(err.Error(), "can't open") {
+		t.Errorf("OpenPlyFile of a missing file = %v, want a can't open error", err)
+	}
+
+	good := filepath.Join(t.TempDir(), "good.ply")
+	if err := WriteMesh(good, cubeMesh(PLY_ASCII)); err != nil {
+		t.Fatal(err)
+	}
+	if plyfile, _ := PlyOpenForReading(good); plyfile != nil {
+		PlyClose(plyfile)
+	}
+	if err := PlyHeaderError(); err != nil {
+		t.Errorf("PlyHeaderError after a good header = %v", err)
+	}
+}
+
+/* TestConcurrentHeaderErrors opens files with different header errors from several goroutines, and checks that each gets its own. */
+func TestConcurrentHeaderErrors(t *testing.T) {
+	dir := t.TempDir()
+	files := []string{"plx\n", "ply\nformat binary 1.0\nend_header\n", "ply\ncomment no format\nend_header\n"}
+	for i, file := range files {
+		if err := os.WriteFile(filepath.Join(dir, fmt.Sprintf("%d.ply", i)), []byte(file), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	var wg sync.WaitGroup
+	for g := 0; g < 8; g++ {
+		wg.Add(1)
+		go func(g int) {
+			defer wg.Done()
+			for n := 0; n < 100; n++ {
+				i := (g + n) % len(files)
+				_, _, err := OpenPlyFile(filepath.Join(dir, fmt.Sprintf("%d.ply", i)))
+				var parse_err *ParseError
+				if !errors.As(err, &parse_err) || parse_err.Line != i+1 {
+					t.Errorf("file %d: error %v, want line %d", i, err, i+1)
+					return
+				}
+			}
+		}(g)
+	}
+	wg.Wait()
+}
+
+/* TestBodyDiagnostics checks the element, index, property and line of body errors. */
+func TestBodyDiagnostics(t *testing.T) {
+	header := "ply\nformat ascii 1.0\nelement vertex 2\nproperty float x\nproperty uchar red\nelement face 1\nproperty list uchar int vertex_indices\nend_header\n"
+	tests := []struct {
+		body string
+		want BodyError
+	}{
+		{"1 2\n3\n", BodyError{"vertex", 1, "red", 10, "too few values"}},
+		{"1 2\n3 256\n", BodyError{"vertex", 1, "red", 10, "uchar value '256' out of range"}},
+		{"1 2\nx 3\n", BodyError{"vertex", 1, "x", 10, "bad float value 'x'"}},
+		{"1 2 3\n", BodyError{"vertex", 0, "", 9, "1 extra values"}},
+		{"1 2\n3 4\n3 0 1\n", BodyError{"face", 0, "vertex_indices", 11, "too few values"}},
+		{"1 2\n", BodyError{"vertex", 1, "", 10, "unexpected end of file"}},
+	}
+	for _, test := range tests {
+		_, err := DecodeMesh(strings.NewReader(header + test.body))
+		if want := &test.want; !reflect.DeepEqual(err, want) {
+			t.Errorf("%q: error %v, want %v", test.body, err, want)
+		}
+	}
+
+	binary_file := "ply\nformat binary_little_endian 1.0\nelement face 2\nproperty list uchar int vertex_indices\nend_header\n" +
+		"\x01\x00\x00\x00\x00\x02\x00\x00"
+	want := &BodyError{Element: "face", Index: 1, Property: "vertex_indices", Reason: "unexpected end of file"}
+	if _, err := DecodeMesh(strings.NewReader(binary_file)); !reflect.DeepEqual(err, want) {
+		t.Errorf("binary: error %v, want %v", err, want)
+	}
+	if got := want.Error(); got != "plyfile: element 'face' 1, property 'vertex_indices': unexpected end of file" {
+		t.Errorf("Error() = %q", got)
+	}
+}
+
+/* TestTriangulate checks that a concave polygon is split into triangles that lie inside it. */
+func TestTriangulate(t *testing.T) {
+	/* an L shape, whose first corner is the reflex one, so a fan would cover the notch */
+	positions := [][3]float64{{1, 1, 0}, {1, 2, 0}, {0, 2, 0}, {0, 0, 0}, {2, 0, 0}, {2, 1, 0}}
+	polygon := []int{0, 1, 2, 3, 4, 5}
+
+	triangles := Triangulate(nil, polygon, positions)
+	if len(triangles) != 4 {
+		t.Fatalf("got %d triangles, want 4", len(triangles))
+	}
+	area := 0.0
+	for _, tri := range triangles {
+		a, b, c := positions[tri[0]], positions[tri[1]], positions[tri[2]]
+		twice := (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+		if twice <= 0 {
+			t.Errorf("triangle %v is not counter-clockwise", tri)
+		}
+		area += twice / 2
+	}
+	if area != 3 {
+		t.Errorf("triangles cover an area of %g, want 3", area)
+	}
+}
+
+/* TestPropertyHelpers checks Scalars, ColorByte and AppendText, which the converters share. */
+func TestPropertyHelpers(t *testing.T) {
+	m := cubeMesh(PLY_ASCII)
+	vertex := m.Element("vertex")
+	if props := vertex.Scalars("x", "y", "z"); len(props) != 3 || props[2].Name != "z" {
+		t.Errorf("Scalars(x, y, z) = %v", props)
+	}
+	if props := vertex.Scalars("x", "nx"); props != nil {
+		t.Errorf("Scalars with a missing property = %v", props)
+	}
+	if props := m.Element("face").Scalars("vertex_indices"); props != nil {
+		t.Errorf("Scalars of a list = %v", props)
+	}
+
+	colors := m.AddElement("color", 4)
+	red := colors.AddProperty("red", PLY_FLOAT)
+	copy(red.Data, []float64{0.5, 1.5, -1, 0.1})
+	green := colors.AddProperty("green", PLY_UCHAR)
+	copy(green.Data, []float64{128, 300, -1, 7})
+	for i, want := range []uint8{128, 255, 0, 26} {
+		if got := red.ColorByte(i); got != want {
+			t.Errorf("float ColorByte(%d) = %d, want %d", i, got, want)
+		}
+	}
+	for i, want := range []uint8{128, 255, 0, 7} {
+		if got := green.ColorByte(i); got != want {
+			t.Errorf("uchar ColorByte(%d) = %d, want %d", i, got, want)
+		}
+	}
+
+	if got := string(green.AppendText(red.AppendText(nil, 3), 3)); got != "0.1 7 " {
+		t.Errorf("AppendText = %q, want %q", got, "0.1 7 ")
+	}
+}
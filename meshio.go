@@ -0,0 +1,673 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plyfile
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"strconv"
+	"strings"
+)
+
+/* Mesh reading and writing is implemented in Go, following the file layout used by lib/plyfile.c. Unlike the C library, binary files are always read and written in the byte order named in the header, and errors are returned instead of terminating the program. */
+
+/* maximum number of values preallocated for an element before any data has been read */
+const maxPrealloc = 1 << 16
+
+/* ReadMesh reads the PLY file specified by filename into a Mesh. */
+func ReadMesh(filename string) (*Mesh, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return DecodeMesh(f)
+}
+
+/* WriteMesh writes the mesh to a new PLY file called filename, using the file type stored in the mesh. */
+func WriteMesh(filename string, m *Mesh) error {
+	return WriteMeshOptions(filename, m, WriteOptions{})
+}
+
+/* DecodeMesh reads a complete PLY file, header and data, from r. Use DecodeMeshOptions to limit the resources spent on untrusted files. */
+func DecodeMesh(r io.Reader) (*Mesh, error) {
+	return DecodeMeshOptions(r, ReadOptions{})
+}
+
+/* DecodeHeader reads only the header of a PLY file from r. The returned mesh describes every element and property, but holds no data. */
+func DecodeHeader(r io.Reader) (*Mesh, error) {
+	return DecodeHeaderOptions(r, ReadOptions{})
+}
+
+/* EncodeMesh writes the mesh, header and data, to w. */
+func EncodeMesh(w io.Writer, m *Mesh) error {
+	return EncodeMeshOptions(w, m, WriteOptions{})
+}
+
+/* Reading */
+
+/* decodeHeader reads the header, and returns the mesh it describes and the number of header lines. */
+func decodeHeader(br *bufio.Reader, opts *ReadOptions) (*Mesh, int, error) {
+	m := &Mesh{}
+	found_format := false
+	var elem *Element
+
+	line, err := readLine(br)
+	if err != nil {
+		return nil, 0, headerError(err, 1)
+	}
+	if strings.TrimRight(line, "\r") != "ply" {
+		return nil, 0, &ParseError{Line: 1, Text: line, Reason: "not a PLY file"}
+	}
+	if opts.Mode == ParseStrict {
+		if err := checkStrict(line, 1, len(line)); err != nil {
+			return nil, 0, err
+		}
+	}
+	header_bytes := int64(len(line) + 1)
+
+	for line_num := 2; ; line_num++ {
+		if opts.MaxHeaderLines > 0 && line_num > opts.MaxHeaderLines {
+			return nil, 0, &LimitError{Limit: "MaxHeaderLines", Max: int64(opts.MaxHeaderLines)}
+		}
+		line, err = readLine(br)
+		if err != nil {
+			return nil, 0, headerError(err, line_num)
+		}
+		header_bytes += int64(len(line) + 1)
+		words := strings.Fields(line)
+		if opts.Mode == ParseStrict {
+			words_end := len(line)
+			if len(words) > 0 && (words[0] == "comment" || words[0] == "obj_info") {
+				words_end = minInt(len(line), len(words[0])+1)
+			}
+			if err := checkStrict(line, line_num, words_end); err != nil {
+				return nil, 0, err
+			}
+		}
+		if len(words) == 0 {
+			if opts.Mode == ParseLenient {
+				continue
+			}
+			return nil, 0, &ParseError{Line: line_num, Reason: "blank line in header"}
+		}
+		bad := func(format string, args ...interface{}) *ParseError {
+			return &ParseError{Line: line_num, Text: strings.TrimRight(line, "\r"), Reason: fmt.Sprintf(format, args...)}
+		}
+
+		switch words[0] {
+		case "format":
+			if len(words) != 3 {
+				return nil, 0, bad("bad format line")
+			}
+			switch words[1] {
+			case "ascii":
+				m.Format = PLY_ASCII
+			case "binary_big_endian":
+				m.Format = PLY_BINARY_BE
+			case "binary_little_endian":
+				m.Format = PLY_BINARY_LE
+			default:
+				return nil, 0, bad("unknown format '%s'", words[1])
+			}
+			version, err := strconv.ParseFloat(words[2], 32)
+			if err != nil {
+				return nil, 0, bad("bad version '%s'", words[2])
+			}
+			m.Version = float32(version)
+			found_format = true
+		case "element":
+			if len(words) != 3 {
+				return nil, 0, bad("bad element line")
+			}
+			count, err := strconv.Atoi(words[2])
+			if err != nil || count < 0 {
+				return nil, 0, bad("bad count for element '%s'", words[1])
+			}
+			if m.Element(words[1]) != nil {
+				return nil, 0, bad("element '%s' declared twice", words[1])
+			}
+			if opts.MaxElementCount > 0 && count > opts.MaxElementCount {
+				return nil, 0, &LimitError{Limit: "MaxElementCount", Max: int64(opts.MaxElementCount), Value: int64(count),
+					Where: fmt.Sprintf("element '%s'", words[1])}
+			}
+			elem = &Element{Name: words[1], Count: count}
+			m.Elements = append(m.Elements, elem)
+		case "property":
+			if elem == nil {
+				return nil, 0, bad("property before first element")
+			}
+			prop, err := parseProperty(words)
+			if err != nil {
+				return nil, 0, bad("%v", err)
+			}
+			if elem.Property(prop.Name) != nil {
+				return nil, 0, bad("property '%s' declared twice in element '%s'", prop.Name, elem.Name)
+			}
+			elem.Properties = append(elem.Properties, prop)
+		case "comment", "obj_info":
+			text := headerText(line, words[0])
+			if opts.MaxCommentLength > 0 && len(text) > opts.MaxCommentLength {
+				return nil, 0, &LimitError{Limit: "MaxCommentLength", Max: int64(opts.MaxCommentLength), Value: int64(len(text)),
+					Where: fmt.Sprintf("header line %d", line_num)}
+			}
+			if words[0] == "comment" {
+				m.Comments = append(m.Comments, text)
+			} else {
+				m.ObjInfo = append(m.ObjInfo, text)
+			}
+		case "end_header":
+			if !found_format {
+				return nil, 0, bad("missing format line")
+			}
+			if err := opts.checkHeader(m, header_bytes); err != nil {
+				return nil, 0, err
+			}
+			return m, line_num, nil
+		default:
+			if opts.Mode == ParseLenient {
+				m.Unknown = append(m.Unknown, strings.TrimSpace(line))
+				continue
+			}
+			parse_err := bad("unknown keyword '%s'", words[0])
+			parse_err.Column = 1
+			return nil, 0, parse_err
+		}
+	}
+}
+
+/* parseProperty parses the words of a property line; errors give only the reason, without the line. */
+func parseProperty(words []string) (*Property, error) {
+	if len(words) >= 2 && words[1] == "list" {
+		if len(words) != 5 {
+			return nil, errors.New("bad list property line")
+		}
+		count_type := ParseType(words[2])
+		if !isIntegerType(count_type) {
+			return nil, fmt.Errorf("bad list count type '%s'", words[2])
+		}
+		typ := ParseType(words[3])
+		if typ == 0 {
+			return nil, fmt.Errorf("unknown type '%s'", words[3])
+		}
+		return &Property{Name: words[4], Type: typ, IsList: true, CountType: count_type}, nil
+	}
+	if len(words) != 3 {
+		return nil, errors.New("bad property line")
+	}
+	typ := ParseType(words[1])
+	if typ == 0 {
+		return nil, fmt.Errorf("unknown type '%s'", words[1])
+	}
+	return &Property{Name: words[2], Type: typ}, nil
+}
+
+/* headerText returns the text following the keyword of a comment or obj_info line (see add_comment in lib/plyfile.c). */
+func headerText(line string, keyword string) string {
+	line = strings.TrimLeft(strings.TrimRight(line, "\r"), " \t")
+	return strings.TrimLeft(line[len(keyword):], " \t")
+}
+
+/* readLine reads a single line, without the trailing newline. */
+func readLine(br *bufio.Reader) (string, error) {
+	line, err := br.ReadString('\n')
+	if err != nil {
+		if err == io.EOF && len(line) > 0 {
+			return line, nil
+		}
+		return "", err
+	}
+	return line[:len(line)-1], nil
+}
+
+func headerError(err error, line_num int) error {
+	if err == io.EOF {
+		return &ParseError{Line: line_num, Reason: "unexpected end of file in header"}
+	}
+	return err
+}
+
+/* decodeBody reads the data of every element; line_num is the number of the last header line. */
+func decodeBody(br *bufio.Reader, m *Mesh, opts *ReadOptions, line_num int) error {
+	for _, elem := range m.Elements {
+		if err := decodeElement(br, m.Format, elem, opts, &line_num); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* decodeElement reads the data of every instance of elem; line_num is the number of the last line read. */
+func decodeElement(br *bufio.Reader, format int, elem *Element, opts *ReadOptions, line_num *int) error {
+	/* nothing would be read per instance, so a huge count would never end */
+	if elem.Count > 0 && len(elem.Properties) == 0 {
+		return &BodyError{Element: elem.Name, Reason: "element has no properties"}
+	}
+	for _, prop := range elem.Properties {
+		if prop.IsList {
+			prop.Lists = make([][]float64, 0, minInt(elem.Count, maxPrealloc))
+		} else {
+			prop.Data = make([]float64, 0, minInt(elem.Count, maxPrealloc))
+		}
+	}
+	if format == PLY_ASCII {
+		return asciiGetElements(br, elem, opts, line_num)
+	}
+	return binaryGetElements(br, elem, byteOrder(format), opts)
+}
+
+func asciiGetElements(br *bufio.Reader, elem *Element, opts *ReadOptions, line_num *int) error {
+	for i := 0; i < elem.Count; i++ {
+		line, err := readLine(br)
+		*line_num++
+		for opts.Mode == ParseLenient && err == nil && strings.TrimSpace(line) == "" {
+			line, err = readLine(br)
+			*line_num++
+		}
+		if err != nil {
+			return bodyError(err, elem, i, "", *line_num)
+		}
+		if opts.Mode == ParseStrict {
+			if err := checkStrict(line, *line_num, len(line)); err != nil {
+				return err
+			}
+		}
+		words := strings.Fields(line)
+		which_word := 0
+
+		bad := func(prop *Property, reason string) error {
+			return &BodyError{Element: elem.Name, Index: i, Property: prop.Name, Line: *line_num, Reason: reason}
+		}
+		next := func(prop *Property, typ int) (float64, error) {
+			if which_word >= len(words) {
+				return 0, bad(prop, "too few values")
+			}
+			v, reason, err := parseASCIIItem(words[which_word], typ, opts.Convert)
+			if err != nil {
+				return 0, bad(prop, err.Error())
+			}
+			if reason != "" {
+				return 0, &ConvertError{Element: elem.Name, Index: i, Property: prop.Name, Value: v, Type: typ, Reason: reason}
+			}
+			which_word++
+			return v, nil
+		}
+
+		for _, prop := range elem.Properties {
+			if prop.IsList {
+				n, err := next(prop, prop.CountType)
+				if err != nil {
+					return err
+				}
+				if n < 0 {
+					return bad(prop, "negative list count")
+				}
+				if err := opts.checkList(n, elem, i, prop); err != nil {
+					return err
+				}
+				list := make([]float64, 0, minInt(int(n), len(words)))
+				for k := 0; k < int(n); k++ {
+					v, err := next(prop, prop.Type)
+					if err != nil {
+						return err
+					}
+					list = append(list, v)
+				}
+				prop.Lists = append(prop.Lists, list)
+			} else {
+				v, err := next(prop, prop.Type)
+				if err != nil {
+					return err
+				}
+				prop.Data = append(prop.Data, v)
+			}
+		}
+
+		if which_word != len(words) {
+			return &BodyError{Element: elem.Name, Index: i, Line: *line_num, Reason: fmt.Sprintf("%d extra values", len(words)-which_word)}
+		}
+	}
+	return nil
+}
+
+/* parseASCIIItem parses a single value of the specified type. Under ConvertDefault, integers must be written as integers in the range of their type; other policies accept any number and convert it with convertValue, returning the reason it can't be converted. */
+func parseASCIIItem(word string, typ int, policy ConvertPolicy) (float64, string, error) {
+	if policy != ConvertDefault {
+		v, err := strconv.ParseFloat(word, 64)
+		if isIntegerType(typ) {
+			if i, int_err := strconv.ParseInt(word, 10, 64); int_err == nil {
+				v, err = float64(i), nil
+			}
+		}
+		if err != nil && !errors.Is(err, strconv.ErrRange) {
+			return 0, "", fmt.Errorf("bad %s value '%s'", TypeName(typ), word)
+		}
+		if math.IsInf(v, 0) && err != nil && policy != ConvertWrap {
+			if policy != ConvertSaturate {
+				return v, reasonOverflow, nil
+			}
+			v = math.Copysign(math.MaxFloat64, v)
+		}
+		c, reason := convertValue(v, typ, policy)
+		if reason != "" {
+			return v, reason, nil
+		}
+		return c, "", nil
+	}
+
+	if isIntegerType(typ) {
+		v, err := strconv.ParseInt(word, 10, 64)
+		if err != nil {
+			return 0, "", fmt.Errorf("bad %s value '%s'", TypeName(typ), word)
+		}
+		lo, hi := typeRange(typ)
+		if float64(v) < lo || float64(v) > hi {
+			return 0, "", fmt.Errorf("%s value '%s' out of range", TypeName(typ), word)
+		}
+		return float64(v), "", nil
+	}
+
+	v, err := strconv.ParseFloat(word, 64)
+	if err != nil && !errors.Is(err, strconv.ErrRange) {
+		return 0, "", fmt.Errorf("bad %s value '%s'", TypeName(typ), word)
+	}
+	if typ == PLY_FLOAT {
+		v = float64(float32(v))
+	}
+	return v, "", nil
+}
+
+func binaryGetElements(br *bufio.Reader, elem *Element, order binary.ByteOrder, opts *ReadOptions) error {
+	var buf [8]byte
+
+	get := func(typ int) (float64, error) {
+		size := typeSizes[typ]
+		if _, err := io.ReadFull(br, buf[:size]); err != nil {
+			return 0, err
+		}
+		return getBinaryItem(buf[:size], typ, order), nil
+	}
+
+	for i := 0; i < elem.Count; i++ {
+		for _, prop := range elem.Properties {
+			if prop.IsList {
+				n, err := get(prop.CountType)
+				if err != nil {
+					return bodyError(err, elem, i, prop.Name, 0)
+				}
+				if n < 0 {
+					return &BodyError{Element: elem.Name, Index: i, Property: prop.Name, Reason: "negative list count"}
+				}
+				if err := opts.checkList(n, elem, i, prop); err != nil {
+					return err
+				}
+				list := make([]float64, 0, minInt(int(n), maxPrealloc))
+				for k := 0; k < int(n); k++ {
+					v, err := get(prop.Type)
+					if err != nil {
+						return bodyError(err, elem, i, prop.Name, 0)
+					}
+					list = append(list, v)
+				}
+				prop.Lists = append(prop.Lists, list)
+			} else {
+				v, err := get(prop.Type)
+				if err != nil {
+					return bodyError(err, elem, i, prop.Name, 0)
+				}
+				prop.Data = append(prop.Data, v)
+			}
+		}
+	}
+	return nil
+}
+
+/* getBinaryItem converts the raw bytes of a single value to a float64. */
+func getBinaryItem(b []byte, typ int, order binary.ByteOrder) float64 {
+	switch typ {
+	case PLY_CHAR:
+		return float64(int8(b[0]))
+	case PLY_UCHAR:
+		return float64(b[0])
+	case PLY_SHORT:
+		return float64(int16(order.Uint16(b)))
+	case PLY_USHORT:
+		return float64(order.Uint16(b))
+	case PLY_INT:
+		return float64(int32(order.Uint32(b)))
+	case PLY_UINT:
+		return float64(order.Uint32(b))
+	case PLY_FLOAT:
+		return float64(math.Float32frombits(order.Uint32(b)))
+	case PLY_DOUBLE:
+		return math.Float64frombits(order.Uint64(b))
+	}
+	panic(fmt.Sprintf("Error: bad type = %d", typ))
+}
+
+func bodyError(err error, elem *Element, index int, prop_name string, line_num int) error {
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		return &BodyError{Element: elem.Name, Index: index, Property: prop_name, Line: line_num, Reason: "unexpected end of file"}
+	}
+	return err
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+func byteOrder(format int) binary.ByteOrder {
+	if format == PLY_BINARY_BE {
+		return binary.BigEndian
+	}
+	return binary.LittleEndian
+}
+
+/* Writing */
+
+func encodeHeader(bw *bufio.Writer, m *Mesh) error {
+	bw.WriteString("ply\n")
+	switch m.Format {
+	case PLY_ASCII:
+		bw.WriteString("format ascii ")
+	case PLY_BINARY_BE:
+		bw.WriteString("format binary_big_endian ")
+	case PLY_BINARY_LE:
+		bw.WriteString("format binary_little_endian ")
+	}
+	bw.WriteString(formatVersion(m.Version))
+	bw.WriteString("\n")
+
+	for _, comment := range m.Comments {
+		if strings.ContainsAny(comment, "\r\n") {
+			return fmt.Errorf("plyfile: comment contains a line break: '%s'", comment)
+		}
+		fmt.Fprintf(bw, "comment %s\n", comment)
+	}
+	for _, obj_info := range m.ObjInfo {
+		if strings.ContainsAny(obj_info, "\r\n") {
+			return fmt.Errorf("plyfile: obj_info contains a line break: '%s'", obj_info)
+		}
+		fmt.Fprintf(bw, "obj_info %s\n", obj_info)
+	}
+
+	for _, elem := range m.Elements {
+		if !validName(elem.Name) {
+			return fmt.Errorf("plyfile: bad element name '%s'", elem.Name)
+		}
+		fmt.Fprintf(bw, "element %s %d\n", elem.Name, elem.Count)
+		for _, prop := range elem.Properties {
+			if !validName(prop.Name) {
+				return fmt.Errorf("plyfile: bad property name '%s'", prop.Name)
+			}
+			if prop.IsList {
+				fmt.Fprintf(bw, "property list %s %s %s\n", TypeName(prop.CountType), TypeName(prop.Type), prop.Name)
+			} else {
+				fmt.Fprintf(bw, "property %s %s\n", TypeName(prop.Type), prop.Name)
+			}
+		}
+	}
+
+	_, err := bw.WriteString("end_header\n")
+	return err
+}
+
+/* validName reports whether name can be written as a single header word. */
+func validName(name string) bool {
+	return name != "" && !strings.ContainsAny(name, " \t\r\n")
+}
+
+func encodeBody(bw *bufio.Writer, m *Mesh, opts *WriteOptions) error {
+	for _, elem := range m.Elements {
+		if err := encodeElement(bw, m.Format, elem, opts); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* encodeElement writes the data of every instance of elem. */
+func encodeElement(bw *bufio.Writer, format int, elem *Element, opts *WriteOptions) error {
+	order := byteOrder(format)
+	var buf [8]byte
+	var line []byte
+
+	decimals := make([]int, len(elem.Properties))
+	for k, prop := range elem.Properties {
+		decimals[k] = -1
+		if d, ok := opts.Decimals[elem.Name+"."+prop.Name]; ok && d >= 0 {
+			decimals[k] = d
+		}
+	}
+
+	for i := 0; i < elem.Count; i++ {
+		line = line[:0]
+		for k, prop := range elem.Properties {
+			var values []float64
+			if !prop.IsList {
+				values = prop.Data[i : i+1]
+			} else {
+				values = prop.Lists[i]
+				lo, hi := typeRange(prop.CountType)
+				if float64(len(values)) < lo || float64(len(values)) > hi {
+					return fmt.Errorf("plyfile: element '%s' %d: list '%s' too long for count type %s", elem.Name, i, prop.Name, TypeName(prop.CountType))
+				}
+				if format == PLY_ASCII {
+					line = appendASCIIItem(line, float64(len(values)), prop.CountType, -1)
+				} else {
+					line = append(line, putBinaryItem(buf[:], float64(len(values)), prop.CountType, order)...)
+				}
+			}
+			for _, v := range values {
+				c, reason := convertValue(v, prop.Type, opts.Convert)
+				if reason != "" {
+					return &ConvertError{Element: elem.Name, Index: i, Property: prop.Name, Value: v, Type: prop.Type, Reason: reason}
+				}
+				if format == PLY_ASCII {
+					line = appendASCIIItem(line, c, prop.Type, decimals[k])
+				} else {
+					line = append(line, putBinaryItem(buf[:], c, prop.Type, order)...)
+				}
+			}
+		}
+		if format == PLY_ASCII {
+			if len(line) > 0 {
+				line = line[:len(line)-1]
+			}
+			line = append(line, '\n')
+		}
+		if _, err := bw.Write(line); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+/* formatVersion formats the version of a format line with the fewest digits, keeping a decimal point as in "1.0". The zero version of a Mesh built by hand is written as 1.0. */
+func formatVersion(version float32) string {
+	if version == 0 {
+		version = 1.0
+	}
+	s := strconv.FormatFloat(float64(version), 'f', -1, 32)
+	if !strings.Contains(s, ".") {
+		s += ".0"
+	}
+	return s
+}
+
+/* appendASCIIItem formats a single value of the specified type, followed by a space. Floating point values have the given number of decimals, or, if decimals is negative, the fewest digits that read back as the same value. */
+func appendASCIIItem(b []byte, v float64, typ int, decimals int) []byte {
+	format := byte('g')
+	if decimals >= 0 {
+		format = 'f'
+	}
+	switch typ {
+	case PLY_CHAR, PLY_SHORT, PLY_INT, PLY_UCHAR, PLY_USHORT, PLY_UINT:
+		b = strconv.AppendInt(b, storeInt(v, typ), 10)
+	case PLY_FLOAT:
+		b = strconv.AppendFloat(b, v, format, decimals, 32)
+	default:
+		b = strconv.AppendFloat(b, v, format, decimals, 64)
+	}
+	return append(b, ' ')
+}
+
+/* putBinaryItem stores a single value of the specified type into buf, returning the bytes used. */
+func putBinaryItem(buf []byte, v float64, typ int, order binary.ByteOrder) []byte {
+	size := typeSizes[typ]
+	switch typ {
+	case PLY_CHAR, PLY_UCHAR:
+		buf[0] = byte(storeInt(v, typ))
+	case PLY_SHORT, PLY_USHORT:
+		order.PutUint16(buf, uint16(storeInt(v, typ)))
+	case PLY_INT, PLY_UINT:
+		order.PutUint32(buf, uint32(storeInt(v, typ)))
+	case PLY_FLOAT:
+		order.PutUint32(buf, math.Float32bits(float32(v)))
+	case PLY_DOUBLE:
+		order.PutUint64(buf, math.Float64bits(v))
+	}
+	return buf[:size]
+}
+
+/* storeInt converts a value to an integer type the way store_item in lib/plyfile.c does: the fraction is truncated and values outside the range of the type wrap around. */
+func storeInt(v float64, typ int) int64 {
+	i := int64(v)
+	switch typ {
+	case PLY_CHAR:
+		return int64(int8(i))
+	case PLY_SHORT:
+		return int64(int16(i))
+	case PLY_INT:
+		return int64(int32(i))
+	case PLY_UCHAR:
+		return int64(uint8(i))
+	case PLY_USHORT:
+		return int64(uint16(i))
+	case PLY_UINT:
+		return int64(uint32(i))
+	}
+	return i
+}
@@ -0,0 +1,268 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plyfile
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+/* Metadata is a key/value view of the comment and obj_info lines of a header. Each line is read as "key value" or "key=value", e.g. "TextureFile tex.png", "scale=0.01" or the obj_info "num_cols 640" of range grids. Lines are kept in their order, and unchanged lines are written back as they were read. */
+type Metadata struct {
+	lines []*metadataLine
+}
+
+/* metadataLine is one comment or obj_info line. */
+type metadataLine struct {
+	obj_info bool
+	key      string /* "" for a blank line */
+	value    string
+	equals   bool   /* written as key=value */
+	text     string /* line as read, or "" once the value is changed */
+}
+
+/* well known metadata keys */
+const (
+	MetaTextureFile = "TextureFile" /* comment naming a texture image, relative to the PLY file */
+	MetaNumCols     = "num_cols"    /* obj_info giving the width of a range grid */
+	MetaNumRows     = "num_rows"    /* obj_info giving the height of a range grid */
+)
+
+/* ParseMetadata returns the metadata of comment and obj_info lines, e.g. Mesh.Comments and Mesh.ObjInfo. */
+func ParseMetadata(comments, obj_info []string) *Metadata {
+	md := &Metadata{}
+	for _, text := range comments {
+		md.lines = append(md.lines, parseMetadataLine(text, false))
+	}
+	for _, text := range obj_info {
+		md.lines = append(md.lines, parseMetadataLine(text, true))
+	}
+	return md
+}
+
+func parseMetadataLine(text string, obj_info bool) *metadataLine {
+	line := &metadataLine{obj_info: obj_info, text: text}
+	trimmed := strings.TrimSpace(text)
+	if trimmed == "" {
+		return line
+	}
+	end := strings.IndexAny(trimmed, " \t=")
+	if end < 0 {
+		line.key = trimmed
+		return line
+	}
+	line.key = trimmed[:end]
+	rest := strings.TrimLeft(trimmed[end:], " \t")
+	if strings.HasPrefix(rest, "=") {
+		line.equals = true
+		rest = strings.TrimLeft(rest[1:], " \t")
+	}
+	line.value = rest
+	return line
+}
+
+/* String returns the line as it is written. */
+func (line *metadataLine) String() string {
+	switch {
+	case line.text != "" || line.key == "":
+		return line.text
+	case line.equals:
+		return line.key + "=" + line.value
+	case line.value == "":
+		return line.key
+	}
+	return line.key + " " + line.value
+}
+
+/* Metadata returns the metadata of the comments and obj_info of the mesh. */
+func (m *Mesh) Metadata() *Metadata {
+	return ParseMetadata(m.Comments, m.ObjInfo)
+}
+
+/* SetMetadata replaces the comments and obj_info of the mesh with those of md. */
+func (m *Mesh) SetMetadata(md *Metadata) {
+	m.Comments = md.Comments()
+	m.ObjInfo = md.ObjInfo()
+}
+
+/* Comments returns the comment lines, in order. */
+func (md *Metadata) Comments() []string {
+	return md.text(false)
+}
+
+/* ObjInfo returns the obj_info lines, in order. */
+func (md *Metadata) ObjInfo() []string {
+	return md.text(true)
+}
+
+func (md *Metadata) text(obj_info bool) []string {
+	var lines []string
+	for _, line := range md.lines {
+		if line.obj_info == obj_info {
+			lines = append(lines, line.String())
+		}
+	}
+	return lines
+}
+
+/* Keys returns the keys of the comments then of the obj_info, in order and each once. */
+func (md *Metadata) Keys() []string {
+	var keys []string
+	seen := map[string]bool{}
+	for _, line := range md.lines {
+		if line.key != "" && !seen[line.key] {
+			seen[line.key] = true
+			keys = append(keys, line.key)
+		}
+	}
+	return keys
+}
+
+/* Map returns the first value of each key. */
+func (md *Metadata) Map() map[string]string {
+	values := map[string]string{}
+	for _, key := range md.Keys() {
+		values[key], _ = md.Get(key)
+	}
+	return values
+}
+
+/* Get returns the value of the first line with the given key, and whether there is one. */
+func (md *Metadata) Get(key string) (string, bool) {
+	if line := md.line(key); line != nil {
+		return line.value, true
+	}
+	return "", false
+}
+
+/* Values returns the values of every line with the given key, e.g. the TextureFile of each texture. */
+func (md *Metadata) Values(key string) []string {
+	var values []string
+	for _, line := range md.lines {
+		if line.key == key {
+			values = append(values, line.value)
+		}
+	}
+	return values
+}
+
+/* Int returns the value of key as an integer, and whether the key is present. A value that isn't an integer is an error. */
+func (md *Metadata) Int(key string) (int, bool, error) {
+	value, ok := md.Get(key)
+	if !ok {
+		return 0, false, nil
+	}
+	n, err := strconv.Atoi(value)
+	if err != nil {
+		return 0, true, fmt.Errorf("plyfile: metadata '%s' is not an integer: '%s'", key, value)
+	}
+	return n, true, nil
+}
+
+/* Float returns the value of key as a number, and whether the key is present. A value that isn't a number is an error. */
+func (md *Metadata) Float(key string) (float64, bool, error) {
+	value, ok := md.Get(key)
+	if !ok {
+		return 0, false, nil
+	}
+	v, err := strconv.ParseFloat(value, 64)
+	if err != nil {
+		return 0, true, fmt.Errorf("plyfile: metadata '%s' is not a number: '%s'", key, value)
+	}
+	return v, true, nil
+}
+
+/* Set changes the value of the first line with the given key, keeping its place and its form. Without such a line, a "key value" line is added at the end: an obj_info line for num_cols and num_rows, a comment otherwise. */
+func (md *Metadata) Set(key, value string) {
+	if line := md.line(key); line != nil {
+		if line.value != value {
+			line.value = value
+			line.text = ""
+		}
+		return
+	}
+	md.Add(key, value)
+}
+
+/* SetInt sets key to an integer value. */
+func (md *Metadata) SetInt(key string, n int) {
+	md.Set(key, strconv.Itoa(n))
+}
+
+/* SetFloat sets key to a number, written with the fewest digits that read back exactly. */
+func (md *Metadata) SetFloat(key string, v float64) {
+	md.Set(key, strconv.FormatFloat(v, 'g', -1, 64))
+}
+
+/* Add adds a "key value" line at the end, even if the key is already present, e.g. for a second TextureFile: an obj_info line for num_cols and num_rows, a comment otherwise. */
+func (md *Metadata) Add(key, value string) {
+	md.lines = append(md.lines, &metadataLine{obj_info: key == MetaNumCols || key == MetaNumRows, key: key, value: value})
+}
+
+/* Delete removes every line with the given key. */
+func (md *Metadata) Delete(key string) {
+	var lines []*metadataLine
+	for _, line := range md.lines {
+		if line.key != key {
+			lines = append(lines, line)
+		}
+	}
+	md.lines = lines
+}
+
+func (md *Metadata) line(key string) *metadataLine {
+	for _, line := range md.lines {
+		if line.key == key {
+			return line
+		}
+	}
+	return nil
+}
+
+/* TextureFiles returns the texture images named by TextureFile comments, in order. */
+func (md *Metadata) TextureFiles() []string {
+	return md.Values(MetaTextureFile)
+}
+
+/* GridSize returns the num_cols and num_rows of a range grid, and whether both are present. */
+func (md *Metadata) GridSize() (int, int, bool, error) {
+	cols, has_cols, err := md.Int(MetaNumCols)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	rows, has_rows, err := md.Int(MetaNumRows)
+	if err != nil {
+		return 0, 0, false, err
+	}
+	return cols, rows, has_cols && has_rows, nil
+}
+
+/* PlyGetMetadata returns the metadata of the comments and obj_info of an open PLY file. */
+func PlyGetMetadata(plyfile CPlyFile) *Metadata {
+	return ParseMetadata(PlyGetComments(plyfile), PlyGetObjInfo(plyfile))
+}
+
+/* PlyPutMetadata writes the comments and obj_info of md into the PLY file header. */
+func PlyPutMetadata(plyfile CPlyFile, md *Metadata) {
+	for _, comment := range md.Comments() {
+		PlyPutComment(plyfile, comment)
+	}
+	for _, obj_info := range md.ObjInfo() {
+		PlyPutObjInfo(plyfile, obj_info)
+	}
+}
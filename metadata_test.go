@@ -0,0 +1,86 @@
+package plyfile
+
+import (
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestMetadata(t *testing.T) {
+	comments := []string{"Created by X", "TextureFile tex.png", "scale=0.01", "TextureFile  detail.png", "", "flag"}
+	obj_info := []string{"num_cols 640", "num_rows 480"}
+	md := ParseMetadata(comments, obj_info)
+
+	if got := md.Keys(); !reflect.DeepEqual(got, []string{"Created", "TextureFile", "scale", "flag", "num_cols", "num_rows"}) {
+		t.Errorf("Keys = %q", got)
+	}
+	want := map[string]string{"Created": "by X", "TextureFile": "tex.png", "scale": "0.01", "flag": "", "num_cols": "640", "num_rows": "480"}
+	if got := md.Map(); !reflect.DeepEqual(got, want) {
+		t.Errorf("Map = %q, want %q", got, want)
+	}
+	if got := md.TextureFiles(); !reflect.DeepEqual(got, []string{"tex.png", "detail.png"}) {
+		t.Errorf("TextureFiles = %q", got)
+	}
+	if cols, rows, ok, err := md.GridSize(); cols != 640 || rows != 480 || !ok || err != nil {
+		t.Errorf("GridSize = %d, %d, %v, %v", cols, rows, ok, err)
+	}
+	if v, ok, err := md.Float("scale"); v != 0.01 || !ok || err != nil {
+		t.Errorf("Float(scale) = %v, %v, %v", v, ok, err)
+	}
+	if _, ok, err := md.Int("Created"); !ok || err == nil {
+		t.Errorf("Int(Created) = %v, %v; want an error", ok, err)
+	}
+	if _, ok, err := md.Int("missing"); ok || err != nil {
+		t.Errorf("Int(missing) = %v, %v", ok, err)
+	}
+
+	/* unchanged lines are written back as read */
+	if !reflect.DeepEqual(md.Comments(), comments) || !reflect.DeepEqual(md.ObjInfo(), obj_info) {
+		t.Errorf("round trip %q %q", md.Comments(), md.ObjInfo())
+	}
+
+	md.SetFloat("scale", 0.5)
+	md.SetInt("num_cols", 320)
+	md.Set("units", "m")
+	md.Set("TextureFile", "tex.png")
+	md.Delete("flag")
+	md.Add(MetaTextureFile, "normal.png")
+	want_comments := []string{"Created by X", "TextureFile tex.png", "scale=0.5", "TextureFile  detail.png", "", "units m", "TextureFile normal.png"}
+	if got := md.Comments(); !reflect.DeepEqual(got, want_comments) {
+		t.Errorf("Comments = %q, want %q", got, want_comments)
+	}
+	if got := md.ObjInfo(); !reflect.DeepEqual(got, []string{"num_cols 320", "num_rows 480"}) {
+		t.Errorf("ObjInfo = %q", got)
+	}
+
+	m := NewMesh(PLY_ASCII)
+	m.SetMetadata(md)
+	if got := m.Metadata(); !reflect.DeepEqual(got.Comments(), want_comments) || !reflect.DeepEqual(got.Map(), md.Map()) {
+		t.Errorf("Mesh.Metadata = %q, want %q", got.Comments(), want_comments)
+	}
+}
+
+func TestPlyMetadata(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "metadata.ply")
+	md := ParseMetadata([]string{"TextureFile tex.png", "scale=0.01"}, []string{"num_cols 2", "num_rows 3"})
+	f, err := CreatePlyFile(path, []string{"vertex"}, PLY_ASCII)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f.PutMetadata(md)
+	f.ElementCount("vertex", 0)
+	f.HeaderComplete()
+	if err := f.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	f, _, err = OpenPlyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	got := f.Metadata()
+	if !reflect.DeepEqual(got.Comments(), md.Comments()) || !reflect.DeepEqual(got.ObjInfo(), md.ObjInfo()) {
+		t.Errorf("Metadata = %q %q, want %q %q", got.Comments(), got.ObjInfo(), md.Comments(), md.ObjInfo())
+	}
+}
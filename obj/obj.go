@@ -0,0 +1,432 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package obj converts between Wavefront OBJ files and PLY meshes.
+
+Positions (v), normals (vn), texture coordinates (vt) and polygonal faces (f) of any size are supported, including the v, v/vt, v//vn and v/vt/vn index forms and negative (relative) indices. Vertex colors written as "v x y z r g b" are carried over to the PLY red, green and blue properties.
+
+OBJ indexes positions, normals and texture coordinates separately, while PLY stores a single list of vertices. When a position is used with different normals or texture coordinates, the position is duplicated so that every PLY vertex has exactly one of each. Other statements (groups, materials, lines, ...) are ignored when reading. When writing, EncodeWithMTL and EncodeMTL carry the TextureFile comments of a PLY mesh over to an MTL material library.
+*/
+package obj
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+func init() {
+	plyfile.RegisterFormat("obj", sniff, Decode)
+}
+
+/* sniff reports whether the first statement of prefix (after comments) is an OBJ statement. */
+func sniff(prefix []byte) bool {
+	if bytes.IndexByte(prefix, 0) >= 0 {
+		return false
+	}
+	for _, line := range strings.Split(string(prefix), "\n") {
+		words := strings.Fields(line)
+		if len(words) == 0 || strings.HasPrefix(words[0], "#") {
+			continue
+		}
+		switch words[0] {
+		case "v", "vn", "vt", "vp", "f", "l", "p", "o", "g", "s", "mtllib", "usemtl":
+			return true
+		}
+		return false
+	}
+	return false
+}
+
+/* vertex is a unique combination of position, texture coordinate and normal indices. -1 means not used, -2 means not assigned yet. */
+type vertex struct {
+	v, vt, vn int
+}
+
+/* Decode reads an OBJ file from r and returns it as a PLY mesh with a vertex and (if the file has any faces) a face element. */
+func Decode(r io.Reader) (*plyfile.Mesh, error) {
+	var positions, normals, texcoords [][]float64
+	var colors [][]float64
+	var faces [][]vertex
+	var comments []string
+
+	br := bufio.NewReader(r)
+	for line_num := 1; ; line_num++ {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if err == io.EOF && line == "" {
+			break
+		}
+
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			comments = append(comments, strings.TrimSpace(line[1:]))
+			continue
+		}
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+
+		switch words[0] {
+		case "v":
+			values, err := parseFloats(words[1:])
+			if err != nil || (len(values) != 3 && len(values) != 4 && len(values) != 6) {
+				return nil, fmt.Errorf("obj: line %d: bad vertex '%s'", line_num, line)
+			}
+			positions = append(positions, values[:3])
+			if len(values) == 6 {
+				for len(colors) < len(positions)-1 {
+					colors = append(colors, nil)
+				}
+				colors = append(colors, values[3:])
+			}
+		case "vn":
+			values, err := parseFloats(words[1:])
+			if err != nil || len(values) != 3 {
+				return nil, fmt.Errorf("obj: line %d: bad normal '%s'", line_num, line)
+			}
+			normals = append(normals, values)
+		case "vt":
+			values, err := parseFloats(words[1:])
+			if err != nil || len(values) < 1 || len(values) > 3 {
+				return nil, fmt.Errorf("obj: line %d: bad texture coordinate '%s'", line_num, line)
+			}
+			if len(values) == 1 {
+				values = append(values, 0)
+			}
+			texcoords = append(texcoords, values[:2])
+		case "f":
+			if len(words) < 4 {
+				return nil, fmt.Errorf("obj: line %d: face with less than 3 vertices", line_num)
+			}
+			face := make([]vertex, len(words)-1)
+			for i, word := range words[1:] {
+				face[i], err = parseCorner(word, len(positions), len(texcoords), len(normals))
+				if err != nil {
+					return nil, fmt.Errorf("obj: line %d: %v", line_num, err)
+				}
+			}
+			faces = append(faces, face)
+		}
+	}
+
+	/* assign a PLY vertex to every corner, duplicating positions as necessary */
+	verts := make([]vertex, len(positions))
+	for i := range verts {
+		verts[i] = vertex{i, -2, -2}
+	}
+	extra := make(map[vertex]int)
+	indices := make([][]float64, len(faces))
+	for i, face := range faces {
+		indices[i] = make([]float64, len(face))
+		for j, c := range face {
+			index := c.v
+			switch {
+			case verts[index].vt == -2:
+				verts[index] = c
+			case verts[index] == c:
+			default:
+				n, ok := extra[c]
+				if !ok {
+					n = len(verts)
+					verts = append(verts, c)
+					extra[c] = n
+				}
+				index = n
+			}
+			indices[i][j] = float64(index)
+		}
+	}
+
+	has_normals, has_texcoords := false, false
+	for _, vert := range verts {
+		has_normals = has_normals || vert.vn >= 0
+		has_texcoords = has_texcoords || vert.vt >= 0
+	}
+
+	m := plyfile.NewMesh(plyfile.PLY_BINARY_LE)
+	m.Comments = comments
+
+	vertex_elem := m.AddElement("vertex", len(verts))
+	x := vertex_elem.AddProperty("x", plyfile.PLY_FLOAT)
+	y := vertex_elem.AddProperty("y", plyfile.PLY_FLOAT)
+	z := vertex_elem.AddProperty("z", plyfile.PLY_FLOAT)
+	for i, vert := range verts {
+		p := positions[vert.v]
+		x.Data[i], y.Data[i], z.Data[i] = p[0], p[1], p[2]
+	}
+
+	if has_normals {
+		nx := vertex_elem.AddProperty("nx", plyfile.PLY_FLOAT)
+		ny := vertex_elem.AddProperty("ny", plyfile.PLY_FLOAT)
+		nz := vertex_elem.AddProperty("nz", plyfile.PLY_FLOAT)
+		for i, vert := range verts {
+			if vert.vn >= 0 {
+				n := normals[vert.vn]
+				nx.Data[i], ny.Data[i], nz.Data[i] = n[0], n[1], n[2]
+			}
+		}
+	}
+
+	if len(colors) > 0 {
+		red := vertex_elem.AddProperty("red", plyfile.PLY_UCHAR)
+		green := vertex_elem.AddProperty("green", plyfile.PLY_UCHAR)
+		blue := vertex_elem.AddProperty("blue", plyfile.PLY_UCHAR)
+		for i, vert := range verts {
+			if vert.v < len(colors) && colors[vert.v] != nil {
+				c := colors[vert.v]
+				red.Data[i], green.Data[i], blue.Data[i] = colorToByte(c[0]), colorToByte(c[1]), colorToByte(c[2])
+			}
+		}
+	}
+
+	if has_texcoords {
+		s := vertex_elem.AddProperty("s", plyfile.PLY_FLOAT)
+		t := vertex_elem.AddProperty("t", plyfile.PLY_FLOAT)
+		for i, vert := range verts {
+			if vert.vt >= 0 {
+				tc := texcoords[vert.vt]
+				s.Data[i], t.Data[i] = tc[0], tc[1]
+			}
+		}
+	}
+
+	if len(faces) > 0 {
+		count_type := plyfile.PLY_UCHAR
+		for _, face := range faces {
+			if len(face) > math.MaxUint8 {
+				count_type = plyfile.PLY_UINT
+			}
+		}
+		face_elem := m.AddElement("face", len(faces))
+		face_elem.AddListProperty("vertex_indices", count_type, plyfile.PLY_INT).Lists = indices
+	}
+
+	return m, nil
+}
+
+/* parseCorner parses a face corner in one of the forms v, v/vt, v//vn or v/vt/vn. */
+func parseCorner(word string, npositions int, ntexcoords int, nnormals int) (vertex, error) {
+	parts := strings.Split(word, "/")
+	if len(parts) > 3 {
+		return vertex{}, fmt.Errorf("bad face vertex '%s'", word)
+	}
+	c := vertex{-1, -1, -1}
+	var err error
+	if c.v, err = parseIndex(parts[0], npositions); err != nil {
+		return c, err
+	}
+	if len(parts) > 1 && parts[1] != "" {
+		if c.vt, err = parseIndex(parts[1], ntexcoords); err != nil {
+			return c, err
+		}
+	}
+	if len(parts) > 2 && parts[2] != "" {
+		if c.vn, err = parseIndex(parts[2], nnormals); err != nil {
+			return c, err
+		}
+	}
+	return c, nil
+}
+
+/* parseIndex converts a 1-based (or negative, relative) OBJ index into a 0-based index. */
+func parseIndex(word string, n int) (int, error) {
+	i, err := strconv.Atoi(word)
+	if err != nil {
+		return 0, fmt.Errorf("bad index '%s'", word)
+	}
+	if i < 0 {
+		i += n
+	} else {
+		i--
+	}
+	if i < 0 || i >= n {
+		return 0, fmt.Errorf("index '%s' out of range", word)
+	}
+	return i, nil
+}
+
+func parseFloats(words []string) ([]float64, error) {
+	values := make([]float64, len(words))
+	for i, word := range words {
+		v, err := strconv.ParseFloat(word, 64)
+		if err != nil {
+			return nil, err
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func colorToByte(c float64) float64 {
+	return math.Max(0, math.Min(255, math.Round(c*255)))
+}
+
+/* Encode writes the vertex and face elements of a PLY mesh to w as an OBJ file. Normals (nx, ny, nz), colors (red, green, blue) and texture coordinates (s, t, u, v or texture_u, texture_v of the vertices, or texcoord lists of the faces) are written when the mesh has them. */
+func Encode(w io.Writer, m *plyfile.Mesh) error {
+	return EncodeWithMTL(w, m, "")
+}
+
+/* EncodeWithMTL writes the mesh as Encode does and, if mtllib isn't empty and the mesh has TextureFile comments, refers to the materials that EncodeMTL writes to the file mtllib: each face uses the material of its texnumber, or the first one. */
+func EncodeWithMTL(w io.Writer, m *plyfile.Mesh, mtllib string) error {
+	if m.FaceTexCoords() != nil {
+		m = m.Clone()
+		if err := m.ToVertexTexCoords(); err != nil {
+			return fmt.Errorf("obj: %v", err)
+		}
+	}
+	vertex_elem := m.Element("vertex")
+	if vertex_elem == nil {
+		return fmt.Errorf("obj: mesh has no vertex element")
+	}
+	x, y, z := vertex_elem.Property("x"), vertex_elem.Property("y"), vertex_elem.Property("z")
+	if x == nil || y == nil || z == nil || x.IsList || y.IsList || z.IsList {
+		return fmt.Errorf("obj: vertex element has no x, y and z properties")
+	}
+	normals := vertex_elem.Scalars("nx", "ny", "nz")
+	colors := vertex_elem.Scalars("red", "green", "blue")
+	var texcoords []*plyfile.Property
+	if u, v := m.VertexTexCoords(); u != nil {
+		texcoords = []*plyfile.Property{u, v}
+	}
+	textures := m.TextureFiles()
+
+	bw := bufio.NewWriter(w)
+	for _, comment := range m.Comments {
+		fmt.Fprintf(bw, "# %s\n", comment)
+	}
+	if mtllib != "" && len(textures) > 0 {
+		fmt.Fprintf(bw, "mtllib %s\n", mtllib)
+	}
+
+	var line []byte
+	for i := 0; i < vertex_elem.Count; i++ {
+		line = append(line[:0], 'v', ' ')
+		line = x.AppendText(line, i)
+		line = y.AppendText(line, i)
+		line = z.AppendText(line, i)
+		if colors != nil {
+			for _, c := range colors {
+				v := c.Data[i]
+				if c.Type != plyfile.PLY_FLOAT && c.Type != plyfile.PLY_DOUBLE {
+					v /= 255
+				}
+				line = strconv.AppendFloat(line, v, 'g', -1, 32)
+				line = append(line, ' ')
+			}
+		}
+		line[len(line)-1] = '\n'
+		bw.Write(line)
+	}
+	if normals != nil {
+		for i := 0; i < vertex_elem.Count; i++ {
+			line = append(line[:0], 'v', 'n', ' ')
+			for _, n := range normals {
+				line = n.AppendText(line, i)
+			}
+			line[len(line)-1] = '\n'
+			bw.Write(line)
+		}
+	}
+	if texcoords != nil {
+		for i := 0; i < vertex_elem.Count; i++ {
+			line = append(line[:0], 'v', 't', ' ')
+			for _, t := range texcoords {
+				line = t.AppendText(line, i)
+			}
+			line[len(line)-1] = '\n'
+			bw.Write(line)
+		}
+	}
+
+	if face_elem := m.Element("face"); face_elem != nil {
+		indices := face_elem.FindProperty("vertex_indices", "vertex_index")
+		if indices == nil || !indices.IsList {
+			return fmt.Errorf("obj: face element has no vertex_indices list")
+		}
+		texnumber := face_elem.Property(plyfile.TexNumberProperty)
+		material := -1
+		for i, face := range indices.Lists {
+			if mtllib != "" && len(textures) > 0 {
+				k := 0
+				if texnumber != nil && !texnumber.IsList {
+					k = int(texnumber.Data[i])
+				}
+				if k < 0 || k >= len(textures) {
+					return fmt.Errorf("obj: face %d: texnumber %d out of range", i, k)
+				}
+				if k != material {
+					fmt.Fprintf(bw, "usemtl %s\n", materialName(k))
+					material = k
+				}
+			}
+			line = append(line[:0], 'f')
+			for _, v := range face {
+				index := int(v)
+				if index < 0 || index >= vertex_elem.Count {
+					return fmt.Errorf("obj: face %d: vertex index %d out of range", i, index)
+				}
+				line = append(line, ' ')
+				line = strconv.AppendInt(line, int64(index+1), 10)
+				switch {
+				case texcoords != nil && normals != nil:
+					line = append(line, '/')
+					line = strconv.AppendInt(line, int64(index+1), 10)
+					line = append(line, '/')
+					line = strconv.AppendInt(line, int64(index+1), 10)
+				case texcoords != nil:
+					line = append(line, '/')
+					line = strconv.AppendInt(line, int64(index+1), 10)
+				case normals != nil:
+					line = append(line, '/', '/')
+					line = strconv.AppendInt(line, int64(index+1), 10)
+				}
+			}
+			line = append(line, '\n')
+			bw.Write(line)
+		}
+	}
+
+	return bw.Flush()
+}
+
+/* EncodeMTL writes a material library for the TextureFile comments of the mesh to w, with one material per texture, named as EncodeWithMTL refers to them. The texture paths are written as they are in the comments, so they must be relative to the MTL file. */
+func EncodeMTL(w io.Writer, m *plyfile.Mesh) error {
+	bw := bufio.NewWriter(w)
+	for i, texture := range m.TextureFiles() {
+		if i > 0 {
+			bw.WriteString("\n")
+		}
+		fmt.Fprintf(bw, "newmtl %s\nKd 1 1 1\nmap_Kd %s\n", materialName(i), filepath.ToSlash(texture))
+	}
+	return bw.Flush()
+}
+
+/* materialName returns the name of the material of texture i. */
+func materialName(i int) string {
+	return "material" + strconv.Itoa(i)
+}
@@ -0,0 +1,164 @@
+package obj
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+const cube = `# unit cube
+v 0 0 0 1 0 0
+v 1 0 0 0 1 0
+v 1 1 0 0 0 1
+v 0 1 0 1 1 1
+vn 0 0 -1
+vn 0 0 1
+vt 0 0
+vt 1 1
+f 1//1 4//1 3//1 2//1
+f -4/1/2 -3/2/2 -2/1/2
+`
+
+func TestDecode(t *testing.T) {
+	m, err := Decode(strings.NewReader(cube))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m.Comments, []string{"unit cube"}) {
+		t.Errorf("comments = %q", m.Comments)
+	}
+
+	vertex := m.Element("vertex")
+	var names []string
+	for _, prop := range vertex.Properties {
+		names = append(names, prop.Name)
+	}
+	if want := []string{"x", "y", "z", "nx", "ny", "nz", "red", "green", "blue", "s", "t"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("vertex properties = %v, want %v", names, want)
+	}
+
+	/* vertices 1, 2 and 3 are used with a second normal in the triangle and get duplicated */
+	if vertex.Count != 7 {
+		t.Fatalf("vertex count = %d, want 7", vertex.Count)
+	}
+	if got := vertex.Property("x").Data; !reflect.DeepEqual(got, []float64{0, 1, 1, 0, 0, 1, 1}) {
+		t.Errorf("x = %v", got)
+	}
+	if got := vertex.Property("nz").Data; !reflect.DeepEqual(got, []float64{-1, -1, -1, -1, 1, 1, 1}) {
+		t.Errorf("nz = %v", got)
+	}
+	if got := vertex.Property("green").Data; !reflect.DeepEqual(got, []float64{0, 255, 0, 255, 0, 255, 0}) {
+		t.Errorf("green = %v", got)
+	}
+	if got := vertex.Property("s").Data; !reflect.DeepEqual(got, []float64{0, 0, 0, 0, 0, 1, 0}) {
+		t.Errorf("s = %v", got)
+	}
+
+	faces := m.Element("face").Property("vertex_indices").Lists
+	if want := [][]float64{{0, 3, 2, 1}, {4, 5, 6}}; !reflect.DeepEqual(faces, want) {
+		t.Errorf("faces = %v, want %v", faces, want)
+	}
+}
+
+/* TestRoundTrip converts OBJ to PLY and back, and checks that the second conversion gives the same mesh. */
+func TestRoundTrip(t *testing.T) {
+	m, err := Decode(strings.NewReader(cube))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	if err := plyfile.EncodeMesh(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	ply, err := plyfile.DecodeMesh(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	buf.Reset()
+	if err := Encode(&buf, ply); err != nil {
+		t.Fatal(err)
+	}
+	got, err := Decode(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(got, m) {
+		t.Errorf("mesh changed in round trip:\n got %+v\nwant %+v", got, m)
+	}
+}
+
+/* TestEncodeMTL writes a mesh with texcoord lists and two textures, selected by the texnumber of each face. */
+func TestEncodeMTL(t *testing.T) {
+	m := plyfile.NewMesh(plyfile.PLY_ASCII)
+	m.Comments = []string{"TextureFile a.png", "TextureFile b.png"}
+	vertex := m.AddElement("vertex", 3)
+	vertex.AddProperty("x", plyfile.PLY_FLOAT).Data[1] = 1
+	vertex.AddProperty("y", plyfile.PLY_FLOAT).Data[2] = 1
+	vertex.AddProperty("z", plyfile.PLY_FLOAT)
+	face := m.AddElement("face", 2)
+	face.AddListProperty("vertex_indices", plyfile.PLY_UCHAR, plyfile.PLY_INT).Lists = [][]float64{{0, 1, 2}, {0, 2, 1}}
+	face.AddListProperty("texcoord", plyfile.PLY_UCHAR, plyfile.PLY_FLOAT).Lists = [][]float64{{0, 0, 1, 0, 0, 1}, {0, 0, 0, 1, 1, 0}}
+	copy(face.AddProperty("texnumber", plyfile.PLY_INT).Data, []float64{0, 1})
+
+	var buf bytes.Buffer
+	if err := EncodeWithMTL(&buf, m, "quad.mtl"); err != nil {
+		t.Fatal(err)
+	}
+	const want_obj = `# TextureFile a.png
+# TextureFile b.png
+mtllib quad.mtl
+v 0 0 0
+v 1 0 0
+v 0 1 0
+vt 0 0
+vt 1 0
+vt 0 1
+usemtl material0
+f 1/1 2/2 3/3
+usemtl material1
+f 1/1 3/3 2/2
+`
+	if buf.String() != want_obj {
+		t.Errorf("OBJ:\n%s\nwant:\n%s", buf.String(), want_obj)
+	}
+	if m.FaceTexCoords() == nil {
+		t.Errorf("EncodeWithMTL changed the mesh")
+	}
+
+	buf.Reset()
+	if err := EncodeMTL(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	const want_mtl = "newmtl material0\nKd 1 1 1\nmap_Kd a.png\n\nnewmtl material1\nKd 1 1 1\nmap_Kd b.png\n"
+	if buf.String() != want_mtl {
+		t.Errorf("MTL:\n%s\nwant:\n%s", buf.String(), want_mtl)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	files := []string{
+		"v 1 2\n",
+		"v 1 2 3\nf 1 2\n",
+		"v 1 2 3\nv 1 2 3\nv 1 2 3\nf 1 2 4\n",
+		"v 1 2 3\nv 1 2 3\nv 1 2 3\nf 1/1 2 3\n",
+		"v 1 2 3\nv 1 2 3\nv 1 2 3\nf 1 2 x\n",
+	}
+	for _, file := range files {
+		if _, err := Decode(strings.NewReader(file)); err == nil {
+			t.Errorf("expected an error for %q", file)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add([]byte(cube))
+	f.Add([]byte("v 1 2 3 1 0 0\nvt 0.5\nvn 0 0 1\nf -1/-1/-1 -1/-1/-1 -1/-1/-1\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Decode(bytes.NewReader(data))
+	})
+}
@@ -0,0 +1,379 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package off converts between Object File Format (OFF) files and PLY meshes.
+
+The OFF, COFF, NOFF, STOFF and combined (e.g. CNOFF, STCNOFF) variants are supported. Vertex lines hold the position followed by the normal (N), the color (C, 3 or 4 components) and the texture coordinate (ST). They become the PLY vertex properties x, y, z, nx, ny, nz, red, green, blue, alpha, s and t. Face lines may end in a color with 3 or 4 components, which becomes the red, green, blue (and alpha) properties of the face element. Colors are stored as uchar; colors written as floats between 0 and 1 are scaled to 0 to 255. Lines starting with # are kept as comments.
+*/
+package off
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+func init() {
+	plyfile.RegisterFormat("off", sniff, Decode)
+}
+
+/* sniff reports whether the first word of prefix (after comments) is an OFF keyword. */
+func sniff(prefix []byte) bool {
+	for _, line := range strings.Split(string(prefix), "\n") {
+		words := strings.Fields(line)
+		if len(words) == 0 || strings.HasPrefix(words[0], "#") {
+			continue
+		}
+		keyword := strings.TrimSuffix(words[0], "OFF")
+		keyword = strings.TrimPrefix(keyword, "ST")
+		keyword = strings.TrimPrefix(keyword, "C")
+		keyword = strings.TrimPrefix(keyword, "N")
+		return keyword == "" && strings.HasSuffix(words[0], "OFF")
+	}
+	return false
+}
+
+/* line is a non-empty line of an OFF file, split into words */
+type line struct {
+	num   int
+	words []string
+}
+
+/* Decode reads an OFF file from r and returns it as a PLY mesh with a vertex and (if the file has any faces) a face element. */
+func Decode(r io.Reader) (*plyfile.Mesh, error) {
+	var lines []line
+	var comments []string
+
+	br := bufio.NewReader(r)
+	for line_num := 1; ; line_num++ {
+		text, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if err == io.EOF && text == "" {
+			break
+		}
+		text = strings.TrimSpace(text)
+		if strings.HasPrefix(text, "#") {
+			comments = append(comments, strings.TrimSpace(text[1:]))
+			continue
+		}
+		if i := strings.IndexByte(text, '#'); i >= 0 {
+			text = text[:i]
+		}
+		if words := strings.Fields(text); len(words) > 0 {
+			lines = append(lines, line{line_num, words})
+		}
+	}
+	if len(lines) == 0 {
+		return nil, errors.New("off: empty file")
+	}
+
+	/* the keyword may be followed by the counts on the same line */
+	keyword := lines[0].words[0]
+	if !strings.HasSuffix(keyword, "OFF") {
+		return nil, fmt.Errorf("off: line %d: bad keyword '%s'", lines[0].num, keyword)
+	}
+	prefix := strings.TrimSuffix(keyword, "OFF")
+	has_texcoords := strings.HasPrefix(prefix, "ST")
+	prefix = strings.TrimPrefix(prefix, "ST")
+	has_colors := strings.HasPrefix(prefix, "C")
+	prefix = strings.TrimPrefix(prefix, "C")
+	has_normals := strings.HasPrefix(prefix, "N")
+	prefix = strings.TrimPrefix(prefix, "N")
+	if prefix != "" {
+		return nil, fmt.Errorf("off: line %d: unsupported keyword '%s'", lines[0].num, keyword)
+	}
+	counts := lines[0].words[1:]
+	lines = lines[1:]
+	if len(counts) == 0 && len(lines) > 0 {
+		counts = lines[0].words
+		lines = lines[1:]
+	}
+	if len(counts) < 2 {
+		return nil, errors.New("off: missing vertex and face counts")
+	}
+	nverts, err1 := strconv.Atoi(counts[0])
+	nfaces, err2 := strconv.Atoi(counts[1])
+	if err1 != nil || err2 != nil || nverts < 0 || nfaces < 0 {
+		return nil, fmt.Errorf("off: bad counts '%s'", strings.Join(counts, " "))
+	}
+	/* checked one at a time, as nverts+nfaces can overflow */
+	if nverts > len(lines) || nfaces > len(lines)-nverts {
+		return nil, errors.New("off: unexpected end of file")
+	}
+
+	m := plyfile.NewMesh(plyfile.PLY_BINARY_LE)
+	m.Comments = comments
+
+	/* vertex properties in the order of the vertex lines */
+	vertex_elem := m.AddElement("vertex", nverts)
+	names := []string{"x", "y", "z"}
+	if has_normals {
+		names = append(names, "nx", "ny", "nz")
+	}
+	props := make([]*plyfile.Property, len(names))
+	for i, name := range names {
+		props[i] = vertex_elem.AddProperty(name, plyfile.PLY_FLOAT)
+	}
+	var colors, texcoords []*plyfile.Property
+	ntexcoords := 0
+	if has_texcoords {
+		ntexcoords = 2
+	}
+	if has_colors {
+		/* colors have an alpha component if any vertex has one */
+		names := []string{"red", "green", "blue"}
+		for _, l := range lines[:nverts] {
+			if len(l.words)-len(props)-ntexcoords == 4 {
+				names = append(names, "alpha")
+				break
+			}
+		}
+		for _, name := range names {
+			colors = append(colors, vertex_elem.AddProperty(name, plyfile.PLY_UCHAR))
+		}
+		if len(colors) == 4 {
+			for i := range colors[3].Data {
+				colors[3].Data[i] = 255
+			}
+		}
+	}
+	if has_texcoords {
+		texcoords = []*plyfile.Property{
+			vertex_elem.AddProperty("s", plyfile.PLY_FLOAT),
+			vertex_elem.AddProperty("t", plyfile.PLY_FLOAT),
+		}
+	}
+
+	for i, l := range lines[:nverts] {
+		words := l.words
+		ncolors := len(words) - len(props) - len(texcoords)
+		if !has_colors && ncolors != 0 || has_colors && ncolors != 3 && ncolors != 4 {
+			return nil, fmt.Errorf("off: line %d: bad vertex", l.num)
+		}
+		for k, prop := range props {
+			v, err := strconv.ParseFloat(words[k], 64)
+			if err != nil {
+				return nil, fmt.Errorf("off: line %d: bad number '%s'", l.num, words[k])
+			}
+			prop.Data[i] = v
+		}
+		words = words[len(props):]
+		if has_colors {
+			if err := parseColor(words[:ncolors], colors[:ncolors], i); err != nil {
+				return nil, fmt.Errorf("off: line %d: %v", l.num, err)
+			}
+			words = words[ncolors:]
+		}
+		for k, prop := range texcoords {
+			v, err := strconv.ParseFloat(words[k], 64)
+			if err != nil {
+				return nil, fmt.Errorf("off: line %d: bad number '%s'", l.num, words[k])
+			}
+			prop.Data[i] = v
+		}
+	}
+
+	if nfaces == 0 {
+		return m, nil
+	}
+	indices := make([][]float64, nfaces)
+	var face_colors [][]string
+	count_type := plyfile.PLY_UCHAR
+	for i, l := range lines[nverts : nverts+nfaces] {
+		n, err := strconv.Atoi(l.words[0])
+		if err != nil || n < 0 || n > len(l.words)-1 {
+			return nil, fmt.Errorf("off: line %d: bad face", l.num)
+		}
+		if n > math.MaxUint8 {
+			count_type = plyfile.PLY_UINT
+		}
+		indices[i] = make([]float64, n)
+		for j, word := range l.words[1 : n+1] {
+			index, err := strconv.Atoi(word)
+			if err != nil || index < 0 || index >= nverts {
+				return nil, fmt.Errorf("off: line %d: bad vertex index '%s'", l.num, word)
+			}
+			indices[i][j] = float64(index)
+		}
+		/* 0 or 1 (a color map index) trailing values carry no color */
+		switch rest := l.words[n+1:]; len(rest) {
+		case 0, 1:
+		case 3, 4:
+			for len(face_colors) < i {
+				face_colors = append(face_colors, nil)
+			}
+			face_colors = append(face_colors, rest)
+		default:
+			return nil, fmt.Errorf("off: line %d: bad face color", l.num)
+		}
+	}
+
+	face_elem := m.AddElement("face", nfaces)
+	face_elem.AddListProperty("vertex_indices", count_type, plyfile.PLY_INT).Lists = indices
+	if face_colors != nil {
+		names := []string{"red", "green", "blue"}
+		for _, c := range face_colors {
+			if len(c) == 4 {
+				names = append(names, "alpha")
+				break
+			}
+		}
+		colors := make([]*plyfile.Property, len(names))
+		for k, name := range names {
+			colors[k] = face_elem.AddProperty(name, plyfile.PLY_UCHAR)
+		}
+		if len(colors) == 4 {
+			for i := range colors[3].Data {
+				colors[3].Data[i] = 255
+			}
+		}
+		for i, c := range face_colors {
+			if c == nil {
+				continue
+			}
+			if err := parseColor(c, colors[:len(c)], i); err != nil {
+				return nil, fmt.Errorf("off: face %d: %v", i, err)
+			}
+		}
+	}
+	return m, nil
+}
+
+/* parseColor stores the color given by words as the i-th values of props. A color with a decimal point or exponent in any of its components is taken to be between 0 and 1, in all of them. */
+func parseColor(words []string, props []*plyfile.Property, i int) error {
+	scale := 1.0
+	for _, word := range words {
+		if strings.ContainsAny(word, ".eE") {
+			scale = 255
+		}
+	}
+	for k, word := range words {
+		v, err := strconv.ParseFloat(word, 64)
+		if err != nil {
+			return fmt.Errorf("bad color '%s'", word)
+		}
+		props[k].Data[i] = math.Max(0, math.Min(255, math.Round(v*scale)))
+	}
+	return nil
+}
+
+/* Encode writes the vertex and face elements of a PLY mesh to w as an OFF file. Normals (nx, ny, nz), colors (red, green, blue and optionally alpha) and texture coordinates (s, t or u, v) are written when the vertex element has them, and colors of the face element are written at the end of the face lines. */
+func Encode(w io.Writer, m *plyfile.Mesh) error {
+	vertex_elem := m.Element("vertex")
+	if vertex_elem == nil {
+		return errors.New("off: mesh has no vertex element")
+	}
+	position := vertex_elem.Scalars("x", "y", "z")
+	if position == nil {
+		return errors.New("off: vertex element has no x, y and z properties")
+	}
+	normals := vertex_elem.Scalars("nx", "ny", "nz")
+	colors := colorProperties(vertex_elem)
+	texcoords := vertex_elem.Scalars("s", "t")
+	if texcoords == nil {
+		texcoords = vertex_elem.Scalars("u", "v")
+	}
+
+	var faces [][]float64
+	var face_colors []*plyfile.Property
+	if face_elem := m.Element("face"); face_elem != nil {
+		indices := face_elem.FindProperty("vertex_indices", "vertex_index")
+		if indices == nil || !indices.IsList {
+			return errors.New("off: face element has no vertex_indices list")
+		}
+		faces = indices.Lists
+		face_colors = colorProperties(face_elem)
+	}
+
+	keyword := "OFF"
+	if normals != nil {
+		keyword = "N" + keyword
+	}
+	if colors != nil {
+		keyword = "C" + keyword
+	}
+	if texcoords != nil {
+		keyword = "ST" + keyword
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%s\n", keyword)
+	for _, comment := range m.Comments {
+		fmt.Fprintf(bw, "# %s\n", comment)
+	}
+	fmt.Fprintf(bw, "%d %d 0\n", vertex_elem.Count, len(faces))
+
+	var buf []byte
+	for i := 0; i < vertex_elem.Count; i++ {
+		buf = buf[:0]
+		for _, props := range [][]*plyfile.Property{position, normals} {
+			for _, prop := range props {
+				buf = prop.AppendText(buf, i)
+			}
+		}
+		buf = appendColor(buf, colors, i)
+		for _, prop := range texcoords {
+			buf = prop.AppendText(buf, i)
+		}
+		buf[len(buf)-1] = '\n'
+		bw.Write(buf)
+	}
+
+	for i, face := range faces {
+		buf = strconv.AppendInt(buf[:0], int64(len(face)), 10)
+		for _, v := range face {
+			if v < 0 || int(v) >= vertex_elem.Count {
+				return fmt.Errorf("off: face %d: vertex index %v out of range", i, v)
+			}
+			buf = append(buf, ' ')
+			buf = strconv.AppendInt(buf, int64(v), 10)
+		}
+		if face_colors != nil {
+			buf = append(buf, ' ')
+			buf = appendColor(buf, face_colors, i)
+			buf = buf[:len(buf)-1]
+		}
+		buf = append(buf, '\n')
+		bw.Write(buf)
+	}
+	return bw.Flush()
+}
+
+/* colorProperties returns the red, green, blue and (if present) alpha properties of elem, or nil. */
+func colorProperties(elem *plyfile.Element) []*plyfile.Property {
+	if colors := elem.Scalars("red", "green", "blue", "alpha"); colors != nil {
+		return colors
+	}
+	return elem.Scalars("red", "green", "blue")
+}
+
+/* appendColor appends the i-th color as integers between 0 and 255, each followed by a space, see Property.ColorByte. */
+func appendColor(b []byte, props []*plyfile.Property, i int) []byte {
+	for _, prop := range props {
+		b = strconv.AppendInt(b, int64(prop.ColorByte(i)), 10)
+		b = append(b, ' ')
+	}
+	return b
+}
@@ -0,0 +1,125 @@
+package off
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+const coff = `COFF
+# two triangles
+4 2 5
+0 0 0 255 0 0 255
+1 0 0 0 1.0 0 0.5
+1 1 0 0 0 255 255
+0 1 0 10 20 30 255
+
+3 0 1 2 255 255 0
+3 0 2 3
+`
+
+func TestDecode(t *testing.T) {
+	m, err := Decode(strings.NewReader(coff))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"two triangles"}; !reflect.DeepEqual(m.Comments, want) {
+		t.Errorf("comments = %q, want %q", m.Comments, want)
+	}
+	vertex := m.Element("vertex")
+	var names []string
+	for _, prop := range vertex.Properties {
+		names = append(names, prop.Name+":"+plyfile.TypeName(prop.Type))
+	}
+	if want := []string{"x:float", "y:float", "z:float", "red:uchar", "green:uchar", "blue:uchar", "alpha:uchar"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("properties = %v, want %v", names, want)
+	}
+	/* float colors are scaled to 0..255 */
+	if got := vertex.Property("green").Data; !reflect.DeepEqual(got, []float64{0, 255, 0, 20}) {
+		t.Errorf("green = %v", got)
+	}
+	if got := vertex.Property("alpha").Data; !reflect.DeepEqual(got, []float64{255, 128, 255, 255}) {
+		t.Errorf("alpha = %v", got)
+	}
+	/* the scale is decided for the whole color, not for each component */
+	if got := vertex.Property("red").Data; !reflect.DeepEqual(got, []float64{255, 0, 0, 10}) {
+		t.Errorf("red = %v", got)
+	}
+
+	face := m.Element("face")
+	if got := face.Property("vertex_indices").Lists; !reflect.DeepEqual(got, [][]float64{{0, 1, 2}, {0, 2, 3}}) {
+		t.Errorf("faces = %v", got)
+	}
+	if got := face.Property("red").Data; !reflect.DeepEqual(got, []float64{255, 0}) {
+		t.Errorf("face red = %v", got)
+	}
+	if face.Property("alpha") != nil {
+		t.Errorf("unexpected face alpha")
+	}
+
+	m, err = Decode(strings.NewReader("COFF\n1 0 0\n0 0 0 0.5 1 0\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vertex = m.Element("vertex")
+	if got := []float64{vertex.Property("red").Data[0], vertex.Property("green").Data[0], vertex.Property("blue").Data[0]}; !reflect.DeepEqual(got, []float64{128, 255, 0}) {
+		t.Errorf("color 0.5 1 0 = %v, want [128 255 0]", got)
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	files := []string{
+		coff,
+		"OFF 3 1 0\n0 0 0\n1 0 0\n0 1 0\n3 0 1 2\n",
+		"STNOFF\n2 0 0\n0 0 0 0 0 1 0.5 0.25\n1 2 3 0 1 0 1 0\n",
+	}
+	for _, file := range files {
+		m, err := Decode(strings.NewReader(file))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := Encode(&buf, m); err != nil {
+			t.Fatal(err)
+		}
+		m2, err := Decode(&buf)
+		if err != nil {
+			t.Fatalf("%v\n%s", err, buf.String())
+		}
+		if !reflect.DeepEqual(m, m2) {
+			t.Errorf("round trip of\n%s\nchanged the mesh, wrote\n%s", file, buf.String())
+		}
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	files := []string{
+		"",
+		"PLY\n3 0 0\n",
+		"4OFF\n0 0 0\n",
+		"OFF\n3 0 0\n0 0 0\n",
+		"OFF\n1 1 0\n0 0 0\n3 0 1 2\n",
+		"COFF\n1 0 0\n0 0 0\n",
+		"OFF\n1 0 0\n0 0 x\n",
+		"OFF\n-1 0 0\n",
+		"OFF\n9223372036854775807 1 0\n",
+		"OFF\n1 9223372036854775807 0\n0 0 0\n",
+	}
+	for _, file := range files {
+		if _, err := Decode(strings.NewReader(file)); err == nil {
+			t.Errorf("expected an error for %q", file)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add([]byte(coff))
+	f.Add([]byte("STNOFF\n2 0 0\n0 0 0 0 0 1 0.5 0.25\n1 2 3 0 1 0 1 0\n"))
+	f.Add([]byte("OFF\n9223372036854775807 1 0\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Decode(bytes.NewReader(data))
+	})
+}
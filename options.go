@@ -0,0 +1,214 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plyfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+/* ReadOptions controls how a PLY file is read by ReadMeshOptions, DecodeMeshOptions, DecodeHeaderOptions, OpenOptions and DecodeOptions; OpenPlyFileOptions applies some of the limits to the C library. The limits guard against untrusted files whose header declares more data than the file holds, e.g. "element vertex 4000000000", or whose lists never end. A limit of zero means no limit, so the zero value reads any file, as ReadMesh and DecodeMesh do. When a limit is exceeded, reading stops with a *LimitError. */
+type ReadOptions struct {
+	MaxElementCount  int   /* largest count of a single element */
+	MaxTotalBytes    int64 /* largest size of the file, header included */
+	MaxListLength    int   /* largest number of values in a single list */
+	MaxHeaderLines   int   /* largest number of header lines, from "ply" to "end_header" */
+	MaxCommentLength int   /* largest length of the text of a comment or obj_info line */
+
+	Mode    ParseMode     /* how closely the header and ASCII data must follow the PLY layout */
+	Convert ConvertPolicy /* what happens to ASCII values that don't fit their property type; binary values always fit */
+
+	ApplyOrigin bool /* add the origin comment of the file, if any, to the vertex x, y and z, giving absolute double coordinates (see Mesh.ApplyOrigin) */
+}
+
+/* ParseMode selects how closely a file must follow the layout of the PLY format. */
+type ParseMode int
+
+const (
+	ParseDefault ParseMode = iota /* words may be separated by any white space and lines may end with \r\n; blank header lines and unknown header keywords are errors */
+	ParseLenient                  /* as ParseDefault, but blank lines are skipped, and header lines with unknown keywords are kept in Mesh.Unknown */
+	ParseStrict                   /* lines end with a single \n, and words are separated by single spaces; every deviation is a *ParseError giving its line and column */
+)
+
+/* LimitError is returned when a file exceeds one of the limits of ReadOptions. */
+type LimitError struct {
+	Limit string /* name of the exceeded ReadOptions field, e.g. "MaxElementCount" */
+	Max   int64  /* value of the limit */
+	Value int64  /* value found in the file, or 0 if not known */
+	Where string /* part of the file, e.g. "element 'vertex'", or "" */
+}
+
+func (e *LimitError) Error() string {
+	msg := "plyfile: "
+	if e.Where != "" {
+		msg += e.Where + ": "
+	}
+	if e.Value > 0 {
+		return msg + fmt.Sprintf("%d exceeds %s of %d", e.Value, e.Limit, e.Max)
+	}
+	return msg + fmt.Sprintf("%s of %d exceeded", e.Limit, e.Max)
+}
+
+/* ReadMeshOptions reads the PLY file specified by filename into a Mesh, using the given options. */
+func ReadMeshOptions(filename string, opts ReadOptions) (*Mesh, error) {
+	f, err := os.Open(filename)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return DecodeMeshOptions(f, opts)
+}
+
+/* DecodeMeshOptions reads a complete PLY file, header and data, from r using the given options. */
+func DecodeMeshOptions(r io.Reader, opts ReadOptions) (*Mesh, error) {
+	br := opts.newReader(r)
+	m, header_lines, err := decodeHeader(br, &opts)
+	if err != nil {
+		return nil, err
+	}
+	if err := decodeBody(br, m, &opts, header_lines); err != nil {
+		return nil, err
+	}
+	if opts.ApplyOrigin {
+		m.ApplyOrigin()
+	}
+	return m, nil
+}
+
+/* DecodeHeaderOptions reads only the header of a PLY file from r using the given options. Besides the header limits, the declared size of the body is checked against MaxTotalBytes. */
+func DecodeHeaderOptions(r io.Reader, opts ReadOptions) (*Mesh, error) {
+	m, _, err := decodeHeader(opts.newReader(r), &opts)
+	return m, err
+}
+
+/* newReader returns a buffered reader for r, which fails once more than MaxTotalBytes have been read. */
+func (opts *ReadOptions) newReader(r io.Reader) *bufio.Reader {
+	if opts.MaxTotalBytes > 0 {
+		r = &limitReader{r: r, n: opts.MaxTotalBytes, max: opts.MaxTotalBytes}
+	}
+	return bufio.NewReader(r)
+}
+
+/* checkHeader checks the elements of a complete header against the limits, including the smallest body size the header allows. */
+func (opts *ReadOptions) checkHeader(m *Mesh, header_bytes int64) error {
+	if opts.MaxTotalBytes <= 0 {
+		return nil
+	}
+	/* every binary value takes its size, every ASCII value at least one digit and a separator */
+	size := float64(header_bytes)
+	for _, elem := range m.Elements {
+		record := 0
+		for _, prop := range elem.Properties {
+			switch {
+			case m.Format == PLY_ASCII:
+				record += 2
+			case prop.IsList:
+				record += typeSizes[prop.CountType]
+			default:
+				record += typeSizes[prop.Type]
+			}
+		}
+		size += float64(elem.Count) * float64(record)
+	}
+	if size > float64(opts.MaxTotalBytes) {
+		return &LimitError{Limit: "MaxTotalBytes", Max: opts.MaxTotalBytes, Value: int64(size), Where: "declared size"}
+	}
+	return nil
+}
+
+/* checkList checks the count of a list read from the body. */
+func (opts *ReadOptions) checkList(n float64, elem *Element, index int, prop *Property) error {
+	if opts.MaxListLength > 0 && n > float64(opts.MaxListLength) {
+		return &LimitError{Limit: "MaxListLength", Max: int64(opts.MaxListLength), Value: int64(n),
+			Where: fmt.Sprintf("element '%s' %d property '%s'", elem.Name, index, prop.Name)}
+	}
+	return nil
+}
+
+/* checkMesh checks the element counts and list lengths of a decoded mesh, for formats decoded without options. */
+func (opts *ReadOptions) checkMesh(m *Mesh) error {
+	for _, elem := range m.Elements {
+		if opts.MaxElementCount > 0 && elem.Count > opts.MaxElementCount {
+			return &LimitError{Limit: "MaxElementCount", Max: int64(opts.MaxElementCount), Value: int64(elem.Count),
+				Where: fmt.Sprintf("element '%s'", elem.Name)}
+		}
+		for _, prop := range elem.Properties {
+			for i, list := range prop.Lists {
+				if err := opts.checkList(float64(len(list)), elem, i, prop); err != nil {
+					return err
+				}
+			}
+		}
+	}
+	return nil
+}
+
+/* limitReader reads at most max bytes from r. Reading past the limit fails with a *LimitError if r holds more data, and returns the error of r otherwise. */
+type limitReader struct {
+	r   io.Reader
+	n   int64 /* bytes left */
+	max int64
+}
+
+func (l *limitReader) Read(p []byte) (int, error) {
+	if l.n <= 0 {
+		var b [1]byte
+		if n, err := io.ReadFull(l.r, b[:]); n == 0 {
+			return 0, err
+		}
+		return 0, &LimitError{Limit: "MaxTotalBytes", Max: l.max}
+	}
+	if int64(len(p)) > l.n {
+		p = p[:l.n]
+	}
+	n, err := l.r.Read(p)
+	l.n -= int64(n)
+	return n, err
+}
+
+/* checkStrict checks the white space of a line for ParseStrict. Only the first words_end bytes are checked for repeated, leading and trailing spaces, so that the text of comments is kept as written. */
+func checkStrict(line string, line_num int, words_end int) error {
+	deviation := func(column int, reason string) error {
+		return &ParseError{Line: line_num, Column: column, Text: strings.TrimRight(line, "\r"), Reason: reason}
+	}
+	if strings.HasSuffix(line, "\r") {
+		return deviation(len(line), "carriage return at end of line")
+	}
+	if line == "" {
+		return &ParseError{Line: line_num, Reason: "blank line"}
+	}
+	for i := 0; i < len(line); i++ {
+		c := line[i]
+		switch {
+		case c == '\t':
+			return deviation(i+1, "tab instead of space")
+		case c < ' ' || c == 0x7f:
+			return deviation(i+1, "control character")
+		case c != ' ' || i >= words_end:
+		case i == 0:
+			return deviation(i+1, "leading space")
+		case i == len(line)-1:
+			return deviation(i+1, "trailing space")
+		case line[i-1] == ' ':
+			return deviation(i+1, "repeated space")
+		}
+	}
+	return nil
+}
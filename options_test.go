@@ -0,0 +1,242 @@
+package plyfile
+
+import (
+	"bytes"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/* repeatReader returns a header followed by the same body bytes forever. */
+func repeatReader(header string, body []byte) io.Reader {
+	return io.MultiReader(strings.NewReader(header), &repeater{b: body})
+}
+
+type repeater struct {
+	b []byte
+	i int
+}
+
+func (r *repeater) Read(p []byte) (int, error) {
+	for k := range p {
+		p[k] = r.b[r.i%len(r.b)]
+		r.i++
+	}
+	return len(p), nil
+}
+
+/* TestReadLimits checks that every limit is reported as a *LimitError, and that files within the limits decode. */
+func TestReadLimits(t *testing.T) {
+	var cube bytes.Buffer
+	if err := EncodeMesh(&cube, cubeMesh(PLY_BINARY_LE)); err != nil {
+		t.Fatal(err)
+	}
+	endless_list := "ply\nformat binary_little_endian 1.0\nelement face 1\nproperty list uint uchar vertex_indices\nend_header\n\xff\xff\xff\xff"
+
+	tests := []struct {
+		name  string
+		r     io.Reader
+		opts  ReadOptions
+		limit string
+	}{
+		{"element count", strings.NewReader("ply\nformat ascii 1.0\nelement vertex 4000000000\nproperty float x\nend_header\n"),
+			ReadOptions{MaxElementCount: 1000000}, "MaxElementCount"},
+		{"declared size", strings.NewReader("ply\nformat binary_little_endian 1.0\nelement vertex 4000000000\nproperty float x\nend_header\n"),
+			ReadOptions{MaxTotalBytes: 1 << 20}, "MaxTotalBytes"},
+		{"endless list", repeatReader(endless_list, []byte{255}), ReadOptions{MaxListLength: 1000}, "MaxListLength"},
+		{"endless body", repeatReader(endless_list, []byte{255}), ReadOptions{MaxTotalBytes: 1 << 20}, "MaxTotalBytes"},
+		{"endless ascii list", repeatReader("ply\nformat ascii 1.0\nelement face 1\nproperty list uchar int vertex_indices\nend_header\n255", []byte(" 255")),
+			ReadOptions{MaxTotalBytes: 1 << 16}, "MaxTotalBytes"},
+		{"header lines", repeatReader("ply\nformat ascii 1.0\n", []byte("comment spam\n")), ReadOptions{MaxHeaderLines: 100}, "MaxHeaderLines"},
+		{"comment length", strings.NewReader("ply\nformat ascii 1.0\ncomment " + strings.Repeat("x", 1000) + "\nend_header\n"),
+			ReadOptions{MaxCommentLength: 80}, "MaxCommentLength"},
+		{"obj_info length", strings.NewReader("ply\nformat ascii 1.0\nobj_info " + strings.Repeat("x", 1000) + "\nend_header\n"),
+			ReadOptions{MaxCommentLength: 80}, "MaxCommentLength"},
+		{"file size", bytes.NewReader(cube.Bytes()), ReadOptions{MaxTotalBytes: int64(cube.Len() - 1)}, "MaxTotalBytes"},
+	}
+	for _, test := range tests {
+		_, err := DecodeMeshOptions(test.r, test.opts)
+		var limit_err *LimitError
+		if !errors.As(err, &limit_err) {
+			t.Errorf("%s: error %v, want a *LimitError", test.name, err)
+			continue
+		}
+		if limit_err.Limit != test.limit {
+			t.Errorf("%s: limit %s, want %s (%v)", test.name, limit_err.Limit, test.limit, err)
+		}
+	}
+
+	/* the limits are inclusive */
+	opts := ReadOptions{MaxElementCount: 8, MaxTotalBytes: int64(cube.Len()), MaxListLength: 4, MaxHeaderLines: 20, MaxCommentLength: 80}
+	m, err := DecodeMeshOptions(bytes.NewReader(cube.Bytes()), opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err := DecodeHeaderOptions(bytes.NewReader(cube.Bytes()), opts); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(m, cubeMesh(PLY_BINARY_LE)) {
+		t.Errorf("mesh read with limits differs")
+	}
+}
+
+/* TestCReadLimits checks that OpenPlyFileOptions and PlySetLimits report every limit they apply to the C library as a *LimitError, and that files within the limits are read. */
+func TestCReadLimits(t *testing.T) {
+	dir := t.TempDir()
+	cube := filepath.Join(dir, "cube.ply")
+	if err := WriteMesh(cube, cubeMesh(PLY_BINARY_LE)); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(cube)
+	if err != nil {
+		t.Fatal(err)
+	}
+	long_list := filepath.Join(dir, "long_list.ply")
+	if err := os.WriteFile(long_list, []byte("ply\nformat ascii 1.0\nelement face 1\nproperty list uchar int vertex_indices\nend_header\n5 0 1 2 3 4\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	spam := filepath.Join(dir, "spam.ply")
+	header := "ply\nformat ascii 1.0\n" + strings.Repeat("comment spam\n", 200) + "obj_info " + strings.Repeat("x", 100) + "\nend_header\n"
+	if err := os.WriteFile(spam, []byte(header), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		path  string
+		opts  ReadOptions
+		limit string
+	}{
+		{"header lines", spam, ReadOptions{MaxHeaderLines: 100}, "MaxHeaderLines"},
+		{"comment length", spam, ReadOptions{MaxCommentLength: 80}, "MaxCommentLength"},
+		{"element count", cube, ReadOptions{MaxElementCount: 7}, "MaxElementCount"},
+		{"file size", cube, ReadOptions{MaxTotalBytes: info.Size() - 1}, "MaxTotalBytes"},
+		{"list length", cube, ReadOptions{MaxListLength: 3}, "MaxListLength"},
+		{"ascii list length", long_list, ReadOptions{MaxListLength: 4}, "MaxListLength"},
+	}
+	for _, test := range tests {
+		err := readCBody(test.path, test.opts)
+		var limit_err *LimitError
+		if !errors.As(err, &limit_err) {
+			t.Errorf("%s: error %v, want a *LimitError", test.name, err)
+			continue
+		}
+		if limit_err.Limit != test.limit {
+			t.Errorf("%s: limit %s, want %s (%v)", test.name, limit_err.Limit, test.limit, err)
+		}
+		if test.path == spam {
+			/* the header limits are reported as the Go reader reports them */
+			if _, go_err := ReadMeshOptions(spam, test.opts); !reflect.DeepEqual(err, go_err) {
+				t.Errorf("%s: C error %v, Go error %v", test.name, err, go_err)
+			}
+		}
+	}
+	if err := readCBody(spam, ReadOptions{MaxHeaderLines: 204, MaxCommentLength: 100}); err != nil {
+		t.Errorf("header within the limits: %v", err)
+	}
+
+	/* PlySetLimits alone checks the element count when the element is read */
+	plyfile, _ := PlyOpenForReading(cube)
+	if plyfile == nil {
+		t.Fatal(PlyHeaderError())
+	}
+	defer PlyClose(plyfile)
+	PlySetLimits(plyfile, ReadOptions{MaxElementCount: 7})
+	PlyGetProperty(plyfile, "vertex", PlyProperty{"x", 0, PLY_DOUBLE, 0, 0, 0, 0, 0})
+	var x float64
+	PlyGetElement(plyfile, &x, 8)
+	want := &LimitError{Limit: "MaxElementCount", Max: 7, Value: 8, Where: "element 'vertex'"}
+	if err := PlyBodyError(plyfile); !reflect.DeepEqual(err, want) {
+		t.Errorf("PlyBodyError = %v, want %v", err, want)
+	}
+
+	/* the limits are inclusive */
+	opts := ReadOptions{MaxElementCount: 8, MaxTotalBytes: info.Size(), MaxListLength: 4}
+	if err := readCBody(cube, opts); err != nil {
+		t.Error(err)
+	}
+	if err := readCBody(long_list, ReadOptions{MaxListLength: 5}); err != nil {
+		t.Error(err)
+	}
+}
+
+/* TestParseModes reads files with Windows line endings, tabs, stray spaces, blank lines and unknown keywords in each mode. */
+func TestParseModes(t *testing.T) {
+	const (
+		ok = iota
+		fails
+	)
+	tests := []struct {
+		name    string
+		file    string
+		results [3]int /* ParseDefault, ParseLenient, ParseStrict */
+		line    int    /* line and column of the strict error */
+		column  int
+	}{
+		{"clean", "ply\nformat ascii 1.0\ncomment a  b\nelement vertex 1\nproperty float x\nend_header\n1.5\n",
+			[3]int{ok, ok, ok}, 0, 0},
+		{"crlf", "ply\r\nformat ascii 1.0\r\nelement vertex 1\r\nproperty float x\r\nend_header\r\n1.5\r\n",
+			[3]int{ok, ok, fails}, 1, 4},
+		{"crlf body", "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nend_header\n1.5\r\n",
+			[3]int{ok, ok, fails}, 6, 4},
+		{"tab", "ply\nformat ascii 1.0\nelement vertex 1\nproperty\tfloat x\nend_header\n1.5\n",
+			[3]int{ok, ok, fails}, 4, 9},
+		{"tab body", "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nproperty float y\nend_header\n1.5\t2\n",
+			[3]int{ok, ok, fails}, 7, 4},
+		{"trailing space", "ply\nformat ascii 1.0 \nelement vertex 1\nproperty float x\nend_header\n1.5\n",
+			[3]int{ok, ok, fails}, 2, 17},
+		{"trailing space body", "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\nend_header\n1.5 \n",
+			[3]int{ok, ok, fails}, 6, 4},
+		{"leading space", "ply\nformat ascii 1.0\n element vertex 1\nproperty float x\nend_header\n1.5\n",
+			[3]int{ok, ok, fails}, 3, 1},
+		{"repeated space", "ply\nformat ascii 1.0\nelement  vertex 1\nproperty float x\nend_header\n1.5\n",
+			[3]int{ok, ok, fails}, 3, 9},
+		{"blank header line", "ply\nformat ascii 1.0\n\nelement vertex 1\nproperty float x\nend_header\n1.5\n",
+			[3]int{fails, ok, fails}, 3, 0},
+		{"blank body line", "ply\nformat ascii 1.0\nelement vertex 2\nproperty float x\nend_header\n1.5\n\n2.5\n",
+			[3]int{fails, ok, fails}, 7, 0},
+		{"unknown keyword", "ply\nformat ascii 1.0\nelement vertex 1\nproperty float x\ncreator scanner 2\nend_header\n1.5\n",
+			[3]int{fails, ok, fails}, 5, 1},
+	}
+	for _, test := range tests {
+		for mode, result := range test.results {
+			m, err := DecodeMeshOptions(strings.NewReader(test.file), ReadOptions{Mode: ParseMode(mode)})
+			if result == fails {
+				if err == nil {
+					t.Errorf("%s: mode %d: expected an error", test.name, mode)
+				}
+				if mode != int(ParseStrict) {
+					continue
+				}
+				var parse_err *ParseError
+				if !errors.As(err, &parse_err) {
+					t.Errorf("%s: error %v, want a *ParseError", test.name, err)
+				} else if parse_err.Line != test.line || parse_err.Column != test.column {
+					t.Errorf("%s: %v, want line %d, column %d", test.name, err, test.line, test.column)
+				}
+				continue
+			}
+			if err != nil {
+				t.Errorf("%s: mode %d: %v", test.name, mode, err)
+				continue
+			}
+			if x := m.Element("vertex").Property("x").Data[0]; x != 1.5 {
+				t.Errorf("%s: mode %d: x = %v", test.name, mode, x)
+			}
+		}
+	}
+
+	m, err := DecodeMeshOptions(strings.NewReader(tests[len(tests)-1].file), ReadOptions{Mode: ParseLenient})
+	if err != nil || !reflect.DeepEqual(m.Unknown, []string{"creator scanner 2"}) {
+		t.Errorf("unknown header lines = %q (%v)", m.Unknown, err)
+	}
+	m, err = DecodeMeshOptions(strings.NewReader(tests[0].file), ReadOptions{Mode: ParseStrict})
+	if err != nil || !reflect.DeepEqual(m.Comments, []string{"a  b"}) {
+		t.Errorf("comments = %q (%v)", m.Comments, err)
+	}
+}
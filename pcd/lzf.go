@@ -0,0 +1,136 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package pcd
+
+import (
+	"errors"
+)
+
+/* LZF compression, as used by the binary_compressed PCD data format. */
+
+const (
+	lzfMaxLiteral = 1 << 5
+	lzfMaxOffset  = 1 << 13
+	lzfMaxMatch   = (1 << 8) + (1 << 3)
+	lzfHashBits   = 14
+)
+
+var errLZFCorrupt = errors.New("pcd: corrupt binary_compressed data")
+
+/* lzfDecompress decompresses in, which must expand to exactly size bytes. */
+func lzfDecompress(in []byte, size int) ([]byte, error) {
+	out := make([]byte, 0, minInt(size, len(in)*lzfMaxMatch))
+	for ip := 0; ip < len(in); {
+		ctrl := int(in[ip])
+		ip++
+
+		if ctrl < lzfMaxLiteral {
+			n := ctrl + 1
+			if ip+n > len(in) || len(out)+n > size {
+				return nil, errLZFCorrupt
+			}
+			out = append(out, in[ip:ip+n]...)
+			ip += n
+			continue
+		}
+
+		n := ctrl >> 5
+		if n == 7 {
+			if ip >= len(in) {
+				return nil, errLZFCorrupt
+			}
+			n += int(in[ip])
+			ip++
+		}
+		n += 2
+		if ip >= len(in) {
+			return nil, errLZFCorrupt
+		}
+		ref := len(out) - (ctrl&0x1f)<<8 - int(in[ip]) - 1
+		ip++
+		if ref < 0 || len(out)+n > size {
+			return nil, errLZFCorrupt
+		}
+		/* the reference may overlap the bytes being written, so copy one byte at a time */
+		for i := 0; i < n; i++ {
+			out = append(out, out[ref+i])
+		}
+	}
+	if len(out) != size {
+		return nil, errLZFCorrupt
+	}
+	return out, nil
+}
+
+/* lzfCompress compresses in, using a hash table of recent three byte sequences to find back references. */
+func lzfCompress(in []byte) []byte {
+	out := make([]byte, 0, len(in)/2+16)
+	var table [1 << lzfHashBits]int
+	for i := range table {
+		table[i] = -1
+	}
+
+	literal := -1 /* position of the control byte of the current literal run */
+	putLiteral := func(b byte) {
+		if literal < 0 {
+			literal = len(out)
+			out = append(out, 0)
+		} else {
+			out[literal]++
+		}
+		out = append(out, b)
+		if out[literal] == lzfMaxLiteral-1 {
+			literal = -1
+		}
+	}
+
+	for ip := 0; ip < len(in); {
+		if ip+2 < len(in) {
+			h := (uint32(in[ip])<<16 | uint32(in[ip+1])<<8 | uint32(in[ip+2])) * 2654435761 >> (32 - lzfHashBits)
+			ref := table[h]
+			table[h] = ip
+
+			if ref >= 0 && ip-ref-1 < lzfMaxOffset &&
+				in[ref] == in[ip] && in[ref+1] == in[ip+1] && in[ref+2] == in[ip+2] {
+				n := 3
+				for n < lzfMaxMatch && ip+n < len(in) && in[ref+n] == in[ip+n] {
+					n++
+				}
+				off := ip - ref - 1
+				if l := n - 2; l < 7 {
+					out = append(out, byte(l<<5|off>>8))
+				} else {
+					out = append(out, byte(7<<5|off>>8), byte(l-7))
+				}
+				out = append(out, byte(off))
+				literal = -1
+				ip += n
+				continue
+			}
+		}
+		putLiteral(in[ip])
+		ip++
+	}
+	return out
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
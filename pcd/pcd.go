@@ -0,0 +1,710 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package pcd converts between Point Cloud Library PCD files and PLY point clouds.
+
+Every PCD field becomes a property of the PLY vertex element, with the SIZE and TYPE of the field mapped onto a PLY_* type (see PLYType). Fields with a COUNT larger than one become list properties of that length. The packed rgb (float) and rgba (uint) fields are split into red, green, blue (and alpha) uchar properties, and normal_x, normal_y and normal_z are renamed to nx, ny and nz. Encoding reverses these mappings.
+
+The ascii, binary and binary_compressed data formats are supported for reading and writing. The WIDTH and HEIGHT of organized clouds are stored as "num_cols" and "num_rows" obj_info lines, and a VIEWPOINT other than the default as a "VIEWPOINT" comment.
+*/
+package pcd
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+/* PCD data formats */
+const (
+	DataASCII            = "ascii"
+	DataBinary           = "binary"
+	DataBinaryCompressed = "binary_compressed"
+)
+
+const defaultViewpoint = "0 0 0 1 0 0 0"
+
+/* maximum size of a single point, which limits the allocations made for malformed headers */
+const maxPointSize = 1 << 24
+
+/* Header is the header of a PCD file. Fields, Size, Type and Count hold one entry per field. */
+type Header struct {
+	Version   string
+	Fields    []string
+	Size      []int
+	Type      []byte /* 'I' (signed), 'U' (unsigned) or 'F' (floating point) */
+	Count     []int
+	Width     int
+	Height    int
+	Viewpoint string
+	Points    int
+	Data      string
+}
+
+func init() {
+	plyfile.RegisterFormat("pcd", sniff, Decode)
+}
+
+/* sniff reports whether the first header line of prefix (after comments) is a VERSION or FIELDS line. */
+func sniff(prefix []byte) bool {
+	for _, line := range strings.Split(string(prefix), "\n") {
+		words := strings.Fields(line)
+		if len(words) == 0 || strings.HasPrefix(words[0], "#") {
+			continue
+		}
+		return words[0] == "VERSION" || words[0] == "FIELDS"
+	}
+	return false
+}
+
+/* PLYType returns the PLY_* type for a PCD field TYPE and SIZE. 8 byte integers have no PLY equivalent and are mapped to PLY_DOUBLE. */
+func PLYType(typ byte, size int) (int, error) {
+	switch {
+	case typ == 'I' && size == 1:
+		return plyfile.PLY_CHAR, nil
+	case typ == 'I' && size == 2:
+		return plyfile.PLY_SHORT, nil
+	case typ == 'I' && size == 4:
+		return plyfile.PLY_INT, nil
+	case typ == 'U' && size == 1:
+		return plyfile.PLY_UCHAR, nil
+	case typ == 'U' && size == 2:
+		return plyfile.PLY_USHORT, nil
+	case typ == 'U' && size == 4:
+		return plyfile.PLY_UINT, nil
+	case typ == 'F' && size == 4:
+		return plyfile.PLY_FLOAT, nil
+	case (typ == 'F' || typ == 'I' || typ == 'U') && size == 8:
+		return plyfile.PLY_DOUBLE, nil
+	}
+	return 0, fmt.Errorf("pcd: unsupported field type %c with size %d", typ, size)
+}
+
+/* PCDType returns the PCD field TYPE and SIZE for a PLY_* type. */
+func PCDType(typ int) (byte, int, error) {
+	switch typ {
+	case plyfile.PLY_CHAR, plyfile.PLY_SHORT, plyfile.PLY_INT:
+		return 'I', plyfile.TypeSize(typ), nil
+	case plyfile.PLY_UCHAR, plyfile.PLY_USHORT, plyfile.PLY_UINT:
+		return 'U', plyfile.TypeSize(typ), nil
+	case plyfile.PLY_FLOAT, plyfile.PLY_DOUBLE:
+		return 'F', plyfile.TypeSize(typ), nil
+	}
+	return 0, 0, fmt.Errorf("pcd: bad PLY type = %d", typ)
+}
+
+/* property names used for PCD fields in PLY files */
+var propertyNames = map[string]string{
+	"normal_x": "nx",
+	"normal_y": "ny",
+	"normal_z": "nz",
+}
+
+/* ReadHeader reads the header of a PCD file, up to and including the DATA line. */
+func ReadHeader(br *bufio.Reader) (*Header, error) {
+	h := &Header{Height: 1, Viewpoint: defaultViewpoint}
+	for line_num := 1; ; line_num++ {
+		line, err := br.ReadString('\n')
+		if err != nil {
+			if err == io.EOF {
+				return nil, errors.New("pcd: unexpected end of file in header")
+			}
+			return nil, err
+		}
+		words := strings.Fields(line)
+		if len(words) == 0 || strings.HasPrefix(words[0], "#") {
+			continue
+		}
+		values := words[1:]
+
+		switch words[0] {
+		case "VERSION":
+			if len(values) > 0 {
+				h.Version = values[0]
+			}
+		case "FIELDS":
+			h.Fields = values
+		case "SIZE":
+			h.Size, err = parseInts(values)
+		case "TYPE":
+			h.Type = make([]byte, len(values))
+			for i, v := range values {
+				if len(v) != 1 {
+					err = fmt.Errorf("bad type '%s'", v)
+				}
+				h.Type[i] = v[0]
+			}
+		case "COUNT":
+			h.Count, err = parseInts(values)
+		case "WIDTH", "HEIGHT", "POINTS":
+			var n []int
+			if n, err = parseInts(values); err == nil && len(n) != 1 {
+				err = fmt.Errorf("expected a single value")
+			}
+			if err == nil {
+				switch words[0] {
+				case "WIDTH":
+					h.Width = n[0]
+				case "HEIGHT":
+					h.Height = n[0]
+				default:
+					h.Points = n[0]
+				}
+			}
+		case "VIEWPOINT":
+			h.Viewpoint = strings.Join(values, " ")
+		case "DATA":
+			if len(values) != 1 {
+				return nil, fmt.Errorf("pcd: line %d: bad DATA line", line_num)
+			}
+			h.Data = values[0]
+			return h, h.validate()
+		default:
+			return nil, fmt.Errorf("pcd: line %d: unknown header keyword '%s'", line_num, words[0])
+		}
+		if err != nil {
+			return nil, fmt.Errorf("pcd: line %d: %s: %v", line_num, words[0], err)
+		}
+	}
+}
+
+/* validate checks that the header is consistent, filling in the defaults of optional entries. */
+func (h *Header) validate() error {
+	n := len(h.Fields)
+	if h.Count == nil {
+		h.Count = make([]int, n)
+		for i := range h.Count {
+			h.Count[i] = 1
+		}
+	}
+	if len(h.Size) != n || len(h.Type) != n || len(h.Count) != n {
+		return errors.New("pcd: FIELDS, SIZE, TYPE and COUNT have different lengths")
+	}
+	for i := range h.Fields {
+		if _, err := PLYType(h.Type[i], h.Size[i]); err != nil {
+			return err
+		}
+		if h.Count[i] < 1 || h.Count[i] > maxPointSize {
+			return fmt.Errorf("pcd: bad count %d for field '%s'", h.Count[i], h.Fields[i])
+		}
+	}
+	if h.pointSize() > maxPointSize {
+		return fmt.Errorf("pcd: points of %d bytes are too large", h.pointSize())
+	}
+	if h.Points < 0 || h.Width < 0 || h.Height < 0 {
+		return errors.New("pcd: negative point count")
+	}
+	if h.Width != 0 && h.Height > math.MaxInt/h.Width {
+		return fmt.Errorf("pcd: WIDTH %d and HEIGHT %d give too many points", h.Width, h.Height)
+	}
+	if h.Points == 0 && h.Width > 0 {
+		h.Points = h.Width * h.Height
+	}
+	if h.Width > 0 && h.Points != h.Width*h.Height {
+		return fmt.Errorf("pcd: POINTS %d doesn't match WIDTH %d and HEIGHT %d", h.Points, h.Width, h.Height)
+	}
+	switch h.Data {
+	case DataASCII, DataBinary, DataBinaryCompressed:
+	default:
+		return fmt.Errorf("pcd: unknown data format '%s'", h.Data)
+	}
+	return nil
+}
+
+/* pointSize returns the number of bytes used by a single point. */
+func (h *Header) pointSize() int {
+	size := 0
+	for i := range h.Fields {
+		size += h.Size[i] * h.Count[i]
+	}
+	return size
+}
+
+/* Decode reads a PCD file from r and returns it as a PLY mesh with a single vertex element. */
+func Decode(r io.Reader) (*plyfile.Mesh, error) {
+	br := bufio.NewReader(r)
+	h, err := ReadHeader(br)
+	if err != nil {
+		return nil, err
+	}
+
+	/* read every field as a column of values, count values per point */
+	columns := make([][]float64, len(h.Fields))
+	switch h.Data {
+	case DataASCII:
+		err = decodeASCII(br, h, columns)
+	case DataBinary:
+		err = decodeBinary(br, h, columns)
+	case DataBinaryCompressed:
+		err = decodeCompressed(br, h, columns)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	m := plyfile.NewMesh(plyfile.PLY_BINARY_LE)
+	if h.Height > 1 {
+		m.ObjInfo = append(m.ObjInfo, fmt.Sprintf("num_cols %d", h.Width), fmt.Sprintf("num_rows %d", h.Height))
+	}
+	if h.Viewpoint != defaultViewpoint {
+		m.Comments = append(m.Comments, "VIEWPOINT "+h.Viewpoint)
+	}
+
+	vertex := m.AddElement("vertex", h.Points)
+	for i, field := range h.Fields {
+		typ, _ := PLYType(h.Type[i], h.Size[i])
+		switch {
+		case field == "_":
+			/* padding */
+		case (field == "rgb" || field == "rgba") && h.Count[i] == 1 && h.Size[i] == 4:
+			addColors(vertex, columns[i], h.Type[i], field == "rgba")
+		case h.Count[i] == 1:
+			vertex.AddProperty(propertyName(field), typ).Data = columns[i]
+		default:
+			prop := vertex.AddListProperty(propertyName(field), countType(h.Count[i]), typ)
+			for p := range prop.Lists {
+				prop.Lists[p] = columns[i][p*h.Count[i] : (p+1)*h.Count[i] : (p+1)*h.Count[i]]
+			}
+		}
+	}
+	return m, nil
+}
+
+func propertyName(field string) string {
+	if name, ok := propertyNames[field]; ok {
+		return name
+	}
+	return field
+}
+
+func fieldName(property string) string {
+	for field, name := range propertyNames {
+		if name == property {
+			return field
+		}
+	}
+	return property
+}
+
+/* countType returns the smallest list count type that can store n. */
+func countType(n int) int {
+	switch {
+	case n <= math.MaxUint8:
+		return plyfile.PLY_UCHAR
+	case n <= math.MaxUint16:
+		return plyfile.PLY_USHORT
+	}
+	return plyfile.PLY_UINT
+}
+
+/* addColors unpacks a packed rgb or rgba column into red, green, blue (and alpha) properties. */
+func addColors(vertex *plyfile.Element, column []float64, typ byte, alpha bool) {
+	red := vertex.AddProperty("red", plyfile.PLY_UCHAR)
+	green := vertex.AddProperty("green", plyfile.PLY_UCHAR)
+	blue := vertex.AddProperty("blue", plyfile.PLY_UCHAR)
+	var a *plyfile.Property
+	if alpha {
+		a = vertex.AddProperty("alpha", plyfile.PLY_UCHAR)
+	}
+	for i, v := range column {
+		packed := uint32(v)
+		if typ == 'F' {
+			packed = math.Float32bits(float32(v))
+		}
+		red.Data[i] = float64(packed >> 16 & 0xff)
+		green.Data[i] = float64(packed >> 8 & 0xff)
+		blue.Data[i] = float64(packed & 0xff)
+		if a != nil {
+			a.Data[i] = float64(packed >> 24)
+		}
+	}
+}
+
+func decodeASCII(br *bufio.Reader, h *Header, columns [][]float64) error {
+	for p := 0; p < h.Points; p++ {
+		line, err := br.ReadString('\n')
+		if err != nil && (err != io.EOF || line == "") {
+			if err == io.EOF {
+				return fmt.Errorf("pcd: unexpected end of file at point %d", p)
+			}
+			return err
+		}
+		words := strings.Fields(line)
+		if len(words) != len(columns)+sumExtra(h) {
+			return fmt.Errorf("pcd: point %d: expected %d values, found %d", p, len(columns)+sumExtra(h), len(words))
+		}
+		for i := range columns {
+			for k := 0; k < h.Count[i]; k++ {
+				word := words[0]
+				words = words[1:]
+				v, err := parseValue(word, h.Type[i], h.Size[i])
+				if errors.Is(err, strconv.ErrRange) {
+					return fmt.Errorf("pcd: point %d: value '%s' out of range for field '%s'", p, word, h.Fields[i])
+				}
+				if err != nil {
+					return fmt.Errorf("pcd: point %d: bad value '%s' for field '%s'", p, word, h.Fields[i])
+				}
+				columns[i] = append(columns[i], v)
+			}
+		}
+	}
+	return nil
+}
+
+/* parseValue reads an ASCII value of the given PCD type and size. Values that don't fit the size fail with strconv.ErrRange. */
+func parseValue(word string, typ byte, size int) (float64, error) {
+	switch typ {
+	case 'F':
+		return strconv.ParseFloat(word, size*8)
+	case 'U':
+		n, err := strconv.ParseUint(word, 10, size*8)
+		return float64(n), err
+	default:
+		n, err := strconv.ParseInt(word, 10, size*8)
+		return float64(n), err
+	}
+}
+
+/* sumExtra returns the number of values per point beyond one per field. */
+func sumExtra(h *Header) int {
+	n := 0
+	for _, count := range h.Count {
+		n += count - 1
+	}
+	return n
+}
+
+func decodeBinary(br *bufio.Reader, h *Header, columns [][]float64) error {
+	size := h.pointSize()
+	buf := make([]byte, size)
+	for p := 0; p < h.Points; p++ {
+		if _, err := io.ReadFull(br, buf); err != nil {
+			return fmt.Errorf("pcd: unexpected end of file at point %d", p)
+		}
+		offset := 0
+		for i := range columns {
+			for k := 0; k < h.Count[i]; k++ {
+				columns[i] = append(columns[i], getValue(buf[offset:], h.Type[i], h.Size[i]))
+				offset += h.Size[i]
+			}
+		}
+	}
+	return nil
+}
+
+func decodeCompressed(br *bufio.Reader, h *Header, columns [][]float64) error {
+	var sizes [8]byte
+	if _, err := io.ReadFull(br, sizes[:]); err != nil {
+		return errors.New("pcd: unexpected end of file in binary_compressed data")
+	}
+	compressed := binary.LittleEndian.Uint32(sizes[:])
+	uncompressed := binary.LittleEndian.Uint32(sizes[4:])
+	if uint64(uncompressed) != uint64(h.Points)*uint64(h.pointSize()) {
+		return fmt.Errorf("pcd: binary_compressed data has %d bytes, expected %d", uncompressed, h.Points*h.pointSize())
+	}
+	in, err := io.ReadAll(io.LimitReader(br, int64(compressed)))
+	if err != nil {
+		return err
+	}
+	if len(in) != int(compressed) {
+		return errors.New("pcd: unexpected end of file in binary_compressed data")
+	}
+	data, err := lzfDecompress(in, int(uncompressed))
+	if err != nil {
+		return err
+	}
+
+	/* the uncompressed data stores all values of a field together */
+	for i := range columns {
+		n := h.Points * h.Count[i]
+		columns[i] = make([]float64, n)
+		for k := range columns[i] {
+			columns[i][k] = getValue(data[k*h.Size[i]:], h.Type[i], h.Size[i])
+		}
+		data = data[n*h.Size[i]:]
+	}
+	return nil
+}
+
+/* getValue decodes a little endian value of a PCD type and size. */
+func getValue(b []byte, typ byte, size int) float64 {
+	switch typ {
+	case 'I':
+		switch size {
+		case 1:
+			return float64(int8(b[0]))
+		case 2:
+			return float64(int16(binary.LittleEndian.Uint16(b)))
+		case 4:
+			return float64(int32(binary.LittleEndian.Uint32(b)))
+		}
+		return float64(int64(binary.LittleEndian.Uint64(b)))
+	case 'U':
+		switch size {
+		case 1:
+			return float64(b[0])
+		case 2:
+			return float64(binary.LittleEndian.Uint16(b))
+		case 4:
+			return float64(binary.LittleEndian.Uint32(b))
+		}
+		return float64(binary.LittleEndian.Uint64(b))
+	}
+	if size == 4 {
+		return float64(math.Float32frombits(binary.LittleEndian.Uint32(b)))
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(b))
+}
+
+/* putValue encodes a little endian value of a PCD type and size into b. */
+func putValue(b []byte, v float64, typ byte, size int) {
+	if typ == 'F' {
+		if size == 4 {
+			binary.LittleEndian.PutUint32(b, math.Float32bits(float32(v)))
+		} else {
+			binary.LittleEndian.PutUint64(b, math.Float64bits(v))
+		}
+		return
+	}
+	n := uint64(int64(v))
+	if typ == 'U' {
+		n = uint64(v)
+	}
+	switch size {
+	case 1:
+		b[0] = byte(n)
+	case 2:
+		binary.LittleEndian.PutUint16(b, uint16(n))
+	case 4:
+		binary.LittleEndian.PutUint32(b, uint32(n))
+	default:
+		binary.LittleEndian.PutUint64(b, n)
+	}
+}
+
+/* field is a PCD field built from one or more PLY properties. */
+type field struct {
+	name  string
+	typ   byte
+	size  int
+	count int
+	value func(point int, k int) float64
+}
+
+/* fields maps the properties of the vertex element onto PCD fields. */
+func fields(vertex *plyfile.Element) ([]field, error) {
+	var fs []field
+	red, green, blue := vertex.Property("red"), vertex.Property("green"), vertex.Property("blue")
+	alpha := vertex.Property("alpha")
+	has_colors := red != nil && green != nil && blue != nil && !red.IsList && !green.IsList && !blue.IsList
+	if alpha != nil && alpha.IsList {
+		alpha = nil
+	}
+
+	for _, prop := range vertex.Properties {
+		prop := prop
+		if has_colors && (prop == green || prop == blue || (prop == alpha && alpha != nil)) {
+			continue
+		}
+		if has_colors && prop == red {
+			scale := func(p *plyfile.Property, i int) uint32 {
+				return uint32(p.ColorByte(i))
+			}
+			pack := func(i int) uint32 {
+				packed := scale(red, i)<<16 | scale(green, i)<<8 | scale(blue, i)
+				if alpha != nil {
+					packed |= scale(alpha, i) << 24
+				}
+				return packed
+			}
+			if alpha != nil {
+				fs = append(fs, field{"rgba", 'U', 4, 1, func(i int, k int) float64 { return float64(pack(i)) }})
+			} else {
+				fs = append(fs, field{"rgb", 'F', 4, 1, func(i int, k int) float64 { return float64(math.Float32frombits(pack(i))) }})
+			}
+			continue
+		}
+
+		typ, size, err := PCDType(prop.Type)
+		if err != nil {
+			return nil, err
+		}
+		if !prop.IsList {
+			fs = append(fs, field{fieldName(prop.Name), typ, size, 1, func(i int, k int) float64 { return prop.Data[i] }})
+			continue
+		}
+		count := 1
+		if len(prop.Lists) > 0 {
+			count = len(prop.Lists[0])
+		}
+		for i, list := range prop.Lists {
+			if len(list) != count {
+				return nil, fmt.Errorf("pcd: list property '%s' has lists of different lengths (vertex %d)", prop.Name, i)
+			}
+		}
+		if count == 0 {
+			return nil, fmt.Errorf("pcd: list property '%s' has empty lists", prop.Name)
+		}
+		fs = append(fs, field{fieldName(prop.Name), typ, size, count, func(i int, k int) float64 { return prop.Lists[i][k] }})
+	}
+	return fs, nil
+}
+
+/* Encode writes the vertex element of a PLY mesh to w as a PCD file, using the specified data format (DataASCII, DataBinary or DataBinaryCompressed). Other elements, such as faces, are not written. */
+func Encode(w io.Writer, m *plyfile.Mesh, data string) error {
+	vertex := m.Element("vertex")
+	if vertex == nil {
+		return errors.New("pcd: mesh has no vertex element")
+	}
+	fs, err := fields(vertex)
+	if err != nil {
+		return err
+	}
+	if len(fs) == 0 {
+		return errors.New("pcd: vertex element has no properties")
+	}
+
+	h := &Header{Version: "0.7", Width: vertex.Count, Height: 1, Viewpoint: defaultViewpoint, Points: vertex.Count, Data: data}
+	cols, rows := -1, -1
+	for _, info := range m.ObjInfo {
+		words := strings.Fields(info)
+		if len(words) == 2 && words[0] == "num_cols" {
+			cols, _ = strconv.Atoi(words[1])
+		} else if len(words) == 2 && words[0] == "num_rows" {
+			rows, _ = strconv.Atoi(words[1])
+		}
+	}
+	if cols > 0 && rows > 0 && cols*rows == vertex.Count {
+		h.Width, h.Height = cols, rows
+	}
+	for _, comment := range m.Comments {
+		if strings.HasPrefix(comment, "VIEWPOINT ") {
+			h.Viewpoint = strings.TrimSpace(comment[len("VIEWPOINT "):])
+		}
+	}
+	for _, f := range fs {
+		h.Fields = append(h.Fields, f.name)
+		h.Size = append(h.Size, f.size)
+		h.Type = append(h.Type, f.typ)
+		h.Count = append(h.Count, f.count)
+	}
+	if err := h.validate(); err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	writeHeader(bw, h)
+
+	switch data {
+	case DataASCII:
+		var line []byte
+		for i := 0; i < vertex.Count; i++ {
+			line = line[:0]
+			for _, f := range fs {
+				for k := 0; k < f.count; k++ {
+					v := f.value(i, k)
+					switch {
+					case f.typ != 'F':
+						line = strconv.AppendInt(line, int64(v), 10)
+					case f.size == 4:
+						line = strconv.AppendFloat(line, v, 'g', -1, 32)
+					default:
+						line = strconv.AppendFloat(line, v, 'g', -1, 64)
+					}
+					line = append(line, ' ')
+				}
+			}
+			line[len(line)-1] = '\n'
+			bw.Write(line)
+		}
+	case DataBinary:
+		buf := make([]byte, h.pointSize())
+		for i := 0; i < vertex.Count; i++ {
+			offset := 0
+			for _, f := range fs {
+				for k := 0; k < f.count; k++ {
+					putValue(buf[offset:], f.value(i, k), f.typ, f.size)
+					offset += f.size
+				}
+			}
+			bw.Write(buf)
+		}
+	case DataBinaryCompressed:
+		buf := make([]byte, vertex.Count*h.pointSize())
+		offset := 0
+		for _, f := range fs {
+			for i := 0; i < vertex.Count; i++ {
+				for k := 0; k < f.count; k++ {
+					putValue(buf[offset:], f.value(i, k), f.typ, f.size)
+					offset += f.size
+				}
+			}
+		}
+		compressed := lzfCompress(buf)
+		var sizes [8]byte
+		binary.LittleEndian.PutUint32(sizes[:], uint32(len(compressed)))
+		binary.LittleEndian.PutUint32(sizes[4:], uint32(len(buf)))
+		bw.Write(sizes[:])
+		bw.Write(compressed)
+	}
+	return bw.Flush()
+}
+
+func writeHeader(bw *bufio.Writer, h *Header) {
+	fmt.Fprintf(bw, "# .PCD v%s - Point Cloud Data file format\n", h.Version)
+	fmt.Fprintf(bw, "VERSION %s\n", h.Version)
+	fmt.Fprintf(bw, "FIELDS %s\n", strings.Join(h.Fields, " "))
+	fmt.Fprintf(bw, "SIZE %s\n", joinInts(h.Size))
+	types := make([]string, len(h.Type))
+	for i, t := range h.Type {
+		types[i] = string(t)
+	}
+	fmt.Fprintf(bw, "TYPE %s\n", strings.Join(types, " "))
+	fmt.Fprintf(bw, "COUNT %s\n", joinInts(h.Count))
+	fmt.Fprintf(bw, "WIDTH %d\n", h.Width)
+	fmt.Fprintf(bw, "HEIGHT %d\n", h.Height)
+	fmt.Fprintf(bw, "VIEWPOINT %s\n", h.Viewpoint)
+	fmt.Fprintf(bw, "POINTS %d\n", h.Points)
+	fmt.Fprintf(bw, "DATA %s\n", h.Data)
+}
+
+func parseInts(words []string) ([]int, error) {
+	values := make([]int, len(words))
+	for i, word := range words {
+		v, err := strconv.Atoi(word)
+		if err != nil {
+			return nil, fmt.Errorf("bad number '%s'", word)
+		}
+		values[i] = v
+	}
+	return values, nil
+}
+
+func joinInts(values []int) string {
+	words := make([]string, len(values))
+	for i, v := range values {
+		words[i] = strconv.Itoa(v)
+	}
+	return strings.Join(words, " ")
+}
@@ -0,0 +1,165 @@
+package pcd
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+const cloud = `# .PCD v0.7 - Point Cloud Data file format
+VERSION 0.7
+FIELDS x y z rgb normal_x normal_y normal_z intensity label hist
+SIZE 4 4 4 4 4 4 4 2 4 4
+TYPE F F F F F F F U I F
+COUNT 1 1 1 1 1 1 1 1 1 3
+WIDTH 2
+HEIGHT 2
+VIEWPOINT 1 2 3 1 0 0 0
+POINTS 4
+DATA ascii
+0 0 0 2.3418052e-38 0 0 1 100 -1 1 2 3
+1 0 0 9.147676e-41 0 0 1 200 0 4 5 6
+0 1 0 3.573311e-43 0 0 1 300 1 7 8 9
+1 1 nan 1.4809146e-39 0 0 1 65535 2 0 0 0
+`
+
+func TestDecode(t *testing.T) {
+	m, err := Decode(strings.NewReader(cloud))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []string{"num_cols 2", "num_rows 2"}; !reflect.DeepEqual(m.ObjInfo, want) {
+		t.Errorf("obj_info = %q, want %q", m.ObjInfo, want)
+	}
+	if want := []string{"VIEWPOINT 1 2 3 1 0 0 0"}; !reflect.DeepEqual(m.Comments, want) {
+		t.Errorf("comments = %q, want %q", m.Comments, want)
+	}
+
+	vertex := m.Element("vertex")
+	var names, types []string
+	for _, prop := range vertex.Properties {
+		names = append(names, prop.Name)
+		types = append(types, plyfile.TypeName(prop.Type))
+	}
+	if want := []string{"x", "y", "z", "red", "green", "blue", "nx", "ny", "nz", "intensity", "label", "hist"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("properties = %v, want %v", names, want)
+	}
+	if want := []string{"float", "float", "float", "uchar", "uchar", "uchar", "float", "float", "float", "ushort", "int", "float"}; !reflect.DeepEqual(types, want) {
+		t.Errorf("types = %v, want %v", types, want)
+	}
+
+	/* the rgb values are the floats with bits 0xff0000, 0x00ff00, 0x0000ff and 0x102030 */
+	if got := vertex.Property("red").Data; !reflect.DeepEqual(got, []float64{255, 0, 0, 0x10}) {
+		t.Errorf("red = %v", got)
+	}
+	if got := vertex.Property("green").Data; !reflect.DeepEqual(got, []float64{0, 255, 0, 0x20}) {
+		t.Errorf("green = %v", got)
+	}
+	if got := vertex.Property("blue").Data; !reflect.DeepEqual(got, []float64{0, 0, 255, 0x30}) {
+		t.Errorf("blue = %v", got)
+	}
+	if !math.IsNaN(vertex.Property("z").Data[3]) {
+		t.Errorf("z[3] = %v, want NaN", vertex.Property("z").Data[3])
+	}
+	if got := vertex.Property("hist").Lists[1]; !reflect.DeepEqual(got, []float64{4, 5, 6}) {
+		t.Errorf("hist[1] = %v", got)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	header := "FIELDS x\nSIZE %s\nTYPE %s\nWIDTH %s\nHEIGHT %s\n%sDATA ascii\n%s\n"
+	tests := []struct{ size, typ, width, height, points, data, want string }{
+		{"4", "F", "9223372036854775807", "2", "", "", "too many points"},
+		{"4", "F", "3", "3074457345618258603", "", "", "too many points"},
+		{"4", "F", "2", "2", "POINTS 3\n", "", "doesn't match"},
+		{"4", "F", "1", "1", "", "1e39", "out of range"},
+		{"1", "U", "1", "1", "", "256", "out of range"},
+		{"2", "I", "1", "1", "", "-32769", "out of range"},
+		{"8", "I", "1", "1", "", "9223372036854775808", "out of range"},
+		{"8", "U", "1", "1", "", "-1", "bad value"},
+	}
+	for _, test := range tests {
+		file := fmt.Sprintf(header, test.size, test.typ, test.width, test.height, test.points, test.data)
+		if _, err := Decode(strings.NewReader(file)); err == nil || !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Decode(%q) = %v, want an error containing %q", file, err, test.want)
+		}
+	}
+
+	/* 8-byte unsigned values are read as unsigned */
+	file := fmt.Sprintf(header, "8", "U", "1", "1", "", "18446744073709551615")
+	m, err := Decode(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := m.Element("vertex").Property("x").Data[0]; got != math.MaxUint64 {
+		t.Errorf("U 8 value = %v, want %v", got, uint64(math.MaxUint64))
+	}
+}
+
+/* TestRoundTrip writes a cloud in every data format and checks that it reads back unchanged. */
+func TestRoundTrip(t *testing.T) {
+	m, err := Decode(strings.NewReader(cloud))
+	if err != nil {
+		t.Fatal(err)
+	}
+	m.Element("vertex").Property("z").Data[3] = 5 /* NaN != NaN */
+
+	for _, data := range []string{DataASCII, DataBinary, DataBinaryCompressed} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, m, data); err != nil {
+			t.Fatalf("%s: %v", data, err)
+		}
+		got, err := Decode(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", data, err)
+		}
+		if !reflect.DeepEqual(got, m) {
+			t.Errorf("%s: cloud changed in round trip:\n got %+v\nwant %+v", data, got, m)
+		}
+	}
+}
+
+func TestLZF(t *testing.T) {
+	inputs := [][]byte{
+		nil,
+		[]byte("a"),
+		[]byte(strings.Repeat("abcabcabd", 1000)),
+		bytes.Repeat([]byte{0}, 100000),
+	}
+	for _, in := range inputs {
+		compressed := lzfCompress(in)
+		out, err := lzfDecompress(compressed, len(in))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !bytes.Equal(out, in) {
+			t.Errorf("round trip of %d bytes failed", len(in))
+		}
+	}
+	if _, err := lzfDecompress([]byte{0xe0, 0xff, 0x00}, 10); err == nil {
+		t.Error("expected an error for a reference before the start of the data")
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add([]byte(cloud))
+	m, err := Decode(strings.NewReader(cloud))
+	if err != nil {
+		f.Fatal(err)
+	}
+	for _, data := range []string{DataBinary, DataBinaryCompressed} {
+		var buf bytes.Buffer
+		if err := Encode(&buf, m, data); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes())
+	}
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Decode(bytes.NewReader(data))
+	})
+}
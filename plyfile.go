@@ -17,6 +17,7 @@ limitations under the License.
 package plyfile
 
 /*
+#include <stdlib.h>
 #include <string.h>
 #include "lib/ply.h"
 */
@@ -26,7 +27,9 @@ import (
 	"bytes"
 	"encoding/binary"
 	"fmt"
+	"math"
 	"os"
+	"sync"
 	"unsafe"
 )
 
@@ -68,7 +71,7 @@ type PlyProperty struct {
 	Count_offset   int /* offset byte for list count */
 }
 
-/* ToC converts a PlyProperty go structure to a PlyProperty C structure for passing to C functions */
+/* ToC converts a PlyProperty go structure to a PlyProperty C structure for passing to C functions. The name is allocated in C memory, and must be freed with C.free; the C functions copy it. */
 func (prop *PlyProperty) ToC() C.struct_PlyProperty {
 	var cprop C.struct_PlyProperty
 	cprop.name = C.CString(prop.Name)
@@ -98,36 +101,81 @@ func (prop *PlyProperty) FromC(cprop CPlyProperty) {
 type CPlyFile *C.struct_PlyFile
 type CPlyElement *C.struct_PlyElement
 
-/* PlyOpenForWriting creates a new PLY file (called filename) and writes in header information, specified by the other parameters. The returned PlyFile object is used to access header information and data stored in the PLY file.  */
+/* PlyOpenForWriting creates a new PLY file (called filename) and writes in header information, specified by the other parameters. The returned PlyFile object is used to access header information and data stored in the PLY file. If nelems is not between 1 and len(elem_names), or the file can't be created, the returned PlyFile is nil.  */
 func PlyOpenForWriting(filename string, nelems int, elem_names []string, file_type int, version *float32) CPlyFile {
-
-	c_elem_names := make([]*C.char, nelems)
-	for i := 0; i < nelems; i++ {
-		c_elem_names[i] = C.CString(elem_names[i])
+	if nelems <= 0 || nelems > len(elem_names) {
+		return nil
 	}
 
-	plyfile := C.ply_open_for_writing(C.CString(filename), C.int(nelems), &c_elem_names[0], C.int(file_type), (*C.float)(version))
+	c_elem_names := cStrings(elem_names[:nelems])
+	defer freeCStrings(c_elem_names)
+	cfilename := C.CString(filename)
+	defer C.free(unsafe.Pointer(cfilename))
+
+	plyfile := C.ply_open_for_writing(cfilename, C.int(nelems), &c_elem_names[0], C.int(file_type), (*C.float)(version))
 
 	return plyfile
 }
 
-/* PlyUseExistingForWriting uses an existing file pointer to create a new PLY file and writes in header information, specified by the other parameters. The returned PlyFile object is used to access header information and data stored in the PLY file.  */
+/* PlyUseExistingForWriting uses an existing file pointer to create a new PLY file and writes in header information, specified by the other parameters. The returned PlyFile object is used to access header information and data stored in the PLY file. If nelems is not between 1 and len(elem_names), or the file can't be created, the returned PlyFile is nil.  */
 func PlyUseExistingForWriting(fp *os.File, nelems int, elem_names []string, file_type int, version *float32) CPlyFile {
-
-	c_elem_names := make([]*C.char, nelems)
-	for i := 0; i < nelems; i++ {
-		c_elem_names[i] = C.CString(elem_names[i])
+	if nelems <= 0 || nelems > len(elem_names) {
+		return nil
 	}
 
+	c_elem_names := cStrings(elem_names[:nelems])
+	defer freeCStrings(c_elem_names)
+
 	plyfile := C.ply_use_fp_for_writing(C.int(fp.Fd()), C.int(nelems), &c_elem_names[0], C.int(file_type), (*C.float)(version))
 
 	return plyfile
 }
 
-/* PlyOpenForReading opens a PLY file (specified by filename) and reads in the header information. The returned PlyFile object is used to access header information and data stored in the PLY file. */
+/* PlyOpenForReading opens a PLY file (specified by filename) and reads in the header information. The returned PlyFile object is used to access header information and data stored in the PLY file. If the file can't be opened or its header is malformed, the returned PlyFile is nil; OpenPlyFile returns why. */
 func PlyOpenForReading(filename string) (CPlyFile, []string) {
+	plyfile, elem_names, err := plyOpenForReading(filename, ReadOptions{})
+	last_header_error.Lock()
+	last_header_error.err = nil
+	if _, ok := err.(*ParseError); ok {
+		last_header_error.err = err
+	}
+	last_header_error.Unlock()
+	return plyfile, elem_names
+}
+
+/* last_header_error is the header error of the last file opened by PlyOpenForReading, for PlyHeaderError. */
+var last_header_error struct {
+	sync.Mutex
+	err error
+}
 
-	plyfile := C.ply_open_and_read_header(C.CString(filename))
+/* plyOpenForReading opens a PLY file and reads its header, as PlyOpenForReading does, but returns why the file couldn't be opened, a *LimitError if the header exceeds MaxHeaderLines or MaxCommentLength, or a *ParseError if it was rejected. MaxElementCount and MaxListLength apply to PlyGetElement, as with PlySetLimits. The error belongs to this call, so files may be opened concurrently. */
+func plyOpenForReading(filename string, opts ReadOptions) (CPlyFile, []string, error) {
+	cfilename := C.CString(filename)
+	defer C.free(unsafe.Pointer(cfilename))
+
+	limits := C.PlyLimits{
+		max_header_lines:   cLimit(opts.MaxHeaderLines),
+		max_comment_length: cLimit(opts.MaxCommentLength),
+		max_element_count:  cLimit(opts.MaxElementCount),
+		max_list_length:    cLimit(opts.MaxListLength),
+	}
+	var header_error C.PlyHeaderError
+	plyfile := C.ply_open_and_read_header_limits(cfilename, &header_error, &limits)
+	if plyfile == nil {
+		switch header_error.limit {
+		case C.PLY_LIMIT_HEADER_LINES:
+			return nil, nil, &LimitError{Limit: "MaxHeaderLines", Max: int64(opts.MaxHeaderLines)}
+		case C.PLY_LIMIT_COMMENT_LENGTH:
+			return nil, nil, &LimitError{Limit: "MaxCommentLength", Max: int64(opts.MaxCommentLength), Value: int64(header_error.value),
+				Where: fmt.Sprintf("header line %d", int(header_error.line))}
+		}
+		reason := C.GoString(&header_error.reason[0])
+		if header_error.line == 0 {
+			return nil, nil, fmt.Errorf("plyfile: can't open '%s': %s", filename, reason)
+		}
+		return nil, nil, &ParseError{Line: int(header_error.line), Text: C.GoString(&header_error.text[0]), Reason: reason}
+	}
 
 	nelems := int(plyfile.nelems)
 
@@ -139,35 +187,152 @@ func PlyOpenForReading(filename string) (CPlyFile, []string) {
 		elem_names[i] = C.GoString(elements[i].name)
 	}
 
-	return plyfile, elem_names
+	return plyfile, elem_names, nil
+}
+
+/* plyHeader returns the format, comments, elements and properties of a file opened for reading, without data. */
+func plyHeader(plyfile CPlyFile) *Mesh {
+	m := &Mesh{Format: int(plyfile.file_type), Version: float32(plyfile.version), Comments: PlyGetComments(plyfile), ObjInfo: PlyGetObjInfo(plyfile)}
+	for i := 0; i < int(plyfile.nelems); i++ {
+		name := C.GoString(C.ply_get_element_by_index(plyfile, C.int(i)).name)
+		plist, num_elems, _ := PlyGetElementDescription(plyfile, name)
+		elem := &Element{Name: name, Count: num_elems}
+		for _, p := range plist {
+			prop := &Property{Name: p.Name, Type: p.External_type}
+			if p.Is_list == PLY_LIST {
+				prop.IsList, prop.CountType = true, p.Count_external
+			}
+			elem.Properties = append(elem.Properties, prop)
+		}
+		m.Elements = append(m.Elements, elem)
+	}
+	return m
+}
+
+/*
+PlyHeaderError returns a *ParseError describing why the header of the last file read by PlyOpenForReading was rejected, or nil if it was read without errors (or the file couldn't be opened).
+
+Deprecated: the last file may have been opened by another goroutine. Use OpenPlyFile, which returns the error of each call.
+*/
+func PlyHeaderError() error {
+	last_header_error.Lock()
+	defer last_header_error.Unlock()
+	return last_header_error.err
 }
 
-/* PlyClose closes the open plyfile, specified by the CPlyFile object. Note that the PLY file memory is tracked by C, not by Go, and calling this function is necessary to free memory associated with the open PLY file. */
+/* PlyClose closes the open plyfile, specified by the CPlyFile object. Note that the PLY file memory is tracked by C, not by Go, and calling this function is necessary to free memory associated with the open PLY file, including the lists read by PlyGetElement. */
 func PlyClose(plyfile CPlyFile) {
 	C.ply_close(plyfile)
 }
 
+/* PlyFreeLists frees the lists read by PlyGetElement so far, which are otherwise freed by PlyClose. The pointers to them in elements already read become invalid. */
+func PlyFreeLists(plyfile CPlyFile) {
+	C.ply_free_lists(plyfile)
+}
+
+/* PlySetConvertPolicy sets what happens to values that don't fit the type they are converted to, between the file and the user's structures, when reading with PlyGetElement or writing with PlyPutElement. Under ConvertErrorOnOverflow and ConvertErrorOnPrecisionLoss, the first such value is reported by PlyConvertError. */
+func PlySetConvertPolicy(plyfile CPlyFile, policy ConvertPolicy) {
+	C.ply_set_convert_policy(plyfile, C.int(policy))
+}
+
+/* PlyConvertError returns a *ConvertError for the first value that couldn't be converted under the policy set by PlySetConvertPolicy, or nil. */
+func PlyConvertError(plyfile CPlyFile) error {
+	var elem_name, prop_name *C.char
+	var index, typ C.int
+	var value C.double
+	reason := C.ply_get_convert_error(plyfile, &elem_name, &index, &prop_name, &value, &typ)
+	if reason == 0 {
+		return nil
+	}
+	err := &ConvertError{Element: C.GoString(elem_name), Index: int(index), Property: C.GoString(prop_name), Value: float64(value), Type: int(typ),
+		Reason: reasonOverflow}
+	if reason == C.PLY_LOSES_PRECISION {
+		err.Reason = reasonPrecision
+	}
+	return err
+}
+
+/* PlyBodyError returns a *BodyError for the first value PlyGetElement couldn't read, e.g. at the end of a truncated file, a *LimitError for the first limit set by PlySetLimits that was exceeded, or nil. After such an error, PlyGetElement reads nothing more. */
+func PlyBodyError(plyfile CPlyFile) error {
+	var elem_name, prop_name *C.char
+	var index, line C.int
+	reason := C.ply_get_body_error(plyfile, &elem_name, &index, &prop_name, &line)
+	if reason == nil {
+		return nil
+	}
+	switch plyfile.body_limit {
+	case C.PLY_LIMIT_ELEMENT_COUNT:
+		return &LimitError{Limit: "MaxElementCount", Max: int64(plyfile.max_element_count), Value: int64(plyfile.body_value),
+			Where: fmt.Sprintf("element '%s'", C.GoString(elem_name))}
+	case C.PLY_LIMIT_LIST_LENGTH:
+		return &LimitError{Limit: "MaxListLength", Max: int64(plyfile.max_list_length), Value: int64(plyfile.body_value),
+			Where: fmt.Sprintf("element '%s' %d property '%s'", C.GoString(elem_name), int(index), C.GoString(prop_name))}
+	}
+	err := &BodyError{Element: C.GoString(elem_name), Index: int(index), Line: int(line), Reason: C.GoString(reason)}
+	if prop_name != nil {
+		err.Property = C.GoString(prop_name)
+	}
+	return err
+}
+
+/* PlySetLimits applies the MaxElementCount and MaxListLength of opts to PlyGetElement, which then reads no instance of an element whose count exceeds MaxElementCount, and stops at the first list longer than MaxListLength. PlyBodyError reports the exceeded limit as a *LimitError. The other fields of opts are ignored. */
+func PlySetLimits(plyfile CPlyFile, opts ReadOptions) {
+	C.ply_set_limits(plyfile, cLimit(opts.MaxElementCount), cLimit(opts.MaxListLength))
+}
+
+/* cLimit converts a limit to a C int; the C library counts in ints, so a larger limit is no limit. */
+func cLimit(n int) C.int {
+	if n < 0 || n > math.MaxInt32 {
+		return 0
+	}
+	return C.int(n)
+}
+
+/* cStrings copies strings to C memory, to be freed with freeCStrings. */
+func cStrings(strs []string) []*C.char {
+	cstrs := make([]*C.char, len(strs))
+	for i, str := range strs {
+		cstrs[i] = C.CString(str)
+	}
+	return cstrs
+}
+
+func freeCStrings(cstrs []*C.char) {
+	for _, cstr := range cstrs {
+		C.free(unsafe.Pointer(cstr))
+	}
+}
+
 /* Writing Functions */
 
 /* PlyElementCount specifies the number of elements that are about to be written. */
 func PlyElementCount(plyfile CPlyFile, element_name string, nelems int) {
-	C.ply_element_count(plyfile, C.CString(element_name), C.int(nelems))
+	cname := C.CString(element_name)
+	defer C.free(unsafe.Pointer(cname))
+	C.ply_element_count(plyfile, cname, C.int(nelems))
 }
 
 /* PlyDescribeProperty describes a property of an element. */
 func PlyDescribeProperty(plyfile CPlyFile, element_name string, prop PlyProperty) {
 	propertyptr := prop.ToC()
-	C.ply_describe_property(plyfile, C.CString(element_name), &propertyptr)
+	defer C.free(unsafe.Pointer(propertyptr.name))
+	cname := C.CString(element_name)
+	defer C.free(unsafe.Pointer(cname))
+	C.ply_describe_property(plyfile, cname, &propertyptr)
 }
 
 /* PlyPutComment writes the specified comment into the PLY file header. */
 func PlyPutComment(plyfile CPlyFile, comment string) {
-	C.ply_put_comment(plyfile, C.CString(comment))
+	ccomment := C.CString(comment)
+	defer C.free(unsafe.Pointer(ccomment))
+	C.ply_put_comment(plyfile, ccomment)
 }
 
 /* PlyPutObjInfo writes the specified object info string into the PLY file header. */
 func PlyPutObjInfo(plyfile CPlyFile, obj_info string) {
-	C.ply_put_obj_info(plyfile, C.CString(obj_info))
+	cobj_info := C.CString(obj_info)
+	defer C.free(unsafe.Pointer(cobj_info))
+	C.ply_put_obj_info(plyfile, cobj_info)
 }
 
 /* PlyHeaderComplete signals that the PLY header is fully described and flushes it to disk. */
@@ -177,7 +342,9 @@ func PlyHeaderComplete(plyfile CPlyFile) {
 
 /* PlyPutElementSetup specifies which element is about to be written. This should be called prior to PlyPutElement. */
 func PlyPutElementSetup(plyfile CPlyFile, element_name string) {
-	C.ply_put_element_setup(plyfile, C.CString(element_name))
+	cname := C.CString(element_name)
+	defer C.free(unsafe.Pointer(cname))
+	C.ply_put_element_setup(plyfile, cname)
 }
 
 /* PlyPutElement writes an element to the PLY file. The type of element is specified by PlyPutElementSetup, which must be called first. */
@@ -205,18 +372,26 @@ func PlyGetElementDescription(plyfile CPlyFile, element_name string) ([]PlyPrope
 	cnelems := C.int(nelems)
 	cnprops := C.int(nprops)
 
-	cplist_ptr := C.ply_get_element_description(plyfile, C.CString(element_name), &cnelems, &cnprops)
+	cname := C.CString(element_name)
+	defer C.free(unsafe.Pointer(cname))
+	cplist_ptr := C.ply_get_element_description(plyfile, cname, &cnelems, &cnprops)
+	if cplist_ptr == nil {
+		return nil, 0, 0
+	}
+	defer C.free(unsafe.Pointer(cplist_ptr))
 
 	nprops = int(cnprops)
 
 	// convert cplist_ptr to a go slice of pointers
 	cplist_ptr_go := (*[1 << 30]*CPlyProperty)(unsafe.Pointer(cplist_ptr))[:nprops]
 
-	// iterate through the slice of pointers, converting from CPlyProperty to PlyProperty
+	// iterate through the slice of pointers, converting from CPlyProperty to PlyProperty, and free the C copies
 	plist := make([]PlyProperty, nprops)
 	for i := 0; i < nprops; i++ {
 		tmp := *cplist_ptr_go[i]
 		plist[i].FromC(tmp)
+		C.free(unsafe.Pointer(tmp.name))
+		C.free(unsafe.Pointer(cplist_ptr_go[i]))
 	}
 
 	return plist, int(cnelems), int(cnprops)
@@ -225,10 +400,13 @@ func PlyGetElementDescription(plyfile CPlyFile, element_name string) ([]PlyPrope
 /* PlyGetProperty specifies a property of an element that should be returned with a call to PlyGetElement. Note that PlyGetProperty must be called before PlyGetElement, and can be called multiple times (for each PLYProperty an element contains). */
 func PlyGetProperty(plyfile CPlyFile, elem_name string, prop PlyProperty) {
 	cprop := prop.ToC()
-	C.ply_get_property(plyfile, C.CString(elem_name), &cprop)
+	defer C.free(unsafe.Pointer(cprop.name))
+	cname := C.CString(elem_name)
+	defer C.free(unsafe.Pointer(cname))
+	C.ply_get_property(plyfile, cname, &cprop)
 }
 
-/* PlyGetElement retrieves an element from the PLY file. The properties returned must be specified by PlyGetProperty before calling PlyGetElement. */
+/* PlyGetElement retrieves an element from the PLY file. The properties returned must be specified by PlyGetProperty before calling PlyGetElement. If a value can't be read, PlyBodyError says why. */
 func PlyGetElement(plyfile CPlyFile, element interface{}, size uintptr) {
 	// memory should be allocated before calling PlyGetElement
 	buf := make([]byte, size)
@@ -249,6 +427,9 @@ func PlyGetComments(plyfile CPlyFile) []string {
 	cptr = C.ply_get_comments(plyfile, &cnum_comments)
 
 	num_comments := int(cnum_comments)
+	if num_comments == 0 {
+		return []string{}
+	}
 
 	// convert cptr to a go slice of pointers
 	cstring_list := (*[1 << 30]*C.char)(unsafe.Pointer(cptr))[:num_comments]
@@ -269,6 +450,9 @@ func PlyGetObjInfo(plyfile CPlyFile) []string {
 	cptr = C.ply_get_obj_info(plyfile, &cnum_obj_info)
 
 	num_obj_info := int(cnum_obj_info)
+	if num_obj_info == 0 {
+		return []string{}
+	}
 
 	// convert cptr to a go slice of pointers
 	cstring_list := (*[1 << 30]*C.char)(unsafe.Pointer(cptr))[:num_obj_info]
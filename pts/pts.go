@@ -0,0 +1,196 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package pts converts between Leica PTS point files and the vertex element of PLY meshes.
+
+A PTS file consists of one or more blocks (scans), each starting with a line holding the number of points in the block, followed by one point per line:
+
+	x y z
+	x y z intensity
+	x y z r g b
+	x y z intensity r g b
+
+Positions are stored as double and colors as uchar. Intensities are stored as short when they are all integers in the range of a short (as written by Leica scanners, -2048 to 2047), and as float otherwise.
+*/
+package pts
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+func init() {
+	plyfile.RegisterFormat("pts", sniff, Decode)
+}
+
+/* sniff reports whether prefix starts with a point count line followed by a point line with 3, 4, 6 or 7 numeric columns. */
+func sniff(prefix []byte) bool {
+	var lines [][]string
+	for _, line := range strings.Split(string(prefix), "\n") {
+		if words := strings.Fields(line); len(words) > 0 {
+			lines = append(lines, words)
+		}
+	}
+	if len(lines) < 2 || len(lines[0]) != 1 {
+		return false
+	}
+	if n, err := strconv.Atoi(lines[0][0]); err != nil || n < 0 {
+		return false
+	}
+	switch len(lines[1]) {
+	case 3, 4, 6, 7:
+	default:
+		return false
+	}
+	for _, word := range lines[1] {
+		if _, err := strconv.ParseFloat(word, 64); err != nil {
+			return false
+		}
+	}
+	return true
+}
+
+/* Decode reads a PTS file from r and returns the points of all its blocks as the vertex element of a PLY mesh. */
+func Decode(r io.Reader) (*plyfile.Mesh, error) {
+	var columns [][]float64
+	remaining := -1 /* points left in the current block */
+
+	br := bufio.NewReader(r)
+	for line_num := 1; ; line_num++ {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if err == io.EOF && line == "" {
+			break
+		}
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+
+		if remaining <= 0 {
+			/* a new block */
+			n, err := strconv.Atoi(words[0])
+			if len(words) != 1 || err != nil || n < 0 {
+				return nil, fmt.Errorf("pts: line %d: bad point count '%s'", line_num, strings.TrimSpace(line))
+			}
+			remaining = n
+			continue
+		}
+
+		if columns == nil {
+			if n := len(words); n != 3 && n != 4 && n != 6 && n != 7 {
+				return nil, fmt.Errorf("pts: line %d: %d columns, want 3, 4, 6 or 7", line_num, n)
+			}
+			columns = make([][]float64, len(words))
+		}
+		if len(words) != len(columns) {
+			return nil, fmt.Errorf("pts: line %d: %d columns, want %d", line_num, len(words), len(columns))
+		}
+		for k, word := range words {
+			v, err := strconv.ParseFloat(word, 64)
+			if err != nil {
+				return nil, fmt.Errorf("pts: line %d: bad number '%s'", line_num, word)
+			}
+			columns[k] = append(columns[k], v)
+		}
+		remaining--
+	}
+	if remaining > 0 {
+		return nil, fmt.Errorf("pts: unexpected end of file, %d points missing", remaining)
+	}
+	if remaining < 0 {
+		return nil, errors.New("pts: empty file")
+	}
+	if columns == nil {
+		columns = make([][]float64, 3)
+	}
+
+	m := plyfile.NewMesh(plyfile.PLY_BINARY_LE)
+	vertex := m.AddElement("vertex", len(columns[0]))
+	add := func(name string, typ int, data []float64) {
+		copy(vertex.AddProperty(name, typ).Data, data)
+	}
+	add("x", plyfile.PLY_DOUBLE, columns[0])
+	add("y", plyfile.PLY_DOUBLE, columns[1])
+	add("z", plyfile.PLY_DOUBLE, columns[2])
+	extra := columns[3:]
+	if len(extra) == 1 || len(extra) == 4 {
+		add("intensity", intensityType(extra[0]), extra[0])
+		extra = extra[1:]
+	}
+	if len(extra) == 3 {
+		for k, name := range []string{"red", "green", "blue"} {
+			for i, v := range extra[k] {
+				extra[k][i] = math.Max(0, math.Min(255, math.Round(v)))
+			}
+			add(name, plyfile.PLY_UCHAR, extra[k])
+		}
+	}
+	return m, nil
+}
+
+/* intensityType returns PLY_SHORT if all values fit a short, and PLY_FLOAT otherwise. */
+func intensityType(values []float64) int {
+	for _, v := range values {
+		if v < math.MinInt16 || v > math.MaxInt16 || v != math.Trunc(v) {
+			return plyfile.PLY_FLOAT
+		}
+	}
+	return plyfile.PLY_SHORT
+}
+
+/* Encode writes the vertex element of a PLY mesh to w as a PTS file with a single block. The intensity (or scalar_intensity) and colors (red, green, blue) are written when the vertex element has them. */
+func Encode(w io.Writer, m *plyfile.Mesh) error {
+	vertex := m.Element("vertex")
+	if vertex == nil {
+		return errors.New("pts: mesh has no vertex element")
+	}
+	props := vertex.Scalars("x", "y", "z")
+	if props == nil {
+		return errors.New("pts: vertex element has no x, y and z properties")
+	}
+	if intensity := vertex.FindProperty("intensity", "scalar_intensity"); intensity != nil && !intensity.IsList {
+		props = append(props, intensity)
+	}
+	colors := vertex.Scalars("red", "green", "blue")
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "%d\n", vertex.Count)
+	var buf []byte
+	for i := 0; i < vertex.Count; i++ {
+		buf = buf[:0]
+		for _, prop := range props {
+			buf = prop.AppendText(buf, i)
+		}
+		for _, prop := range colors {
+			buf = strconv.AppendInt(buf, int64(prop.ColorByte(i)), 10)
+			buf = append(buf, ' ')
+		}
+		buf[len(buf)-1] = '\n'
+		bw.Write(buf)
+	}
+	return bw.Flush()
+}
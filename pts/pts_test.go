@@ -0,0 +1,78 @@
+package pts
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+/* two blocks with intensity and colors */
+const scan = `2
+1.5 2 3 -2048 255 0 0
+4 5 6 2047 0 255 0
+1
+7 8 9 0 0 0 255
+`
+
+func TestDecode(t *testing.T) {
+	m, err := Decode(strings.NewReader(scan))
+	if err != nil {
+		t.Fatal(err)
+	}
+	vertex := m.Element("vertex")
+	var names []string
+	for _, prop := range vertex.Properties {
+		names = append(names, prop.Name+":"+plyfile.TypeName(prop.Type))
+	}
+	if want := []string{"x:double", "y:double", "z:double", "intensity:short", "red:uchar", "green:uchar", "blue:uchar"}; !reflect.DeepEqual(names, want) {
+		t.Errorf("properties = %v, want %v", names, want)
+	}
+	if got := vertex.Property("z").Data; !reflect.DeepEqual(got, []float64{3, 6, 9}) {
+		t.Errorf("z = %v", got)
+	}
+	if got := vertex.Property("blue").Data; !reflect.DeepEqual(got, []float64{0, 0, 255}) {
+		t.Errorf("blue = %v", got)
+	}
+
+	m, err = Decode(strings.NewReader("1\n0 0 0 0.25\n"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if typ := m.Element("vertex").Property("intensity").Type; typ != plyfile.PLY_FLOAT {
+		t.Errorf("intensity type = %s, want float", plyfile.TypeName(typ))
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	m, err := Decode(strings.NewReader(scan))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	want := "3\n" + strings.Replace(scan[2:], "1\n7", "7", 1)
+	if buf.String() != want {
+		t.Errorf("wrote\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, file := range []string{"", "x\n", "2\n0 0 0\n", "1\n0 0\n", "2\n0 0 0\n0 0 0 1\n", "1\n0 0 0 1 2\n"} {
+		if _, err := Decode(strings.NewReader(file)); err == nil {
+			t.Errorf("expected an error for %q", file)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add([]byte(scan))
+	f.Add([]byte("1\n0 0 0 0.25\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Decode(bytes.NewReader(data))
+	})
+}
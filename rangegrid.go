@@ -0,0 +1,185 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plyfile
+
+import (
+	"fmt"
+	"math"
+)
+
+/* RangeGridElement is the element of the Stanford range grid layout: "obj_info num_cols <cols>" and "obj_info num_rows <rows>", then one range_grid element per grid cell, row by row, whose vertex_indices list is empty where the scanner saw nothing and holds the index of the vertex otherwise. */
+const RangeGridElement = "range_grid"
+
+/* RangeGrid is the grid of a structured scan. */
+type RangeGrid struct {
+	Cols  int
+	Rows  int
+	Cells []int /* Rows*Cols vertex indices, row by row, -1 for empty cells */
+}
+
+/* Index returns the vertex index of a cell, or -1 if the cell is empty or outside the grid. */
+func (g *RangeGrid) Index(row, col int) int {
+	if row < 0 || row >= g.Rows || col < 0 || col >= g.Cols {
+		return -1
+	}
+	return g.Cells[row*g.Cols+col]
+}
+
+/* RangeGrid returns the range grid of the mesh, or nil if it has no range_grid element. */
+func (m *Mesh) RangeGrid() (*RangeGrid, error) {
+	elem := m.Element(RangeGridElement)
+	if elem == nil {
+		return nil, nil
+	}
+	cols, rows, ok, err := m.Metadata().GridSize()
+	if err != nil {
+		return nil, err
+	}
+	if !ok {
+		return nil, fmt.Errorf("plyfile: range_grid without num_cols and num_rows obj_info")
+	}
+	/* cols is checked against the count first, so that cols*rows can't overflow */
+	if cols < 0 || rows < 0 || rows != 0 && cols > elem.Count/rows || cols*rows != elem.Count {
+		return nil, fmt.Errorf("plyfile: range_grid has %d cells, expected %d columns by %d rows", elem.Count, cols, rows)
+	}
+	indices := elem.FindProperty("vertex_indices", "vertex_index")
+	if indices == nil || !indices.IsList {
+		return nil, fmt.Errorf("plyfile: range_grid element has no vertex_indices list")
+	}
+	if len(indices.Lists) != elem.Count {
+		return nil, fmt.Errorf("plyfile: range_grid has %d cells but %d vertex_indices lists", elem.Count, len(indices.Lists))
+	}
+	nverts := 0
+	if vertex := m.Element("vertex"); vertex != nil {
+		nverts = vertex.Count
+	}
+	g := &RangeGrid{Cols: cols, Rows: rows, Cells: make([]int, elem.Count)}
+	for i, list := range indices.Lists {
+		switch {
+		case len(list) == 0:
+			g.Cells[i] = -1
+		case len(list) > 1:
+			return nil, fmt.Errorf("plyfile: range_grid cell %d has %d vertex indices", i, len(list))
+		case list[0] < 0 || int(list[0]) >= nverts:
+			return nil, fmt.Errorf("plyfile: range_grid cell %d: vertex index %v out of range", i, list[0])
+		default:
+			g.Cells[i] = int(list[0])
+		}
+	}
+	return g, nil
+}
+
+/* Triangulate returns the triangles joining neighboring cells of the grid: two per square of four full cells, split along the shorter diagonal, or one where a single cell of the square is empty. Triangles with an edge longer than max_edge are skipped, so that the surface doesn't bridge depth discontinuities; a max_edge of 0 keeps every triangle. All triangles have the same winding in grid space. */
+func (g *RangeGrid) Triangulate(positions [][3]float64, max_edge float64) [][3]int {
+	var triangles [][3]int
+	add := func(a, b, c int) {
+		if max_edge > 0 && (distance(positions[a], positions[b]) > max_edge || distance(positions[b], positions[c]) > max_edge || distance(positions[c], positions[a]) > max_edge) {
+			return
+		}
+		triangles = append(triangles, [3]int{a, b, c})
+	}
+	for row := 0; row+1 < g.Rows; row++ {
+		for col := 0; col+1 < g.Cols; col++ {
+			/* a b
+			   d e */
+			a, b := g.Index(row, col), g.Index(row, col+1)
+			d, e := g.Index(row+1, col), g.Index(row+1, col+1)
+			switch {
+			case a >= 0 && b >= 0 && d >= 0 && e >= 0:
+				if distance(positions[a], positions[e]) <= distance(positions[b], positions[d]) {
+					add(a, d, e)
+					add(a, e, b)
+				} else {
+					add(a, d, b)
+					add(b, d, e)
+				}
+			case a < 0 && b >= 0 && d >= 0 && e >= 0:
+				add(b, d, e)
+			case b < 0 && a >= 0 && d >= 0 && e >= 0:
+				add(a, d, e)
+			case d < 0 && a >= 0 && b >= 0 && e >= 0:
+				add(a, e, b)
+			case e < 0 && a >= 0 && b >= 0 && d >= 0:
+				add(a, d, b)
+			}
+		}
+	}
+	return triangles
+}
+
+func distance(p, q [3]float64) float64 {
+	return math.Sqrt((p[0]-q[0])*(p[0]-q[0]) + (p[1]-q[1])*(p[1]-q[1]) + (p[2]-q[2])*(p[2]-q[2]))
+}
+
+/* TriangulateRangeGrid replaces the face element of the mesh with the triangles of its range grid, as RangeGrid.Triangulate builds them. */
+func (m *Mesh) TriangulateRangeGrid(max_edge float64) error {
+	g, err := m.RangeGrid()
+	if err != nil {
+		return err
+	}
+	if g == nil {
+		return fmt.Errorf("plyfile: mesh has no range_grid element")
+	}
+	positions, err := m.Positions()
+	if err != nil {
+		return err
+	}
+	triangles := g.Triangulate(positions, max_edge)
+	face := m.AddElement("face", len(triangles))
+	indices := face.AddListProperty("vertex_indices", PLY_UCHAR, PLY_INT)
+	for i, tri := range triangles {
+		indices.Lists[i] = []float64{float64(tri[0]), float64(tri[1]), float64(tri[2])}
+	}
+	return nil
+}
+
+/* NewRangeGridMesh returns a mesh in the range grid layout for points[row][col]. Every row must have the same length. Points with a NaN coordinate are empty cells; the others become float vertices, in grid order. */
+func NewRangeGridMesh(format int, points [][][3]float64) (*Mesh, error) {
+	rows, cols := len(points), 0
+	if rows > 0 {
+		cols = len(points[0])
+	}
+	var positions [][3]float64
+	cells := make([][]float64, 0, rows*cols)
+	for row, line := range points {
+		if len(line) != cols {
+			return nil, fmt.Errorf("plyfile: range grid row %d has %d points, expected %d", row, len(line), cols)
+		}
+		for _, p := range line {
+			if math.IsNaN(p[0]) || math.IsNaN(p[1]) || math.IsNaN(p[2]) {
+				cells = append(cells, []float64{})
+				continue
+			}
+			cells = append(cells, []float64{float64(len(positions))})
+			positions = append(positions, p)
+		}
+	}
+
+	m := NewMesh(format)
+	md := m.Metadata()
+	md.SetInt(MetaNumCols, cols)
+	md.SetInt(MetaNumRows, rows)
+	m.SetMetadata(md)
+	vertex := m.AddElement("vertex", len(positions))
+	x, y, z := vertex.AddProperty("x", PLY_FLOAT), vertex.AddProperty("y", PLY_FLOAT), vertex.AddProperty("z", PLY_FLOAT)
+	for i, p := range positions {
+		x.Data[i], y.Data[i], z.Data[i] = p[0], p[1], p[2]
+	}
+	grid := m.AddElement(RangeGridElement, len(cells))
+	grid.AddListProperty("vertex_indices", PLY_UCHAR, PLY_INT).Lists = cells
+	return m, nil
+}
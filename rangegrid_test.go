@@ -0,0 +1,110 @@
+package plyfile
+
+import (
+	"bytes"
+	"math"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+/* rangeGridPoints returns a flat 3 by 3 grid with unit spacing, whose first cell is empty and whose last point is far behind the others. */
+func rangeGridPoints() [][][3]float64 {
+	points := make([][][3]float64, 3)
+	for row := range points {
+		points[row] = make([][3]float64, 3)
+		for col := range points[row] {
+			points[row][col] = [3]float64{float64(col), float64(-row), 0}
+		}
+	}
+	points[0][0] = [3]float64{math.NaN(), math.NaN(), math.NaN()}
+	points[2][2][2] = -10
+	return points
+}
+
+func TestRangeGrid(t *testing.T) {
+	for _, format := range []int{PLY_ASCII, PLY_BINARY_BE} {
+		m, err := NewRangeGridMesh(format, rangeGridPoints())
+		if err != nil {
+			t.Fatal(err)
+		}
+		var buf bytes.Buffer
+		if err := EncodeMesh(&buf, m); err != nil {
+			t.Fatal(err)
+		}
+		if format == PLY_ASCII && !strings.Contains(buf.String(), "obj_info num_cols 3\nobj_info num_rows 3\n") {
+			t.Errorf("header without num_cols and num_rows:\n%s", buf.String())
+		}
+		m, err = DecodeMesh(&buf)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		g, err := m.RangeGrid()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want := &RangeGrid{Cols: 3, Rows: 3, Cells: []int{-1, 0, 1, 2, 3, 4, 5, 6, 7}}
+		if !reflect.DeepEqual(g, want) {
+			t.Errorf("RangeGrid = %+v, want %+v", g, want)
+		}
+		if g.Index(2, 1) != 6 || g.Index(0, 0) != -1 || g.Index(3, 0) != -1 {
+			t.Errorf("Index = %d %d %d", g.Index(2, 1), g.Index(0, 0), g.Index(3, 0))
+		}
+
+		positions, _ := m.Positions()
+		if n := len(g.Triangulate(positions, 0)); n != 7 {
+			t.Errorf("%d triangles without an edge limit, want 7", n)
+		}
+		/* the square next to the far point is split along its shorter diagonal, and its triangle touching the far point is dropped */
+		if err := m.TriangulateRangeGrid(2); err != nil {
+			t.Fatal(err)
+		}
+		triangles, err := m.Triangles()
+		if err != nil {
+			t.Fatal(err)
+		}
+		want_triangles := [][3]int{{0, 2, 3}, {0, 3, 4}, {0, 4, 1}, {2, 5, 6}, {2, 6, 3}, {3, 6, 4}}
+		if !reflect.DeepEqual(triangles, want_triangles) {
+			t.Errorf("triangles = %v, want %v", triangles, want_triangles)
+		}
+	}
+}
+
+func TestRangeGridErrors(t *testing.T) {
+	if m, err := NewRangeGridMesh(PLY_ASCII, [][][3]float64{{{0, 0, 0}}, {}}); err == nil {
+		t.Errorf("NewRangeGridMesh with rows of different lengths = %v", m)
+	}
+	if g, err := NewMesh(PLY_ASCII).RangeGrid(); g != nil || err != nil {
+		t.Errorf("RangeGrid without range_grid = %v, %v", g, err)
+	}
+
+	bad := func(change func(m *Mesh)) *Mesh {
+		m, _ := NewRangeGridMesh(PLY_ASCII, rangeGridPoints())
+		change(m)
+		return m
+	}
+	for name, m := range map[string]*Mesh{
+		"no size":      bad(func(m *Mesh) { m.ObjInfo = nil }),
+		"wrong size":   bad(func(m *Mesh) { m.ObjInfo = []string{"num_cols 2", "num_rows 3"} }),
+		"two indices":  bad(func(m *Mesh) { m.Element(RangeGridElement).Properties[0].Lists[1] = []float64{0, 1} }),
+		"out of range": bad(func(m *Mesh) { m.Element(RangeGridElement).Properties[0].Lists[1] = []float64{8} }),
+		"missing cells": bad(func(m *Mesh) {
+			indices := m.Element(RangeGridElement).Properties[0]
+			indices.Lists = indices.Lists[:1]
+		}),
+		/* 2^32 by 2^32 cells overflow to 0 */
+		"overflowing size": bad(func(m *Mesh) {
+			m.ObjInfo = []string{"num_cols 4294967296", "num_rows 4294967296"}
+			elem := m.Element(RangeGridElement)
+			elem.Count, elem.Properties[0].Lists = 0, nil
+		}),
+	} {
+		if _, err := m.RangeGrid(); err == nil {
+			t.Errorf("%s: RangeGrid succeeded", name)
+		}
+		if err := m.TriangulateRangeGrid(0); err == nil {
+			t.Errorf("%s: TriangulateRangeGrid succeeded", name)
+		}
+	}
+}
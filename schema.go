@@ -0,0 +1,268 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plyfile
+
+import (
+	"fmt"
+	"strings"
+)
+
+/* Schema is the layout a file is expected to have: its elements and their properties, in order. Element counts and data are ignored. */
+type Schema struct {
+	Elements []*Element
+}
+
+/* SchemaMode selects how closely a header must match a Schema. */
+type SchemaMode int
+
+const (
+	SchemaExact       SchemaMode = iota /* same elements and properties, in the same order and with the same types */
+	SchemaSuperset                      /* the elements and properties of the schema, with the same types, among others in any order */
+	SchemaConvertible                   /* as SchemaSuperset, but each type only has to convert to the schema type without loss, e.g. uchar to int or float to double */
+)
+
+/* SchemaOf returns the schema of a header, e.g. one built with NewHeader. */
+func SchemaOf(header *Mesh) *Schema {
+	s := &Schema{Elements: describeMesh(header).Elements}
+	for _, elem := range s.Elements {
+		elem.Count = 0
+	}
+	return s
+}
+
+/* ParseSchema parses a schema written as the element and property lines of a header, e.g. "element vertex\nproperty float x\nproperty float y". Element counts may be omitted. */
+func ParseSchema(text string) (*Schema, error) {
+	s := &Schema{}
+	var elem *Element
+	for i, line := range strings.Split(text, "\n") {
+		words := strings.Fields(line)
+		if len(words) == 0 {
+			continue
+		}
+		bad := func(reason string) error {
+			return &ParseError{Line: i + 1, Text: strings.TrimSpace(line), Reason: reason}
+		}
+		switch words[0] {
+		case "element":
+			if len(words) != 2 && len(words) != 3 {
+				return nil, bad("bad element line")
+			}
+			for _, e := range s.Elements {
+				if e.Name == words[1] {
+					return nil, bad(fmt.Sprintf("element '%s' declared twice", e.Name))
+				}
+			}
+			elem = &Element{Name: words[1]}
+			s.Elements = append(s.Elements, elem)
+		case "property":
+			if elem == nil {
+				return nil, bad("property before first element")
+			}
+			prop, err := parseProperty(words)
+			if err != nil {
+				return nil, bad(err.Error())
+			}
+			if elem.Property(prop.Name) != nil {
+				return nil, bad(fmt.Sprintf("property '%s' declared twice in element '%s'", prop.Name, elem.Name))
+			}
+			elem.Properties = append(elem.Properties, prop)
+		default:
+			return nil, bad(fmt.Sprintf("unknown keyword '%s'", words[0]))
+		}
+	}
+	return s, nil
+}
+
+/* SchemaDiffKind is the kind of a difference between a header and a schema. */
+type SchemaDiffKind int
+
+const (
+	SchemaMissing  SchemaDiffKind = iota /* in the schema, not in the header */
+	SchemaExtra                          /* in the header, not in the schema */
+	SchemaMismatch                       /* in both, with types that don't match */
+	SchemaOrder                          /* in both, in another order */
+)
+
+/* SchemaDiff is one difference between a header and a schema. */
+type SchemaDiff struct {
+	Kind     SchemaDiffKind
+	Element  string /* "" for the order of the elements */
+	Property string /* "" for a difference about the whole element */
+	Got      string /* header line, or the order of the header, e.g. "property uchar red" or "y x z" */
+	Want     string /* same, from the schema */
+}
+
+func (d SchemaDiff) String() string {
+	var where string
+	switch {
+	case d.Element == "":
+		where = "elements"
+	case d.Property == "":
+		where = fmt.Sprintf("element '%s'", d.Element)
+	default:
+		where = fmt.Sprintf("property '%s' of element '%s'", d.Property, d.Element)
+	}
+	switch d.Kind {
+	case SchemaMissing:
+		return fmt.Sprintf("- %s: %s", where, d.Want)
+	case SchemaExtra:
+		return fmt.Sprintf("+ %s: %s", where, d.Got)
+	case SchemaMismatch:
+		return fmt.Sprintf("~ %s: %s, want %s", where, d.Got, d.Want)
+	}
+	return fmt.Sprintf("~ %s: order %s, want %s", where, d.Got, d.Want)
+}
+
+/* SchemaError lists the differences between a header and a schema: missing (-), extra (+) and mismatched (~) elements and properties. */
+type SchemaError struct {
+	Diffs []SchemaDiff
+}
+
+func (e *SchemaError) Error() string {
+	lines := []string{"plyfile: header doesn't match the schema:"}
+	for _, d := range e.Diffs {
+		lines = append(lines, d.String())
+	}
+	return strings.Join(lines, "\n\t")
+}
+
+/* ExpectSchema checks that a header (e.g. from DecodeHeader, File.Header or PlyFile.Header) has the layout of schema, and returns a *SchemaError listing every difference if it hasn't. */
+func ExpectSchema(header *Mesh, schema *Schema, mode SchemaMode) error {
+	var diffs []SchemaDiff
+	for _, want := range schema.Elements {
+		elem := header.Element(want.Name)
+		if elem == nil {
+			diffs = append(diffs, SchemaDiff{Kind: SchemaMissing, Element: want.Name, Want: "element " + want.Name})
+			continue
+		}
+		for _, want_prop := range want.Properties {
+			prop := elem.Property(want_prop.Name)
+			if prop == nil {
+				diffs = append(diffs, SchemaDiff{Kind: SchemaMissing, Element: elem.Name, Property: want_prop.Name, Want: propertyLine(want_prop)})
+			} else if !schemaTypesMatch(prop, want_prop, mode) {
+				diffs = append(diffs, SchemaDiff{Kind: SchemaMismatch, Element: elem.Name, Property: prop.Name, Got: propertyLine(prop), Want: propertyLine(want_prop)})
+			}
+		}
+		if mode != SchemaExact {
+			continue
+		}
+		for _, prop := range elem.Properties {
+			if want.Property(prop.Name) == nil {
+				diffs = append(diffs, SchemaDiff{Kind: SchemaExtra, Element: elem.Name, Property: prop.Name, Got: propertyLine(prop)})
+			}
+		}
+		got_order, want_order := sharedOrder(propertyNames(elem.Properties), propertyNames(want.Properties))
+		if got_order != want_order {
+			diffs = append(diffs, SchemaDiff{Kind: SchemaOrder, Element: elem.Name, Got: got_order, Want: want_order})
+		}
+	}
+	if mode == SchemaExact {
+		for _, elem := range header.Elements {
+			if schema.element(elem.Name) == nil {
+				diffs = append(diffs, SchemaDiff{Kind: SchemaExtra, Element: elem.Name, Got: "element " + elem.Name})
+			}
+		}
+		got_order, want_order := sharedOrder(elementNames(header.Elements), elementNames(schema.Elements))
+		if got_order != want_order {
+			diffs = append(diffs, SchemaDiff{Kind: SchemaOrder, Got: got_order, Want: want_order})
+		}
+	}
+	if len(diffs) > 0 {
+		return &SchemaError{Diffs: diffs}
+	}
+	return nil
+}
+
+/* propertyLine returns the header line of a property, e.g. "property list uchar int vertex_indices". */
+func propertyLine(prop *Property) string {
+	if prop.IsList {
+		return fmt.Sprintf("property list %s %s %s", TypeName(prop.CountType), TypeName(prop.Type), prop.Name)
+	}
+	return fmt.Sprintf("property %s %s", TypeName(prop.Type), prop.Name)
+}
+
+func (s *Schema) element(name string) *Element {
+	for _, elem := range s.Elements {
+		if elem.Name == name {
+			return elem
+		}
+	}
+	return nil
+}
+
+func elementNames(elems []*Element) []string {
+	names := make([]string, len(elems))
+	for i, elem := range elems {
+		names[i] = elem.Name
+	}
+	return names
+}
+
+func propertyNames(props []*Property) []string {
+	names := make([]string, len(props))
+	for i, prop := range props {
+		names[i] = prop.Name
+	}
+	return names
+}
+
+/* sharedOrder returns the names found in both got and want, in the order of each. */
+func sharedOrder(got, want []string) (string, string) {
+	shared := func(names, other []string) string {
+		var kept []string
+		for _, name := range names {
+			for _, o := range other {
+				if o == name {
+					kept = append(kept, name)
+					break
+				}
+			}
+		}
+		return strings.Join(kept, " ")
+	}
+	return shared(got, want), shared(want, got)
+}
+
+func schemaTypesMatch(prop, want *Property, mode SchemaMode) bool {
+	if prop.IsList != want.IsList {
+		return false
+	}
+	if mode != SchemaConvertible {
+		return prop.Type == want.Type && (!prop.IsList || prop.CountType == want.CountType)
+	}
+	return convertsExactly(prop.Type, want.Type) && (!prop.IsList || isIntegerType(prop.CountType))
+}
+
+/* convertsExactly reports whether every value of PLY_* type from is exactly represented by type to. */
+func convertsExactly(from, to int) bool {
+	if from == to {
+		return validType(from)
+	}
+	if !validType(from) || !validType(to) {
+		return false
+	}
+	if isIntegerType(from) {
+		lo, hi := typeRange(from)
+		if isIntegerType(to) {
+			to_lo, to_hi := typeRange(to)
+			return lo >= to_lo && hi <= to_hi
+		}
+		/* floats represent integers exactly up to 2^24 and 2^53 */
+		return to == PLY_DOUBLE || TypeSize(from) <= 2
+	}
+	return from == PLY_FLOAT && to == PLY_DOUBLE
+}
@@ -0,0 +1,133 @@
+package plyfile
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const meshlabSchema = `
+element vertex
+property float x
+property float y
+property float z
+element face
+property list uchar int vertex_indices
+property list uchar float texcoord
+`
+
+/* TestExpectSchema checks a MeshLab file, read by both the Go and the C readers, against schemas in each mode. */
+func TestExpectSchema(t *testing.T) {
+	const path = "testdata/golden/meshlab_texcoord.ply"
+	m, err := ReadMesh(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, _, err := OpenPlyFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c_header := f.Header()
+	f.Close()
+	if !reflect.DeepEqual(describeMesh(c_header), describeMesh(m)) {
+		t.Errorf("PlyFile.Header = %+v, want %+v", c_header, describeMesh(m))
+	}
+
+	exact, err := ParseSchema(meshlabSchema)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(exact, SchemaOf(describeMesh(m))) {
+		t.Errorf("ParseSchema differs from SchemaOf")
+	}
+	for _, test := range []struct {
+		schema string
+		mode   SchemaMode
+		diffs  []string
+	}{
+		{meshlabSchema, SchemaExact, nil},
+		{"element vertex\nproperty float x\nproperty float y\nproperty float z", SchemaSuperset, nil},
+		{"element vertex\nproperty float x\nproperty float y\nproperty float z", SchemaExact, []string{
+			"+ element 'face': element face"}},
+		{"element face 2\nproperty list uchar int vertex_indices\nproperty list uchar float texcoord\nelement vertex 4\nproperty float z\nproperty float y\nproperty float x",
+			SchemaExact, []string{
+				"~ element 'vertex': order x y z, want z y x",
+				"~ elements: order vertex face, want face vertex"}},
+		{"element vertex\nproperty float x\nproperty uchar red\nelement face\nproperty list uint uint vertex_indices", SchemaExact, []string{
+			"- property 'red' of element 'vertex': property uchar red",
+			"+ property 'y' of element 'vertex': property float y",
+			"+ property 'z' of element 'vertex': property float z",
+			"~ property 'vertex_indices' of element 'face': property list uchar int vertex_indices, want property list uint uint vertex_indices",
+			"+ property 'texcoord' of element 'face': property list uchar float texcoord"}},
+		{"element vertex\nproperty double x\nelement face\nproperty list int int vertex_indices\nelement edge", SchemaSuperset, []string{
+			"~ property 'x' of element 'vertex': property float x, want property double x",
+			"~ property 'vertex_indices' of element 'face': property list uchar int vertex_indices, want property list int int vertex_indices",
+			"- element 'edge': element edge"}},
+		{"element vertex\nproperty double x\nelement face\nproperty list int int vertex_indices\nproperty list uchar double texcoord", SchemaConvertible, nil},
+		{"element vertex\nproperty int x\nelement face\nproperty list uchar short vertex_indices\nproperty float texcoord", SchemaConvertible, []string{
+			"~ property 'x' of element 'vertex': property float x, want property int x",
+			"~ property 'vertex_indices' of element 'face': property list uchar int vertex_indices, want property list uchar short vertex_indices",
+			"~ property 'texcoord' of element 'face': property list uchar float texcoord, want property float texcoord"}},
+	} {
+		schema, err := ParseSchema(test.schema)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = ExpectSchema(m, schema, test.mode)
+		var diffs []string
+		if err != nil {
+			schema_err, ok := err.(*SchemaError)
+			if !ok {
+				t.Fatalf("error %T, want *SchemaError", err)
+			}
+			for _, d := range schema_err.Diffs {
+				diffs = append(diffs, d.String())
+			}
+			if !strings.HasPrefix(err.Error(), "plyfile: header doesn't match the schema:\n\t") {
+				t.Errorf("error %q", err)
+			}
+		}
+		if !reflect.DeepEqual(diffs, test.diffs) {
+			t.Errorf("%q mode %d: diffs\n%s\nwant\n%s", test.schema, test.mode, strings.Join(diffs, "\n"), strings.Join(test.diffs, "\n"))
+		}
+	}
+}
+
+func TestConvertsExactly(t *testing.T) {
+	for _, test := range []struct {
+		from, to int
+		want     bool
+	}{
+		{PLY_UCHAR, PLY_CHAR, false},
+		{PLY_UCHAR, PLY_SHORT, true},
+		{PLY_CHAR, PLY_UINT, false},
+		{PLY_USHORT, PLY_INT, true},
+		{PLY_UINT, PLY_INT, false},
+		{PLY_SHORT, PLY_FLOAT, true},
+		{PLY_INT, PLY_FLOAT, false},
+		{PLY_UINT, PLY_DOUBLE, true},
+		{PLY_FLOAT, PLY_DOUBLE, true},
+		{PLY_DOUBLE, PLY_FLOAT, false},
+		{PLY_FLOAT, PLY_INT, false},
+		{0, 0, false},
+	} {
+		if got := convertsExactly(test.from, test.to); got != test.want {
+			t.Errorf("convertsExactly(%s, %s) = %v", TypeName(test.from), TypeName(test.to), got)
+		}
+	}
+}
+
+func TestParseSchemaErrors(t *testing.T) {
+	for text, want := range map[string]string{
+		"property float x":                                   "plyfile: line 1: property before first element: 'property float x'",
+		"element vertex\nproperty float3 x":                  "plyfile: line 2: unknown type 'float3': 'property float3 x'",
+		"element vertex\nproperty float x\nproperty float x": "plyfile: line 3: property 'x' declared twice in element 'vertex'",
+		"element vertex\nelement vertex":                     "plyfile: line 2: element 'vertex' declared twice",
+		"element":                                            "plyfile: line 1: bad element line",
+		"comment x":                                          "plyfile: line 1: unknown keyword 'comment'",
+	} {
+		if _, err := ParseSchema(text); err == nil || !strings.HasPrefix(err.Error(), want) {
+			t.Errorf("ParseSchema(%q) = %v, want %q", text, err, want)
+		}
+	}
+}
@@ -0,0 +1,336 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package stl converts between STL files (ASCII and binary) and PLY meshes.
+
+STL stores every triangle with its own copy of its three corners. When reading, corners closer than a tolerance are welded into a single PLY vertex, giving the usual vertex and face elements. When writing, polygonal faces are triangulated and a facet normal is computed for every triangle.
+*/
+package stl
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+/* size of the header and of a single triangle of a binary STL file */
+const (
+	headerSize   = 80
+	triangleSize = 50
+)
+
+func init() {
+	plyfile.RegisterFormat("stl", sniff, Decode)
+}
+
+/* sniff reports whether prefix looks like the start of an ASCII or binary STL file. An ASCII file starts with "solid" and has facets. A binary file has a text (or zero padded) header followed by binary data, and its first triangle has finite coordinates. */
+func sniff(prefix []byte) bool {
+	trimmed := bytes.TrimLeft(prefix, " \t\r\n")
+	if bytes.HasPrefix(trimmed, []byte("solid")) && bytes.IndexByte(prefix, 0) < 0 &&
+		(bytes.Contains(prefix, []byte("facet")) || bytes.Contains(prefix, []byte("endsolid"))) {
+		return true
+	}
+
+	if len(prefix) < headerSize+4 || binary.LittleEndian.Uint32(prefix[headerSize:]) == 0 {
+		return false
+	}
+	for _, c := range prefix[:headerSize] {
+		if c != 0 && !isText(c) {
+			return false
+		}
+	}
+	binary_data := false
+	for _, c := range prefix[headerSize:minInt(len(prefix), headerSize+4+triangleSize)] {
+		binary_data = binary_data || !isText(c)
+	}
+	if !binary_data {
+		return false
+	}
+	if len(prefix) >= headerSize+4+triangleSize {
+		for k := 0; k < 12; k++ {
+			v := float64(math.Float32frombits(binary.LittleEndian.Uint32(prefix[headerSize+4+4*k:])))
+			if math.IsNaN(v) || math.IsInf(v, 0) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+func isText(c byte) bool {
+	return c >= 0x20 && c < 0x7f || c == '\t' || c == '\r' || c == '\n'
+}
+
+func minInt(a, b int) int {
+	if a < b {
+		return a
+	}
+	return b
+}
+
+/* Decode reads an ASCII or binary STL file from r, welding corners with identical coordinates. */
+func Decode(r io.Reader) (*plyfile.Mesh, error) {
+	return DecodeWeld(r, 0)
+}
+
+/* DecodeWeld reads an ASCII or binary STL file from r. Corners that are at most tolerance apart (in every coordinate) are welded into a single vertex. Triangles that collapse because of welding are dropped. */
+func DecodeWeld(r io.Reader, tolerance float64) (*plyfile.Mesh, error) {
+	data, err := io.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	var triangles [][3][3]float64
+	if isBinary(data) {
+		triangles, err = decodeBinary(data)
+	} else {
+		triangles, err = decodeASCII(data)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	w := newWelder(tolerance)
+	var faces [][]float64
+	for _, tri := range triangles {
+		a, b, c := w.vertex(tri[0]), w.vertex(tri[1]), w.vertex(tri[2])
+		if a == b || b == c || c == a {
+			continue
+		}
+		faces = append(faces, []float64{float64(a), float64(b), float64(c)})
+	}
+
+	m := plyfile.NewMesh(plyfile.PLY_BINARY_LE)
+	vertex := m.AddElement("vertex", len(w.positions))
+	x := vertex.AddProperty("x", plyfile.PLY_FLOAT)
+	y := vertex.AddProperty("y", plyfile.PLY_FLOAT)
+	z := vertex.AddProperty("z", plyfile.PLY_FLOAT)
+	for i, p := range w.positions {
+		x.Data[i], y.Data[i], z.Data[i] = p[0], p[1], p[2]
+	}
+	face := m.AddElement("face", len(faces))
+	face.AddListProperty("vertex_indices", plyfile.PLY_UCHAR, plyfile.PLY_INT).Lists = faces
+	return m, nil
+}
+
+/* isBinary reports whether data is a binary STL file. ASCII files start with "solid", but so do some binary files, so the size given by the triangle count is checked first. */
+func isBinary(data []byte) bool {
+	if len(data) >= headerSize+4 {
+		n := binary.LittleEndian.Uint32(data[headerSize:])
+		if uint64(len(data)) == headerSize+4+uint64(n)*triangleSize {
+			return true
+		}
+	}
+	return !bytes.HasPrefix(bytes.TrimLeft(data, " \t\r\n"), []byte("solid"))
+}
+
+func decodeBinary(data []byte) ([][3][3]float64, error) {
+	if len(data) < headerSize+4 {
+		return nil, errors.New("stl: file too short")
+	}
+	n := binary.LittleEndian.Uint32(data[headerSize:])
+	data = data[headerSize+4:]
+	if uint64(len(data)) < uint64(n)*triangleSize {
+		return nil, fmt.Errorf("stl: file too short for %d triangles", n)
+	}
+
+	triangles := make([][3][3]float64, n)
+	for i := range triangles {
+		/* skip the normal, read the corners */
+		t := data[i*triangleSize+12:]
+		for j := 0; j < 3; j++ {
+			for k := 0; k < 3; k++ {
+				bits := binary.LittleEndian.Uint32(t[(j*3+k)*4:])
+				triangles[i][j][k] = float64(math.Float32frombits(bits))
+			}
+		}
+	}
+	return triangles, nil
+}
+
+func decodeASCII(data []byte) ([][3][3]float64, error) {
+	var triangles [][3][3]float64
+	var tri [3][3]float64
+	corners := 0
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line_num := 1; scanner.Scan(); line_num++ {
+		words := strings.Fields(scanner.Text())
+		if len(words) == 0 {
+			continue
+		}
+		switch words[0] {
+		case "vertex":
+			if len(words) != 4 || corners == 3 {
+				return nil, fmt.Errorf("stl: line %d: bad vertex", line_num)
+			}
+			for k := 0; k < 3; k++ {
+				v, err := strconv.ParseFloat(words[k+1], 64)
+				if err != nil {
+					return nil, fmt.Errorf("stl: line %d: bad coordinate '%s'", line_num, words[k+1])
+				}
+				tri[corners][k] = v
+			}
+			corners++
+		case "endloop":
+			if corners != 3 {
+				return nil, fmt.Errorf("stl: line %d: facet with %d vertices", line_num, corners)
+			}
+			triangles = append(triangles, tri)
+			corners = 0
+		case "solid", "facet", "outer", "endfacet", "endsolid":
+		default:
+			return nil, fmt.Errorf("stl: line %d: unexpected '%s'", line_num, words[0])
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	if corners != 0 {
+		return nil, errors.New("stl: unexpected end of file")
+	}
+	return triangles, nil
+}
+
+/* welder merges positions that are within a tolerance of each other, using a grid with cells of the tolerance size. */
+type welder struct {
+	tolerance float64
+	positions [][3]float64
+	cells     map[[3]int64][]int
+	exact     map[[3]float64]int
+}
+
+func newWelder(tolerance float64) *welder {
+	return &welder{tolerance: tolerance, cells: make(map[[3]int64][]int), exact: make(map[[3]float64]int)}
+}
+
+/* vertex returns the index of the vertex for position p, adding a new vertex if no existing one is close enough. */
+func (w *welder) vertex(p [3]float64) int {
+	if w.tolerance <= 0 {
+		if i, ok := w.exact[p]; ok {
+			return i
+		}
+		w.exact[p] = len(w.positions)
+		w.positions = append(w.positions, p)
+		return len(w.positions) - 1
+	}
+
+	var cell [3]int64
+	for k := range cell {
+		cell[k] = int64(math.Floor(p[k] / w.tolerance))
+	}
+	for dx := int64(-1); dx <= 1; dx++ {
+		for dy := int64(-1); dy <= 1; dy++ {
+			for dz := int64(-1); dz <= 1; dz++ {
+				for _, i := range w.cells[[3]int64{cell[0] + dx, cell[1] + dy, cell[2] + dz}] {
+					q := w.positions[i]
+					if math.Abs(p[0]-q[0]) <= w.tolerance && math.Abs(p[1]-q[1]) <= w.tolerance && math.Abs(p[2]-q[2]) <= w.tolerance {
+						return i
+					}
+				}
+			}
+		}
+	}
+	w.cells[cell] = append(w.cells[cell], len(w.positions))
+	w.positions = append(w.positions, p)
+	return len(w.positions) - 1
+}
+
+/* triangles returns the corners and facet normals of every triangle of the mesh. */
+func triangles(m *plyfile.Mesh) ([][3][3]float64, [][3]float64, error) {
+	positions, err := m.Positions()
+	if err != nil {
+		return nil, nil, fmt.Errorf("stl: %v", err)
+	}
+	tris, err := m.Triangles()
+	if err != nil {
+		return nil, nil, fmt.Errorf("stl: %v", err)
+	}
+	corners := make([][3][3]float64, len(tris))
+	normals := make([][3]float64, len(tris))
+	for i, tri := range tris {
+		corners[i] = [3][3]float64{positions[tri[0]], positions[tri[1]], positions[tri[2]]}
+		normals[i] = plyfile.TriangleNormal(corners[i][0], corners[i][1], corners[i][2])
+	}
+	return corners, normals, nil
+}
+
+/* Encode writes the faces of a PLY mesh to w as a binary STL file. */
+func Encode(w io.Writer, m *plyfile.Mesh) error {
+	corners, normals, err := triangles(m)
+	if err != nil {
+		return err
+	}
+	if uint64(len(corners)) > math.MaxUint32 {
+		return errors.New("stl: too many triangles")
+	}
+
+	bw := bufio.NewWriter(w)
+	var header [headerSize + 4]byte
+	copy(header[:], "binary STL written by go-plyfile")
+	binary.LittleEndian.PutUint32(header[headerSize:], uint32(len(corners)))
+	bw.Write(header[:])
+
+	var buf [triangleSize]byte
+	for i, tri := range corners {
+		values := [12]float64{normals[i][0], normals[i][1], normals[i][2]}
+		for j := 0; j < 3; j++ {
+			copy(values[3+j*3:], tri[j][:])
+		}
+		for k, v := range values {
+			binary.LittleEndian.PutUint32(buf[k*4:], math.Float32bits(float32(v)))
+		}
+		if _, err := bw.Write(buf[:]); err != nil {
+			return err
+		}
+	}
+	return bw.Flush()
+}
+
+/* EncodeASCII writes the faces of a PLY mesh to w as an ASCII STL file, using name as the name of the solid. */
+func EncodeASCII(w io.Writer, m *plyfile.Mesh, name string) error {
+	corners, normals, err := triangles(m)
+	if err != nil {
+		return err
+	}
+
+	bw := bufio.NewWriter(w)
+	fmt.Fprintf(bw, "solid %s\n", name)
+	for i, tri := range corners {
+		fmt.Fprintf(bw, "  facet normal %s\n    outer loop\n", formatVector(normals[i]))
+		for _, p := range tri {
+			fmt.Fprintf(bw, "      vertex %s\n", formatVector(p))
+		}
+		bw.WriteString("    endloop\n  endfacet\n")
+	}
+	fmt.Fprintf(bw, "endsolid %s\n", name)
+	return bw.Flush()
+}
+
+func formatVector(v [3]float64) string {
+	return strconv.FormatFloat(float64(float32(v[0])), 'e', -1, 32) + " " +
+		strconv.FormatFloat(float64(float32(v[1])), 'e', -1, 32) + " " +
+		strconv.FormatFloat(float64(float32(v[2])), 'e', -1, 32)
+}
@@ -0,0 +1,133 @@
+package stl
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+/* cube returns a unit cube with quadrilateral faces. */
+func cube() *plyfile.Mesh {
+	m := plyfile.NewMesh(plyfile.PLY_BINARY_LE)
+	vertex := m.AddElement("vertex", 8)
+	x := vertex.AddProperty("x", plyfile.PLY_FLOAT)
+	y := vertex.AddProperty("y", plyfile.PLY_FLOAT)
+	z := vertex.AddProperty("z", plyfile.PLY_FLOAT)
+	for i := 0; i < 8; i++ {
+		x.Data[i], y.Data[i], z.Data[i] = float64(i&1), float64(i>>1&1), float64(i>>2&1)
+	}
+	face := m.AddElement("face", 6)
+	face.AddListProperty("vertex_indices", plyfile.PLY_UCHAR, plyfile.PLY_INT).Lists = [][]float64{
+		{0, 2, 3, 1}, {4, 5, 7, 6}, {0, 1, 5, 4}, {2, 6, 7, 3}, {0, 4, 6, 2}, {1, 3, 7, 5},
+	}
+	return m
+}
+
+func TestRoundTrip(t *testing.T) {
+	encoders := map[string]func(*bytes.Buffer, *plyfile.Mesh) error{
+		"binary": func(buf *bytes.Buffer, m *plyfile.Mesh) error { return Encode(buf, m) },
+		"ascii":  func(buf *bytes.Buffer, m *plyfile.Mesh) error { return EncodeASCII(buf, m, "cube") },
+	}
+	for name, encode := range encoders {
+		var buf bytes.Buffer
+		if err := encode(&buf, cube()); err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+		m, err := Decode(&buf)
+		if err != nil {
+			t.Fatalf("%s: %v", name, err)
+		}
+
+		/* all 36 corners of the 12 triangles are welded back into the 8 corners of the cube */
+		if n := m.Element("vertex").Count; n != 8 {
+			t.Errorf("%s: %d vertices, want 8", name, n)
+		}
+		tris, err := m.Triangles()
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(tris) != 12 {
+			t.Errorf("%s: %d triangles, want 12", name, len(tris))
+		}
+	}
+}
+
+func TestNormals(t *testing.T) {
+	var buf bytes.Buffer
+	if err := EncodeASCII(&buf, cube(), "cube"); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), "facet normal 0e+00 0e+00 -1e+00") {
+		t.Errorf("missing bottom face normal:\n%s", buf.String())
+	}
+}
+
+func TestWeldTolerance(t *testing.T) {
+	const file = `solid t
+facet normal 0 0 1
+outer loop
+vertex 0 0 0
+vertex 1 0 0
+vertex 0 1 0
+endloop
+endfacet
+facet normal 0 0 1
+outer loop
+vertex 1.0001 0 0
+vertex 1 1 0
+vertex 0 1.0001 0
+endloop
+endfacet
+endsolid t
+`
+	for _, tc := range []struct {
+		tolerance float64
+		nverts    int
+	}{{0, 6}, {0.001, 4}} {
+		m, err := DecodeWeld(strings.NewReader(file), tc.tolerance)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if n := m.Element("vertex").Count; n != tc.nverts {
+			t.Errorf("tolerance %g: %d vertices, want %d", tc.tolerance, n, tc.nverts)
+		}
+	}
+
+	m, _ := DecodeWeld(strings.NewReader(file), 0.001)
+	faces := m.Element("face").Property("vertex_indices").Lists
+	if want := [][]float64{{0, 1, 2}, {1, 3, 2}}; !reflect.DeepEqual(faces, want) {
+		t.Errorf("faces = %v, want %v", faces, want)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	files := []string{
+		"solid t\nfacet normal 0 0 1\nouter loop\nvertex 0 0 0\nvertex 1 0 0\nendloop\n",
+		"solid t\nfacet normal 0 0 1\nouter loop\nvertex 0 0 x\n",
+		"\x00\x01",
+	}
+	for _, file := range files {
+		if _, err := Decode(strings.NewReader(file)); err == nil {
+			t.Errorf("expected an error for %q", file)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	for _, encode := range []func(*bytes.Buffer) error{
+		func(buf *bytes.Buffer) error { return Encode(buf, cube()) },
+		func(buf *bytes.Buffer) error { return EncodeASCII(buf, cube(), "cube") },
+	} {
+		var buf bytes.Buffer
+		if err := encode(&buf); err != nil {
+			f.Fatal(err)
+		}
+		f.Add(buf.Bytes(), 0.0)
+	}
+	f.Fuzz(func(t *testing.T, data []byte, tolerance float64) {
+		DecodeWeld(bytes.NewReader(data), tolerance)
+	})
+}
@@ -0,0 +1,209 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plyfile
+
+import (
+	"fmt"
+	"path/filepath"
+)
+
+/* Textured meshes name their images in "TextureFile <path>" comments, and hold texture coordinates either per vertex (s and t, u and v, or texture_u and texture_v properties) or per face corner, as MeshLab writes them: a "texcoord" list of u, v pairs on the face element, with an optional "texnumber" property giving the texture of each face. */
+const (
+	TexCoordProperty  = "texcoord"  /* face list of u, v pairs, one pair per vertex of the face */
+	TexNumberProperty = "texnumber" /* face index into the TextureFile comments */
+)
+
+/* vertex texture coordinate names, in order of preference */
+var vertexTexCoordNames = [][2]string{{"s", "t"}, {"u", "v"}, {"texture_u", "texture_v"}}
+
+/* TextureFiles returns the texture images named by the TextureFile comments of the mesh, as written in the file. */
+func (m *Mesh) TextureFiles() []string {
+	return m.Metadata().TextureFiles()
+}
+
+/* TexturePaths returns the texture images of the mesh with relative paths resolved against the directory of the PLY file at ply_path. */
+func (m *Mesh) TexturePaths(ply_path string) []string {
+	files := m.TextureFiles()
+	paths := make([]string, len(files))
+	for i, file := range files {
+		file = filepath.FromSlash(file)
+		if !filepath.IsAbs(file) {
+			file = filepath.Join(filepath.Dir(ply_path), file)
+		}
+		paths[i] = filepath.Clean(file)
+	}
+	return paths
+}
+
+/* SetTextureFiles replaces the TextureFile comments of the mesh, in the place of the first one. */
+func (m *Mesh) SetTextureFiles(files []string) {
+	md := m.Metadata()
+	var lines []*metadataLine
+	placed := false
+	for _, line := range md.lines {
+		if line.key != MetaTextureFile {
+			lines = append(lines, line)
+			continue
+		}
+		if !placed {
+			for _, file := range files {
+				lines = append(lines, &metadataLine{key: MetaTextureFile, value: file})
+			}
+			placed = true
+		}
+	}
+	md.lines = lines
+	if !placed {
+		for _, file := range files {
+			md.Add(MetaTextureFile, file)
+		}
+	}
+	m.SetMetadata(md)
+}
+
+/* VertexTexCoords returns the per vertex texture coordinates, s and t, u and v, or texture_u and texture_v, or nil if the vertex element has none. */
+func (m *Mesh) VertexTexCoords() (*Property, *Property) {
+	vertex := m.Element("vertex")
+	if vertex == nil {
+		return nil, nil
+	}
+	for _, names := range vertexTexCoordNames {
+		u, v := vertex.Property(names[0]), vertex.Property(names[1])
+		if u != nil && v != nil && !u.IsList && !v.IsList {
+			return u, v
+		}
+	}
+	return nil, nil
+}
+
+/* FaceTexCoords returns the texcoord list of the face element, or nil if there is none. */
+func (m *Mesh) FaceTexCoords() *Property {
+	face := m.Element("face")
+	if face == nil {
+		return nil
+	}
+	if prop := face.Property(TexCoordProperty); prop != nil && prop.IsList {
+		return prop
+	}
+	return nil
+}
+
+/* ToVertexTexCoords moves the texcoord lists of the faces to s and t float properties of the vertices, replacing any vertex texture coordinates. A vertex used with different texture coordinates by different faces is duplicated, so other elements referring to vertices by index, e.g. edges, may refer to only one of the copies. Vertices used by no face get 0, 0. It does nothing if the faces have no texcoord lists. */
+func (m *Mesh) ToVertexTexCoords() error {
+	texcoord := m.FaceTexCoords()
+	if texcoord == nil {
+		return nil
+	}
+	vertex := m.Element("vertex")
+	if vertex == nil {
+		return fmt.Errorf("plyfile: mesh has no vertex element to hold texture coordinates")
+	}
+	faces, err := m.Faces()
+	if err != nil {
+		return err
+	}
+	if len(texcoord.Lists) != len(faces) {
+		return fmt.Errorf("plyfile: %d texcoord lists for %d faces", len(texcoord.Lists), len(faces))
+	}
+	for i, face := range faces {
+		if len(texcoord.Lists[i]) != 2*len(face) {
+			return fmt.Errorf("plyfile: face %d has %d texcoord values for %d vertices", i, len(texcoord.Lists[i]), len(face))
+		}
+	}
+
+	indices := m.Element("face").FindProperty("vertex_indices", "vertex_index")
+	s, t := make([]float64, vertex.Count), make([]float64, vertex.Count)
+	assigned := make([]bool, vertex.Count)
+	copies := map[[3]float64]int{}
+	for i, face := range faces {
+		uv := texcoord.Lists[i]
+		for j, k := range face {
+			u, v := uv[2*j], uv[2*j+1]
+			switch {
+			case !assigned[k]:
+				s[k], t[k], assigned[k] = u, v, true
+			case s[k] == u && t[k] == v:
+			default:
+				key := [3]float64{float64(k), u, v}
+				c, ok := copies[key]
+				if !ok {
+					c = vertex.duplicate(k)
+					s, t = append(s, u), append(t, v)
+					copies[key] = c
+				}
+				indices.Lists[i][j] = float64(c)
+			}
+		}
+	}
+
+	if u, v := m.VertexTexCoords(); u != nil {
+		vertex.RemoveProperty(u.Name)
+		vertex.RemoveProperty(v.Name)
+	}
+	copy(vertex.AddProperty("s", PLY_FLOAT).Data, s)
+	copy(vertex.AddProperty("t", PLY_FLOAT).Data, t)
+	m.Element("face").RemoveProperty(texcoord.Name)
+	return nil
+}
+
+/* ToFaceTexCoords moves the vertex texture coordinates to a texcoord list of float u, v pairs on each face, as MeshLab writes them. It does nothing if the vertices have no texture coordinates. */
+func (m *Mesh) ToFaceTexCoords() error {
+	u, v := m.VertexTexCoords()
+	if u == nil {
+		return nil
+	}
+	face := m.Element("face")
+	if face == nil {
+		return fmt.Errorf("plyfile: mesh has no face element to hold texcoord lists")
+	}
+	faces, err := m.Faces()
+	if err != nil {
+		return err
+	}
+	count_type := PLY_UCHAR
+	for _, f := range faces {
+		if 2*len(f) > 255 {
+			count_type = PLY_INT
+		}
+	}
+	face.RemoveProperty(TexCoordProperty)
+	texcoord := face.AddListProperty(TexCoordProperty, count_type, PLY_FLOAT)
+	for i, f := range faces {
+		uv := make([]float64, 0, 2*len(f))
+		for _, k := range f {
+			uv = append(uv, u.Data[k], v.Data[k])
+		}
+		texcoord.Lists[i] = uv
+	}
+	vertex := m.Element("vertex")
+	vertex.RemoveProperty(u.Name)
+	vertex.RemoveProperty(v.Name)
+	return nil
+}
+
+/* duplicate appends a copy of element i and returns the index of the copy. */
+func (e *Element) duplicate(i int) int {
+	for _, prop := range e.Properties {
+		if prop.IsList {
+			prop.Lists = append(prop.Lists, append([]float64(nil), prop.Lists[i]...))
+		} else {
+			prop.Data = append(prop.Data, prop.Data[i])
+		}
+	}
+	e.Count++
+	return e.Count - 1
+}
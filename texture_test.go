@@ -0,0 +1,113 @@
+package plyfile
+
+import (
+	"bytes"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+/* texturedQuad returns two triangles sharing the edge 1-2, in MeshLab form: each face has its own texture coordinates, which differ at vertex 2. */
+func texturedQuad() *Mesh {
+	m := NewMesh(PLY_ASCII)
+	m.Comments = []string{"TextureFile tex/a.png", "TextureFile b.png"}
+	vertex := m.AddElement("vertex", 4)
+	copy(vertex.AddProperty("x", PLY_FLOAT).Data, []float64{0, 1, 1, 0})
+	copy(vertex.AddProperty("y", PLY_FLOAT).Data, []float64{0, 0, 1, 1})
+	vertex.AddProperty("z", PLY_FLOAT)
+	face := m.AddElement("face", 2)
+	face.AddListProperty("vertex_indices", PLY_UCHAR, PLY_INT).Lists = [][]float64{{0, 1, 2}, {0, 2, 3}}
+	face.AddListProperty("texcoord", PLY_UCHAR, PLY_FLOAT).Lists = [][]float64{{0, 0, 0.5, 0, 0.5, 0.5}, {0, 0, 0.25, 0.75, 0, 1}}
+	copy(face.AddProperty("texnumber", PLY_INT).Data, []float64{0, 1})
+	return m
+}
+
+func TestTexturePaths(t *testing.T) {
+	m := texturedQuad()
+	if got := m.TextureFiles(); !reflect.DeepEqual(got, []string{"tex/a.png", "b.png"}) {
+		t.Errorf("TextureFiles = %q", got)
+	}
+	want := []string{filepath.Join("scans", "tex", "a.png"), filepath.Join("scans", "b.png")}
+	if got := m.TexturePaths(filepath.Join("scans", "quad.ply")); !reflect.DeepEqual(got, want) {
+		t.Errorf("TexturePaths = %q, want %q", got, want)
+	}
+
+	m.Comments = []string{"made by a scanner", "TextureFile a.png", "scale 2", "TextureFile b.png"}
+	m.SetTextureFiles([]string{"c.png", "d.png"})
+	if want := []string{"made by a scanner", "TextureFile c.png", "TextureFile d.png", "scale 2"}; !reflect.DeepEqual(m.Comments, want) {
+		t.Errorf("SetTextureFiles comments %q, want %q", m.Comments, want)
+	}
+}
+
+func TestTexCoords(t *testing.T) {
+	m := texturedQuad()
+	if err := m.ToVertexTexCoords(); err != nil {
+		t.Fatal(err)
+	}
+	if m.FaceTexCoords() != nil {
+		t.Errorf("texcoord lists kept")
+	}
+	/* vertex 2 is used with (0.5, 0.5) and (0.25, 0.75), so it is copied as vertex 4 */
+	vertex := m.Element("vertex")
+	u, v := m.VertexTexCoords()
+	if vertex.Count != 5 || u.Name != "s" || !reflect.DeepEqual(u.Data, []float64{0, 0.5, 0.5, 0, 0.25}) || !reflect.DeepEqual(v.Data, []float64{0, 0, 0.5, 1, 0.75}) {
+		t.Errorf("%d vertices, s %v, t %v", vertex.Count, u.Data, v.Data)
+	}
+	if got := vertex.Property("x").Data; !reflect.DeepEqual(got, []float64{0, 1, 1, 0, 1}) {
+		t.Errorf("x %v", got)
+	}
+	if got := m.Element("face").Property("vertex_indices").Lists; !reflect.DeepEqual(got, [][]float64{{0, 1, 2}, {0, 4, 3}}) {
+		t.Errorf("faces %v", got)
+	}
+
+	/* back to lists, which read as they were written */
+	if err := m.ToFaceTexCoords(); err != nil {
+		t.Fatal(err)
+	}
+	if u, _ := m.VertexTexCoords(); u != nil {
+		t.Errorf("vertex texture coordinates kept")
+	}
+	var buf bytes.Buffer
+	if err := EncodeMesh(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	got, err := DecodeMesh(&buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lists := got.FaceTexCoords().Lists; !reflect.DeepEqual(lists, texturedQuad().FaceTexCoords().Lists) {
+		t.Errorf("texcoord lists %v", lists)
+	}
+
+	bad := texturedQuad()
+	bad.FaceTexCoords().Lists[1] = []float64{0, 0}
+	if err := bad.ToVertexTexCoords(); err == nil || bad.Element("vertex").Count != 4 {
+		t.Errorf("ToVertexTexCoords with a short texcoord list = %v", err)
+	}
+	bad = texturedQuad()
+	bad.FaceTexCoords().Lists = bad.FaceTexCoords().Lists[:1]
+	if err := bad.ToVertexTexCoords(); err == nil || bad.Element("vertex").Count != 4 {
+		t.Errorf("ToVertexTexCoords with a missing texcoord list = %v", err)
+	}
+	bad = NewMesh(PLY_ASCII)
+	face := bad.AddElement("face", 1)
+	face.AddListProperty("vertex_indices", PLY_UCHAR, PLY_INT)
+	face.AddListProperty(TexCoordProperty, PLY_UCHAR, PLY_FLOAT)
+	if err := bad.ToVertexTexCoords(); err == nil {
+		t.Errorf("ToVertexTexCoords without vertices succeeded")
+	}
+}
+
+func TestClone(t *testing.T) {
+	m := texturedQuad()
+	c := m.Clone()
+	if !reflect.DeepEqual(c, m) {
+		t.Fatalf("clone differs")
+	}
+	c.Comments[0] = "changed"
+	c.Element("vertex").Property("x").Data[0] = 9
+	c.FaceTexCoords().Lists[0][0] = 9
+	if !reflect.DeepEqual(m, texturedQuad()) {
+		t.Errorf("changing the clone changed the mesh")
+	}
+}
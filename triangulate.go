@@ -0,0 +1,198 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plyfile
+
+import (
+	"fmt"
+	"math"
+)
+
+/* Positions returns the x, y and z properties of the vertex element. */
+func (m *Mesh) Positions() ([][3]float64, error) {
+	vertex := m.Element("vertex")
+	if vertex == nil {
+		return nil, fmt.Errorf("plyfile: mesh has no vertex element")
+	}
+	x, y, z := vertex.Property("x"), vertex.Property("y"), vertex.Property("z")
+	if x == nil || y == nil || z == nil || x.IsList || y.IsList || z.IsList {
+		return nil, fmt.Errorf("plyfile: vertex element has no x, y and z properties")
+	}
+	positions := make([][3]float64, vertex.Count)
+	for i := range positions {
+		positions[i] = [3]float64{x.Data[i], y.Data[i], z.Data[i]}
+	}
+	return positions, nil
+}
+
+/* Faces returns the vertex indices of every face, taken from the vertex_indices (or vertex_index) list of the face element. It returns nil if the mesh has no faces, and an error if an index is out of range. */
+func (m *Mesh) Faces() ([][]int, error) {
+	face := m.Element("face")
+	if face == nil {
+		return nil, nil
+	}
+	indices := face.FindProperty("vertex_indices", "vertex_index")
+	if indices == nil || !indices.IsList {
+		return nil, fmt.Errorf("plyfile: face element has no vertex_indices list")
+	}
+	nverts := 0
+	if vertex := m.Element("vertex"); vertex != nil {
+		nverts = vertex.Count
+	}
+	faces := make([][]int, len(indices.Lists))
+	for i, list := range indices.Lists {
+		faces[i] = make([]int, len(list))
+		for j, v := range list {
+			if v < 0 || int(v) >= nverts {
+				return nil, fmt.Errorf("plyfile: face %d: vertex index %v out of range", i, v)
+			}
+			faces[i][j] = int(v)
+		}
+	}
+	return faces, nil
+}
+
+/* Triangles returns the faces of the mesh split into triangles. Polygons are triangulated by ear clipping in the plane of the polygon, so concave faces are handled as well. Faces with less than three vertices are skipped. */
+func (m *Mesh) Triangles() ([][3]int, error) {
+	positions, err := m.Positions()
+	if err != nil {
+		return nil, err
+	}
+	faces, err := m.Faces()
+	if err != nil {
+		return nil, err
+	}
+	var triangles [][3]int
+	for _, face := range faces {
+		triangles = Triangulate(triangles, face, positions)
+	}
+	return triangles, nil
+}
+
+/* Triangulate appends the triangles of a single polygon, given as indices into positions, to triangles and returns the extended slice. */
+func Triangulate(triangles [][3]int, polygon []int, positions [][3]float64) [][3]int {
+	n := len(polygon)
+	switch {
+	case n < 3:
+		return triangles
+	case n == 3:
+		return append(triangles, [3]int{polygon[0], polygon[1], polygon[2]})
+	}
+
+	/* project the polygon onto the plane most perpendicular to its (Newell) normal */
+	normal := polygonNormal(polygon, positions)
+	u, v := 0, 1
+	switch ax, ay, az := math.Abs(normal[0]), math.Abs(normal[1]), math.Abs(normal[2]); {
+	case ax >= ay && ax >= az:
+		u, v = 1, 2
+	case ay >= az:
+		u, v = 2, 0
+	}
+	if normal[3-u-v] < 0 {
+		u, v = v, u
+	}
+	if normal == ([3]float64{}) {
+		return fan(triangles, polygon)
+	}
+	pts := make([][2]float64, n)
+	for i, index := range polygon {
+		pts[i] = [2]float64{positions[index][u], positions[index][v]}
+	}
+
+	/* the projected polygon is counter-clockwise; clip convex corners that contain no other vertex */
+	remaining := make([]int, n)
+	for i := range remaining {
+		remaining[i] = i
+	}
+	for len(remaining) > 3 {
+		clipped := false
+		for i := range remaining {
+			a := remaining[(i+len(remaining)-1)%len(remaining)]
+			b := remaining[i]
+			c := remaining[(i+1)%len(remaining)]
+			if cross(pts[a], pts[b], pts[c]) <= 0 {
+				continue
+			}
+			ear := true
+			for _, p := range remaining {
+				if p != a && p != b && p != c && insideTriangle(pts[p], pts[a], pts[b], pts[c]) {
+					ear = false
+					break
+				}
+			}
+			if ear {
+				triangles = append(triangles, [3]int{polygon[a], polygon[b], polygon[c]})
+				remaining = append(remaining[:i], remaining[i+1:]...)
+				clipped = true
+				break
+			}
+		}
+		if !clipped {
+			/* self intersecting or degenerate polygon */
+			rest := make([]int, len(remaining))
+			for i, p := range remaining {
+				rest[i] = polygon[p]
+			}
+			return fan(triangles, rest)
+		}
+	}
+	return append(triangles, [3]int{polygon[remaining[0]], polygon[remaining[1]], polygon[remaining[2]]})
+}
+
+/* fan triangulates a polygon around its first vertex. */
+func fan(triangles [][3]int, polygon []int) [][3]int {
+	for i := 1; i+1 < len(polygon); i++ {
+		triangles = append(triangles, [3]int{polygon[0], polygon[i], polygon[i+1]})
+	}
+	return triangles
+}
+
+/* polygonNormal computes the (unnormalized) normal of a polygon using Newell's method. */
+func polygonNormal(polygon []int, positions [][3]float64) [3]float64 {
+	var normal [3]float64
+	for i, index := range polygon {
+		p := positions[index]
+		q := positions[polygon[(i+1)%len(polygon)]]
+		normal[0] += (p[1] - q[1]) * (p[2] + q[2])
+		normal[1] += (p[2] - q[2]) * (p[0] + q[0])
+		normal[2] += (p[0] - q[0]) * (p[1] + q[1])
+	}
+	return normal
+}
+
+func cross(a, b, c [2]float64) float64 {
+	return (b[0]-a[0])*(c[1]-a[1]) - (b[1]-a[1])*(c[0]-a[0])
+}
+
+func insideTriangle(p, a, b, c [2]float64) bool {
+	return cross(a, b, p) >= 0 && cross(b, c, p) >= 0 && cross(c, a, p) >= 0
+}
+
+/* TriangleNormal returns the unit normal of a counter-clockwise triangle, or the zero vector for a degenerate triangle. */
+func TriangleNormal(a, b, c [3]float64) [3]float64 {
+	e1 := [3]float64{b[0] - a[0], b[1] - a[1], b[2] - a[2]}
+	e2 := [3]float64{c[0] - a[0], c[1] - a[1], c[2] - a[2]}
+	n := [3]float64{
+		e1[1]*e2[2] - e1[2]*e2[1],
+		e1[2]*e2[0] - e1[0]*e2[2],
+		e1[0]*e2[1] - e1[1]*e2[0],
+	}
+	length := math.Sqrt(n[0]*n[0] + n[1]*n[1] + n[2]*n[2])
+	if length == 0 {
+		return [3]float64{}
+	}
+	return [3]float64{n[0] / length, n[1] / length, n[2] / length}
+}
@@ -0,0 +1,118 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+package plyfile
+
+import (
+	"math"
+)
+
+/* Sized names of the PLY_* types, for use with HeaderBuilder. */
+const (
+	Int8    = PLY_CHAR
+	Int16   = PLY_SHORT
+	Int32   = PLY_INT
+	Uint8   = PLY_UCHAR
+	Uint16  = PLY_USHORT
+	Uint32  = PLY_UINT
+	Float32 = PLY_FLOAT
+	Float64 = PLY_DOUBLE
+)
+
+/* Names of the PLY_ASCII, PLY_BINARY_BE and PLY_BINARY_LE file formats. */
+const (
+	FormatASCII    = PLY_ASCII
+	FormatBinaryBE = PLY_BINARY_BE
+	FormatBinaryLE = PLY_BINARY_LE
+)
+
+/* type names used in PLY headers, indexed by PLY_* type (same as type_names in lib/plyfile.c) */
+var typeNames = [...]string{
+	"invalid",
+	"char", "short", "int",
+	"uchar", "ushort", "uint",
+	"float", "double",
+}
+
+/* sized type names written by newer PLY producers, accepted when reading (same as type_aliases in lib/plyfile.c) */
+var typeAliases = map[string]int{
+	"int8":    PLY_CHAR,
+	"int16":   PLY_SHORT,
+	"int32":   PLY_INT,
+	"uint8":   PLY_UCHAR,
+	"uint16":  PLY_USHORT,
+	"uint32":  PLY_UINT,
+	"float32": PLY_FLOAT,
+	"float64": PLY_DOUBLE,
+}
+
+/* size in bytes of each PLY_* type (same as ply_type_size in lib/plyfile.c) */
+var typeSizes = [...]int{0, 1, 2, 4, 1, 2, 4, 4, 8}
+
+/* TypeName returns the header name of a PLY_* type, or "invalid" for an unknown type. */
+func TypeName(typ int) string {
+	if !validType(typ) {
+		return typeNames[0]
+	}
+	return typeNames[typ]
+}
+
+/* TypeSize returns the size in bytes of a PLY_* type, or 0 for an unknown type. */
+func TypeSize(typ int) int {
+	if !validType(typ) {
+		return 0
+	}
+	return typeSizes[typ]
+}
+
+/* ParseType returns the PLY_* type for a header type name, or 0 if the name is unknown. Both the original names (uchar, float, ...) and the sized names (uint8, float32, ...) are accepted. */
+func ParseType(name string) int {
+	for i := PLY_START_TYPE + 1; i < PLY_END_TYPE; i++ {
+		if typeNames[i] == name {
+			return i
+		}
+	}
+	return typeAliases[name]
+}
+
+func validType(typ int) bool {
+	return typ > PLY_START_TYPE && typ < PLY_END_TYPE
+}
+
+func isIntegerType(typ int) bool {
+	return validType(typ) && typ != PLY_FLOAT && typ != PLY_DOUBLE
+}
+
+/* typeRange returns the smallest and largest value representable by an integer PLY_* type. */
+func typeRange(typ int) (float64, float64) {
+	switch typ {
+	case PLY_CHAR:
+		return math.MinInt8, math.MaxInt8
+	case PLY_SHORT:
+		return math.MinInt16, math.MaxInt16
+	case PLY_INT:
+		return math.MinInt32, math.MaxInt32
+	case PLY_UCHAR:
+		return 0, math.MaxUint8
+	case PLY_USHORT:
+		return 0, math.MaxUint16
+	case PLY_UINT:
+		return 0, math.MaxUint32
+	case PLY_FLOAT:
+		return -math.MaxFloat32, math.MaxFloat32
+	}
+	return -math.MaxFloat64, math.MaxFloat64
+}
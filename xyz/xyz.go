@@ -0,0 +1,209 @@
+/*
+Copyright 2016 Alex Baden
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+    http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+
+/*
+Package xyz converts between XYZ point files and the vertex element of PLY meshes.
+
+An XYZ file has one point per line, with columns separated by spaces, tabs, commas or semicolons. Every line must have the same number of columns. The first three columns are x, y and z (stored as double). The remaining columns are mapped by their number:
+
+	x y z i                   intensity (float)
+	x y z r g b               red, green, blue (uchar) if every value is an integer from 0 to 255, otherwise nx, ny, nz (float)
+	x y z r g b nx ny nz      red, green, blue and nx, ny, nz
+
+Any other columns become double properties named scalar_1, scalar_2 and so on. Lines starting with # or // are kept as comments.
+*/
+package xyz
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+func init() {
+	plyfile.RegisterFormat("xyz", sniff, Decode)
+}
+
+/* sniff reports whether the first line of prefix (after comments) has at least three numeric columns. */
+func sniff(prefix []byte) bool {
+	lines := strings.Split(string(prefix), "\n")
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			continue
+		}
+		if i == len(lines)-1 && len(prefix) == plyfile.SniffLen {
+			/* the line may be cut off */
+			return false
+		}
+		words := strings.FieldsFunc(line, isSeparator)
+		if len(words) < 3 {
+			return false
+		}
+		for _, word := range words {
+			if _, err := strconv.ParseFloat(word, 64); err != nil {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+/* Decode reads an XYZ file from r and returns its points as the vertex element of a PLY mesh. */
+func Decode(r io.Reader) (*plyfile.Mesh, error) {
+	var columns [][]float64
+	var comments []string
+
+	br := bufio.NewReader(r)
+	for line_num := 1; ; line_num++ {
+		line, err := br.ReadString('\n')
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+		if err == io.EOF && line == "" {
+			break
+		}
+
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") || strings.HasPrefix(line, "//") {
+			comments = append(comments, strings.TrimSpace(strings.TrimLeft(line, "#/")))
+			continue
+		}
+		words := strings.FieldsFunc(line, isSeparator)
+		if len(words) == 0 {
+			continue
+		}
+		if columns == nil {
+			if len(words) < 3 {
+				return nil, fmt.Errorf("xyz: line %d: less than 3 columns", line_num)
+			}
+			columns = make([][]float64, len(words))
+		}
+		if len(words) != len(columns) {
+			return nil, fmt.Errorf("xyz: line %d: %d columns, want %d", line_num, len(words), len(columns))
+		}
+		for k, word := range words {
+			v, err := strconv.ParseFloat(word, 64)
+			if err != nil {
+				return nil, fmt.Errorf("xyz: line %d: bad number '%s'", line_num, word)
+			}
+			columns[k] = append(columns[k], v)
+		}
+	}
+	if columns == nil {
+		return nil, errors.New("xyz: no points")
+	}
+
+	m := plyfile.NewMesh(plyfile.PLY_BINARY_LE)
+	m.Comments = comments
+	vertex := m.AddElement("vertex", len(columns[0]))
+	add := func(name string, typ int, data []float64) {
+		copy(vertex.AddProperty(name, typ).Data, data)
+	}
+	add("x", plyfile.PLY_DOUBLE, columns[0])
+	add("y", plyfile.PLY_DOUBLE, columns[1])
+	add("z", plyfile.PLY_DOUBLE, columns[2])
+
+	extra := columns[3:]
+	switch {
+	case len(extra) == 1:
+		add("intensity", plyfile.PLY_FLOAT, extra[0])
+	case len(extra) == 3 && isColor(extra):
+		add("red", plyfile.PLY_UCHAR, extra[0])
+		add("green", plyfile.PLY_UCHAR, extra[1])
+		add("blue", plyfile.PLY_UCHAR, extra[2])
+	case len(extra) == 3:
+		add("nx", plyfile.PLY_FLOAT, extra[0])
+		add("ny", plyfile.PLY_FLOAT, extra[1])
+		add("nz", plyfile.PLY_FLOAT, extra[2])
+	case len(extra) == 6 && isColor(extra[:3]):
+		add("red", plyfile.PLY_UCHAR, extra[0])
+		add("green", plyfile.PLY_UCHAR, extra[1])
+		add("blue", plyfile.PLY_UCHAR, extra[2])
+		add("nx", plyfile.PLY_FLOAT, extra[3])
+		add("ny", plyfile.PLY_FLOAT, extra[4])
+		add("nz", plyfile.PLY_FLOAT, extra[5])
+	default:
+		for k, data := range extra {
+			add("scalar_"+strconv.Itoa(k+1), plyfile.PLY_DOUBLE, data)
+		}
+	}
+	return m, nil
+}
+
+func isSeparator(r rune) bool {
+	return r == ' ' || r == '\t' || r == ',' || r == ';' || r == '\r'
+}
+
+/* isColor reports whether every value of the columns is an integer from 0 to 255. */
+func isColor(columns [][]float64) bool {
+	for _, column := range columns {
+		for _, v := range column {
+			if v < 0 || v > 255 || v != math.Trunc(v) {
+				return false
+			}
+		}
+	}
+	return true
+}
+
+/* Encode writes the vertex element of a PLY mesh to w as an XYZ file with space separated columns. Colors (red, green, blue) and normals (nx, ny, nz) are written when the vertex element has them; otherwise an intensity property is written if present. */
+func Encode(w io.Writer, m *plyfile.Mesh) error {
+	vertex := m.Element("vertex")
+	if vertex == nil {
+		return errors.New("xyz: mesh has no vertex element")
+	}
+	props := vertex.Scalars("x", "y", "z")
+	if props == nil {
+		return errors.New("xyz: vertex element has no x, y and z properties")
+	}
+	colors := vertex.Scalars("red", "green", "blue")
+	normals := vertex.Scalars("nx", "ny", "nz")
+	if colors == nil && normals == nil {
+		if intensity := vertex.Scalars("intensity"); intensity != nil {
+			props = append(props, intensity...)
+		}
+	}
+
+	bw := bufio.NewWriter(w)
+	for _, comment := range m.Comments {
+		fmt.Fprintf(bw, "# %s\n", comment)
+	}
+	var buf []byte
+	for i := 0; i < vertex.Count; i++ {
+		buf = buf[:0]
+		for _, prop := range props {
+			buf = prop.AppendText(buf, i)
+		}
+		for _, prop := range colors {
+			buf = strconv.AppendInt(buf, int64(prop.ColorByte(i)), 10)
+			buf = append(buf, ' ')
+		}
+		for _, prop := range normals {
+			buf = prop.AppendText(buf, i)
+		}
+		buf[len(buf)-1] = '\n'
+		bw.Write(buf)
+	}
+	return bw.Flush()
+}
@@ -0,0 +1,73 @@
+package xyz
+
+import (
+	"bytes"
+	"reflect"
+	"strings"
+	"testing"
+
+	plyfile "github.com/ecopia-map/go-plyfile"
+)
+
+func propertyNames(m *plyfile.Mesh) []string {
+	var names []string
+	for _, prop := range m.Element("vertex").Properties {
+		names = append(names, prop.Name+":"+plyfile.TypeName(prop.Type))
+	}
+	return names
+}
+
+func TestDecode(t *testing.T) {
+	tests := []struct {
+		file  string
+		names []string
+	}{
+		{"1 2 3\n4 5 6\n", []string{"x:double", "y:double", "z:double"}},
+		{"//X,Y,Z,I\n1,2,3,0.5\n4,5,6,1\n", []string{"x:double", "y:double", "z:double", "intensity:float"}},
+		{"1;2;3;255;0;10\n", []string{"x:double", "y:double", "z:double", "red:uchar", "green:uchar", "blue:uchar"}},
+		{"1 2 3 0 0.6 0.8\n", []string{"x:double", "y:double", "z:double", "nx:float", "ny:float", "nz:float"}},
+		{"1 2 3 1 2 3 0 0 1\n", []string{"x:double", "y:double", "z:double", "red:uchar", "green:uchar", "blue:uchar", "nx:float", "ny:float", "nz:float"}},
+		{"1\t2\t3\t7\t8\r\n", []string{"x:double", "y:double", "z:double", "scalar_1:double", "scalar_2:double"}},
+	}
+	for _, test := range tests {
+		m, err := Decode(strings.NewReader(test.file))
+		if err != nil {
+			t.Errorf("%q: %v", test.file, err)
+			continue
+		}
+		if got := propertyNames(m); !reflect.DeepEqual(got, test.names) {
+			t.Errorf("%q: properties = %v, want %v", test.file, got, test.names)
+		}
+	}
+}
+
+func TestRoundTrip(t *testing.T) {
+	const file = "# scan\n1.5 2 3 255 128 0 0 0 1\n-1e+06 0.1 7 0 0 0 1 0 0\n"
+	m, err := Decode(strings.NewReader(file))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := Encode(&buf, m); err != nil {
+		t.Fatal(err)
+	}
+	if buf.String() != file {
+		t.Errorf("wrote\n%s\nwant\n%s", buf.String(), file)
+	}
+}
+
+func TestDecodeErrors(t *testing.T) {
+	for _, file := range []string{"", "1 2\n", "1 2 3\n1 2 3 4\n", "1 2 z\n"} {
+		if _, err := Decode(strings.NewReader(file)); err == nil {
+			t.Errorf("expected an error for %q", file)
+		}
+	}
+}
+
+func FuzzDecode(f *testing.F) {
+	f.Add([]byte("# scan\n1.5 2 3 255 128 0 0 0 1\n-1e+06 0.1 7 0 0 0 1 0 0\n"))
+	f.Add([]byte("//X,Y,Z,I\n1,2,3,0.5\n"))
+	f.Fuzz(func(t *testing.T, data []byte) {
+		Decode(bytes.NewReader(data))
+	})
+}